@@ -0,0 +1,118 @@
+// Copyright (c) 2024 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/palantir/conjure-go-runtime/v2/conjure-go-client/httpclient/internal"
+)
+
+// BackoffStrategy determines the delay before retrying a request. It is set by WithBackoffStrategy.
+type BackoffStrategy interface {
+	// NextBackoff returns the delay before the next attempt. attempt is the number of backoffs performed so far
+	// for the request, starting at 0, and resp is the response of the failed attempt. resp is nil if the attempt
+	// failed without a response. If the response was converted to an error by the ErrorDecoder, resp only
+	// contains the status code.
+	NextBackoff(attempt int, resp *http.Response) time.Duration
+}
+
+// NewExponentialBackoffStrategy returns a BackoffStrategy which doubles the delay after each attempt, starting at
+// initialBackoff and bounded by maxBackoff. A maxBackoff of 0 indicates no bound.
+func NewExponentialBackoffStrategy(initialBackoff, maxBackoff time.Duration) BackoffStrategy {
+	return exponentialBackoffStrategy{initialBackoff: initialBackoff, maxBackoff: maxBackoff}
+}
+
+type exponentialBackoffStrategy struct {
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+}
+
+func (s exponentialBackoffStrategy) NextBackoff(attempt int, _ *http.Response) time.Duration {
+	backoff := float64(s.initialBackoff) * math.Pow(2, float64(attempt))
+	if s.maxBackoff != 0 && backoff > float64(s.maxBackoff) {
+		return s.maxBackoff
+	}
+	if backoff > math.MaxInt64 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(backoff)
+}
+
+// NewConstantBackoffStrategy returns a BackoffStrategy which waits backoff before every attempt.
+func NewConstantBackoffStrategy(backoff time.Duration) BackoffStrategy {
+	return constantBackoffStrategy(backoff)
+}
+
+type constantBackoffStrategy time.Duration
+
+func (s constantBackoffStrategy) NextBackoff(int, *http.Response) time.Duration {
+	return time.Duration(s)
+}
+
+// backoffStrategyRetrier implements retry.Retrier by waiting for the delays returned by a BackoffStrategy.
+// resp must be set to the response of each failed attempt before the retrier is advanced.
+type backoffStrategyRetrier struct {
+	ctx            context.Context
+	strategy       BackoffStrategy
+	resp           *http.Response
+	currentAttempt int
+	isReset        bool
+}
+
+func newBackoffStrategyRetrier(ctx context.Context, strategy BackoffStrategy) *backoffStrategyRetrier {
+	return &backoffStrategyRetrier{ctx: ctx, strategy: strategy, isReset: true}
+}
+
+// setAttemptResult records the result of a failed attempt, which is passed to the strategy on the next backoff.
+func (r *backoffStrategyRetrier) setAttemptResult(resp *http.Response, err error) {
+	if resp == nil {
+		if statusCode, ok := internal.StatusCodeFromError(err); ok {
+			resp = &http.Response{StatusCode: statusCode, Status: http.StatusText(statusCode)}
+		}
+	}
+	r.resp = resp
+}
+
+func (r *backoffStrategyRetrier) Reset() {
+	if r.ctx.Err() != nil {
+		return
+	}
+	r.currentAttempt = 0
+	r.isReset = true
+}
+
+func (r *backoffStrategyRetrier) Next() bool {
+	if r.isReset {
+		r.isReset = false
+		return true
+	}
+	timer := time.NewTimer(r.strategy.NextBackoff(r.currentAttempt, r.resp))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		r.currentAttempt++
+		return true
+	case <-r.ctx.Done():
+		return false
+	}
+}
+
+func (r *backoffStrategyRetrier) CurrentAttempt() int {
+	return r.currentAttempt
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2024 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+
+	werror "github.com/palantir/witchcraft-go-error"
+)
+
+// DigestMismatchError is returned when a response body does not match the digest provided in its headers.
+// See WithVerifyResponseDigest.
+type DigestMismatchError struct {
+	// Header is the response header containing the expected digest, e.g. "Content-MD5" or "Digest".
+	Header string
+	// Algorithm is the digest algorithm, e.g. "MD5" or "SHA-256".
+	Algorithm string
+	// Expected is the base64-encoded digest provided in the response header.
+	Expected string
+	// Actual is the base64-encoded digest of the response body.
+	Actual string
+}
+
+func (e *DigestMismatchError) Error() string {
+	return fmt.Sprintf("httpclient: response body %s digest %q does not match %s header value %q", e.Algorithm, e.Actual, e.Header, e.Expected)
+}
+
+// setContentMD5 sets the Content-MD5 header to the digest of the request body, if there is one.
+// The body must be replayable (i.e. GetBody is set) so that it can be read without consuming req.Body.
+func setContentMD5(req *http.Request) error {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil
+	}
+	if req.GetBody == nil {
+		return werror.ErrorWithContextParams(req.Context(), "httpclient: Content-MD5 requires a replayable request body")
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return werror.WrapWithContextParams(req.Context(), err, "failed to get request body to compute Content-MD5")
+	}
+	defer func() {
+		_ = body.Close()
+	}()
+	h := md5.New()
+	if _, err := io.Copy(h, body); err != nil {
+		return werror.WrapWithContextParams(req.Context(), err, "failed to read request body to compute Content-MD5")
+	}
+	req.Header.Set("Content-MD5", base64.StdEncoding.EncodeToString(h.Sum(nil)))
+	return nil
+}
+
+// newDigestVerifyingReader returns a reader which returns a *DigestMismatchError upon reaching EOF if the body
+// does not match the digest in the response's Content-MD5 or Digest header. If neither header contains a supported
+// digest, the body is returned unmodified.
+func newDigestVerifyingReader(resp *http.Response) io.ReadCloser {
+	if v := resp.Header.Get("Content-MD5"); v != "" {
+		return &digestVerifyingReader{ReadCloser: resp.Body, header: "Content-MD5", algorithm: "MD5", expected: v, hash: md5.New()}
+	}
+	// Digest header values are formatted as a comma-separated list of "<algorithm>=<base64 digest>" (RFC 3230).
+	for _, digest := range strings.Split(resp.Header.Get("Digest"), ",") {
+		algorithm, expected, ok := strings.Cut(strings.TrimSpace(digest), "=")
+		if !ok {
+			continue
+		}
+		switch algorithm = strings.ToUpper(algorithm); algorithm {
+		case "SHA-256":
+			return &digestVerifyingReader{ReadCloser: resp.Body, header: "Digest", algorithm: algorithm, expected: expected, hash: sha256.New()}
+		case "MD5":
+			return &digestVerifyingReader{ReadCloser: resp.Body, header: "Digest", algorithm: algorithm, expected: expected, hash: md5.New()}
+		}
+	}
+	return resp.Body
+}
+
+type digestVerifyingReader struct {
+	io.ReadCloser
+	header    string
+	algorithm string
+	expected  string
+	hash      hash.Hash
+}
+
+func (r *digestVerifyingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	_, _ = r.hash.Write(p[:n])
+	if err == io.EOF {
+		if actual := base64.StdEncoding.EncodeToString(r.hash.Sum(nil)); actual != r.expected {
+			return n, &DigestMismatchError{Header: r.header, Algorithm: r.algorithm, Expected: r.expected, Actual: actual}
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2024 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient_test
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/palantir/conjure-go-runtime/v2/conjure-go-client/httpclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestContentMD5(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		body, err := io.ReadAll(req.Body)
+		assert.NoError(t, err)
+		sum := md5.Sum(body)
+		assert.Equal(t, base64.StdEncoding.EncodeToString(sum[:]), req.Header.Get("Content-MD5"))
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client, err := httpclient.NewClient(httpclient.WithBaseURLs([]string{server.URL}))
+	require.NoError(t, err)
+
+	t.Run("encoded object", func(t *testing.T) {
+		_, err := client.Post(context.Background(), httpclient.WithRequestContentMD5(), httpclient.WithJSONRequest(map[string]string{"key": "value"}))
+		require.NoError(t, err)
+	})
+	t.Run("in memory", func(t *testing.T) {
+		_, err := client.Post(context.Background(), httpclient.WithRequestContentMD5(),
+			httpclient.WithBinaryRequestBody(httpclient.RequestBodyInMemory(strings.NewReader("hello"))))
+		require.NoError(t, err)
+	})
+	t.Run("stream once", func(t *testing.T) {
+		_, err := client.Post(context.Background(), httpclient.WithRequestContentMD5(),
+			httpclient.WithBinaryRequestBody(httpclient.RequestBodyStreamOnce(func() io.ReadCloser { return io.NopCloser(strings.NewReader("hello")) })))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "httpclient: Content-MD5 requires a replayable request body")
+	})
+}
+
+func TestVerifyResponseDigest(t *testing.T) {
+	const body = `"hello"`
+	md5Sum := md5.Sum([]byte(body))
+	sha256Sum := sha256.Sum256([]byte(body))
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/md5":
+			rw.Header().Set("Content-MD5", base64.StdEncoding.EncodeToString(md5Sum[:]))
+		case "/sha256":
+			rw.Header().Set("Digest", "unknown=abc, SHA-256="+base64.StdEncoding.EncodeToString(sha256Sum[:]))
+		case "/mismatch":
+			rw.Header().Set("Content-MD5", base64.StdEncoding.EncodeToString(sha256Sum[:]))
+		}
+		_, _ = rw.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	client, err := httpclient.NewClient(httpclient.WithBaseURLs([]string{server.URL}), httpclient.WithMaxRetries(0))
+	require.NoError(t, err)
+
+	for _, path := range []string{"/md5", "/sha256", "/none"} {
+		t.Run(path, func(t *testing.T) {
+			var out string
+			_, err := client.Get(context.Background(), httpclient.WithPath(path), httpclient.WithVerifyResponseDigest(), httpclient.WithJSONResponse(&out))
+			require.NoError(t, err)
+			assert.Equal(t, "hello", out)
+		})
+	}
+	t.Run("mismatch", func(t *testing.T) {
+		var out string
+		_, err := client.Get(context.Background(), httpclient.WithPath("/mismatch"), httpclient.WithVerifyResponseDigest(), httpclient.WithJSONResponse(&out))
+		var mismatchErr *httpclient.DigestMismatchError
+		require.True(t, errors.As(err, &mismatchErr), "expected DigestMismatchError, got %v", err)
+		assert.Equal(t, "Content-MD5", mismatchErr.Header)
+		assert.Equal(t, "MD5", mismatchErr.Algorithm)
+		assert.Equal(t, base64.StdEncoding.EncodeToString(md5Sum[:]), mismatchErr.Actual)
+	})
+	t.Run("mismatch raw", func(t *testing.T) {
+		resp, err := client.Get(context.Background(), httpclient.WithPath("/mismatch"), httpclient.WithVerifyResponseDigest(), httpclient.WithRawResponseBody())
+		require.NoError(t, err)
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+		_, err = io.ReadAll(resp.Body)
+		var mismatchErr *httpclient.DigestMismatchError
+		assert.True(t, errors.As(err, &mismatchErr), "expected DigestMismatchError, got %v", err)
+	})
+}
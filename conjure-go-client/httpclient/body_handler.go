@@ -15,7 +15,11 @@
 package httpclient
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"io"
+	"mime/multipart"
 	"net/http"
 
 	"github.com/palantir/conjure-go-runtime/v2/conjure-go-contract/codecs"
@@ -23,6 +27,12 @@ import (
 	werror "github.com/palantir/witchcraft-go-error"
 )
 
+// discardResponseBodyDrainLimit is the most bytes read from a response body discarded by WithDiscardResponseBody.
+const discardResponseBodyDrainLimit = 4 << 10
+
+// maxPooledResponseBufferBytes is the capacity of the largest buffer returned to the pool set by WithResponseBufferPool.
+const maxPooledResponseBufferBytes = 1 << 20
+
 type bodyMiddleware struct {
 	requestInput   interface{}
 	requestEncoder codecs.Encoder
@@ -32,8 +42,78 @@ type bodyMiddleware struct {
 	rawOutput       bool
 	responseOutput  interface{}
 	responseDecoder codecs.Decoder
+	// if rawOutputOnError is true, responses are returned as raw output regardless of status and the error
+	// decoders are not invoked. It is only set along with rawOutput.
+	rawOutputOnError bool
+	// if rawOutputOnErrorStatus is set, responses with status codes for which it returns true are returned as raw
+	// output and the error decoders are not invoked for them. It is only set along with rawOutput.
+	rawOutputOnErrorStatus func(statusCode int) bool
+	// if discardResponseBody is true, the response body is not decoded and at most discardResponseBodyDrainLimit
+	// bytes of it are read before it is closed.
+	discardResponseBody bool
+	// if spillResponseBody is true, rawOutput is also true and the client reads the response body to the end and
+	// replaces it with its content, kept in memory up to spillThreshold bytes and otherwise written to a temporary file.
+	spillResponseBody bool
+	spillThreshold    int64
+
+	// if maxRequestBodyBytes is positive, requests with larger bodies fail with a *RequestBodyTooLargeError.
+	maxRequestBodyBytes int64
+
+	// if forceContentLength is true, request bodies of unknown length are buffered to send them with a Content-Length.
+	forceContentLength bool
+	// if forceChunked is true, request bodies are sent with chunked transfer encoding.
+	forceChunked bool
+
+	// if requestContentMD5 is true, the Content-MD5 header is set to the digest of the request body.
+	requestContentMD5 bool
+	// if verifyResponseDigest is true, the response body is verified against its Content-MD5 or Digest header.
+	verifyResponseDigest bool
+
+	// if eventStreamHandler is set, the response body is parsed as a server-sent event stream.
+	eventStreamHandler EventStreamHandler
+	// if jsonArrayHandler is set, the response body is decoded as a JSON array one element at a time.
+	jsonArrayHandler *jsonArrayHandler
+	// if multipartHandler is set, the response body is parsed as a multipart body one part at a time.
+	multipartHandler func(part *multipart.Part) error
+	// if responseUnmarshalFunc is set, it is called with successful responses to read the body.
+	responseUnmarshalFunc func(resp *http.Response) error
+	// if responseStatusValidator is set, it replaces the error decoders and is called with every response before
+	// the body is read.
+	responseStatusValidator func(resp *http.Response) error
+	// statusResponseOutputs are set by WithResponseForStatus, keyed by status code or status class.
+	statusResponseOutputs map[int]statusResponseOutput
+	// if responseHeaderCallback is set, it is called with successful responses before the body is read.
+	responseHeaderCallback func(resp *http.Response) error
+	// if responseBodyTransformer is set, the bodies of successful responses are read through the reader it returns.
+	responseBodyTransformer func(body io.Reader) io.Reader
+	// if responseTrailerCallback is set, the response body is read to the end and it is called with the trailers.
+	responseTrailerCallback func(trailer http.Header)
+	// if requirePartialContent is true, a successful response must have status 206 Partial Content.
+	requirePartialContent bool
+
+	// noRetriesResponse is set when the response was handled in a way that should not be retried,
+	// e.g. the event stream was partially read or the server ignored a range request.
+	noRetriesResponse bool
 
-	bufferPool bytesbuffers.Pool
+	bufferPool         bytesbuffers.Pool
+	responseBufferPool bytesbuffers.Pool
+}
+
+// resetResponseMode clears the fields which select how the response body is read, so that the last of the params
+// which set them, e.g. WithResponseBody and WithRawResponseBody, takes precedence.
+func (b *bodyMiddleware) resetResponseMode() {
+	b.rawOutput = false
+	b.rawOutputOnError = false
+	b.rawOutputOnErrorStatus = nil
+	b.responseOutput = nil
+	b.responseDecoder = nil
+	b.eventStreamHandler = nil
+	b.jsonArrayHandler = nil
+	b.multipartHandler = nil
+	b.responseUnmarshalFunc = nil
+	b.discardResponseBody = false
+	b.spillResponseBody = false
+	b.spillThreshold = 0
 }
 
 func (b *bodyMiddleware) RoundTrip(req *http.Request, next http.RoundTripper) (*http.Response, error) {
@@ -45,7 +125,11 @@ func (b *bodyMiddleware) RoundTrip(req *http.Request, next http.RoundTripper) (*
 	resp, respErr := next.RoundTrip(req)
 	cleanup()
 
-	if err := b.readResponse(resp, respErr); err != nil {
+	if err := b.readResponse(req.Context(), resp, respErr); err != nil {
+		// the response is discarded, so close its body without draining it (it may be an unbounded stream).
+		if respErr == nil && resp != nil && resp.Body != nil {
+			_ = resp.Body.Close()
+		}
 		return nil, err
 	}
 
@@ -80,19 +164,161 @@ func (b *bodyMiddleware) setRequestBody(req *http.Request) (func(), error) {
 			werror.SafeParam("requestInputType", fmt.Sprintf("%T", b.requestInput)))
 	}
 
-	return cleanup, requestBody.setRequestBody(req)
+	if err := requestBody.setRequestBody(req); err != nil {
+		return cleanup, err
+	}
+	if b.maxRequestBodyBytes > 0 {
+		if err := limitRequestBody(req, b.maxRequestBodyBytes); err != nil {
+			return cleanup, err
+		}
+	}
+	if b.forceContentLength {
+		if err := bufferRequestBody(req); err != nil {
+			return cleanup, err
+		}
+	}
+	if b.forceChunked && req.Body != nil && req.Body != http.NoBody {
+		req.ContentLength = -1
+		req.TransferEncoding = []string{"chunked"}
+	}
+	if b.requestContentMD5 {
+		if err := setContentMD5(req); err != nil {
+			return cleanup, err
+		}
+	}
+	return cleanup, nil
+}
+
+// bufferRequestBody reads a request body of unknown length into memory so that it is sent with a Content-Length.
+func bufferRequestBody(req *http.Request) error {
+	if req.Body == nil || req.Body == http.NoBody || req.ContentLength >= 0 {
+		return nil
+	}
+	content, err := io.ReadAll(req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		return werror.WrapWithContextParams(req.Context(), err, "failed to read request body to compute Content-Length")
+	}
+	req.ContentLength = int64(len(content))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(content)), nil
+	}
+	req.Body, _ = req.GetBody()
+	return nil
+}
+
+// RequestBodyTooLargeError is returned when the request body is larger than the limit set by WithMaxRequestBodyBytes
+// or RequestBodyBuffered.
+type RequestBodyTooLargeError struct {
+	Limit int64
+	// ContentLength is the length of the request body, or -1 if the body was streamed and its length is unknown.
+	ContentLength int64
+}
+
+func (e *RequestBodyTooLargeError) Error() string {
+	if e.ContentLength < 0 {
+		return fmt.Sprintf("httpclient: request body exceeds the limit of %d bytes", e.Limit)
+	}
+	return fmt.Sprintf("httpclient: request body of %d bytes exceeds the limit of %d bytes", e.ContentLength, e.Limit)
+}
+
+// limitRequestBody fails if the request body is known to be larger than limit. If its length is unknown,
+// the body is wrapped so that reading more than limit bytes fails.
+func limitRequestBody(req *http.Request, limit int64) error {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil
+	}
+	if req.ContentLength > limit {
+		_ = req.Body.Close()
+		return &RequestBodyTooLargeError{Limit: limit, ContentLength: req.ContentLength}
+	}
+	if req.ContentLength >= 0 {
+		return nil
+	}
+	req.Body = &limitedReadCloser{ReadCloser: req.Body, remaining: limit, limit: limit}
+	if getBody := req.GetBody; getBody != nil {
+		req.GetBody = func() (io.ReadCloser, error) {
+			body, err := getBody()
+			if err != nil {
+				return nil, err
+			}
+			return &limitedReadCloser{ReadCloser: body, remaining: limit, limit: limit}, nil
+		}
+	}
+	return nil
+}
+
+// limitedReadCloser fails with a *RequestBodyTooLargeError once more than limit bytes are read.
+type limitedReadCloser struct {
+	io.ReadCloser
+	remaining int64
+	limit     int64
+}
+
+func (r *limitedReadCloser) Read(p []byte) (int, error) {
+	if r.remaining < 0 {
+		return 0, &RequestBodyTooLargeError{Limit: r.limit, ContentLength: -1}
+	}
+	// read one byte more than remaining to detect a body exceeding the limit
+	if int64(len(p)) > r.remaining+1 {
+		p = p[:r.remaining+1]
+	}
+	n, err := r.ReadCloser.Read(p)
+	r.remaining -= int64(n)
+	if r.remaining < 0 {
+		return 0, &RequestBodyTooLargeError{Limit: r.limit, ContentLength: -1}
+	}
+	return n, err
 }
 
 // returns true if the request body is a noRetriesRequestBody
 func (b *bodyMiddleware) noRetriesRequestBody() bool {
 	if b.requestEncoder == nil && b.requestInput != nil {
-		_, ok := b.requestInput.(noRetriesRequestBody)
-		return ok
+		body, ok := b.requestInput.(RequestBody)
+		return ok && isStreamOnceRequestBody(body)
 	}
 	return false
 }
 
-func (b *bodyMiddleware) readResponse(resp *http.Response, respErr error) error {
+func (b *bodyMiddleware) readResponse(ctx context.Context, resp *http.Response, respErr error) error {
+	if b.verifyResponseDigest && respErr == nil && resp != nil && resp.Body != nil {
+		resp.Body = newDigestVerifyingReader(resp)
+	}
+
+	if b.responseStatusValidator != nil && respErr == nil && resp != nil {
+		if err := b.responseStatusValidator(resp); err != nil {
+			b.noRetriesResponse = true
+			return err
+		}
+	}
+
+	if b.requirePartialContent && respErr == nil && resp != nil && resp.StatusCode != http.StatusPartialContent {
+		b.noRetriesResponse = true
+		return werror.WrapWithContextParams(ctx, ErrRangeIgnored, "", werror.SafeParam("statusCode", resp.StatusCode))
+	}
+
+	if b.responseHeaderCallback != nil && respErr == nil && resp != nil {
+		if err := b.responseHeaderCallback(resp); err != nil {
+			b.noRetriesResponse = true
+			return err
+		}
+	}
+
+	if b.responseBodyTransformer != nil && respErr == nil && resp != nil && resp.Body != nil {
+		transformResponseBody(resp, b.responseBodyTransformer)
+	}
+
+	if respErr == nil && resp != nil {
+		if output, ok := b.statusResponseOutput(resp.StatusCode); ok {
+			if resp.Body != nil && resp.ContentLength != 0 {
+				if err := b.decodeResponseBody(ctx, resp.Body, output.output, output.decoder); err != nil {
+					return err
+				}
+			}
+			return b.verifyResponseBody(resp)
+		}
+	}
+
 	// If rawOutput is true, return response directly without draining or closing body
 	if b.rawOutput && respErr == nil {
 		return nil
@@ -102,16 +328,132 @@ func (b *bodyMiddleware) readResponse(resp *http.Response, respErr error) error
 		return respErr
 	}
 
+	if b.eventStreamHandler != nil && resp != nil && resp.Body != nil {
+		b.noRetriesResponse = true
+		if err := readEventStream(ctx, resp.Body, b.eventStreamHandler); err != nil {
+			return err
+		}
+		return b.verifyResponseBody(resp)
+	}
+
+	if b.jsonArrayHandler != nil && resp != nil && resp.Body != nil && resp.ContentLength != 0 {
+		b.noRetriesResponse = true
+		if err := readJSONArray(ctx, resp.Body, b.jsonArrayHandler); err != nil {
+			return err
+		}
+		return b.verifyResponseBody(resp)
+	}
+
+	if b.multipartHandler != nil && resp != nil && resp.Body != nil {
+		b.noRetriesResponse = true
+		if err := readMultipart(ctx, resp, b.multipartHandler); err != nil {
+			return err
+		}
+		return b.verifyResponseBody(resp)
+	}
+
+	if b.responseUnmarshalFunc != nil && resp != nil {
+		b.noRetriesResponse = true
+		if err := b.responseUnmarshalFunc(resp); err != nil {
+			return err
+		}
+		return b.verifyResponseBody(resp)
+	}
+
 	// Verify we have a body to unmarshal. If the request was unsuccessful, the errorMiddleware will
 	// set a non-nil error and return no response.
 	if b.responseOutput == nil || resp == nil || resp.Body == nil || resp.ContentLength == 0 {
-		return nil
+		return b.verifyResponseBody(resp)
 	}
 
-	decErr := b.responseDecoder.Decode(resp.Body, b.responseOutput)
+	decErr := b.decodeResponseBody(ctx, resp.Body, b.responseOutput, b.responseDecoder)
 	if decErr != nil {
 		return decErr
 	}
 
+	return b.verifyResponseBody(resp)
+}
+
+// decodeResponseBody decodes body into output. If a response buffer pool is set, the body is read into a pooled
+// buffer and unmarshaled from it, rather than allocating the decoder's read buffer for each response. Bodies decoded
+// by codecs.Binary or into an io.Writer are always streamed.
+func (b *bodyMiddleware) decodeResponseBody(ctx context.Context, body io.Reader, output interface{}, decoder codecs.Decoder) error {
+	if _, isWriter := output.(io.Writer); b.responseBufferPool == nil || isWriter || decoder == codecs.Binary {
+		return decoder.Decode(body, output)
+	}
+	buf := b.responseBufferPool.Get()
+	defer func() {
+		// buffers grown by a large response are dropped so that the pool does not retain them.
+		if buf.Cap() <= maxPooledResponseBufferBytes {
+			b.responseBufferPool.Put(buf)
+		}
+	}()
+	if _, err := buf.ReadFrom(body); err != nil {
+		return werror.WrapWithContextParams(ctx, err, "failed to read response body")
+	}
+	return decoder.Unmarshal(buf.Bytes(), output)
+}
+
+// transformResponseBody replaces the response body with the reader returned by transform. The length of the
+// transformed body is unknown unless the body is empty.
+func transformResponseBody(resp *http.Response, transform func(body io.Reader) io.Reader) {
+	resp.Body = transformedBody{Reader: transform(resp.Body), Closer: resp.Body}
+	if resp.ContentLength != 0 {
+		resp.Header.Del("Content-Length")
+		resp.ContentLength = -1
+	}
+}
+
+// transformedBody reads a transformed body and closes the original body.
+type transformedBody struct {
+	io.Reader
+	io.Closer
+}
+
+// statusResponseOutput is an output set by WithResponseForStatus.
+type statusResponseOutput struct {
+	output  interface{}
+	decoder codecs.Decoder
+}
+
+// statusResponseOutput returns the output set for statusCode, if any, preferring one set for the exact status code
+// over one set for its class.
+func (b *bodyMiddleware) statusResponseOutput(statusCode int) (statusResponseOutput, bool) {
+	if output, ok := b.statusResponseOutputs[statusCode]; ok {
+		return output, true
+	}
+	output, ok := b.statusResponseOutputs[statusCode/100]
+	return output, ok
+}
+
+// streamsResponse returns true if the response body is read as a stream rather than decoded, so it must not be
+// read into memory before it is returned.
+func (b *bodyMiddleware) streamsResponse() bool {
+	return b.rawOutput || b.eventStreamHandler != nil || b.jsonArrayHandler != nil || b.multipartHandler != nil
+}
+
+// exemptsErrorDecoders returns true if responses with the status code are decoded into an output set by
+// WithResponseForStatus or returned as raw output by WithRawResponseBodyOnErrorStatus rather than being decoded by
+// the error decoders.
+func (b *bodyMiddleware) exemptsErrorDecoders(statusCode int) bool {
+	if b.rawOutputOnErrorStatus != nil && b.rawOutputOnErrorStatus(statusCode) {
+		return true
+	}
+	_, ok := b.statusResponseOutput(statusCode)
+	return ok
+}
+
+// verifyResponseBody reads the remainder of the response body so that its digest is verified and its trailers
+// are populated for the responseTrailerCallback.
+func (b *bodyMiddleware) verifyResponseBody(resp *http.Response) error {
+	if (!b.verifyResponseDigest && b.responseTrailerCallback == nil) || resp == nil || resp.Body == nil {
+		return nil
+	}
+	if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+		return err
+	}
+	if b.responseTrailerCallback != nil {
+		b.responseTrailerCallback(resp.Trailer)
+	}
 	return nil
 }
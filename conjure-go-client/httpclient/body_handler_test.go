@@ -16,16 +16,26 @@ package httpclient_test
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"mime"
+	"mime/multipart"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/textproto"
+	"os"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/palantir/conjure-go-runtime/v2/conjure-go-client/httpclient"
 	"github.com/palantir/conjure-go-runtime/v2/conjure-go-contract/codecs"
+	"github.com/palantir/conjure-go-runtime/v2/conjure-go-contract/codecs/jsonschema"
 	"github.com/palantir/pkg/bytesbuffers"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -102,6 +112,294 @@ func TestRawBody(t *testing.T) {
 	assert.Equal(t, respVar, gotRespBytes)
 }
 
+func TestRawBodyOnError(t *testing.T) {
+	var decoderCalls int
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		rw.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = rw.Write([]byte(`{"reason":"maintenance"}`))
+	}))
+	defer server.Close()
+
+	client, err := httpclient.NewClient(
+		httpclient.WithBaseURLs([]string{server.URL}),
+		httpclient.WithMaxRetries(0),
+		httpclient.WithErrorDecoder(errorDecoderFunc(func(*http.Response) error {
+			decoderCalls++
+			return fmt.Errorf("decoded error")
+		})),
+	)
+	require.NoError(t, err)
+
+	t.Run("returns error responses", func(t *testing.T) {
+		resp, err := client.Get(context.Background(), httpclient.WithRawResponseBodyOnError())
+		require.NoError(t, err)
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		gotRespBytes, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, `{"reason":"maintenance"}`, string(gotRespBytes))
+		assert.Equal(t, 0, decoderCalls, "error decoder should not be called")
+	})
+
+	t.Run("later response param takes precedence", func(t *testing.T) {
+		var output map[string]string
+		_, err := client.Get(context.Background(), httpclient.WithRawResponseBodyOnError(), httpclient.WithJSONResponse(&output))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "decoded error")
+		assert.Equal(t, 1, decoderCalls)
+	})
+}
+
+func TestRawBodyOnErrorStatus(t *testing.T) {
+	var decoderCalls int
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/diagnostics" {
+			rw.WriteHeader(http.StatusInternalServerError)
+			_, _ = rw.Write([]byte("diagnostics"))
+			return
+		}
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client, err := httpclient.NewClient(
+		httpclient.WithBaseURLs([]string{server.URL}),
+		httpclient.WithMaxRetries(0),
+		httpclient.WithErrorDecoder(errorDecoderFunc(func(*http.Response) error {
+			decoderCalls++
+			return fmt.Errorf("decoded error")
+		})),
+	)
+	require.NoError(t, err)
+	isServerError := func(statusCode int) bool {
+		return statusCode == http.StatusInternalServerError
+	}
+
+	t.Run("returns matching error responses", func(t *testing.T) {
+		resp, err := client.Get(context.Background(),
+			httpclient.WithPath("/diagnostics"),
+			httpclient.WithRawResponseBodyOnErrorStatus(isServerError))
+		require.NoError(t, err)
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+		gotRespBytes, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "diagnostics", string(gotRespBytes))
+		assert.Equal(t, 0, decoderCalls, "error decoder should not be called")
+	})
+
+	t.Run("decodes other error responses", func(t *testing.T) {
+		resp, err := client.Get(context.Background(), httpclient.WithRawResponseBodyOnErrorStatus(isServerError))
+		require.Error(t, err)
+		assert.Nil(t, resp)
+		assert.Contains(t, err.Error(), "decoded error")
+		assert.Equal(t, 1, decoderCalls)
+	})
+
+	t.Run("nil func", func(t *testing.T) {
+		_, err := client.Get(context.Background(), httpclient.WithRawResponseBodyOnErrorStatus(nil))
+		require.Error(t, err)
+	})
+}
+
+// errorDecoderFunc handles every response by returning the error from the func.
+type errorDecoderFunc func(*http.Response) error
+
+func (f errorDecoderFunc) Handles(*http.Response) bool { return true }
+
+func (f errorDecoderFunc) DecodeError(resp *http.Response) error { return f(resp) }
+
+func TestJSONSchemaValidatingResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		_, _ = rw.Write([]byte(`{"name":` + req.URL.Query().Get("name") + `}`))
+	}))
+	defer server.Close()
+
+	client, err := httpclient.NewClient(httpclient.WithBaseURLs([]string{server.URL}))
+	require.NoError(t, err)
+
+	decoder := jsonschema.Validating(jsonschema.MustCompile([]byte(
+		`{"type":"object","required":["name"],"properties":{"name":{"type":"string"}}}`)))
+
+	var output map[string]string
+	_, err = client.Get(context.Background(),
+		httpclient.WithQueryValues(map[string][]string{"name": {`"alice"`}}),
+		httpclient.WithResponseBody(&output, decoder))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"name": "alice"}, output)
+
+	_, err = client.Get(context.Background(),
+		httpclient.WithQueryValues(map[string][]string{"name": {`1`}}),
+		httpclient.WithResponseBody(&output, decoder))
+	require.Error(t, err)
+	var validationErr *jsonschema.ValidationError
+	require.True(t, errors.As(err, &validationErr), "expected a validation error but got %v", err)
+	assert.Equal(t, []jsonschema.Violation{{Path: "/name", Message: "expected string but got integer"}}, validationErr.Violations)
+}
+
+func TestRequestBodyJSON(t *testing.T) {
+	type testObject struct {
+		Key    string `json:"key"`
+		Values []int  `json:"values"`
+	}
+	input := testObject{Key: "value", Values: []int{1, 2}}
+
+	var contentTypes []string
+	var received []testObject
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		contentTypes = append(contentTypes, req.Header.Get("Content-Type"))
+		var object testObject
+		assert.NoError(t, codecs.JSON.Decode(req.Body, &object))
+		received = append(received, object)
+		if len(received) == 1 {
+			// the body must be replayed on retry
+			rw.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	client, err := httpclient.NewClient(httpclient.WithBaseURLs([]string{server.URL}), httpclient.WithInitialBackoff(time.Millisecond))
+	require.NoError(t, err)
+
+	_, err = client.Post(context.Background(), httpclient.WithBinaryRequestBody(httpclient.RequestBodyJSON(input)))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"application/json", "application/json"}, contentTypes)
+	assert.Equal(t, []testObject{input, input}, received)
+
+	t.Run("wrapped", func(t *testing.T) {
+		contentTypes = nil
+		var buf bytes.Buffer
+		body := httpclient.RequestBodyTee(httpclient.RequestBodyJSON(input), &buf)
+		_, err := client.Post(context.Background(), httpclient.WithBinaryRequestBody(body))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"application/json"}, contentTypes)
+		content, _, _, err := httpclient.PeekRequestBody(httpclient.RequestBodyJSON(input))
+		require.NoError(t, err)
+		assert.Equal(t, string(content), buf.String())
+	})
+
+	t.Run("WithRequestContentType takes precedence", func(t *testing.T) {
+		contentTypes = nil
+		_, err := client.Post(context.Background(),
+			httpclient.WithBinaryRequestBody(httpclient.RequestBodyJSON(input)),
+			httpclient.WithRequestContentType("application/vnd.test+json"))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"application/vnd.test+json"}, contentTypes)
+	})
+}
+
+func TestMaxRequestBodyBytes(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		_, _ = io.Copy(io.Discard, req.Body)
+	}))
+	defer server.Close()
+
+	client, err := httpclient.NewClient(httpclient.WithBaseURLs([]string{server.URL}), httpclient.WithMaxRequestBodyBytes(100))
+	require.NoError(t, err)
+
+	small, large := strings.Repeat("a", 10), strings.Repeat("a", 1000)
+	for _, test := range []struct {
+		Name          string
+		Param         httpclient.RequestParam
+		ContentLength int64
+	}{
+		{Name: "encoded object", Param: httpclient.WithJSONRequest(small)},
+		{Name: "encoded object too large", Param: httpclient.WithJSONRequest(large), ContentLength: 1002},
+		{Name: "streamed object", Param: httpclient.WithBinaryRequestBody(httpclient.RequestBodyEncoderObjectStream(small, codecs.JSON))},
+		{Name: "streamed object too large", Param: httpclient.WithBinaryRequestBody(httpclient.RequestBodyEncoderObjectStream(large, codecs.JSON)), ContentLength: -1},
+	} {
+		t.Run(test.Name, func(t *testing.T) {
+			atomic.StoreInt32(&calls, 0)
+			_, err := client.Post(context.Background(), test.Param)
+			if test.ContentLength == 0 {
+				require.NoError(t, err)
+				assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+				return
+			}
+			var tooLargeErr *httpclient.RequestBodyTooLargeError
+			require.True(t, errors.As(err, &tooLargeErr), "expected a RequestBodyTooLargeError, got %v", err)
+			assert.Equal(t, int64(100), tooLargeErr.Limit)
+			assert.Equal(t, test.ContentLength, tooLargeErr.ContentLength)
+			assert.LessOrEqual(t, atomic.LoadInt32(&calls), int32(1), "request should not be retried")
+		})
+	}
+
+	_, err = httpclient.NewClient(httpclient.WithBaseURLs([]string{server.URL}), httpclient.WithMaxRequestBodyBytes(-1))
+	assert.EqualError(t, err, "httpclient: max request body bytes must not be negative")
+}
+
+func TestForceContentLengthAndChunked(t *testing.T) {
+	type received struct {
+		ContentLength    int64
+		TransferEncoding []string
+		Body             string
+	}
+	var got received
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		body, err := io.ReadAll(req.Body)
+		assert.NoError(t, err)
+		got = received{ContentLength: req.ContentLength, TransferEncoding: req.TransferEncoding, Body: string(body)}
+	}))
+	defer server.Close()
+
+	client, err := httpclient.NewClient(httpclient.WithBaseURLs([]string{server.URL}))
+	require.NoError(t, err)
+
+	stream := func() httpclient.RequestParam {
+		return httpclient.WithBinaryRequestBody(httpclient.RequestBodyStreamOnce(func() io.ReadCloser {
+			return io.NopCloser(strings.NewReader("hello"))
+		}))
+	}
+	inMemory := func() httpclient.RequestParam {
+		return httpclient.WithBinaryRequestBody(httpclient.RequestBodyInMemory(strings.NewReader("hello")))
+	}
+	for _, test := range []struct {
+		Name     string
+		Params   []httpclient.RequestParam
+		Expected received
+	}{
+		{
+			Name:     "stream is chunked by default",
+			Params:   []httpclient.RequestParam{stream()},
+			Expected: received{ContentLength: -1, TransferEncoding: []string{"chunked"}, Body: "hello"},
+		},
+		{
+			Name:     "stream with forced content length",
+			Params:   []httpclient.RequestParam{stream(), httpclient.WithForceContentLength()},
+			Expected: received{ContentLength: 5, Body: "hello"},
+		},
+		{
+			Name:     "in memory body has content length by default",
+			Params:   []httpclient.RequestParam{inMemory()},
+			Expected: received{ContentLength: 5, Body: "hello"},
+		},
+		{
+			Name:     "in memory body with forced chunked",
+			Params:   []httpclient.RequestParam{inMemory(), httpclient.WithForceChunked()},
+			Expected: received{ContentLength: -1, TransferEncoding: []string{"chunked"}, Body: "hello"},
+		},
+		{
+			Name:     "later param takes precedence",
+			Params:   []httpclient.RequestParam{inMemory(), httpclient.WithForceChunked(), httpclient.WithForceContentLength()},
+			Expected: received{ContentLength: 5, Body: "hello"},
+		},
+	} {
+		t.Run(test.Name, func(t *testing.T) {
+			_, err := client.Post(context.Background(), test.Params...)
+			require.NoError(t, err)
+			assert.Equal(t, test.Expected, got)
+		})
+	}
+}
+
 func TestRawRequestRetry(t *testing.T) {
 	count := 0
 	requestBytes := []byte{12, 13}
@@ -273,3 +571,876 @@ func TestRedirectWithBodyAndBytesBuffer(t *testing.T) {
 		assert.Nil(t, resp)
 	})
 }
+
+type countingReader struct {
+	r     io.Reader
+	count int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.count += n
+	return n, err
+}
+
+func TestResponseBodyWithResponseBufferPool(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/invalid" {
+			_, _ = rw.Write([]byte(`{"1":`))
+			return
+		}
+		_, _ = rw.Write([]byte(`{"1":"2"}`))
+	}))
+	defer server.Close()
+
+	pool := &countingBytesBufferPool{Pool: bytesbuffers.NewSizedPool(1, 10)}
+	client, err := httpclient.NewClient(
+		httpclient.WithBaseURLs([]string{server.URL}),
+		httpclient.WithResponseBufferPool(pool),
+	)
+	require.NoError(t, err)
+
+	t.Run("decodes response", func(t *testing.T) {
+		var actualRespVar map[string]string
+		_, err := client.Get(context.Background(), httpclient.WithJSONResponse(&actualRespVar))
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"1": "2"}, actualRespVar)
+		assert.Equal(t, pool.gets, pool.puts, "buffers should be returned to the pool")
+	})
+
+	t.Run("returns buffer on decode error", func(t *testing.T) {
+		var actualRespVar map[string]string
+		_, err := client.Get(context.Background(), httpclient.WithPath("/invalid"), httpclient.WithJSONResponse(&actualRespVar))
+		require.Error(t, err)
+		assert.Equal(t, pool.gets, pool.puts, "buffers should be returned to the pool")
+	})
+
+	t.Run("streams binary response", func(t *testing.T) {
+		pool.gets, pool.puts = 0, 0
+		var buf bytes.Buffer
+		_, err := client.Get(context.Background(), httpclient.WithResponseBody(&buf, codecs.Binary))
+		require.NoError(t, err)
+		assert.Equal(t, `{"1":"2"}`, buf.String())
+		assert.Equal(t, 0, pool.gets, "binary response should not be buffered")
+	})
+
+	t.Run("request buffer pool is not used for responses", func(t *testing.T) {
+		requestPool := &countingBytesBufferPool{Pool: bytesbuffers.NewSizedPool(1, 10)}
+		client, err := httpclient.NewClient(
+			httpclient.WithBaseURLs([]string{server.URL}),
+			httpclient.WithBytesBufferPool(requestPool),
+		)
+		require.NoError(t, err)
+		var actualRespVar map[string]string
+		_, err = client.Get(context.Background(), httpclient.WithJSONResponse(&actualRespVar))
+		require.NoError(t, err)
+		assert.Equal(t, 0, requestPool.gets)
+	})
+}
+
+// countingBytesBufferPool counts the buffers taken from and returned to the pool.
+type countingBytesBufferPool struct {
+	bytesbuffers.Pool
+	gets, puts int
+}
+
+func (p *countingBytesBufferPool) Get() *bytes.Buffer {
+	p.gets++
+	return p.Pool.Get()
+}
+
+func (p *countingBytesBufferPool) Put(buf *bytes.Buffer) {
+	p.puts++
+	p.Pool.Put(buf)
+}
+
+func TestExpectContinue(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "100-continue", req.Header.Get("Expect"))
+		if req.Header.Get("X-Reject") != "" {
+			rw.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		body, err := io.ReadAll(req.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, "hello", string(body))
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client, err := httpclient.NewClient(httpclient.WithBaseURLs([]string{server.URL}))
+	require.NoError(t, err)
+
+	t.Run("accepted", func(t *testing.T) {
+		body := &countingReader{r: strings.NewReader("hello")}
+		resp, err := client.Put(context.Background(),
+			httpclient.WithExpectContinue(),
+			httpclient.WithBinaryRequestBody(httpclient.RequestBodyStreamOnce(func() io.ReadCloser { return io.NopCloser(body) })))
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, 5, body.count)
+	})
+
+	t.Run("rejected before body is sent", func(t *testing.T) {
+		body := &countingReader{r: strings.NewReader("hello")}
+		_, err := client.Put(context.Background(),
+			httpclient.WithExpectContinue(),
+			httpclient.WithHeader("X-Reject", "true"),
+			httpclient.WithBinaryRequestBody(httpclient.RequestBodyStreamOnce(func() io.ReadCloser { return io.NopCloser(body) })))
+		code, ok := httpclient.StatusCodeFromError(err)
+		require.True(t, ok)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, code)
+		assert.Equal(t, 0, body.count)
+	})
+}
+
+func TestEventStreamHandler(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "text/event-stream", req.Header.Get("Accept"))
+		if req.URL.Path == "/error" {
+			rw.WriteHeader(http.StatusNotFound)
+			return
+		}
+		rw.Header().Set("Content-Type", "text/event-stream")
+		for i := 0; i < 3; i++ {
+			_, _ = fmt.Fprintf(rw, "id: %d\ndata: event-%d\n\n", i, i)
+			rw.(http.Flusher).Flush()
+		}
+	}))
+	defer server.Close()
+
+	client, err := httpclient.NewClient(httpclient.WithBaseURLs([]string{server.URL}))
+	require.NoError(t, err)
+
+	t.Run("events", func(t *testing.T) {
+		var data []string
+		_, err := client.Get(context.Background(), httpclient.WithEventStreamHandler(func(event httpclient.SSEvent) error {
+			data = append(data, event.Data)
+			return nil
+		}))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"event-0", "event-1", "event-2"}, data)
+	})
+	t.Run("handler error is not retried", func(t *testing.T) {
+		var count int
+		_, err := client.Get(context.Background(), httpclient.WithEventStreamHandler(func(event httpclient.SSEvent) error {
+			count++
+			return fmt.Errorf("stop")
+		}))
+		var handlerErr *httpclient.EventHandlerError
+		require.ErrorAs(t, err, &handlerErr)
+		assert.Equal(t, 1, count)
+	})
+	t.Run("error status", func(t *testing.T) {
+		_, err := client.Get(context.Background(), httpclient.WithPath("/error"), httpclient.WithEventStreamHandler(func(event httpclient.SSEvent) error {
+			t.Fatal("handler should not be called")
+			return nil
+		}))
+		code, ok := httpclient.StatusCodeFromError(err)
+		require.True(t, ok)
+		assert.Equal(t, http.StatusNotFound, code)
+	})
+}
+
+func TestJSONArrayResponseHandler(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		calls++
+		switch req.URL.Path {
+		case "/error":
+			rw.WriteHeader(http.StatusNotFound)
+		case "/object":
+			_, _ = rw.Write([]byte(`{"key":"value"}`))
+		case "/null":
+			_, _ = rw.Write([]byte(`null`))
+		default:
+			_, _ = rw.Write([]byte(`[{"key":"a","other":"x"},{"key":"b"},{"key":"c"}]`))
+		}
+	}))
+	defer server.Close()
+
+	client, err := httpclient.NewClient(httpclient.WithBaseURLs([]string{server.URL}))
+	require.NoError(t, err)
+
+	type element struct {
+		Key   string `json:"key"`
+		Other string `json:"other"`
+	}
+
+	t.Run("elements", func(t *testing.T) {
+		var elem element
+		var elems []element
+		_, err := client.Get(context.Background(), httpclient.WithJSONArrayResponseHandler(&elem, func() error {
+			elems = append(elems, elem)
+			return nil
+		}))
+		require.NoError(t, err)
+		assert.Equal(t, []element{{Key: "a", Other: "x"}, {Key: "b"}, {Key: "c"}}, elems)
+	})
+	t.Run("null", func(t *testing.T) {
+		_, err := client.Get(context.Background(), httpclient.WithPath("/null"), httpclient.WithJSONArrayResponseHandler(&element{}, func() error {
+			t.Fatal("fn should not be called")
+			return nil
+		}))
+		require.NoError(t, err)
+	})
+	t.Run("fn error is not retried", func(t *testing.T) {
+		calls = 0
+		var count int
+		_, err := client.Get(context.Background(), httpclient.WithJSONArrayResponseHandler(&element{}, func() error {
+			count++
+			return fmt.Errorf("stop")
+		}))
+		require.EqualError(t, err, "httpclient request failed: stop")
+		assert.Equal(t, 1, count)
+		assert.Equal(t, 1, calls)
+	})
+	t.Run("not an array", func(t *testing.T) {
+		_, err := client.Get(context.Background(), httpclient.WithPath("/object"), httpclient.WithJSONArrayResponseHandler(&element{}, func() error {
+			return nil
+		}))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "httpclient: response body is not a JSON array")
+	})
+	t.Run("error status", func(t *testing.T) {
+		_, err := client.Get(context.Background(), httpclient.WithPath("/error"), httpclient.WithJSONArrayResponseHandler(&element{}, func() error {
+			t.Fatal("fn should not be called")
+			return nil
+		}))
+		code, ok := httpclient.StatusCodeFromError(err)
+		require.True(t, ok)
+		assert.Equal(t, http.StatusNotFound, code)
+	})
+	t.Run("elem must be a pointer", func(t *testing.T) {
+		_, err := client.Get(context.Background(), httpclient.WithJSONArrayResponseHandler(element{}, func() error {
+			return nil
+		}))
+		require.EqualError(t, err, "elem must be a non-nil pointer")
+	})
+}
+
+func TestResponseHeaderCallback(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		calls++
+		rw.Header().Set("Content-Disposition", `attachment; filename="file.txt"`)
+		_, _ = rw.Write([]byte(`"content"`))
+	}))
+	defer server.Close()
+
+	client, err := httpclient.NewClient(httpclient.WithBaseURLs([]string{server.URL}))
+	require.NoError(t, err)
+
+	t.Run("called before decoding", func(t *testing.T) {
+		var disposition, output string
+		_, err := client.Get(context.Background(),
+			httpclient.WithResponseHeaderCallback(func(resp *http.Response) error {
+				disposition = resp.Header.Get("Content-Disposition")
+				assert.Empty(t, output, "callback should be called before the body is decoded")
+				return nil
+			}),
+			httpclient.WithJSONResponse(&output))
+		require.NoError(t, err)
+		assert.Equal(t, `attachment; filename="file.txt"`, disposition)
+		assert.Equal(t, "content", output)
+	})
+	t.Run("error aborts without retry", func(t *testing.T) {
+		calls = 0
+		var output string
+		_, err := client.Get(context.Background(),
+			httpclient.WithResponseHeaderCallback(func(resp *http.Response) error {
+				return fmt.Errorf("unexpected file")
+			}),
+			httpclient.WithJSONResponse(&output))
+		require.EqualError(t, err, "httpclient request failed: unexpected file")
+		assert.Empty(t, output)
+		assert.Equal(t, 1, calls)
+	})
+}
+
+func TestResponseTrailerCallback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Trailer", "Grpc-Status")
+		rw.Header().Set("Content-Type", "application/json")
+		_, _ = rw.Write([]byte(`"content"` + "\n\n"))
+		rw.(http.Flusher).Flush()
+		rw.Header().Set("Grpc-Status", "0")
+	}))
+	defer server.Close()
+
+	client, err := httpclient.NewClient(httpclient.WithBaseURLs([]string{server.URL}))
+	require.NoError(t, err)
+
+	for _, test := range []struct {
+		Name   string
+		Params []httpclient.RequestParam
+	}{
+		{Name: "no response body handler"},
+		{Name: "decoded response", Params: []httpclient.RequestParam{httpclient.WithJSONResponse(new(string))}},
+		{Name: "discarded response", Params: []httpclient.RequestParam{httpclient.WithDiscardResponseBody()}},
+	} {
+		t.Run(test.Name, func(t *testing.T) {
+			var trailer http.Header
+			_, err := client.Get(context.Background(), append(test.Params,
+				httpclient.WithResponseTrailerCallback(func(tr http.Header) {
+					trailer = tr
+				}))...)
+			require.NoError(t, err)
+			assert.Equal(t, http.Header{"Grpc-Status": {"0"}}, trailer)
+		})
+	}
+}
+
+func TestResponseStatusValidator(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		calls++
+		rw.Header().Set("Content-Type", "application/json")
+		switch req.URL.Path {
+		case "/redirect":
+			rw.WriteHeader(http.StatusNotModified)
+		case "/unavailable":
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = rw.Write([]byte(`{"status":"ok","value":"unavailable"}`))
+		case "/failed":
+			_, _ = rw.Write([]byte(`{"status":"failed","value":"failed"}`))
+		default:
+			_, _ = rw.Write([]byte(`{"status":"ok","value":"ok"}`))
+		}
+	}))
+	defer server.Close()
+
+	client, err := httpclient.NewClient(httpclient.WithBaseURLs([]string{server.URL}), httpclient.WithMaxRetries(2))
+	require.NoError(t, err)
+
+	type response struct {
+		Status string `json:"status"`
+		Value  string `json:"value"`
+	}
+	// bodyStatusValidator accepts any status code but requires the status field of the body to be "ok".
+	bodyStatusValidator := func(resp *http.Response) error {
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		var r response
+		if err := codecs.JSON.Unmarshal(body, &r); err != nil {
+			return err
+		}
+		if r.Status != "ok" {
+			return fmt.Errorf("unexpected status %q", r.Status)
+		}
+		return nil
+	}
+
+	for _, test := range []struct {
+		Name      string
+		Path      string
+		Validator func(*http.Response) error
+		Output    response
+		Err       string
+		Calls     int
+	}{
+		{
+			Name:      "error status accepted",
+			Path:      "/unavailable",
+			Validator: bodyStatusValidator,
+			Output:    response{Status: "ok", Value: "unavailable"},
+			Calls:     1,
+		},
+		{
+			Name:      "successful status rejected",
+			Path:      "/failed",
+			Validator: bodyStatusValidator,
+			Err:       `httpclient request failed: unexpected status "failed"`,
+			Calls:     1,
+		},
+		{
+			Name: "redirect status accepted",
+			Path: "/redirect",
+			Validator: func(resp *http.Response) error {
+				if resp.StatusCode >= 400 {
+					return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+				}
+				return nil
+			},
+			Calls: 1,
+		},
+		{
+			Name:      "successful status accepted",
+			Path:      "/ok",
+			Validator: bodyStatusValidator,
+			Output:    response{Status: "ok", Value: "ok"},
+			Calls:     1,
+		},
+	} {
+		t.Run(test.Name, func(t *testing.T) {
+			calls = 0
+			var output response
+			_, err := client.Get(context.Background(),
+				httpclient.WithPath(test.Path),
+				httpclient.WithResponseStatusValidator(test.Validator),
+				httpclient.WithJSONResponse(&output))
+			if test.Err == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, test.Err)
+			}
+			assert.Equal(t, test.Output, output)
+			assert.Equal(t, test.Calls, calls)
+		})
+	}
+
+	t.Run("nil validator", func(t *testing.T) {
+		_, err := client.Get(context.Background(), httpclient.WithResponseStatusValidator(nil))
+		require.EqualError(t, err, "validator can not be nil")
+	})
+}
+
+func TestMultipartResponseHandler(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/not-multipart" {
+			rw.Header().Set("Content-Type", "application/json")
+			_, _ = rw.Write([]byte(`{}`))
+			return
+		}
+		assert.Equal(t, "multipart/mixed", req.Header.Get("Accept"))
+		writer := multipart.NewWriter(rw)
+		rw.Header().Set("Content-Type", "multipart/mixed; boundary="+writer.Boundary())
+
+		part, _ := writer.CreatePart(textproto.MIMEHeader{"Content-Type": {"application/json"}})
+		_, _ = part.Write([]byte(`{"key":"value"}`))
+
+		nestedBody := &bytes.Buffer{}
+		nested := multipart.NewWriter(nestedBody)
+		nestedPart, _ := nested.CreatePart(textproto.MIMEHeader{"Content-Type": {"text/plain"}})
+		_, _ = nestedPart.Write([]byte("nested text"))
+		_ = nested.Close()
+		part, _ = writer.CreatePart(textproto.MIMEHeader{"Content-Type": {"multipart/alternative; boundary=" + nested.Boundary()}})
+		_, _ = part.Write(nestedBody.Bytes())
+
+		part, _ = writer.CreatePart(textproto.MIMEHeader{"Content-Type": {"text/plain"}})
+		_, _ = part.Write([]byte(strings.Repeat("a", 100*1024)))
+		_ = writer.Close()
+	}))
+	defer server.Close()
+
+	client, err := httpclient.NewClient(httpclient.WithBaseURLs([]string{server.URL}))
+	require.NoError(t, err)
+
+	t.Run("handles each part", func(t *testing.T) {
+		var parts []string
+		_, err := client.Get(context.Background(), httpclient.WithMultipartResponseHandler(func(part *multipart.Part) error {
+			mediaType, params, err := mime.ParseMediaType(part.Header.Get("Content-Type"))
+			if err != nil {
+				return err
+			}
+			if mediaType == "multipart/alternative" {
+				nested, err := multipart.NewReader(part, params["boundary"]).NextPart()
+				if err != nil {
+					return err
+				}
+				content, err := io.ReadAll(nested)
+				parts = append(parts, mediaType+" > "+nested.Header.Get("Content-Type")+": "+string(content))
+				return err
+			}
+			// the last part is not read, which must not prevent the body from being drained.
+			if mediaType == "text/plain" {
+				parts = append(parts, mediaType)
+				return nil
+			}
+			content, err := io.ReadAll(part)
+			parts = append(parts, mediaType+": "+string(content))
+			return err
+		}))
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			`application/json: {"key":"value"}`,
+			"multipart/alternative > text/plain: nested text",
+			"text/plain",
+		}, parts)
+	})
+
+	t.Run("handler error aborts", func(t *testing.T) {
+		var calls int
+		_, err := client.Get(context.Background(), httpclient.WithMultipartResponseHandler(func(part *multipart.Part) error {
+			calls++
+			return fmt.Errorf("handler failed")
+		}))
+		require.EqualError(t, err, "httpclient request failed: handler failed")
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("not multipart", func(t *testing.T) {
+		_, err := client.Get(context.Background(), httpclient.WithPath("/not-multipart"),
+			httpclient.WithMultipartResponseHandler(func(part *multipart.Part) error {
+				return nil
+			}))
+		require.EqualError(t, err, "httpclient request failed: httpclient: response is not multipart")
+	})
+}
+
+func TestResponseUnmarshalFunc(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		calls++
+		if req.URL.Path == "/error" {
+			rw.WriteHeader(http.StatusNotFound)
+			return
+		}
+		rw.Header().Set("X-Status", "partial")
+		_, _ = rw.Write([]byte("3 items\n" + strings.Repeat("a", 100*1024)))
+	}))
+	defer server.Close()
+
+	client, err := httpclient.NewClient(httpclient.WithBaseURLs([]string{server.URL}))
+	require.NoError(t, err)
+
+	t.Run("decodes response", func(t *testing.T) {
+		var status string
+		var count int
+		resp, err := client.Get(context.Background(), httpclient.WithResponseUnmarshalFunc(func(resp *http.Response) error {
+			status = resp.Header.Get("X-Status")
+			// the rest of the body is not read, which must not prevent it from being drained.
+			_, err := fmt.Fscanf(resp.Body, "%d items\n", &count)
+			return err
+		}))
+		require.NoError(t, err)
+		assert.Equal(t, "partial", status)
+		assert.Equal(t, 3, count)
+		_, err = resp.Body.Read(make([]byte, 1))
+		assert.Error(t, err, "body should be closed")
+	})
+
+	t.Run("error aborts without retry", func(t *testing.T) {
+		calls = 0
+		_, err := client.Get(context.Background(), httpclient.WithResponseUnmarshalFunc(func(resp *http.Response) error {
+			return fmt.Errorf("unmarshal failed")
+		}))
+		require.EqualError(t, err, "httpclient request failed: unmarshal failed")
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("not invoked for error responses", func(t *testing.T) {
+		var invoked bool
+		_, err := client.Get(context.Background(), httpclient.WithPath("/error"),
+			httpclient.WithResponseUnmarshalFunc(func(resp *http.Response) error {
+				invoked = true
+				return nil
+			}))
+		require.Error(t, err)
+		assert.False(t, invoked)
+	})
+}
+
+func TestResponseForStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		switch req.URL.Path {
+		case "/ok":
+			_, _ = rw.Write([]byte(`{"value":"ok"}`))
+		case "/invalid":
+			rw.WriteHeader(http.StatusUnprocessableEntity)
+			_, _ = rw.Write([]byte(`{"field":"name"}`))
+		case "/missing":
+			rw.WriteHeader(http.StatusNotFound)
+			_, _ = rw.Write([]byte(`{"reason":"missing"}`))
+		default:
+			rw.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	client, err := httpclient.NewClient(httpclient.WithBaseURLs([]string{server.URL}), httpclient.WithMaxRetries(0))
+	require.NoError(t, err)
+
+	type okOutput struct {
+		Value string `json:"value"`
+	}
+	type validationError struct {
+		Field string `json:"field"`
+	}
+	type clientError struct {
+		Reason string `json:"reason"`
+	}
+	do := func(path string) (*http.Response, okOutput, validationError, clientError, error) {
+		var ok okOutput
+		var invalid validationError
+		var other clientError
+		resp, err := client.Get(context.Background(),
+			httpclient.WithPath(path),
+			httpclient.WithJSONResponse(&ok),
+			httpclient.WithResponseForStatus(http.StatusUnprocessableEntity, &invalid, codecs.JSON),
+			httpclient.WithResponseForStatus(4, &other, codecs.JSON))
+		return resp, ok, invalid, other, err
+	}
+
+	t.Run("unlisted success status", func(t *testing.T) {
+		resp, ok, invalid, other, err := do("/ok")
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, okOutput{Value: "ok"}, ok)
+		assert.Zero(t, invalid)
+		assert.Zero(t, other)
+	})
+
+	t.Run("status code", func(t *testing.T) {
+		resp, ok, invalid, other, err := do("/invalid")
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+		assert.Zero(t, ok)
+		assert.Equal(t, validationError{Field: "name"}, invalid)
+		assert.Zero(t, other)
+	})
+
+	t.Run("status class", func(t *testing.T) {
+		resp, ok, invalid, other, err := do("/missing")
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+		assert.Zero(t, ok)
+		assert.Zero(t, invalid)
+		assert.Equal(t, clientError{Reason: "missing"}, other)
+	})
+
+	t.Run("unlisted error status", func(t *testing.T) {
+		_, _, _, _, err := do("/error")
+		require.Error(t, err)
+		status, ok := httpclient.StatusCodeFromError(err)
+		require.True(t, ok)
+		assert.Equal(t, http.StatusInternalServerError, status)
+	})
+
+	t.Run("invalid status", func(t *testing.T) {
+		var output map[string]string
+		_, err := client.Get(context.Background(), httpclient.WithResponseForStatus(42, &output, codecs.JSON))
+		require.EqualError(t, err, "httpclient: status must be a status code or a status class from 1 to 5")
+	})
+}
+
+func TestResponseBodyTransformer(t *testing.T) {
+	const xssiPrefix = ")]}'\n"
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		var w io.Writer = rw
+		if req.Header.Get("Accept-Encoding") == "gzip" {
+			rw.Header().Set("Content-Encoding", "gzip")
+			zw := gzip.NewWriter(rw)
+			defer func() {
+				_ = zw.Close()
+			}()
+			w = zw
+		}
+		_, _ = io.WriteString(w, xssiPrefix+`{"key":"value"}`)
+	}))
+	defer server.Close()
+
+	client, err := httpclient.NewClient(httpclient.WithBaseURLs([]string{server.URL}))
+	require.NoError(t, err)
+
+	stripPrefix := httpclient.WithResponseBodyTransformer(func(body io.Reader) io.Reader {
+		prefix := make([]byte, len(xssiPrefix))
+		if _, err := io.ReadFull(body, prefix); err != nil || string(prefix) != xssiPrefix {
+			return io.MultiReader(bytes.NewReader(prefix), body)
+		}
+		return body
+	})
+
+	t.Run("decoded", func(t *testing.T) {
+		var output map[string]string
+		_, err := client.Get(context.Background(), stripPrefix, httpclient.WithJSONResponse(&output))
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"key": "value"}, output)
+	})
+
+	t.Run("decompressed", func(t *testing.T) {
+		var output map[string]string
+		_, err := client.Get(context.Background(), stripPrefix, httpclient.WithAcceptGzip(), httpclient.WithJSONResponse(&output))
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"key": "value"}, output)
+	})
+
+	t.Run("raw", func(t *testing.T) {
+		resp, err := client.Get(context.Background(), stripPrefix, httpclient.WithRawResponseBody())
+		require.NoError(t, err)
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+		assert.Equal(t, int64(-1), resp.ContentLength)
+		content, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, `{"key":"value"}`, string(content))
+	})
+}
+
+func TestPooledResponseBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		switch req.URL.Path {
+		case "/invalid":
+			_, _ = rw.Write([]byte(`{"items":`))
+		case "/empty":
+			rw.WriteHeader(http.StatusNoContent)
+		default:
+			_, _ = rw.Write([]byte(`{"items":["a","b"]}`))
+		}
+	}))
+	defer server.Close()
+
+	client, err := httpclient.NewClient(httpclient.WithBaseURLs([]string{server.URL}), httpclient.WithMaxRetries(0))
+	require.NoError(t, err)
+
+	type largeResponse struct {
+		Items []string `json:"items"`
+	}
+	var resets int
+	pool := httpclient.NewResponsePool(func(v *largeResponse) {
+		resets++
+		v.Items = v.Items[:0]
+	})
+
+	for i := 0; i < 2; i++ {
+		var output *largeResponse
+		_, err := client.Get(context.Background(), httpclient.WithPooledResponseBody(&output, pool, codecs.JSON))
+		require.NoError(t, err)
+		require.NotNil(t, output)
+		assert.Equal(t, []string{"a", "b"}, output.Items)
+		pool.Put(output)
+		assert.Equal(t, i+1, resets)
+	}
+
+	t.Run("decode failure returns the value to the pool", func(t *testing.T) {
+		resets = 0
+		var output *largeResponse
+		_, err := client.Get(context.Background(), httpclient.WithPath("/invalid"), httpclient.WithPooledResponseBody(&output, pool, codecs.JSON))
+		require.Error(t, err)
+		assert.Nil(t, output)
+		assert.Equal(t, 1, resets)
+	})
+
+	t.Run("empty response", func(t *testing.T) {
+		var output *largeResponse
+		_, err := client.Get(context.Background(), httpclient.WithPath("/empty"), httpclient.WithPooledResponseBody(&output, pool, codecs.JSON))
+		require.NoError(t, err)
+		assert.Nil(t, output)
+	})
+}
+
+func TestDiscardResponseBody(t *testing.T) {
+	var newConns int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		size := 10
+		if req.URL.Path == "/large" {
+			size = 10 << 20
+		}
+		rw.Header().Set("Content-Type", "application/json")
+		_, _ = rw.Write([]byte(`"` + strings.Repeat("a", size) + `"`))
+	}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&newConns, 1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	for _, test := range []struct {
+		Name          string
+		Path          string
+		ExpectedConns int32
+	}{
+		{Name: "small body is drained to reuse connection", Path: "/small", ExpectedConns: 1},
+		{Name: "large body is not drained", Path: "/large", ExpectedConns: 3},
+	} {
+		t.Run(test.Name, func(t *testing.T) {
+			client, err := httpclient.NewClient(httpclient.WithBaseURLs([]string{server.URL}))
+			require.NoError(t, err)
+			atomic.StoreInt32(&newConns, 0)
+			for i := 0; i < 3; i++ {
+				var output string
+				resp, err := client.Get(context.Background(), httpclient.WithPath(test.Path),
+					httpclient.WithJSONResponse(&output), httpclient.WithDiscardResponseBody())
+				require.NoError(t, err)
+				assert.Equal(t, http.StatusOK, resp.StatusCode)
+				assert.Empty(t, output, "response body should not be decoded")
+			}
+			assert.Equal(t, test.ExpectedConns, atomic.LoadInt32(&newConns))
+		})
+	}
+}
+
+func TestResponseSpillToFile(t *testing.T) {
+	content := strings.Repeat("0123456789", 100)
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/error" {
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = rw.Write([]byte(content))
+	}))
+	defer server.Close()
+
+	client, err := httpclient.NewClient(httpclient.WithBaseURLs([]string{server.URL}), httpclient.WithMaxRetries(0))
+	require.NoError(t, err)
+
+	for _, test := range []struct {
+		Name      string
+		Threshold int64
+		File      bool
+	}{
+		{Name: "below threshold is kept in memory", Threshold: int64(len(content)), File: false},
+		{Name: "above threshold is written to a file", Threshold: 10, File: true},
+		{Name: "zero threshold is written to a file", Threshold: 0, File: true},
+	} {
+		t.Run(test.Name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			t.Setenv("TMPDIR", tempDir)
+
+			resp, err := client.Get(context.Background(), httpclient.WithResponseSpillToFile(test.Threshold))
+			require.NoError(t, err)
+			body, ok := resp.Body.(io.ReadSeekCloser)
+			require.True(t, ok, "expected an io.ReadSeekCloser but got %T", resp.Body)
+
+			for i := 0; i < 2; i++ {
+				_, err := body.Seek(0, io.SeekStart)
+				require.NoError(t, err)
+				read, err := io.ReadAll(body)
+				require.NoError(t, err)
+				assert.Equal(t, content, string(read))
+			}
+
+			files, err := os.ReadDir(tempDir)
+			require.NoError(t, err)
+			if test.File {
+				assert.Len(t, files, 1)
+			} else {
+				assert.Empty(t, files)
+			}
+			require.NoError(t, body.Close())
+			files, err = os.ReadDir(tempDir)
+			require.NoError(t, err)
+			assert.Empty(t, files, "closing the body should remove the file")
+		})
+	}
+
+	t.Run("error response", func(t *testing.T) {
+		_, err := client.Get(context.Background(), httpclient.WithPath("/error"), httpclient.WithResponseSpillToFile(0))
+		status, ok := httpclient.StatusCodeFromError(err)
+		require.True(t, ok)
+		assert.Equal(t, http.StatusInternalServerError, status)
+	})
+	t.Run("negative threshold", func(t *testing.T) {
+		_, err := client.Get(context.Background(), httpclient.WithResponseSpillToFile(-1))
+		assert.EqualError(t, err, "httpclient: response spill threshold must not be negative")
+	})
+	t.Run("later response param takes precedence", func(t *testing.T) {
+		var output string
+		resp, err := client.Get(context.Background(),
+			httpclient.WithResponseSpillToFile(0),
+			httpclient.WithResponseBody(&output, codecs.Plain))
+		require.NoError(t, err)
+		assert.Equal(t, content, output)
+		_, spilled := resp.Body.(io.ReadSeekCloser)
+		assert.False(t, spilled, "response should not be spilled")
+	})
+}
@@ -16,10 +16,12 @@ package httpclient
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
+	"sync"
 
 	"github.com/palantir/conjure-go-runtime/v2/conjure-go-contract/codecs"
 )
@@ -92,6 +94,20 @@ type noRetriesRequestBody struct {
 	requestBodyFunc
 }
 
+// isStreamOnceRequestBody returns true if body, or a RequestBody it wraps, is a noRetriesRequestBody.
+func isStreamOnceRequestBody(body RequestBody) bool {
+	for {
+		switch b := body.(type) {
+		case noRetriesRequestBody:
+			return true
+		case contentTypeRequestBody:
+			body = b.RequestBody
+		default:
+			return false
+		}
+	}
+}
+
 // requestBodyStreamInput is a generic constraint for functions that return a reader with optional content length and error.
 type requestBodyStreamInput interface {
 	func() io.ReadCloser | func() (io.ReadCloser, error) | func() (io.ReadCloser, int64, error)
@@ -167,6 +183,41 @@ func RequestBodyStreamWithReplay[T requestBodyStreamInput](input T) RequestBody
 	})
 }
 
+// RequestBodyReader sets the *http.Request Body field for upload to r, which is read only once like
+// RequestBodyStreamOnce, so the request is not retried. Use RequestBodyReaderAt or RequestBodyInMemory for
+// bodies which can be replayed. contentLength is the number of bytes in r, or -1 if it is unknown.
+//
+// If r implements io.Closer, its Close() method will be called when the request is completed.
+func RequestBodyReader(r io.Reader, contentLength int64) RequestBody {
+	return RequestBodyStreamOnce(func() (io.ReadCloser, int64, error) {
+		if r == nil {
+			return nil, 0, nil
+		}
+		rc, ok := r.(io.ReadCloser)
+		if !ok {
+			rc = io.NopCloser(r)
+		}
+		return rc, contentLength, nil
+	})
+}
+
+// RequestBodyFromWriterFunc sets the *http.Request Body field for upload to the output of fn, which is
+// called in a separate goroutine with the write end of an io.Pipe once the body is first read, so the body is
+// streamed to the transport as it is written. An error returned by fn is returned to the transport by the body
+// reader, and closing the body fails writes of fn with io.ErrClosedPipe. The content length is unknown (-1).
+//
+// The GetBody field is set to a function that calls fn again, so fn must produce the same output on every call.
+func RequestBodyFromWriterFunc(fn func(w io.Writer) error) RequestBody {
+	if fn == nil {
+		return requestBodyFunc(func() (int64, io.ReadCloser, func() (io.ReadCloser, error), error) {
+			return 0, nil, nil, nil
+		})
+	}
+	return RequestBodyStreamWithReplay(func() io.ReadCloser {
+		return &pipeWriterReadCloser{write: fn}
+	})
+}
+
 // RequestBodyEncoderObject sets the *http.Request Body field for upload using the provided encoder.
 func RequestBodyEncoderObject(input any, encoder codecs.Encoder) RequestBody {
 	return requestBodyFunc(func() (contentLen int64, body io.ReadCloser, getBody func() (io.ReadCloser, error), err error) {
@@ -182,6 +233,30 @@ func RequestBodyEncoderObject(input any, encoder codecs.Encoder) RequestBody {
 	})
 }
 
+// RequestBodyJSON sets the *http.Request Body field for upload to the JSON encoding of input.
+// RequestBodyJSON carries the application/json Content-Type, so when it is used with WithBinaryRequestBody the
+// request is sent with Content-Type application/json rather than application/octet-stream.
+func RequestBodyJSON(input any) RequestBody {
+	return contentTypeRequestBody{
+		RequestBody: RequestBodyEncoderObject(input, codecs.JSON),
+		contentType: codecs.JSON.ContentType(),
+	}
+}
+
+// contentTypeRequestBody is a RequestBody with a known Content-Type, which is set by WithBinaryRequestBody.
+type contentTypeRequestBody struct {
+	RequestBody
+	contentType string
+}
+
+// requestBodyContentType returns the Content-Type of body, or "application/octet-stream" if it is unknown.
+func requestBodyContentType(body RequestBody) string {
+	if body, ok := body.(contentTypeRequestBody); ok {
+		return body.contentType
+	}
+	return "application/octet-stream"
+}
+
 // RequestBodyEncoderObjectBuffer is like RequestBodyEncoderObject but writes the encoded object to the provided buffer.
 func RequestBodyEncoderObjectBuffer(input any, encoder codecs.Encoder, buffer *bytes.Buffer) RequestBody {
 	return requestBodyFunc(func() (contentLen int64, body io.ReadCloser, getBody func() (io.ReadCloser, error), err error) {
@@ -198,6 +273,266 @@ func RequestBodyEncoderObjectBuffer(input any, encoder codecs.Encoder, buffer *b
 	})
 }
 
+// RequestBodyEncoderObjectStream is like RequestBodyEncoderObject but encodes the object as the body is read by the
+// transport rather than buffering the encoded object in memory, so the ContentLength is unknown (-1).
+// The object is encoded again whenever the body is replayed (e.g. when a request is redirected or retried),
+// so it must not be modified until the request completes. An error returned by the encoder fails the request.
+//
+// WithBinaryRequestBody sets the Content-Type to application/octet-stream, so use WithRequestContentType to set the
+// encoder's Content-Type:
+//
+//	client.Do(ctx,
+//		WithBinaryRequestBody(RequestBodyEncoderObjectStream(input, codecs.JSON)),
+//		WithRequestContentType(codecs.JSON.ContentType()))
+func RequestBodyEncoderObjectStream(input any, encoder codecs.Encoder) RequestBody {
+	return requestBodyFunc(func() (contentLen int64, body io.ReadCloser, getBody func() (io.ReadCloser, error), err error) {
+		getBody = func() (io.ReadCloser, error) {
+			return &pipeWriterReadCloser{write: func(w io.Writer) error {
+				return encoder.Encode(w, input)
+			}}, nil
+		}
+		body, _ = getBody()
+		return -1, body, getBody, nil
+	})
+}
+
+// pipeWriterReadCloser calls write with a pipe from a new goroutine once it is first read.
+// Deferring the write ensures no goroutine is left blocked if the body is closed without being read.
+type pipeWriterReadCloser struct {
+	write func(w io.Writer) error
+
+	once sync.Once
+	pr   *io.PipeReader
+}
+
+func (r *pipeWriterReadCloser) Read(p []byte) (int, error) {
+	r.once.Do(func() {
+		pr, pw := io.Pipe()
+		go func() {
+			_ = pw.CloseWithError(r.write(pw))
+		}()
+		r.pr = pr
+	})
+	return r.pr.Read(p)
+}
+
+func (r *pipeWriterReadCloser) Close() error {
+	r.once.Do(func() {
+		// never read, so there is no encoding goroutine to stop
+		r.pr, _ = io.Pipe()
+	})
+	return r.pr.Close()
+}
+
+// RequestBodyReaderAt sets the *http.Request Body field to the first size bytes of r for upload.
+// The GetBody field is set to a function that returns a new io.SectionReader of r, so the body can be replayed
+// without buffering or re-opening the source. r must support concurrent calls to ReadAt.
+func RequestBodyReaderAt(r io.ReaderAt, size int64) RequestBody {
+	return requestBodyFunc(func() (int64, io.ReadCloser, func() (io.ReadCloser, error), error) {
+		if r == nil {
+			return 0, nil, nil, nil
+		}
+		if size < 0 {
+			return 0, nil, nil, fmt.Errorf("httpclient.RequestBodyReaderAt: size must not be negative, got %d", size)
+		}
+		getBody := func() (io.ReadCloser, error) {
+			return io.NopCloser(io.NewSectionReader(r, 0, size)), nil
+		}
+		body, _ := getBody()
+		return size, body, getBody, nil
+	})
+}
+
+// RequestBodyTee wraps the inner RequestBody such that all bytes read from the body by the transport are
+// written to w. This can be used to observe or checksum the uploaded content without buffering it.
+//
+// If the inner body can be replayed (e.g. when a request is redirected or retried), each replayed body is
+// also written to w, so w may observe the content more than once. Errors returned by w are returned
+// from the body's Read method and will fail the request.
+func RequestBodyTee(inner RequestBody, w io.Writer) RequestBody {
+	tee := requestBodyFunc(func() (contentLen int64, body io.ReadCloser, getBody func() (io.ReadCloser, error), err error) {
+		req := &http.Request{}
+		if err := inner.setRequestBody(req); err != nil {
+			return 0, nil, nil, err
+		}
+		if req.Body == nil || req.Body == http.NoBody {
+			return req.ContentLength, req.Body, req.GetBody, nil
+		}
+		if req.GetBody != nil {
+			getBody = func() (io.ReadCloser, error) {
+				replay, err := req.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				return newTeeReadCloser(replay, w), nil
+			}
+		}
+		return req.ContentLength, newTeeReadCloser(req.Body, w), getBody, nil
+	})
+	var body RequestBody = tee
+	if isStreamOnceRequestBody(inner) {
+		body = noRetriesRequestBody{requestBodyFunc: tee}
+	}
+	if inner, ok := inner.(contentTypeRequestBody); ok {
+		return contentTypeRequestBody{RequestBody: body, contentType: inner.contentType}
+	}
+	return body
+}
+
+// RequestBodyBuffered reads the inner RequestBody into memory the first time it is used and sends the buffered
+// content from then on, so the request is sent with a Content-Length and can be replayed (e.g. when a request is
+// redirected or retried) even if inner is a stream which can only be read once. If inner is larger than maxBytes,
+// the request fails with a *RequestBodyTooLargeError. The inner body is only read once, so an error reading it is
+// returned by every request using the RequestBodyBuffered.
+func RequestBodyBuffered(inner RequestBody, maxBytes int64) RequestBody {
+	buffered := &bufferedRequestBody{inner: inner, maxBytes: maxBytes}
+	if inner, ok := inner.(contentTypeRequestBody); ok {
+		return contentTypeRequestBody{RequestBody: buffered, contentType: inner.contentType}
+	}
+	return buffered
+}
+
+type bufferedRequestBody struct {
+	inner    RequestBody
+	maxBytes int64
+
+	once    sync.Once
+	content []byte
+	err     error
+}
+
+func (b *bufferedRequestBody) setRequestBody(req *http.Request) error {
+	b.once.Do(func() {
+		b.content, b.err = b.read()
+	})
+	if b.err != nil {
+		return b.err
+	}
+	if len(b.content) == 0 {
+		req.ContentLength, req.Body, req.GetBody = 0, http.NoBody, nil
+		return nil
+	}
+	req.ContentLength = int64(len(b.content))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(b.content)), nil
+	}
+	req.Body, _ = req.GetBody()
+	return nil
+}
+
+func (b *bufferedRequestBody) read() ([]byte, error) {
+	req := &http.Request{}
+	if err := b.inner.setRequestBody(req); err != nil {
+		return nil, err
+	}
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	defer func() {
+		_ = req.Body.Close()
+	}()
+	if req.ContentLength > b.maxBytes {
+		return nil, &RequestBodyTooLargeError{Limit: b.maxBytes, ContentLength: req.ContentLength}
+	}
+	// read one byte more than maxBytes to detect a body exceeding the limit
+	content, err := io.ReadAll(io.LimitReader(req.Body, b.maxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(content)) > b.maxBytes {
+		return nil, &RequestBodyTooLargeError{Limit: b.maxBytes, ContentLength: req.ContentLength}
+	}
+	return content, nil
+}
+
+// RequestBodyConcat sends the content of each of bodies in order as a single request body. The request is sent with
+// a Content-Length equal to the sum of the lengths of bodies if all of them are known, and otherwise with an unknown
+// length. The body can only be replayed (e.g. when a request is redirected or retried) if all of bodies can be
+// replayed. Closing the body closes all of the inner bodies.
+func RequestBodyConcat(bodies ...RequestBody) RequestBody {
+	concat := requestBodyFunc(func() (contentLen int64, body io.ReadCloser, getBody func() (io.ReadCloser, error), err error) {
+		var readers []io.ReadCloser
+		var getBodies []func() (io.ReadCloser, error)
+		replayable := true
+		for _, inner := range bodies {
+			req := &http.Request{}
+			if err := inner.setRequestBody(req); err != nil {
+				_ = newMultiReadCloser(readers).Close()
+				return 0, nil, nil, err
+			}
+			if req.Body == nil || req.Body == http.NoBody {
+				continue
+			}
+			if req.ContentLength < 0 || contentLen < 0 {
+				contentLen = -1
+			} else {
+				contentLen += req.ContentLength
+			}
+			readers = append(readers, req.Body)
+			getBodies = append(getBodies, req.GetBody)
+			replayable = replayable && req.GetBody != nil
+		}
+		if len(readers) == 0 {
+			return 0, http.NoBody, nil, nil
+		}
+		if replayable {
+			getBody = func() (io.ReadCloser, error) {
+				replays := make([]io.ReadCloser, 0, len(getBodies))
+				for _, getBody := range getBodies {
+					replay, err := getBody()
+					if err != nil {
+						_ = newMultiReadCloser(replays).Close()
+						return nil, err
+					}
+					replays = append(replays, replay)
+				}
+				return newMultiReadCloser(replays), nil
+			}
+		}
+		return contentLen, newMultiReadCloser(readers), getBody, nil
+	})
+	for _, inner := range bodies {
+		if isStreamOnceRequestBody(inner) {
+			return noRetriesRequestBody{requestBodyFunc: concat}
+		}
+	}
+	return concat
+}
+
+// multiReadCloser reads each of its readers in order and closes all of them when closed.
+type multiReadCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func newMultiReadCloser(rcs []io.ReadCloser) io.ReadCloser {
+	readers := make([]io.Reader, len(rcs))
+	closers := make([]io.Closer, len(rcs))
+	for i, rc := range rcs {
+		readers[i], closers[i] = rc, rc
+	}
+	return &multiReadCloser{Reader: io.MultiReader(readers...), closers: closers}
+}
+
+func (m *multiReadCloser) Close() error {
+	var errs []error
+	for _, closer := range m.closers {
+		if err := closer.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+type teeReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+func newTeeReadCloser(rc io.ReadCloser, w io.Writer) io.ReadCloser {
+	return teeReadCloser{Reader: io.TeeReader(rc, w), Closer: rc}
+}
+
 // RetrieveReaderFromRequestBody extracts the io.ReadCloser and ContentLength from the RequestBody.
 // It is primarily useful for testing.
 // This reader does not 'count' as a stream for RequestBodyStreamOnce constraints and
@@ -209,3 +544,40 @@ func RetrieveReaderFromRequestBody(body RequestBody) (io.ReadCloser, int64, erro
 	}
 	return req.Body, req.ContentLength, nil
 }
+
+// PeekRequestBody returns the content of a replayable RequestBody without affecting subsequent requests made
+// using the same RequestBody. The content is read using the GetBody function, and the body that would have been
+// sent is closed without being read.
+//
+// Stream-once bodies (e.g. RequestBodyStreamOnce) are not read or opened: replayable is false, content is nil,
+// and contentLength is -1.
+func PeekRequestBody(body RequestBody) (content []byte, contentLength int64, replayable bool, err error) {
+	if isStreamOnceRequestBody(body) {
+		return nil, -1, false, nil
+	}
+	req := &http.Request{}
+	if err := body.setRequestBody(req); err != nil {
+		return nil, 0, false, err
+	}
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, 0, true, nil
+	}
+	defer func() {
+		_ = req.Body.Close()
+	}()
+	if req.GetBody == nil {
+		return nil, req.ContentLength, false, nil
+	}
+	replay, err := req.GetBody()
+	if err != nil {
+		return nil, req.ContentLength, true, err
+	}
+	defer func() {
+		_ = replay.Close()
+	}()
+	content, err = io.ReadAll(replay)
+	if err != nil {
+		return nil, req.ContentLength, true, err
+	}
+	return content, req.ContentLength, true, nil
+}
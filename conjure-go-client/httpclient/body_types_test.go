@@ -16,10 +16,16 @@ package httpclient
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
 	"io"
+	"net/http"
+	"runtime"
 	"strings"
+	"sync/atomic"
 	"testing"
 
+	"github.com/palantir/conjure-go-runtime/v2/conjure-go-contract/codecs"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -105,3 +111,404 @@ func TestRetrieveRequestBodyReader(t *testing.T) {
 		})
 	}
 }
+
+func TestRequestBodyTee(t *testing.T) {
+	t.Run("replayable", func(t *testing.T) {
+		var buf bytes.Buffer
+		body := RequestBodyTee(RequestBodyInMemory(strings.NewReader("hello")), &buf)
+
+		req := &http.Request{}
+		require.NoError(t, body.setRequestBody(req))
+		assert.EqualValues(t, 5, req.ContentLength)
+		content, err := io.ReadAll(req.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "hello", string(content))
+		assert.Equal(t, "hello", buf.String())
+
+		require.NotNil(t, req.GetBody)
+		replay, err := req.GetBody()
+		require.NoError(t, err)
+		content, err = io.ReadAll(replay)
+		require.NoError(t, err)
+		assert.Equal(t, "hello", string(content))
+		assert.Equal(t, "hellohello", buf.String())
+	})
+	t.Run("stream once", func(t *testing.T) {
+		var buf bytes.Buffer
+		body := RequestBodyTee(RequestBodyStreamOnce(func() io.ReadCloser { return io.NopCloser(strings.NewReader("hello")) }), &buf)
+		_, ok := body.(noRetriesRequestBody)
+		assert.True(t, ok, "stream once body should not be retried")
+
+		reader, length, err := RetrieveReaderFromRequestBody(body)
+		require.NoError(t, err)
+		assert.EqualValues(t, -1, length)
+		content, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		assert.Equal(t, "hello", string(content))
+		assert.Equal(t, "hello", buf.String())
+	})
+	t.Run("empty", func(t *testing.T) {
+		var buf bytes.Buffer
+		reader, length, err := RetrieveReaderFromRequestBody(RequestBodyTee(RequestBodyEmpty(), &buf))
+		require.NoError(t, err)
+		assert.EqualValues(t, 0, length)
+		assert.Equal(t, http.NoBody, reader)
+	})
+}
+
+func TestRequestBodyBuffered(t *testing.T) {
+	t.Run("stream once", func(t *testing.T) {
+		var opened int
+		body := RequestBodyBuffered(RequestBodyStreamOnce(func() io.ReadCloser {
+			opened++
+			return io.NopCloser(strings.NewReader("hello"))
+		}), 5)
+		for i := 0; i < 2; i++ {
+			req := &http.Request{}
+			require.NoError(t, body.setRequestBody(req))
+			assert.EqualValues(t, 5, req.ContentLength)
+			content, err := io.ReadAll(req.Body)
+			require.NoError(t, err)
+			assert.Equal(t, "hello", string(content))
+
+			require.NotNil(t, req.GetBody)
+			replay, err := req.GetBody()
+			require.NoError(t, err)
+			content, err = io.ReadAll(replay)
+			require.NoError(t, err)
+			assert.Equal(t, "hello", string(content))
+		}
+		assert.Equal(t, 1, opened, "inner body should only be read once")
+		_, ok := body.(noRetriesRequestBody)
+		assert.False(t, ok, "buffered body should be retried")
+	})
+	t.Run("too large", func(t *testing.T) {
+		for _, inner := range []RequestBody{
+			RequestBodyInMemory(strings.NewReader("hello")),
+			RequestBodyStreamOnce(func() io.ReadCloser { return io.NopCloser(strings.NewReader("hello")) }),
+		} {
+			_, _, err := RetrieveReaderFromRequestBody(RequestBodyBuffered(inner, 4))
+			var tooLargeErr *RequestBodyTooLargeError
+			require.True(t, errors.As(err, &tooLargeErr), "expected a RequestBodyTooLargeError, got %v", err)
+			assert.EqualValues(t, 4, tooLargeErr.Limit)
+		}
+	})
+	t.Run("empty", func(t *testing.T) {
+		reader, length, err := RetrieveReaderFromRequestBody(RequestBodyBuffered(RequestBodyEmpty(), 0))
+		require.NoError(t, err)
+		assert.EqualValues(t, 0, length)
+		assert.Equal(t, http.NoBody, reader)
+	})
+	t.Run("keeps content type", func(t *testing.T) {
+		assert.Equal(t, "application/json", requestBodyContentType(RequestBodyBuffered(RequestBodyJSON("value"), 100)))
+	})
+}
+
+type closeTrackingReader struct {
+	io.Reader
+	closed bool
+}
+
+func (r *closeTrackingReader) Close() error {
+	r.closed = true
+	return nil
+}
+
+func TestRequestBodyConcat(t *testing.T) {
+	t.Run("replayable", func(t *testing.T) {
+		body := RequestBodyConcat(
+			RequestBodyInMemory(strings.NewReader("hello")),
+			RequestBodyEmpty(),
+			RequestBodyInMemory(strings.NewReader(" world")),
+		)
+		content, length, replayable, err := PeekRequestBody(body)
+		require.NoError(t, err)
+		assert.True(t, replayable)
+		assert.EqualValues(t, 11, length)
+		assert.Equal(t, "hello world", string(content))
+
+		reader, length, err := RetrieveReaderFromRequestBody(body)
+		require.NoError(t, err)
+		assert.EqualValues(t, 11, length)
+		content, err = io.ReadAll(reader)
+		require.NoError(t, err)
+		assert.Equal(t, "hello world", string(content))
+	})
+	t.Run("stream once", func(t *testing.T) {
+		stream := &closeTrackingReader{Reader: strings.NewReader(" world")}
+		body := RequestBodyConcat(
+			RequestBodyInMemory(strings.NewReader("hello")),
+			RequestBodyStreamOnce(func() io.ReadCloser { return stream }),
+		)
+		_, ok := body.(noRetriesRequestBody)
+		assert.True(t, ok, "body containing a stream once body should not be retried")
+
+		req := &http.Request{}
+		require.NoError(t, body.setRequestBody(req))
+		assert.EqualValues(t, -1, req.ContentLength)
+		assert.Nil(t, req.GetBody)
+		content, err := io.ReadAll(req.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "hello world", string(content))
+		require.NoError(t, req.Body.Close())
+		assert.True(t, stream.closed, "inner body should be closed")
+	})
+	t.Run("wrapped stream once", func(t *testing.T) {
+		body := RequestBodyConcat(
+			RequestBodyInMemory(strings.NewReader("hello")),
+			contentTypeRequestBody{
+				RequestBody: RequestBodyStreamOnce(func() io.ReadCloser { return io.NopCloser(strings.NewReader(" world")) }),
+				contentType: "text/plain",
+			},
+		)
+		assert.True(t, isStreamOnceRequestBody(body), "body containing a wrapped stream once body should not be retried")
+
+		req := &http.Request{}
+		require.NoError(t, body.setRequestBody(req))
+		assert.Nil(t, req.GetBody)
+		content, err := io.ReadAll(req.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "hello world", string(content))
+	})
+	t.Run("empty", func(t *testing.T) {
+		reader, length, err := RetrieveReaderFromRequestBody(RequestBodyConcat(RequestBodyEmpty()))
+		require.NoError(t, err)
+		assert.EqualValues(t, 0, length)
+		assert.Equal(t, http.NoBody, reader)
+	})
+}
+
+func TestRequestBodyEncoderObjectStream(t *testing.T) {
+	t.Run("replayable", func(t *testing.T) {
+		body := RequestBodyEncoderObjectStream(map[string]string{"key": "value"}, codecs.JSON)
+
+		req := &http.Request{}
+		require.NoError(t, body.setRequestBody(req))
+		assert.EqualValues(t, -1, req.ContentLength)
+		content, err := io.ReadAll(req.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "{\"key\":\"value\"}\n", string(content))
+		require.NoError(t, req.Body.Close())
+
+		require.NotNil(t, req.GetBody)
+		replay, err := req.GetBody()
+		require.NoError(t, err)
+		content, err = io.ReadAll(replay)
+		require.NoError(t, err)
+		assert.Equal(t, "{\"key\":\"value\"}\n", string(content))
+	})
+	t.Run("encode error", func(t *testing.T) {
+		reader, _, err := RetrieveReaderFromRequestBody(RequestBodyEncoderObjectStream(make(chan int), codecs.JSON))
+		require.NoError(t, err)
+		_, err = io.ReadAll(reader)
+		assert.EqualError(t, err, "failed to JSON-encode value: json: unsupported type: chan int")
+	})
+	t.Run("closed before read", func(t *testing.T) {
+		reader, _, err := RetrieveReaderFromRequestBody(RequestBodyEncoderObjectStream("value", codecs.JSON))
+		require.NoError(t, err)
+		require.NoError(t, reader.Close())
+		_, err = reader.Read(make([]byte, 1))
+		assert.Equal(t, io.ErrClosedPipe, err)
+	})
+}
+
+func TestRequestBodyReaderAt(t *testing.T) {
+	t.Run("replayable", func(t *testing.T) {
+		body := RequestBodyReaderAt(strings.NewReader("hello world"), 5)
+
+		req := &http.Request{}
+		require.NoError(t, body.setRequestBody(req))
+		assert.EqualValues(t, 5, req.ContentLength)
+		content, err := io.ReadAll(req.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "hello", string(content))
+
+		require.NotNil(t, req.GetBody)
+		replay, err := req.GetBody()
+		require.NoError(t, err)
+		content, err = io.ReadAll(replay)
+		require.NoError(t, err)
+		assert.Equal(t, "hello", string(content))
+	})
+	t.Run("negative size", func(t *testing.T) {
+		_, _, err := RetrieveReaderFromRequestBody(RequestBodyReaderAt(strings.NewReader("hello"), -1))
+		assert.EqualError(t, err, "httpclient.RequestBodyReaderAt: size must not be negative, got -1")
+	})
+}
+
+type closeRecordingReader struct {
+	io.Reader
+	closed bool
+}
+
+func (r *closeRecordingReader) Close() error {
+	r.closed = true
+	return nil
+}
+
+func TestRequestBodyReader(t *testing.T) {
+	for _, test := range []struct {
+		Name          string
+		ContentLength int64
+	}{
+		{Name: "known length", ContentLength: 5},
+		{Name: "unknown length", ContentLength: -1},
+	} {
+		t.Run(test.Name, func(t *testing.T) {
+			body := RequestBodyReader(strings.NewReader("hello"), test.ContentLength)
+			_, ok := body.(noRetriesRequestBody)
+			assert.True(t, ok, "reader body should not be retried")
+
+			req := &http.Request{}
+			require.NoError(t, body.setRequestBody(req))
+			assert.Equal(t, test.ContentLength, req.ContentLength)
+			assert.Nil(t, req.GetBody)
+			content, err := io.ReadAll(req.Body)
+			require.NoError(t, err)
+			assert.Equal(t, "hello", string(content))
+		})
+	}
+	t.Run("closer", func(t *testing.T) {
+		r := &closeRecordingReader{Reader: strings.NewReader("hello")}
+		reader, _, err := RetrieveReaderFromRequestBody(RequestBodyReader(r, 5))
+		require.NoError(t, err)
+		require.NoError(t, reader.Close())
+		assert.True(t, r.closed)
+	})
+	t.Run("nil", func(t *testing.T) {
+		reader, length, err := RetrieveReaderFromRequestBody(RequestBodyReader(nil, -1))
+		require.NoError(t, err)
+		assert.Equal(t, http.NoBody, reader)
+		assert.EqualValues(t, 0, length)
+	})
+}
+
+func TestRequestBodyFromWriterFunc(t *testing.T) {
+	var calls int32
+	body := RequestBodyFromWriterFunc(func(w io.Writer) error {
+		atomic.AddInt32(&calls, 1)
+		for i := 0; i < 3; i++ {
+			if _, err := fmt.Fprintf(w, "row,%d\n", i); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	req := &http.Request{}
+	require.NoError(t, body.setRequestBody(req))
+	assert.EqualValues(t, -1, req.ContentLength)
+	content, err := io.ReadAll(req.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "row,0\nrow,1\nrow,2\n", string(content))
+	require.NoError(t, req.Body.Close())
+
+	require.NotNil(t, req.GetBody)
+	replay, err := req.GetBody()
+	require.NoError(t, err)
+	content, err = io.ReadAll(replay)
+	require.NoError(t, err)
+	assert.Equal(t, "row,0\nrow,1\nrow,2\n", string(content))
+	assert.EqualValues(t, 2, atomic.LoadInt32(&calls))
+
+	t.Run("error", func(t *testing.T) {
+		fnErr := errors.New("generator failed")
+		reader, _, err := RetrieveReaderFromRequestBody(RequestBodyFromWriterFunc(func(w io.Writer) error {
+			_, _ = w.Write([]byte("partial"))
+			return fnErr
+		}))
+		require.NoError(t, err)
+		content, err := io.ReadAll(reader)
+		assert.Equal(t, "partial", string(content))
+		assert.Equal(t, fnErr, err)
+	})
+	t.Run("reader closed early", func(t *testing.T) {
+		fnErrs := make(chan error, 1)
+		reader, _, err := RetrieveReaderFromRequestBody(RequestBodyFromWriterFunc(func(w io.Writer) error {
+			var err error
+			for err == nil {
+				_, err = w.Write([]byte("data"))
+			}
+			fnErrs <- err
+			return err
+		}))
+		require.NoError(t, err)
+		_, err = reader.Read(make([]byte, 4))
+		require.NoError(t, err)
+		require.NoError(t, reader.Close())
+		assert.Equal(t, io.ErrClosedPipe, <-fnErrs)
+	})
+	t.Run("body never read", func(t *testing.T) {
+		var calls int32
+		body := RequestBodyFromWriterFunc(func(w io.Writer) error {
+			atomic.AddInt32(&calls, 1)
+			_, err := w.Write([]byte("data"))
+			return err
+		})
+		before := runtime.NumGoroutine()
+		for i := 0; i < 10; i++ {
+			req := &http.Request{}
+			require.NoError(t, body.setRequestBody(req))
+			// neither body is read nor closed, as when a middleware fails before the request is sent.
+			_, err := req.GetBody()
+			require.NoError(t, err)
+		}
+		assert.EqualValues(t, 0, atomic.LoadInt32(&calls), "fn should not be called for bodies which are not read")
+		assert.LessOrEqual(t, runtime.NumGoroutine(), before, "no goroutines should be leaked")
+	})
+}
+
+func TestPeekRequestBody(t *testing.T) {
+	t.Run("replayable", func(t *testing.T) {
+		body := RequestBodyInMemory(strings.NewReader("hello"))
+		content, length, replayable, err := PeekRequestBody(body)
+		require.NoError(t, err)
+		assert.True(t, replayable)
+		assert.EqualValues(t, 5, length)
+		assert.Equal(t, "hello", string(content))
+
+		reader, _, err := RetrieveReaderFromRequestBody(body)
+		require.NoError(t, err)
+		content, err = io.ReadAll(reader)
+		require.NoError(t, err)
+		assert.Equal(t, "hello", string(content), "body should not be consumed by peek")
+	})
+	t.Run("stream once", func(t *testing.T) {
+		var opened bool
+		body := RequestBodyStreamOnce(func() io.ReadCloser {
+			opened = true
+			return io.NopCloser(strings.NewReader("hello"))
+		})
+		content, length, replayable, err := PeekRequestBody(body)
+		require.NoError(t, err)
+		assert.False(t, replayable)
+		assert.EqualValues(t, -1, length)
+		assert.Nil(t, content)
+		assert.False(t, opened, "stream once body should not be opened by peek")
+	})
+	t.Run("wrapped stream once", func(t *testing.T) {
+		var opened bool
+		streamOnce := RequestBodyStreamOnce(func() io.ReadCloser {
+			opened = true
+			return io.NopCloser(strings.NewReader("hello"))
+		})
+		for _, body := range []RequestBody{
+			contentTypeRequestBody{RequestBody: streamOnce, contentType: "text/csv"},
+			RequestBodyTee(contentTypeRequestBody{RequestBody: streamOnce, contentType: "text/csv"}, io.Discard),
+		} {
+			content, length, replayable, err := PeekRequestBody(body)
+			require.NoError(t, err)
+			assert.False(t, replayable)
+			assert.EqualValues(t, -1, length)
+			assert.Nil(t, content)
+			assert.False(t, opened, "stream once body should not be opened by peek")
+			assert.Equal(t, "text/csv", requestBodyContentType(body))
+		}
+	})
+	t.Run("empty", func(t *testing.T) {
+		content, length, replayable, err := PeekRequestBody(RequestBodyEmpty())
+		require.NoError(t, err)
+		assert.True(t, replayable)
+		assert.EqualValues(t, 0, length)
+		assert.Nil(t, content)
+	})
+}
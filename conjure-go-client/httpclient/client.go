@@ -16,13 +16,18 @@ package httpclient
 
 import (
 	"context"
+	"errors"
+	"io"
 	"net/http"
 	"net/url"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/palantir/conjure-go-runtime/v2/conjure-go-client/httpclient/internal"
 	"github.com/palantir/conjure-go-runtime/v2/conjure-go-client/httpclient/internal/refreshingclient"
 	"github.com/palantir/pkg/bytesbuffers"
+	"github.com/palantir/pkg/metrics"
 	"github.com/palantir/pkg/refreshable"
 	werror "github.com/palantir/witchcraft-go-error"
 	"github.com/palantir/witchcraft-go-logging/wlog/svclog/svc1log"
@@ -54,12 +59,31 @@ type clientImpl struct {
 	client                 RefreshableHTTPClient
 	middlewares            []Middleware
 	errorDecoderMiddleware Middleware
+	errorBodyDrainLimit    int64
 	recoveryMiddleware     Middleware
 
 	uriScorer      internal.RefreshableURIScoringMiddleware
 	maxAttempts    refreshable.IntPtr // 0 means no limit. If nil, uses 2*len(uris).
 	backoffOptions refreshingclient.RefreshableRetryParams
 	bufferPool     bytesbuffers.Pool
+	// responseBufferPool is used to read response bodies before they are decoded if set.
+	responseBufferPool bytesbuffers.Pool
+
+	// backoffStrategy overrides backoffOptions if set.
+	backoffStrategy BackoffStrategy
+
+	cacheMiddleware            Middleware
+	rateLimiter                RateLimiter
+	retryBudget                *internal.RetryBudget
+	attemptCallback            func(info AttemptInfo)
+	requestCompression         RequestCompression
+	requestCompressionMinBytes int64
+	maxRequestBodyBytes        int64
+	endpointConfigs            endpointConfigs
+
+	connectionErrorRetryPredicate func(req *http.Request, err error) bool
+	info                          func() ClientInfo
+	derive                        func(params []ClientParam) (Client, error)
 }
 
 func (c *clientImpl) Get(ctx context.Context, params ...RequestParam) (*http.Response, error) {
@@ -83,9 +107,29 @@ func (c *clientImpl) Delete(ctx context.Context, params ...RequestParam) (*http.
 }
 
 func (c *clientImpl) Do(ctx context.Context, params ...RequestParam) (*http.Response, error) {
-	uris := c.uriScorer.CurrentURIScoringMiddleware().GetURIsInOrderOfIncreasingScore()
-	if len(uris) == 0 {
-		return nil, werror.WrapWithContextParams(ctx, ErrEmptyURIs, "", werror.SafeParam("serviceName", c.serviceName.CurrentString()))
+	// build the request once to read the params which apply to all attempts
+	b, err := c.newRequestBuilder(params)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.do(ctx, b, params)
+	if err != nil && len(b.errorParams) > 0 {
+		return nil, werror.Wrap(err, "", b.errorParams...)
+	}
+	return resp, err
+}
+
+func (c *clientImpl) do(ctx context.Context, b *requestBuilder, params []RequestParam) (*http.Response, error) {
+	uris, err := c.getURIs(ctx, b)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := c.endpointConfig(ctx, b)
+	if endpoint.Timeout != nil && b.requestTimeout == nil {
+		b.requestTimeout = endpoint.Timeout
+		params = append([]RequestParam{WithRequestTimeout(*endpoint.Timeout)}, params...)
 	}
 
 	attempts := 2 * len(uris)
@@ -94,36 +138,169 @@ func (c *clientImpl) Do(ctx context.Context, params ...RequestParam) (*http.Resp
 			attempts = *confMaxAttempts
 		}
 	}
+	if endpoint.MaxNumRetries != nil {
+		attempts = *endpoint.MaxNumRetries + 1
+	}
 
-	retrier := internal.NewRequestRetrier(uris, c.backoffOptions.CurrentRetryParams().Start(ctx), attempts)
+	retryParams := c.backoffOptions.CurrentRetryParams()
+	if endpoint.InitialBackoff != nil {
+		retryParams.InitialBackoff = *endpoint.InitialBackoff
+	}
+	if endpoint.MaxBackoff != nil {
+		retryParams.MaxBackoff = *endpoint.MaxBackoff
+	}
+
+	if b.requestTimeout == nil {
+		return c.doWithRetries(ctx, uris, retryParams, attempts, params)
+	}
+
+	// the request timeout bounds all attempts, including backoff between them.
+	timeoutCtx, cancel := context.WithTimeout(ctx, *b.requestTimeout)
+	resp, err := c.doWithRetries(timeoutCtx, uris, retryParams, attempts, params)
+	if err == nil && resp != nil && resp.Body != nil && b.bodyMiddleware.rawOutput && !b.bodyMiddleware.spillResponseBody {
+		// the caller reads the body after Do returns, so cancel the context once the body is closed.
+		resp.Body = &cancelOnCloseBody{ReadCloser: resp.Body, cancel: cancel}
+		return resp, nil
+	}
+	cancel()
+	if err != nil && ctx.Err() == nil && errors.Is(timeoutCtx.Err(), context.DeadlineExceeded) {
+		return nil, &RequestTimeoutError{Timeout: *b.requestTimeout, Err: err}
+	}
+	return resp, err
+}
+
+func (c *clientImpl) doWithRetries(
+	ctx context.Context,
+	uris []string,
+	retryParams refreshingclient.RetryParams,
+	attempts int,
+	params []RequestParam,
+) (*http.Response, error) {
+	backoffRetrier := retryParams.Start(ctx)
+	var strategyRetrier *backoffStrategyRetrier
+	if c.backoffStrategy != nil {
+		strategyRetrier = newBackoffStrategyRetrier(ctx, c.backoffStrategy)
+		backoffRetrier = strategyRetrier
+	}
+	retrier := internal.NewRequestRetrier(uris, backoffRetrier, attempts)
 	uri, isRelocated := retrier.GetNextURI(nil, nil)
-	for {
+	c.depositRetryBudget(ctx)
+	for attempt := 0; ; attempt++ {
+		start := time.Now()
 		resp, retryable, err := c.doOnce(ctx, uri, isRelocated, params...)
+		c.reportAttempt(attempt, uri, start, resp, err)
 		if !retryable {
 			return resp, err
 		}
+		if strategyRetrier != nil {
+			strategyRetrier.setAttemptResult(resp, err)
+		}
 		uri, isRelocated = retrier.GetNextURI(resp, err)
 		if uri == "" {
 			return resp, err
 		}
+		// relocations are not retries of a failed request, so they do not consume the retry budget
+		if !isRelocated && !c.withdrawRetryBudget(ctx) {
+			svc1log.FromContext(ctx).Debug("Retry budget exhausted, not retrying.")
+			return resp, err
+		}
 		if err != nil {
 			svc1log.FromContext(ctx).Debug("Retrying request", svc1log.Stacktrace(err))
 		}
 	}
 }
 
-func (c *clientImpl) doOnce(
-	ctx context.Context,
-	baseURI string,
-	useBaseURIOnly bool,
-	params ...RequestParam,
-) (_ *http.Response, retryable bool, _ error) {
+// endpointConfig returns the EndpointConfig for the request's endpoint, which is named by WithEndpointName
+// or otherwise by WithRPCMethodName.
+func (c *clientImpl) endpointConfig(ctx context.Context, b *requestBuilder) EndpointConfig {
+	name := b.endpointName
+	if name == "" {
+		for _, configure := range b.configureCtx {
+			ctx = configure(ctx)
+		}
+		name = getRPCMethodName(ctx)
+	}
+	return c.endpointConfigs.get(name, b.path)
+}
 
-	// 1. create the request
+func (c *clientImpl) reportAttempt(attempt int, uri string, start time.Time, resp *http.Response, err error) {
+	if c.attemptCallback == nil {
+		return
+	}
+	info := AttemptInfo{Attempt: attempt, BaseURL: uri, Err: err, Duration: time.Since(start)}
+	if resp != nil {
+		info.StatusCode = resp.StatusCode
+	} else if statusCode, ok := internal.StatusCodeFromError(err); ok {
+		info.StatusCode = statusCode
+	}
+	c.attemptCallback(info)
+}
+
+func (c *clientImpl) depositRetryBudget(ctx context.Context) {
+	if c.retryBudget == nil {
+		return
+	}
+	c.retryBudget.Deposit()
+	c.updateRetryBudgetGauge(ctx)
+}
+
+func (c *clientImpl) withdrawRetryBudget(ctx context.Context) bool {
+	if c.retryBudget == nil {
+		return true
+	}
+	ok := c.retryBudget.TryWithdraw()
+	if !ok {
+		metrics.FromContext(ctx).Counter(MetricRetryBudgetExhausted, c.serviceNameTag()).Inc(1)
+	}
+	c.updateRetryBudgetGauge(ctx)
+	return ok
+}
+
+func (c *clientImpl) updateRetryBudgetGauge(ctx context.Context) {
+	metrics.FromContext(ctx).Gauge(MetricRetryBudget, c.serviceNameTag()).Update(int64(c.retryBudget.Balance()))
+}
+
+func (c *clientImpl) serviceNameTag() metrics.Tag {
+	return metrics.NewTagWithFallbackValue(MetricTagServiceName, c.serviceName.CurrentString(), "unknown")
+}
+
+// getURIs returns the base URIs to attempt in order of preference.
+// If the request overrides the base URL, only that URL is returned.
+func (c *clientImpl) getURIs(ctx context.Context, b *requestBuilder) ([]string, error) {
+	var uris []string
+	scorer := c.uriScorer.CurrentURIScoringMiddleware()
+	if keyedScorer, ok := scorer.(internal.KeyedURIScoringMiddleware); ok && b.shardKey != "" {
+		uris = keyedScorer.GetURIsInOrderOfIncreasingScoreForKey(b.shardKey)
+	} else {
+		uris = scorer.GetURIsInOrderOfIncreasingScore()
+	}
+	if b.baseURL != "" {
+		if b.baseURLStrict && !slices.ContainsFunc(uris, func(uri string) bool {
+			return strings.TrimRight(uri, "/") == strings.TrimRight(b.baseURL, "/")
+		}) {
+			return nil, werror.ErrorWithContextParams(ctx, "httpclient: base URL is not one of the configured URLs",
+				werror.SafeParam("serviceName", c.serviceName.CurrentString()),
+				werror.SafeParam("baseURL", b.baseURL))
+		}
+		uris = []string{b.baseURL}
+	}
+	if len(uris) == 0 {
+		return nil, werror.WrapWithContextParams(ctx, ErrEmptyURIs, "", werror.SafeParam("serviceName", c.serviceName.CurrentString()))
+	}
+	return uris, nil
+}
+
+func (c *clientImpl) newRequestBuilder(params []RequestParam) (*requestBuilder, error) {
 	b := &requestBuilder{
-		headers:        make(http.Header),
-		query:          make(url.Values),
-		bodyMiddleware: &bodyMiddleware{bufferPool: c.bufferPool},
+		headers: make(http.Header),
+		query:   make(url.Values),
+		bodyMiddleware: &bodyMiddleware{
+			bufferPool:          c.bufferPool,
+			responseBufferPool:  c.responseBufferPool,
+			maxRequestBodyBytes: c.maxRequestBodyBytes,
+		},
+
+		errorBodyDrainLimit: c.errorBodyDrainLimit,
 	}
 
 	for _, p := range params {
@@ -131,9 +308,24 @@ func (c *clientImpl) doOnce(
 			continue
 		}
 		if err := p.apply(b); err != nil {
-			return nil, false, err
+			return nil, err
 		}
 	}
+	return b, nil
+}
+
+func (c *clientImpl) doOnce(
+	ctx context.Context,
+	baseURI string,
+	useBaseURIOnly bool,
+	params ...RequestParam,
+) (_ *http.Response, retryable bool, _ error) {
+
+	// 1. create the request
+	b, err := c.newRequestBuilder(params)
+	if err != nil {
+		return nil, false, err
+	}
 	if useBaseURIOnly {
 		b.path = ""
 	}
@@ -153,25 +345,54 @@ func (c *clientImpl) doOnce(
 
 	req.Header = b.headers
 	if q := b.query.Encode(); q != "" {
-		req.URL.RawQuery = q
+		// preserve any query provided in the request path, but not one included in a relocated URI.
+		if req.URL.RawQuery != "" && !useBaseURIOnly {
+			req.URL.RawQuery += "&" + q
+		} else {
+			req.URL.RawQuery = q
+		}
 	}
 
 	// 2. create the transport and client
 	// shallow copy so we can overwrite the Transport with a wrapped one.
 	clientCopy := *c.client.CurrentHTTPClient()
 
-	// use request-specific timeout if set
+	// the request timeout is enforced by the context of all attempts, and replaces the client's timeout.
 	if b.requestTimeout != nil {
-		clientCopy.Timeout = *b.requestTimeout
+		clientCopy.Timeout = 0
 	}
 
 	transport := clientCopy.Transport // start with the client's transport configured with default middleware
 
+	// must be the innermost request middleware so mutators see the final request. The request is signed by the
+	// client's transport afterwards, so that the signature covers their changes.
+	transport = wrapTransport(transport, b.requestMutatorMiddleware())
 	// must precede the error decoders to read the status code of the raw response.
 	transport = wrapTransport(transport, c.uriScorer.CurrentURIScoringMiddleware())
+	if !b.bodyMiddleware.streamsResponse() {
+		// must precede the body middleware to resolve cached responses before they are decoded
+		transport = wrapTransport(transport, c.cacheMiddleware)
+	}
+	if b.acceptGzip {
+		// must precede the error decoders and body middleware to decompress the body before it is read
+		transport = wrapTransport(transport, gzipResponseMiddleware{})
+	}
 	// request decoder must precede the client decoder
 	// must precede the body middleware to read the response body
-	transport = wrapTransport(transport, b.errorDecoderMiddleware, c.errorDecoderMiddleware)
+	if !b.bodyMiddleware.rawOutputOnError && b.bodyMiddleware.responseStatusValidator == nil {
+		requestErrorDecoder, clientErrorDecoder := b.errorDecoderMiddleware, c.errorDecoderMiddleware
+		if len(b.bodyMiddleware.statusResponseOutputs) > 0 || b.bodyMiddleware.rawOutputOnErrorStatus != nil {
+			// responses decoded into an output set by WithResponseForStatus or returned as raw output by
+			// WithRawResponseBodyOnErrorStatus are not errors
+			requestErrorDecoder = withExemptStatuses(requestErrorDecoder, b.bodyMiddleware.exemptsErrorDecoders)
+			clientErrorDecoder = withExemptStatuses(clientErrorDecoder, b.bodyMiddleware.exemptsErrorDecoders)
+		}
+		transport = wrapTransport(transport, requestErrorDecoder, clientErrorDecoder)
+	}
+	// must be wrapped by the client middlewares so request-scoped headers take precedence
+	transport = wrapTransport(transport, b.headerMiddleware())
+	// must be wrapped by the client middlewares so they observe the request body as it is sent
+	transport = wrapTransport(transport, newRequestCompressionMiddleware(c.requestCompression, c.requestCompressionMinBytes, b.forceRequestCompression))
 	// must precede the body middleware to read the request body
 	transport = wrapTransport(transport, c.middlewares...)
 	// must wrap inner middlewares to mutate the return values
@@ -184,27 +405,85 @@ func (c *clientImpl) doOnce(
 	clientCopy.Transport = transport
 
 	// 3. execute the request using the client to get and handle the response
+	if err := waitRateLimiter(ctx, c.rateLimiter, b.priority); err != nil {
+		return nil, false, err
+	}
+	attemptCtx, cancelAttempt := ctx, context.CancelFunc(func() {})
+	if b.perAttemptTimeout != nil {
+		attemptCtx, cancelAttempt = context.WithTimeout(ctx, *b.perAttemptTimeout)
+		req = req.WithContext(attemptCtx)
+	}
 	resp, respErr := clientCopy.Do(req)
+	if respErr == nil && b.bodyMiddleware.spillResponseBody && resp.Body != nil {
+		// must read the body returned by the http.Client, which wraps it to enforce the client timeout.
+		if respErr = spillResponseBody(ctx, resp, b.bodyMiddleware.spillThreshold); respErr != nil {
+			resp = nil
+		}
+	}
 
 	// unless this is exactly the scenario where the caller has opted into being responsible for draining and closing
 	// the response body, be sure to do so here.
 	if !(respErr == nil && b.bodyMiddleware.rawOutput) {
-		internal.DrainBody(ctx, resp)
+		if b.bodyMiddleware.discardResponseBody {
+			internal.DrainBodyLimit(ctx, resp, discardResponseBodyDrainLimit)
+		} else {
+			internal.DrainBody(ctx, resp)
+		}
+		cancelAttempt()
+	} else if resp != nil && resp.Body != nil && !b.bodyMiddleware.spillResponseBody {
+		// a spilled body has already been read, so the attempt can be canceled.
+		resp.Body = &cancelOnCloseBody{ReadCloser: resp.Body, cancel: cancelAttempt}
+	} else {
+		cancelAttempt()
 	}
 
 	// doOnce should be retried unless the body specifically indicates it can not be replayed.
 	if respErr != nil {
-		if !b.bodyMiddleware.noRetriesRequestBody() {
-			retryable = true
-		} else {
+		attemptTimedOut := b.perAttemptTimeout != nil && ctx.Err() == nil && errors.Is(attemptCtx.Err(), context.DeadlineExceeded)
+		if attemptTimedOut {
+			// the request may have been sent, so the retry predicate decides whether the attempt is retried.
+			respErr = newAttemptTimeoutConnectionError(respErr)
+		}
+		var connErr *ConnectionError
+		switch {
+		case b.disableRetry:
+			svc1log.FromContext(ctx).Debug("Retries are disabled for the request, not retrying.")
+		case b.requestMutatorFailed:
+			svc1log.FromContext(ctx).Debug("Request mutator failed, not retrying.")
+		case errors.As(respErr, new(*RequestBodyTooLargeError)):
+			svc1log.FromContext(ctx).Debug("Request body is too large, not retrying.")
+		case errors.Is(respErr, ErrInteractionNotRecorded):
+			svc1log.FromContext(ctx).Debug("No recorded interaction matches the request, not retrying.")
+		case b.bodyMiddleware.noRetriesRequestBody():
 			svc1log.FromContext(ctx).Debug("Request body can not be replayed, not retrying.")
+		case b.bodyMiddleware.noRetriesResponse:
+			svc1log.FromContext(ctx).Debug("Response can not be retried, not retrying.")
+		case errors.As(respErr, &connErr) && !c.connectionErrorRetryPredicate(req, connErr.Err):
+			svc1log.FromContext(ctx).Debug("Connection error is not retryable, not retrying.", svc1log.Stacktrace(respErr))
+		default:
+			retryable = true
 		}
-		return nil, retryable, unwrapURLError(ctx, respErr)
+		err := unwrapURLError(ctx, respErr)
+		if attemptTimedOut {
+			err = &AttemptTimeoutError{Timeout: *b.perAttemptTimeout, Err: err}
+		}
+		return nil, retryable, err
 	}
 
 	return resp, false, nil
 }
 
+// cancelOnCloseBody cancels the request context when the response body is closed.
+type cancelOnCloseBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnCloseBody) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
+
 // unwrapURLError converts a *url.Error to a werror. We need this because all
 // errors from the stdlib's client.Do are wrapped in *url.Error, and if we
 // were to blindly return that we would lose any werror params stored on the
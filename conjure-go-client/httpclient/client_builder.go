@@ -28,6 +28,7 @@ import (
 	"github.com/palantir/pkg/metrics"
 	"github.com/palantir/pkg/refreshable"
 	werror "github.com/palantir/witchcraft-go-error"
+	"github.com/palantir/witchcraft-go-logging/wlog/svclog/svc1log"
 )
 
 const (
@@ -43,6 +44,9 @@ const (
 	defaultHTTP2PingTimeout      = 15 * time.Second
 	defaultInitialBackoff        = 250 * time.Millisecond
 	defaultMaxBackoff            = 2 * time.Second
+	defaultErrorBodyDrainLimit   = 64 << 10
+
+	defaultResponseCacheMaxBodyBytes = 1 << 20
 )
 
 var (
@@ -63,10 +67,27 @@ type clientBuilder struct {
 	AllowEmptyURIs bool
 
 	ErrorDecoder ErrorDecoder
+	// ErrorBodyDrainLimit is the most bytes drained from an error response body after it was decoded.
+	ErrorBodyDrainLimit int64
+
+	BytesBufferPool    bytesbuffers.Pool
+	ResponseBufferPool bytesbuffers.Pool
+	MaxAttempts        refreshable.IntPtr
+	RetryParams        refreshingclient.RefreshableRetryParams
+	BackoffStrategy    BackoffStrategy
 
-	BytesBufferPool bytesbuffers.Pool
-	MaxAttempts     refreshable.IntPtr
-	RetryParams     refreshingclient.RefreshableRetryParams
+	ResponseCache              ResponseCache
+	ResponseCacheMaxBodyBytes  int64
+	RateLimiter                RateLimiter
+	RetryBudget                *internal.RetryBudget
+	AttemptCallback            func(info AttemptInfo)
+	RequestCompression         RequestCompression
+	RequestCompressionMinBytes int64
+	MaxRequestBodyBytes        int64
+	ConnectionWarmup           int
+	EndpointConfigs            endpointConfigs
+
+	ConnectionErrorRetryPredicate func(req *http.Request, err error) bool
 }
 
 type httpClientBuilder struct {
@@ -76,6 +97,10 @@ type httpClientBuilder struct {
 	TLSConfig       *tls.Config // If unset, config in TransportParams will be used.
 	TransportParams refreshingclient.RefreshableTransportParams
 	Middlewares     []Middleware
+	CookieJar       http.CookieJar
+
+	// RefreshableTLSConfig contains a *tls.Config. If set, it is used instead of TLSConfig and config in TransportParams.
+	RefreshableTLSConfig refreshable.Refreshable
 
 	DisableMetrics      refreshable.Bool
 	MetricsTagProviders []TagsProvider
@@ -85,6 +110,34 @@ type httpClientBuilder struct {
 	DisableRequestSpan  bool
 	DisableRecovery     bool
 	DisableTraceHeaders bool
+	// LogRequests is set by WithObservability to log each request attempt.
+	LogRequests bool
+
+	AppendRuntimeUserAgent bool
+	// UserAgent is the User-Agent set by WithUserAgent and ConfigUserAgent is that set by configuration, if any.
+	// They are only used to report the ClientInfo, as the headers are set by middlewares.
+	UserAgent       string
+	ConfigUserAgent refreshable.StringPtr
+
+	OAuth2ClientCredentials *oauth2ClientCredentials
+
+	TLSConfigErrorCallback func(ctx context.Context, err error)
+	// TLSConfigMappers are applied in order to a copy of each tls config used by the transport.
+	TLSConfigMappers []func(*tls.Config) *tls.Config
+
+	// BaseTransport, if set, is used instead of the transport built from the TLS, dialer and transport params.
+	BaseTransport http.RoundTripper
+
+	RequestSigner RequestSigner
+
+	// At most one of InteractionRecorder and InteractionReplayer is set.
+	InteractionRecorder InteractionStore
+	InteractionReplayer InteractionStore
+
+	// ParentTransport is set for clients derived by WithOptions to share the transport of the parent client.
+	ParentTransport *parentTransport
+	// builtTransport is the transport built by Build, before any middleware is applied.
+	builtTransport http.RoundTripper
 }
 
 func (b *httpClientBuilder) Build(ctx context.Context, params ...HTTPClientParam) (RefreshableHTTPClient, error) {
@@ -97,29 +150,107 @@ func (b *httpClientBuilder) Build(ctx context.Context, params ...HTTPClientParam
 		}
 	}
 
-	var tlsProvider refreshingclient.TLSProvider
-	if b.TLSConfig != nil {
-		tlsProvider = refreshingclient.NewStaticTLSConfigProvider(b.TLSConfig)
-	} else {
-		refreshableProvider, err := refreshingclient.NewRefreshableTLSConfig(ctx, b.TransportParams.TLS())
+	var transport http.RoundTripper
+	switch {
+	case b.BaseTransport != nil:
+		if b.TLSConfig != nil || b.RefreshableTLSConfig != nil || len(b.TLSConfigMappers) > 0 {
+			return nil, werror.ErrorWithContextParams(ctx, "httpclient: TLS configuration params can not be used with WithBaseTransport")
+		}
+		transport = b.BaseTransport
+	case b.ParentTransport != nil && b.ParentTransport.sharedWith(b):
+		transport = b.ParentTransport.transport
+	default:
+		refreshableTransport, err := b.newRefreshableTransport(ctx)
 		if err != nil {
 			return nil, err
 		}
-		tlsProvider = refreshableProvider
+		transport = refreshableTransport
 	}
-
-	dialer := refreshingclient.NewRefreshableDialer(ctx, b.DialerParams)
-	transport := refreshingclient.NewRefreshableTransport(ctx, b.TransportParams, tlsProvider, dialer)
+	b.builtTransport = transport
+	transport = wrapTransport(transport, connectionErrorMiddleware{})
+	// must wrap the connection error middleware so that unmatched requests are not retried as connection errors.
+	transport = wrapTransport(transport, newInteractionRecorderMiddleware(b.InteractionRecorder), newInteractionReplayerMiddleware(b.InteractionReplayer))
+	// token requests share the TLS, proxy and dial configuration but not the middlewares of authenticated requests.
+	tokenTransport := transport
+	// must be wrapped by the other middlewares so that the signature covers the headers they set.
+	transport = wrapTransport(transport, newRequestSigningMiddleware(b.RequestSigner))
+	transport = wrapTransport(transport, newCookieJarMiddleware(b.CookieJar))
 	transport = wrapTransport(transport, newMetricsMiddleware(b.ServiceName, b.MetricsTagProviders, b.DisableMetrics))
+	if b.LogRequests {
+		// must be wrapped by the trace middleware to log the request's span
+		transport = wrapTransport(transport, requestLogMiddleware{serviceName: b.ServiceName})
+	}
 	transport = wrapTransport(transport, newTraceMiddleware(b.ServiceName, b.DisableRequestSpan, b.DisableTraceHeaders))
 	if !b.DisableRecovery {
 		transport = wrapTransport(transport, recoveryMiddleware{})
 	}
+	if b.AppendRuntimeUserAgent {
+		transport = wrapTransport(transport, runtimeUserAgentMiddleware{})
+	}
+	transport = wrapTransport(transport, newOAuth2Middleware(b.OAuth2ClientCredentials, tokenTransport))
 	transport = wrapTransport(transport, b.Middlewares...)
 
 	return refreshingclient.NewRefreshableHTTPClient(transport, b.Timeout), nil
 }
 
+// newRefreshableTransport returns the transport built from the TLS, dialer and transport params, which is rebuilt
+// whenever they are updated.
+func (b *httpClientBuilder) newRefreshableTransport(ctx context.Context) (http.RoundTripper, error) {
+	var tlsProvider refreshingclient.TLSProvider
+	switch {
+	case b.RefreshableTLSConfig != nil:
+		if tlsParamsConfigureCertificates(b.TransportParams.CurrentTransportParams().TLS) {
+			return nil, werror.ErrorWithContextParams(ctx, "httpclient: WithRefreshableTLSConfig can not be used with CA files or client certificates configured by TLS params")
+		}
+		transportParams := b.TransportParams
+		tlsProvider = refreshingclient.ConfigureTLSConfig(refreshingclient.NewRefreshingTLSConfigProvider(b.RefreshableTLSConfig), func(tlsConfig *tls.Config) *tls.Config {
+			if transportParams.CurrentTransportParams().TLS.InsecureSkipVerify {
+				tlsConfig.InsecureSkipVerify = true
+			}
+			return tlsConfig
+		})
+	case b.TLSConfig != nil:
+		if tlsParamsConfigureCertificates(b.TransportParams.CurrentTransportParams().TLS) {
+			return nil, werror.ErrorWithContextParams(ctx, "httpclient: WithTLSConfig can not be used with CA files or client certificates configured by TLS params")
+		}
+		tlsProvider = refreshingclient.NewStaticTLSConfigProvider(b.TLSConfig)
+	default:
+		refreshableProvider, err := refreshingclient.NewRefreshableTLSConfig(ctx, b.TransportParams.TLS(), b.onInvalidTLSConfig)
+		if err != nil {
+			return nil, err
+		}
+		tlsProvider = refreshableProvider
+	}
+	if mappers := b.TLSConfigMappers; len(mappers) > 0 {
+		tlsProvider = refreshingclient.ConfigureTLSConfig(tlsProvider, func(tlsConfig *tls.Config) *tls.Config {
+			for _, mapFn := range mappers {
+				tlsConfig = mapFn(tlsConfig)
+			}
+			return tlsConfig
+		})
+	}
+
+	dialer := refreshingclient.NewRefreshableDialer(ctx, b.DialerParams)
+	return refreshingclient.NewRefreshableTransport(ctx, b.TransportParams, tlsProvider, dialer), nil
+}
+
+// tlsParamsConfigureCertificates returns true if p configures certificates which would be ignored in favor of a
+// *tls.Config provided by WithTLSConfig. InsecureSkipVerify is not considered, as WithTLSInsecureSkipVerify applies to
+// both.
+func tlsParamsConfigureCertificates(p refreshingclient.TLSParams) bool {
+	return len(p.CAFiles) != 0 || p.CertFile != "" || p.KeyFile != "" || p.PKCS12File != "" || p.AppendSystemCAs
+}
+
+// onInvalidTLSConfig records that the transport was rebuilt with the previous TLS config because the updated
+// TLS config was invalid.
+func (b *httpClientBuilder) onInvalidTLSConfig(ctx context.Context, err error) {
+	serviceNameTag := metrics.NewTagWithFallbackValue(MetricTagServiceName, b.ServiceName.CurrentString(), "unknown")
+	metrics.FromContext(ctx).Counter(MetricTLSConfigInvalid, serviceNameTag).Inc(1)
+	if b.TLSConfigErrorCallback != nil {
+		b.TLSConfigErrorCallback(ctx, err)
+	}
+}
+
 // NewClient returns a configured client ready for use.
 // We apply "sane defaults" before applying the provided params.
 func NewClient(params ...ClientParam) (Client, error) {
@@ -137,6 +268,22 @@ func NewClientFromRefreshableConfig(ctx context.Context, config RefreshableClien
 	return newClient(ctx, b, params...)
 }
 
+// NewClientFromConfig returns a client configured by the ClientConfig values of config, such as a refreshable
+// of configuration unmarshalled from YAML. The client is updated as config refreshes. If an updated configuration
+// is invalid, the error is logged and the client continues to use the last valid configuration.
+// The initial configuration must be valid.
+func NewClientFromConfig(ctx context.Context, config refreshable.Refreshable, params ...ClientParam) (Client, error) {
+	b := newClientBuilder()
+	if err := newClientBuilderFromRefreshableConfig(ctx, NewRefreshingClientConfig(config), b, func(err error) {
+		if err != nil {
+			svc1log.FromContext(ctx).Warn("Invalid client configuration update, using last valid configuration.", svc1log.Stacktrace(err))
+		}
+	}); err != nil {
+		return nil, err
+	}
+	return newClient(ctx, b, params...)
+}
+
 func newClient(ctx context.Context, b *clientBuilder, params ...ClientParam) (Client, error) {
 	for _, p := range params {
 		if p == nil {
@@ -155,9 +302,12 @@ func newClient(ctx context.Context, b *clientBuilder, params ...ClientParam) (Cl
 
 	var edm Middleware
 	if b.ErrorDecoder != nil {
-		edm = errorDecoderMiddleware{errorDecoder: b.ErrorDecoder}
+		edm = errorDecoderMiddleware{errorDecoder: b.ErrorDecoder, drainLimit: b.ErrorBodyDrainLimit}
 	}
 
+	// clients derived by WithOptions start from the configuration before it is consumed below.
+	parent := b.clone()
+
 	middleware := b.HTTP.Middlewares
 	b.HTTP.Middlewares = nil
 
@@ -166,6 +316,12 @@ func newClient(ctx context.Context, b *clientBuilder, params ...ClientParam) (Cl
 		return nil, err
 	}
 
+	// replayed clients do not send requests, so there are no connections to warm up.
+	if b.ConnectionWarmup > 0 && b.HTTP.InteractionReplayer == nil {
+		go warmUpConnections(ctx, b.HTTP.ServiceName.CurrentString(), b.HTTP.builtTransport,
+			b.URIs.CurrentStringSlice(), b.ConnectionWarmup, b.HTTP.Timeout.CurrentDuration())
+	}
+
 	var recovery Middleware
 	if !b.HTTP.DisableRecovery {
 		recovery = recoveryMiddleware{}
@@ -182,10 +338,26 @@ func newClient(ctx context.Context, b *clientBuilder, params ...ClientParam) (Cl
 		uriScorer:              uriScorer,
 		maxAttempts:            b.MaxAttempts,
 		backoffOptions:         b.RetryParams,
+		backoffStrategy:        b.BackoffStrategy,
 		middlewares:            middleware,
 		errorDecoderMiddleware: edm,
+		errorBodyDrainLimit:    b.ErrorBodyDrainLimit,
 		recoveryMiddleware:     recovery,
 		bufferPool:             b.BytesBufferPool,
+		responseBufferPool:     b.ResponseBufferPool,
+
+		cacheMiddleware:            newResponseCacheMiddleware(b.ResponseCache, b.ResponseCacheMaxBodyBytes),
+		rateLimiter:                b.RateLimiter,
+		retryBudget:                b.RetryBudget,
+		attemptCallback:            b.AttemptCallback,
+		requestCompression:         b.RequestCompression,
+		requestCompressionMinBytes: b.RequestCompressionMinBytes,
+		maxRequestBodyBytes:        b.MaxRequestBodyBytes,
+		endpointConfigs:            b.EndpointConfigs,
+
+		connectionErrorRetryPredicate: b.ConnectionErrorRetryPredicate,
+		info:                          b.newClientInfoFunc(httpClient),
+		derive:                        newClientDeriveFunc(ctx, parent, b.HTTP.builtTransport),
 	}, nil
 }
 
@@ -248,6 +420,11 @@ func newClientBuilder() *clientBuilder {
 		BytesBufferPool: nil,
 		ErrorDecoder:    restErrorDecoder{},
 		MaxAttempts:     nil,
+
+		ErrorBodyDrainLimit:       defaultErrorBodyDrainLimit,
+		ResponseCacheMaxBodyBytes: defaultResponseCacheMaxBodyBytes,
+
+		ConnectionErrorRetryPredicate: IsRetryableConnectionError,
 		RetryParams: refreshingclient.NewRefreshingRetryParams(refreshable.NewDefaultRefreshable(refreshingclient.RetryParams{
 			InitialBackoff: defaultInitialBackoff,
 			MaxBackoff:     defaultMaxBackoff,
@@ -279,10 +456,23 @@ func newClientBuilderFromRefreshableConfig(ctx context.Context, config Refreshab
 		}))
 	b.HTTP.Middlewares = append(b.HTTP.Middlewares,
 		newAuthTokenMiddlewareFromRefreshable(validParams.APIToken()),
-		newBasicAuthMiddlewareFromRefreshable(validParams.BasicAuth()))
+		newBasicAuthMiddlewareFromRefreshable(validParams.BasicAuth()),
+		newUserAgentMiddlewareFromRefreshable(validParams.UserAgent()))
+	b.HTTP.ConfigUserAgent = validParams.UserAgent()
 
 	b.URIs = validParams.URIs()
 	b.MaxAttempts = validParams.MaxAttempts()
 	b.RetryParams = validParams.Retry()
 	return nil
 }
+
+// newUserAgentMiddlewareFromRefreshable sets the configured User-Agent header unless one is already set,
+// so the User-Agent provided by params takes precedence over configuration.
+func newUserAgentMiddlewareFromRefreshable(userAgent refreshable.StringPtr) Middleware {
+	return MiddlewareFunc(func(req *http.Request, next http.RoundTripper) (*http.Response, error) {
+		if ua := userAgent.CurrentStringPtr(); ua != nil && *ua != "" && req.Header.Get("User-Agent") == "" {
+			req.Header.Set("User-Agent", *ua)
+		}
+		return next.RoundTrip(req)
+	})
+}
@@ -16,6 +16,7 @@ package httpclient_test
 
 import (
 	"context"
+	"crypto/tls"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -57,9 +58,74 @@ func TestWrapTransport(t *testing.T) {
 	assert.Equal(t, expected, tracker)
 }
 
+func TestMiddlewareOrderRelativeToBuiltins(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		rw.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	var invoked bool
+	client, err := httpclient.NewClient(
+		httpclient.WithMiddleware(httpclient.MiddlewareFunc(func(req *http.Request, next http.RoundTripper) (*http.Response, error) {
+			invoked = true
+			// request body is set before custom middleware
+			require.NotNil(t, req.GetBody)
+			assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+
+			// error decoder runs before custom middleware sees the response
+			resp, err := next.RoundTrip(req)
+			assert.Nil(t, resp)
+			code, ok := httpclient.StatusCodeFromError(err)
+			assert.True(t, ok)
+			assert.Equal(t, http.StatusInternalServerError, code)
+			return resp, err
+		})),
+		httpclient.WithBaseURLs([]string{server.URL}),
+		httpclient.WithMaxRetries(0),
+	)
+	require.NoError(t, err)
+
+	_, err = client.Post(context.Background(), httpclient.WithJSONRequest(map[string]string{"key": "value"}))
+	require.Error(t, err)
+	assert.True(t, invoked)
+}
+
 func TestNewHTTPClientWithoutURIs(t *testing.T) {
 	cfg := httpclient.ClientConfig{ServiceName: "test-service"}
 	c, err := httpclient.NewHTTPClientFromRefreshableConfig(context.Background(), httpclient.NewRefreshingClientConfig(refreshable.NewDefaultRefreshable(cfg)))
 	require.NoError(t, err)
 	require.NotNil(t, c.CurrentHTTPClient())
 }
+
+func TestNewHTTPClientWithTLSConfigAndTLSParams(t *testing.T) {
+	for _, test := range []struct {
+		Name        string
+		Security    httpclient.SecurityConfig
+		ExpectedErr string
+	}{
+		{Name: "no TLS params"},
+		{Name: "insecure skip verify", Security: httpclient.SecurityConfig{InsecureSkipVerify: &[]bool{true}[0]}},
+		{
+			Name:        "CA files",
+			Security:    httpclient.SecurityConfig{CAFiles: []string{"ca.pem"}},
+			ExpectedErr: "httpclient: WithTLSConfig can not be used with CA files or client certificates configured by TLS params",
+		},
+		{
+			Name:        "client certificate",
+			Security:    httpclient.SecurityConfig{CertFile: "cert.pem", KeyFile: "key.pem"},
+			ExpectedErr: "httpclient: WithTLSConfig can not be used with CA files or client certificates configured by TLS params",
+		},
+	} {
+		t.Run(test.Name, func(t *testing.T) {
+			cfg := httpclient.ClientConfig{ServiceName: "test-service", Security: test.Security}
+			_, err := httpclient.NewHTTPClientFromRefreshableConfig(context.Background(),
+				httpclient.NewRefreshingClientConfig(refreshable.NewDefaultRefreshable(cfg)),
+				httpclient.WithTLSConfig(&tls.Config{}))
+			if test.ExpectedErr != "" {
+				assert.EqualError(t, err, test.ExpectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright (c) 2024 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/palantir/conjure-go-runtime/v2/conjure-go-client/httpclient/internal/refreshingclient"
+)
+
+// ClientInfo is a snapshot of the effective configuration of a client, e.g. to log at startup or when debugging.
+// It does not contain secrets such as tokens, passwords or private keys.
+type ClientInfo struct {
+	ServiceName string
+	// BaseURLs are the configured base URLs, with any password in their userinfo redacted.
+	BaseURLs    []string
+	HTTPTimeout time.Duration
+	// MaxAttempts is the maximum number of attempts of each request. If nil, it is twice the number of BaseURLs.
+	// If 0, the number of attempts is unlimited.
+	MaxAttempts    *int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// UserAgent is the User-Agent header set by WithUserAgent or configuration, if any, including the product token
+	// appended by WithAppendRuntimeUserAgent.
+	UserAgent string
+	TLS       TLSInfo
+}
+
+// TLSInfo summarizes the TLS configuration of a client.
+type TLSInfo struct {
+	// CAFiles, CertFile, KeyFile and PKCS12File are the paths configured by TLS params, if any.
+	CAFiles         []string
+	CertFile        string
+	KeyFile         string
+	PKCS12File      string
+	AppendSystemCAs bool
+
+	InsecureSkipVerify bool
+	ServerName         string
+	MinVersion         string
+	// CustomRootCAs is true if the server certificate is verified against configured roots rather than the system roots.
+	CustomRootCAs bool
+	// ClientCertificate is true if the client presents a certificate when requested by the server.
+	ClientCertificate bool
+}
+
+// ClientInfoProvider is implemented by the Clients returned by NewClient and the other client constructors.
+type ClientInfoProvider interface {
+	ClientInfo() ClientInfo
+}
+
+// ClientInfo returns a snapshot of the client's current configuration.
+func (c *clientImpl) ClientInfo() ClientInfo {
+	return c.info()
+}
+
+// newClientInfoFunc returns a function which builds the ClientInfo of a client built from b with httpClient.
+func (b *clientBuilder) newClientInfoFunc(httpClient RefreshableHTTPClient) func() ClientInfo {
+	serviceName, uris, maxAttempts, retryParams := b.HTTP.ServiceName, b.URIs, b.MaxAttempts, b.RetryParams
+	userAgent, configUserAgent, transportParams := b.HTTP.UserAgent, b.HTTP.ConfigUserAgent, b.HTTP.TransportParams
+	appendRuntime := b.HTTP.AppendRuntimeUserAgent
+	return func() ClientInfo {
+		currentHTTPClient := httpClient.CurrentHTTPClient()
+		info := ClientInfo{
+			ServiceName:    serviceName.CurrentString(),
+			BaseURLs:       redactedURLs(uris.CurrentStringSlice()),
+			HTTPTimeout:    currentHTTPClient.Timeout,
+			InitialBackoff: retryParams.InitialBackoff().CurrentDuration(),
+			MaxBackoff:     retryParams.MaxBackoff().CurrentDuration(),
+			UserAgent:      userAgent,
+			TLS:            newTLSInfo(transportParams.CurrentTransportParams().TLS, currentHTTPClient.Transport),
+		}
+		if maxAttempts != nil {
+			if current := maxAttempts.CurrentIntPtr(); current != nil {
+				attempts := *current
+				info.MaxAttempts = &attempts
+			}
+		}
+		if info.UserAgent == "" && configUserAgent != nil {
+			if current := configUserAgent.CurrentStringPtr(); current != nil {
+				info.UserAgent = *current
+			}
+		}
+		if appendRuntime {
+			info.UserAgent = appendRuntimeUserAgent(info.UserAgent)
+		}
+		return info
+	}
+}
+
+// redactedURLs returns uris with the password of any userinfo redacted. URIs which can not be parsed are
+// returned unchanged, as the client fails to send requests to them.
+func redactedURLs(uris []string) []string {
+	redacted := make([]string, len(uris))
+	for i, uri := range uris {
+		redacted[i] = uri
+		if u, err := url.Parse(uri); err == nil {
+			redacted[i] = u.Redacted()
+		}
+	}
+	return redacted
+}
+
+func newTLSInfo(p refreshingclient.TLSParams, rt http.RoundTripper) TLSInfo {
+	info := TLSInfo{
+		CAFiles:         slices.Clone(p.CAFiles),
+		CertFile:        p.CertFile,
+		KeyFile:         p.KeyFile,
+		PKCS12File:      p.PKCS12File,
+		AppendSystemCAs: p.AppendSystemCAs,
+	}
+	if transport := unwrapHTTPTransport(rt); transport != nil && transport.TLSClientConfig != nil {
+		tlsConfig := transport.TLSClientConfig
+		info.InsecureSkipVerify = tlsConfig.InsecureSkipVerify
+		info.ServerName = tlsConfig.ServerName
+		if tlsConfig.MinVersion != 0 {
+			info.MinVersion = tls.VersionName(tlsConfig.MinVersion)
+		}
+		info.CustomRootCAs = tlsConfig.RootCAs != nil
+		info.ClientCertificate = len(tlsConfig.Certificates) != 0 || tlsConfig.GetClientCertificate != nil
+	}
+	return info
+}
+
+// unwrapHTTPTransport returns the *http.Transport underlying rt, or nil if rt is not built by this package.
+func unwrapHTTPTransport(rt http.RoundTripper) *http.Transport {
+	for {
+		switch v := rt.(type) {
+		case *refreshingclient.RefreshableTransport:
+			rt = v.Current().(http.RoundTripper)
+		case *wrappedClient:
+			rt = v.baseTransport
+		case *http.Transport:
+			return v
+		default:
+			return nil
+		}
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright (c) 2024 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient
+
+import (
+	"context"
+	"maps"
+	"net/http"
+	"reflect"
+	"slices"
+)
+
+// DerivableClient is implemented by the Clients returned by NewClient and the other client constructors.
+type DerivableClient interface {
+	Client
+	// WithOptions returns a new client configured like this one with params applied on top of its configuration,
+	// e.g. to use a different timeout for a subsystem. The configuration is copied, so this client is not modified.
+	//
+	// The new client shares the transport, and so the connection pool, of this client unless params configure
+	// the transport, e.g. TLS, proxy, dialer or HTTP/2 params, in which case a new transport is built. Objects
+	// provided by params, such as buffer pools, response caches, rate limiters, retry budgets and cookie jars, are
+	// shared by reference. The state of middlewares, such as cached OAuth2 tokens and URI scores, is not shared.
+	WithOptions(params ...ClientParam) (Client, error)
+}
+
+// WithOptions returns a new client derived from this one with params applied on top of its configuration.
+func (c *clientImpl) WithOptions(params ...ClientParam) (Client, error) {
+	return c.derive(params)
+}
+
+// newClientDeriveFunc returns a function which builds a client from a copy of parent with params applied,
+// sharing transport if the params do not configure the transport.
+func newClientDeriveFunc(ctx context.Context, parent *clientBuilder, transport http.RoundTripper) func(params []ClientParam) (Client, error) {
+	return func(params []ClientParam) (Client, error) {
+		b := parent.clone()
+		b.HTTP.ParentTransport = &parentTransport{transport: transport, builder: parent.HTTP}
+		return newClient(ctx, b, params...)
+	}
+}
+
+// parentTransport is the transport of a client and the configuration it was built from.
+type parentTransport struct {
+	transport http.RoundTripper
+	builder   *httpClientBuilder
+}
+
+// sharedWith returns true if the transport is configured the same way by b, so that it can be shared by a client
+// built from b.
+func (p *parentTransport) sharedWith(b *httpClientBuilder) bool {
+	parent := p.builder
+	return parent.BaseTransport == nil &&
+		sameValue(parent.DialerParams, b.DialerParams) &&
+		sameValue(parent.TransportParams, b.TransportParams) &&
+		parent.TLSConfig == b.TLSConfig &&
+		sameValue(parent.RefreshableTLSConfig, b.RefreshableTLSConfig) &&
+		len(parent.TLSConfigMappers) == len(b.TLSConfigMappers)
+}
+
+// sameValue returns true if a and b are equal comparable values. Values of types which are not comparable are
+// never considered the same.
+func sameValue(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return reflect.TypeOf(a) == reflect.TypeOf(b) && reflect.TypeOf(a).Comparable() && a == b
+}
+
+// clone returns a copy of b which can be modified by params without modifying b.
+func (b *clientBuilder) clone() *clientBuilder {
+	clone := *b
+	clone.HTTP = b.HTTP.clone()
+	clone.EndpointConfigs = endpointConfigs{
+		byName:       maps.Clone(b.EndpointConfigs.byName),
+		byPathPrefix: maps.Clone(b.EndpointConfigs.byPathPrefix),
+	}
+	return &clone
+}
+
+// clone returns a copy of b which can be modified by params without modifying b.
+func (b *httpClientBuilder) clone() *httpClientBuilder {
+	clone := *b
+	// clip the slices so that appending to them copies them.
+	clone.Middlewares = slices.Clip(b.Middlewares)
+	clone.MetricsTagProviders = slices.Clip(b.MetricsTagProviders)
+	clone.TLSConfigMappers = slices.Clip(b.TLSConfigMappers)
+	clone.builtTransport = nil
+	return &clone
+}
@@ -17,12 +17,17 @@ package httpclient
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
 	"net/http"
+	"net/http/cookiejar"
 	"net/url"
 	"time"
 
 	"github.com/palantir/conjure-go-runtime/v2/conjure-go-client/httpclient/internal"
 	"github.com/palantir/conjure-go-runtime/v2/conjure-go-client/httpclient/internal/refreshingclient"
+	"github.com/palantir/conjure-go-runtime/v2/conjure-go-contract/errors"
 	"github.com/palantir/pkg/bytesbuffers"
 	"github.com/palantir/pkg/refreshable"
 	werror "github.com/palantir/witchcraft-go-error"
@@ -115,6 +120,14 @@ func WithServiceName(serviceName string) ClientOrHTTPClientParam {
 // WithMiddleware will be invoked for custom HTTP behavior after the
 // underlying transport is initialized. Each handler added "wraps" the previous
 // round trip, so it will see the request first and the response last.
+//
+// When used to build a Client, middlewares run in the following order relative to the built-in middlewares:
+//   - the request body has already been encoded and set on the request, so req.GetBody may be used to read it.
+//   - the error decoder has already handled the response, so responses handled by the ErrorDecoder
+//     (by default, status codes >= 307) are returned as a nil response and non-nil error.
+//   - metrics, tracing, and URI scoring middlewares are invoked after (inside) all custom middlewares.
+//
+// Each request attempt, including retries, invokes the middleware chain again.
 func WithMiddleware(h Middleware) ClientOrHTTPClientParam {
 	return clientOrHTTPClientParamFunc(func(b *httpClientBuilder) error {
 		b.Middlewares = append(b.Middlewares, h)
@@ -122,6 +135,19 @@ func WithMiddleware(h Middleware) ClientOrHTTPClientParam {
 	})
 }
 
+// WithClientTrace calls callback after each request attempt with the time spent resolving DNS, connecting,
+// performing the TLS handshake, and waiting for the first response byte. Retried attempts are reported
+// individually. This helps distinguish network latency from server latency.
+func WithClientTrace(callback ClientTraceFunc) ClientOrHTTPClientParam {
+	return clientOrHTTPClientParamFunc(func(b *httpClientBuilder) error {
+		if callback == nil {
+			return werror.Error("callback can not be nil")
+		}
+		b.Middlewares = append(b.Middlewares, clientTraceMiddleware{callback: callback})
+		return nil
+	})
+}
+
 func WithAddHeader(key, value string) ClientOrHTTPClientParam {
 	return WithMiddleware(MiddlewareFunc(func(req *http.Request, next http.RoundTripper) (*http.Response, error) {
 		req.Header.Add(key, value)
@@ -150,7 +176,70 @@ func WithAuthTokenProvider(provideToken TokenProvider) ClientOrHTTPClientParam {
 
 // WithUserAgent sets the User-Agent header.
 func WithUserAgent(userAgent string) ClientOrHTTPClientParam {
-	return WithSetHeader("User-Agent", userAgent)
+	return clientOrHTTPClientParamFunc(func(b *httpClientBuilder) error {
+		b.UserAgent = userAgent
+		return WithSetHeader("User-Agent", userAgent).applyHTTPClient(b)
+	})
+}
+
+// WithUserAgentParts sets the User-Agent header to the provided products followed by the product token of this
+// library, e.g. "my-service/1.2.3 (linux) conjure-go-runtime/2.80.0". Use WithUserAgent to set the header verbatim.
+func WithUserAgentParts(products ...UserAgentProduct) ClientOrHTTPClientParam {
+	return clientOrHTTPClientParamFunc(func(b *httpClientBuilder) error {
+		userAgent, err := formatUserAgent(products)
+		if err != nil {
+			return err
+		}
+		return WithUserAgent(userAgent).applyHTTPClient(b)
+	})
+}
+
+// WithOAuth2ClientCredentials sets the Authorization header of each request to a bearer token obtained from
+// tokenURL using the OAuth2 client credentials grant with the provided client ID, client secret and scopes.
+// The token is cached and refreshed before it expires. If a request is rejected with 401 Unauthorized, the token
+// is refreshed and the request is retried once, unless its body can not be replayed.
+//
+// Token requests use the client's TLS, proxy and dial configuration, but not its middlewares. If a token can not
+// be obtained, requests fail with an error wrapping the cause. The Authorization header set by this param takes
+// precedence over any other Authorization header.
+func WithOAuth2ClientCredentials(tokenURL, clientID, clientSecret string, scopes []string) ClientOrHTTPClientParam {
+	return clientOrHTTPClientParamFunc(func(b *httpClientBuilder) error {
+		if u, err := url.Parse(tokenURL); err != nil || u.Scheme == "" || u.Host == "" {
+			return werror.Error("httpclient: OAuth2 token URL must be an absolute URL")
+		}
+		b.OAuth2ClientCredentials = &oauth2ClientCredentials{
+			tokenURL:     tokenURL,
+			clientID:     clientID,
+			clientSecret: clientSecret,
+			scopes:       scopes,
+		}
+		return nil
+	})
+}
+
+// WithAppendRuntimeUserAgent appends the product token of this library, e.g. "conjure-go-runtime/2.80.0", to the
+// User-Agent header of each request, after any value set by params, configuration, or the request itself.
+// The token is not appended if the header already contains it, such as when set by WithUserAgentParts.
+func WithAppendRuntimeUserAgent() ClientOrHTTPClientParam {
+	return clientOrHTTPClientParamFunc(func(b *httpClientBuilder) error {
+		b.AppendRuntimeUserAgent = true
+		return nil
+	})
+}
+
+// WithContextHeaderPropagation sets a header of each request to the value stored in the request's context under
+// the key's ContextKey, e.g. to forward a tenant ID carried by the context. Values which are not strings are
+// formatted with their String method or fmt.Sprint. Headers are not set if the context has no value for the key,
+// or if the request already sets the header.
+func WithContextHeaderPropagation(keys ...PropagationKey) ClientOrHTTPClientParam {
+	return clientOrHTTPClientParamFunc(func(b *httpClientBuilder) error {
+		middleware, err := newHeaderPropagationMiddleware(keys)
+		if err != nil {
+			return err
+		}
+		b.Middlewares = append(b.Middlewares, middleware)
+		return nil
+	})
 }
 
 // WithOverrideRequestHost overrides the request Host from the default URL.Host
@@ -161,6 +250,28 @@ func WithOverrideRequestHost(host string) ClientOrHTTPClientParam {
 	}))
 }
 
+// WithCookieJar sets the cookie jar used to store cookies from responses and add them to subsequent requests.
+// Cookies set on redirect responses, including 307 and 308 responses handled by the client's retry logic, are
+// stored in the jar.
+func WithCookieJar(jar http.CookieJar) ClientOrHTTPClientParam {
+	return clientOrHTTPClientParamFunc(func(b *httpClientBuilder) error {
+		b.CookieJar = jar
+		return nil
+	})
+}
+
+// WithInMemoryCookieJar sets a new in-memory cookie jar on the client. See WithCookieJar for details.
+func WithInMemoryCookieJar() ClientOrHTTPClientParam {
+	return clientOrHTTPClientParamFunc(func(b *httpClientBuilder) error {
+		jar, err := cookiejar.New(nil)
+		if err != nil {
+			return werror.Wrap(err, "failed to create cookie jar")
+		}
+		b.CookieJar = jar
+		return nil
+	})
+}
+
 // WithMetrics enables the "client.response" metric. See MetricsMiddleware for details.
 // The serviceName will appear as the "service-name" tag.
 func WithMetrics(tagProviders ...TagsProvider) ClientOrHTTPClientParam {
@@ -171,6 +282,27 @@ func WithMetrics(tagProviders ...TagsProvider) ClientOrHTTPClientParam {
 	})
 }
 
+// WithObservability enables the client's metrics, tracing and, if config.LogRequests is true, request logging
+// middleware together, so that they describe each request attempt consistently:
+//   - The client.response metric is tagged with the endpoint name, set by WithEndpointName or WithRPCMethodName, and
+//     the host of the request, in addition to the default tags and config.TagProviders.
+//   - Request logs have the same endpoint and host params as the metric, along with the service name, method,
+//     status code and duration, and the traceId and spanId of the request's span, if any, to find its trace.
+//   - Trace headers are propagated, and a span is created for requests with an RPC method name, which is the
+//     span logged by the request log. WithObservability reverts WithDisableTracing and
+//     WithDisableTraceHeaderPropagation.
+func WithObservability(config ObservabilityConfig) ClientOrHTTPClientParam {
+	return clientOrHTTPClientParamFunc(func(b *httpClientBuilder) error {
+		b.DisableMetrics = refreshable.NewBool(refreshable.NewDefaultRefreshable(false))
+		b.MetricsTagProviders = append(b.MetricsTagProviders, TagsProviderFunc(tagEndpointAndHost))
+		b.MetricsTagProviders = append(b.MetricsTagProviders, config.TagProviders...)
+		b.DisableRequestSpan = false
+		b.DisableTraceHeaders = false
+		b.LogRequests = config.LogRequests
+		return nil
+	})
+}
+
 // WithBytesBufferPool stores a bytes buffer pool on the client for use in encoding request bodies.
 // This prevents allocating a new byte buffer for every request.
 func WithBytesBufferPool(pool bytesbuffers.Pool) ClientParam {
@@ -180,6 +312,198 @@ func WithBytesBufferPool(pool bytesbuffers.Pool) ClientParam {
 	})
 }
 
+// WithResponseBufferPool reads response bodies into a buffer from pool before they are decoded, rather than
+// allocating the decoder's read buffer for each response. Response bodies are read into the buffer in full, so the
+// pool is best suited to clients with bounded responses. Bodies decoded by codecs.Binary or into an io.Writer are
+// streamed as usual, and buffers grown beyond 1MiB are not returned to the pool.
+func WithResponseBufferPool(pool bytesbuffers.Pool) ClientParam {
+	return clientParamFunc(func(b *clientBuilder) error {
+		b.ResponseBufferPool = pool
+		return nil
+	})
+}
+
+// WithResponseCache caches successful GET responses which have an ETag header, keyed by request URL and Accept
+// header. Subsequent GET requests for a cached response set the If-None-Match header, and a 304 Not Modified
+// response is resolved to the cached response, which is then decoded as usual. A cached response is only used for
+// requests with the same values of the headers named by its Vary header.
+//
+// Responses with "Cache-Control: no-store" or "Vary: *" are not cached, nor are responses of requests which read
+// the response body as a stream, e.g. using WithRawResponseBody or WithEventStreamHandler. Cached response bodies
+// are read fully into memory, so bodies larger than the limit set by WithResponseCacheMaxBodyBytes are not cached.
+func WithResponseCache(cache ResponseCache) ClientParam {
+	return clientParamFunc(func(b *clientBuilder) error {
+		b.ResponseCache = cache
+		return nil
+	})
+}
+
+// WithResponseCacheMaxBodyBytes sets the size of the largest response body cached by WithResponseCache.
+// Defaults to 1MiB.
+func WithResponseCacheMaxBodyBytes(maxBytes int64) ClientParam {
+	return clientParamFunc(func(b *clientBuilder) error {
+		if maxBytes < 0 {
+			return werror.Error("httpclient: response cache max body bytes must not be negative",
+				werror.SafeParam("maxBytes", maxBytes))
+		}
+		b.ResponseCacheMaxBodyBytes = maxBytes
+		return nil
+	})
+}
+
+// RateLimiter throttles outbound requests. It is satisfied by *rate.Limiter from golang.org/x/time/rate.
+type RateLimiter interface {
+	// Wait blocks until a request may be sent or returns an error if the context is done first.
+	Wait(ctx context.Context) error
+}
+
+// WithRateLimiter sets a limiter that each request attempt, including retries, waits on before being sent.
+// If the request context is cancelled while waiting, the request fails without being sent.
+// Requests with a priority set by WithRequestPriority are prioritized: high priority requests do not wait, and low
+// priority requests fail fast with ErrRequestShed if the limiter also implements Allow() bool, as *rate.Limiter
+// does, and does not allow them immediately.
+func WithRateLimiter(limiter RateLimiter) ClientParam {
+	return clientParamFunc(func(b *clientBuilder) error {
+		b.RateLimiter = limiter
+		return nil
+	})
+}
+
+// AttemptInfo describes a single attempt of a request. It is passed to the callback set by WithAttemptCallback.
+type AttemptInfo struct {
+	// Attempt is the index of the attempt, starting at 0 for the first attempt of a request.
+	Attempt int
+	// BaseURL is the base URL the attempt was sent to, or the full URL if the attempt followed a redirect.
+	BaseURL string
+	// StatusCode is the status code of the response, including responses converted to errors by the ErrorDecoder.
+	// It is 0 if no response was received.
+	StatusCode int
+	// Err is the error of the attempt, if any.
+	Err error
+	// Duration is the time taken by the attempt, excluding the backoff before it.
+	Duration time.Duration
+}
+
+// WithAttemptCallback calls callback after each attempt of a request, including retries, whether or not it succeeded.
+// The callback is called synchronously before the next attempt, so it should not block.
+func WithAttemptCallback(callback func(info AttemptInfo)) ClientParam {
+	return clientParamFunc(func(b *clientBuilder) error {
+		b.AttemptCallback = callback
+		return nil
+	})
+}
+
+// WithRetryBudget limits retries across all requests made by the client to ratio retries per request,
+// plus minPerSec retries per second regardless of request volume. When the budget is exhausted, the
+// error of the failed attempt is returned instead of retrying. Redirects to other URIs do not consume the budget.
+// The current budget is reported by the "client.retry.budget" gauge.
+func WithRetryBudget(ratio, minPerSec float64) ClientParam {
+	return clientParamFunc(func(b *clientBuilder) error {
+		if ratio < 0 || minPerSec < 0 {
+			return werror.Error("httpclient: retry budget ratio and minimum per second must not be negative",
+				werror.SafeParam("ratio", ratio),
+				werror.SafeParam("minPerSec", minPerSec))
+		}
+		b.RetryBudget = internal.NewRetryBudget(ratio, minPerSec)
+		return nil
+	})
+}
+
+// WithConnectionErrorRetryPredicate sets the predicate which decides whether a request is retried after an attempt
+// fails with a *ConnectionError. It is called with the request of the attempt and the error wrapped by the
+// *ConnectionError. The default is IsRetryableConnectionError, which predicate may call to extend it. Requests with
+// bodies that can not be replayed and requests using WithDisableRetry are not retried regardless of predicate.
+func WithConnectionErrorRetryPredicate(predicate func(req *http.Request, err error) bool) ClientParam {
+	return clientParamFunc(func(b *clientBuilder) error {
+		if predicate == nil {
+			return werror.Error("httpclient: connection error retry predicate can not be nil")
+		}
+		b.ConnectionErrorRetryPredicate = predicate
+		return nil
+	})
+}
+
+// WithRequestSigner signs each attempt of every request with signer after its headers are set and its body is
+// encoded and compressed, immediately before it is sent. The signature covers the headers set by the client's
+// middlewares, such as the Authorization header set by WithOAuth2ClientCredentials, trace headers, cookies and the
+// User-Agent. Only headers which net/http's transport adds while writing the request, such as Content-Length,
+// Accept-Encoding, Transfer-Encoding and its default User-Agent, are not visible to the signer. The signer is given the content of the
+// request body so that it can include a hash of it in the signature, so requests with bodies that can not be
+// replayed, such as RequestBodyStreamOnce, fail with an error.
+func WithRequestSigner(signer RequestSigner) ClientParam {
+	return clientParamFunc(func(b *clientBuilder) error {
+		if signer == nil {
+			return werror.Error("httpclient: request signer can not be nil")
+		}
+		b.HTTP.RequestSigner = signer
+		return nil
+	})
+}
+
+// WithRequestCompression compresses request bodies of at least minSizeBytes with the provided compression
+// and sets the Content-Encoding header. Bodies are not compressed if their size is unknown (e.g. RequestBodyStreamOnce),
+// if they already have a Content-Encoding, or if their Content-Type is an already-compressed format such as
+// images or archives. Use WithForceRequestCompression to compress a request's body regardless of its size or type.
+func WithRequestCompression(compression RequestCompression, minSizeBytes int64) ClientParam {
+	return clientParamFunc(func(b *clientBuilder) error {
+		switch compression {
+		case RequestCompressionGZIP, RequestCompressionDeflate:
+		default:
+			return werror.Error("httpclient: unsupported request compression",
+				werror.SafeParam("compression", compression))
+		}
+		b.RequestCompression = compression
+		b.RequestCompressionMinBytes = minSizeBytes
+		return nil
+	})
+}
+
+// WithMaxRequestBodyBytes fails requests whose body is larger than n bytes with a *RequestBodyTooLargeError,
+// which is not retried. Bodies of known size, such as encoded objects, fail before they are sent, while bodies of
+// unknown size fail once more than n bytes have been read. The limit applies to the body before compression by
+// WithRequestCompression. If unset or 0, request bodies are not limited.
+func WithMaxRequestBodyBytes(n int64) ClientParam {
+	return clientParamFunc(func(b *clientBuilder) error {
+		if n < 0 {
+			return werror.Error("httpclient: max request body bytes must not be negative",
+				werror.SafeParam("maxRequestBodyBytes", n))
+		}
+		b.MaxRequestBodyBytes = n
+		return nil
+	})
+}
+
+// WithEndpointConfig overrides the client's timeout, retry and backoff configuration for requests to the
+// endpoint with the provided name, as set by WithEndpointName or WithRPCMethodName.
+func WithEndpointConfig(name string, config EndpointConfig) ClientParam {
+	return clientParamFunc(func(b *clientBuilder) error {
+		if name == "" {
+			return werror.Error("httpclient: endpoint name can not be empty")
+		}
+		if b.EndpointConfigs.byName == nil {
+			b.EndpointConfigs.byName = make(map[string]EndpointConfig)
+		}
+		b.EndpointConfigs.byName[name] = config
+		return nil
+	})
+}
+
+// WithEndpointPathPrefixConfig overrides the client's timeout, retry and backoff configuration for requests
+// whose path starts with prefix and which do not match an endpoint registered by name with WithEndpointConfig.
+// If several prefixes match, the longest is used.
+func WithEndpointPathPrefixConfig(prefix string, config EndpointConfig) ClientParam {
+	return clientParamFunc(func(b *clientBuilder) error {
+		if prefix == "" {
+			return werror.Error("httpclient: endpoint path prefix can not be empty")
+		}
+		if b.EndpointConfigs.byPathPrefix == nil {
+			b.EndpointConfigs.byPathPrefix = make(map[string]EndpointConfig)
+		}
+		b.EndpointConfigs.byPathPrefix[prefix] = config
+		return nil
+	})
+}
+
 // WithDisablePanicRecovery disables the enabled-by-default panic recovery middleware.
 // If the request was otherwise succeeding (err == nil), we return a new werror with
 // the recovered object as an unsafe param. If there's an error, we werror.Wrap it.
@@ -226,6 +550,11 @@ func WithHTTPTimeout(timeout time.Duration) ClientOrHTTPClientParam {
 
 // WithDisableHTTP2 skips the default behavior of configuring
 // the transport with http2.ConfigureTransport.
+//
+// Without HTTP/2, concurrent requests to a host are not multiplexed over a single connection, so a slow response
+// can not delay other requests on the same connection. In exchange, each concurrent request requires its own
+// connection, which increases connection and TLS handshake overhead. The setting is retained when the transport
+// is rebuilt due to a configuration refresh.
 func WithDisableHTTP2() ClientOrHTTPClientParam {
 	return clientOrHTTPClientParamFunc(func(b *httpClientBuilder) error {
 		b.TransportParams = refreshingclient.ConfigureTransport(b.TransportParams, func(p refreshingclient.TransportParams) refreshingclient.TransportParams {
@@ -236,6 +565,33 @@ func WithDisableHTTP2() ClientOrHTTPClientParam {
 	})
 }
 
+// WithHTTP2 explicitly enables or disables HTTP/2. HTTP/2 is enabled by default.
+// When enabled, the transport is configured with golang.org/x/net/http2 and "h2" is advertised via TLS ALPN.
+// When disabled, "h2" is removed from the TLS config's NextProtos so the advertised protocols match the transport.
+// See WithDisableHTTP2 for the tradeoffs of disabling HTTP/2.
+func WithHTTP2(enabled bool) ClientOrHTTPClientParam {
+	return clientOrHTTPClientParamFunc(func(b *httpClientBuilder) error {
+		b.TransportParams = refreshingclient.ConfigureTransport(b.TransportParams, func(p refreshingclient.TransportParams) refreshingclient.TransportParams {
+			p.DisableHTTP2 = !enabled
+			return p
+		})
+		return nil
+	})
+}
+
+// WithHTTP2StrictMaxConcurrentStreams limits the number of concurrent HTTP/2 streams to each host to the
+// SETTINGS_MAX_CONCURRENT_STREAMS advertised by the server. Requests beyond the limit wait for an available stream
+// instead of opening an additional connection to the host. By default, a new connection is opened.
+func WithHTTP2StrictMaxConcurrentStreams(strict bool) ClientOrHTTPClientParam {
+	return clientOrHTTPClientParamFunc(func(b *httpClientBuilder) error {
+		b.TransportParams = refreshingclient.ConfigureTransport(b.TransportParams, func(p refreshingclient.TransportParams) refreshingclient.TransportParams {
+			p.HTTP2StrictMaxConcurrentStreams = strict
+			return p
+		})
+		return nil
+	})
+}
+
 // WithHTTP2ReadIdleTimeout configures the HTTP/2 ReadIdleTimeout.
 // A ReadIdleTimeout > 0 will enable health checks and allows broken/idle
 // connections to be pruned more quickly, preventing the client from
@@ -347,10 +703,51 @@ func WithProxyURL(proxyURLString string) ClientOrHTTPClientParam {
 	})
 }
 
+// WithBaseTransport sets the round tripper which sends requests, in place of the *http.Transport built by the client.
+// The client's middlewares, e.g. for metrics, tracing, error decoding and retries, are still applied around it.
+// The provided transport owns TLS, proxy, dialing and connection pooling, so the TLS, proxy, dialer and transport
+// params, including those of ClientConfig, are ignored, and building the client returns an error if it is combined
+// with WithTLSConfig, WithRefreshableTLSConfig or a param modifying the tls config, such as WithTLSServerName.
+// A nil transport restores the default.
+func WithBaseTransport(transport http.RoundTripper) ClientOrHTTPClientParam {
+	return clientOrHTTPClientParamFunc(func(b *httpClientBuilder) error {
+		b.BaseTransport = transport
+		return nil
+	})
+}
+
+// WithRecorder records each request sent by the client and the response received for it to store, e.g. to replay
+// them in tests using WithReplayer. Request and response bodies are read into memory. The Authorization header of
+// requests is not recorded. Requests which fail without a response are not recorded. WithRecorder replaces any
+// WithReplayer.
+func WithRecorder(store InteractionStore) ClientOrHTTPClientParam {
+	return clientOrHTTPClientParamFunc(func(b *httpClientBuilder) error {
+		b.InteractionRecorder = store
+		b.InteractionReplayer = nil
+		return nil
+	})
+}
+
+// WithReplayer serves the responses recorded in store, e.g. by WithRecorder, instead of sending requests.
+// A request matches a recorded interaction with the same method, URL, including the base URL, and request body.
+// A request with no match fails with an error wrapping ErrInteractionNotRecorded. The client's middlewares,
+// e.g. error decoding, are applied to replayed responses as usual. WithReplayer replaces any WithRecorder.
+func WithReplayer(store InteractionStore) ClientOrHTTPClientParam {
+	return clientOrHTTPClientParamFunc(func(b *httpClientBuilder) error {
+		b.InteractionReplayer = store
+		b.InteractionRecorder = nil
+		return nil
+	})
+}
+
 // WithTLSConfig sets the SSL/TLS configuration for the HTTP client's Transport using a copy of the provided config.
 // The palantir/pkg/tlsconfig package is recommended to build a tls.Config from sane defaults.
+// The provided config bypasses the TLS params, e.g. those of ClientConfig.Security, entirely, so building the client
+// returns an error if the TLS params configure CA files or client certificates. It composes with the params which
+// modify the tls config, such as WithTLSInsecureSkipVerify and WithTLSServerName.
 func WithTLSConfig(conf *tls.Config) ClientOrHTTPClientParam {
 	return clientOrHTTPClientParamFunc(func(b *httpClientBuilder) error {
+		b.RefreshableTLSConfig = nil
 		if conf == nil {
 			b.TLSConfig = nil
 		} else {
@@ -360,9 +757,130 @@ func WithTLSConfig(conf *tls.Config) ClientOrHTTPClientParam {
 	})
 }
 
+// WithTLSVerifyPeerCertificate sets the VerifyPeerCertificate field of the HTTP client's tls config, e.g. to check a
+// SAN against an allowlist or to validate a custom certificate extension. As with tls.Config, fn is called after
+// normal certificate verification, so verifiedChains is nil if InsecureSkipVerify is set, and it is not called on
+// resumed connections. fn applies to both the tls config built from TLS params and one set with WithTLSConfig.
+func WithTLSVerifyPeerCertificate(fn func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error) ClientOrHTTPClientParam {
+	return clientOrHTTPClientParamFunc(func(b *httpClientBuilder) error {
+		return withTLSConfigMapper(b, func(tlsConfig *tls.Config) *tls.Config {
+			tlsConfig.VerifyPeerCertificate = fn
+			return tlsConfig
+		})
+	})
+}
+
+// WithTLSServerName sets the ServerName field of the HTTP client's tls config, which is used to verify the hostname
+// of the server's certificate and sent in the SNI extension instead of the host of the request URL.
+// It applies to both the tls config built from TLS params and one set with WithTLSConfig.
+func WithTLSServerName(serverName string) ClientOrHTTPClientParam {
+	return clientOrHTTPClientParamFunc(func(b *httpClientBuilder) error {
+		return withTLSConfigMapper(b, func(tlsConfig *tls.Config) *tls.Config {
+			tlsConfig.ServerName = serverName
+			return tlsConfig
+		})
+	})
+}
+
+// WithTLSMinVersion sets the MinVersion field of the HTTP client's tls config, e.g. to tls.VersionTLS13.
+// It applies to both the tls config built from TLS params and one set with WithTLSConfig.
+func WithTLSMinVersion(version uint16) ClientOrHTTPClientParam {
+	return clientOrHTTPClientParamFunc(func(b *httpClientBuilder) error {
+		switch version {
+		case tls.VersionTLS10, tls.VersionTLS11, tls.VersionTLS12, tls.VersionTLS13:
+		default:
+			return werror.Error("httpclient: invalid TLS min version", werror.SafeParam("version", version))
+		}
+		return withTLSConfigMapper(b, func(tlsConfig *tls.Config) *tls.Config {
+			tlsConfig.MinVersion = version
+			return tlsConfig
+		})
+	})
+}
+
+// WithTLSClientCertFromCallback sets the GetClientCertificate field of the HTTP client's tls config, so that fn is
+// called for the client certificate whenever a server requests one, e.g. to load a certificate which is rotated
+// outside of the client's configuration. It takes precedence over any key pair configured with TLS params.
+// It applies to both the tls config built from TLS params and one set with WithTLSConfig.
+func WithTLSClientCertFromCallback(fn func(*tls.CertificateRequestInfo) (*tls.Certificate, error)) ClientOrHTTPClientParam {
+	return clientOrHTTPClientParamFunc(func(b *httpClientBuilder) error {
+		if fn == nil {
+			return werror.Error("httpclient: TLS client certificate callback can not be nil")
+		}
+		return withTLSConfigMapper(b, func(tlsConfig *tls.Config) *tls.Config {
+			tlsConfig.GetClientCertificate = fn
+			return tlsConfig
+		})
+	})
+}
+
+// WithTLSClientCertFromRefreshable sets the GetClientCertificate field of the HTTP client's tls config to return the
+// current value of cert, which must contain a *tls.Certificate, on every handshake. This allows rotating client
+// certificates, e.g. SPIFFE SVIDs, to take effect for new connections as soon as cert is updated, without rebuilding
+// the tls config or transport. No certificate is presented while cert contains a nil *tls.Certificate.
+// It takes precedence over any key pair configured with TLS params.
+func WithTLSClientCertFromRefreshable(cert refreshable.Refreshable) ClientOrHTTPClientParam {
+	return clientOrHTTPClientParamFunc(func(b *httpClientBuilder) error {
+		if cert == nil {
+			return werror.Error("httpclient: TLS client certificate refreshable can not be nil")
+		}
+		if _, ok := cert.Current().(*tls.Certificate); !ok {
+			return werror.Error("httpclient: TLS client certificate refreshable must contain a *tls.Certificate",
+				werror.SafeParam("type", fmt.Sprintf("%T", cert.Current())))
+		}
+		return withTLSConfigMapper(b, func(tlsConfig *tls.Config) *tls.Config {
+			tlsConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+				if current, _ := cert.Current().(*tls.Certificate); current != nil {
+					return current, nil
+				}
+				// An empty certificate results in no certificate being sent.
+				return &tls.Certificate{}, nil
+			}
+			return tlsConfig
+		})
+	})
+}
+
+// withTLSConfigMapper applies mapFn to the HTTP client's tls config after any previously configured mapping functions.
+func withTLSConfigMapper(b *httpClientBuilder, mapFn func(*tls.Config) *tls.Config) error {
+	b.TLSConfigMappers = append(b.TLSConfigMappers, mapFn)
+	return nil
+}
+
+// WithTLSConfigErrorCallback sets a callback which is called with the validation error each time the client's
+// transport is rebuilt with the previous TLS config because the updated TLS config is invalid, e.g. if a configured
+// CA file does not exist. This allows alerting on a client running with a stale TLS config. The
+// client.tls.config.invalid counter is incremented whether or not a callback is set.
+// The callback is not called if WithTLSConfig is used.
+func WithTLSConfigErrorCallback(fn func(ctx context.Context, err error)) ClientOrHTTPClientParam {
+	return clientOrHTTPClientParamFunc(func(b *httpClientBuilder) error {
+		b.TLSConfigErrorCallback = fn
+		return nil
+	})
+}
+
+// WithRefreshableTLSConfig sets the SSL/TLS configuration for the HTTP client's Transport to the current value of
+// tlsConfig, which must contain a *tls.Config, for callers which manage refreshing TLS configuration themselves.
+// The transport is rebuilt with a copy of the new config whenever tlsConfig is updated. A nil *tls.Config uses the
+// default configuration. Like WithTLSConfig, it bypasses the TLS params entirely and replaces any WithTLSConfig.
+func WithRefreshableTLSConfig(tlsConfig refreshable.Refreshable) ClientOrHTTPClientParam {
+	return clientOrHTTPClientParamFunc(func(b *httpClientBuilder) error {
+		if tlsConfig == nil {
+			return werror.Error("httpclient: refreshable TLS config can not be nil")
+		}
+		if _, ok := tlsConfig.Current().(*tls.Config); !ok {
+			return werror.Error("httpclient: refreshable TLS config must contain a *tls.Config",
+				werror.SafeParam("type", fmt.Sprintf("%T", tlsConfig.Current())))
+		}
+		b.TLSConfig = nil
+		b.RefreshableTLSConfig = tlsConfig
+		return nil
+	})
+}
+
 // WithTLSInsecureSkipVerify sets the InsecureSkipVerify field for the HTTP client's tls config.
 // This option should only be used in clients that have way to establish trust with servers.
-// If WithTLSConfig is used, the config's InsecureSkipVerify is set to true.
+// If WithTLSConfig or WithRefreshableTLSConfig is used, the config's InsecureSkipVerify is set to true.
 func WithTLSInsecureSkipVerify() ClientOrHTTPClientParam {
 	return clientOrHTTPClientParamFunc(func(b *httpClientBuilder) error {
 		if b.TLSConfig != nil {
@@ -388,8 +906,43 @@ func WithDialTimeout(timeout time.Duration) ClientOrHTTPClientParam {
 	})
 }
 
+// WithDNSCache caches the IP addresses of the hostnames dialed by the client for ttl. If a hostname resolves to
+// several addresses, each new connection starts with the next address, falling back to the others if it can not
+// connect. Expired addresses continue to be used while they are resolved again in the background. If none of the
+// cached addresses can be connected to, the hostname is resolved again before failing.
+//
+// With an HTTP proxy, only the address of the proxy is cached because the proxy resolves the hostnames of requests.
+// With a SOCKS proxy, the cache is not used. A ttl of zero disables the cache.
+func WithDNSCache(ttl time.Duration) ClientOrHTTPClientParam {
+	return clientOrHTTPClientParamFunc(func(b *httpClientBuilder) error {
+		if ttl < 0 {
+			return werror.Error("httpclient: DNS cache TTL must not be negative", werror.SafeParam("ttl", ttl.String()))
+		}
+		b.DialerParams = refreshingclient.ConfigureDialer(b.DialerParams, func(p refreshingclient.DialerParams) refreshingclient.DialerParams {
+			p.DNSCacheTTL = ttl
+			return p
+		})
+		return nil
+	})
+}
+
+// WithLocalAddr sets the local address used to dial connections, e.g. a *net.TCPAddr with the source IP of a
+// specific interface on a multi-homed host. The port is usually left zero so that it is chosen automatically.
+// With a SOCKS proxy, it is the local address of the connection to the proxy.
+func WithLocalAddr(addr net.Addr) ClientOrHTTPClientParam {
+	return clientOrHTTPClientParamFunc(func(b *httpClientBuilder) error {
+		b.DialerParams = refreshingclient.ConfigureDialer(b.DialerParams, func(p refreshingclient.DialerParams) refreshingclient.DialerParams {
+			p.LocalAddr = addr
+			return p
+		})
+		return nil
+	})
+}
+
 // WithIdleConnTimeout sets the timeout for idle connections.
 // If unset, the client defaults to 90 seconds.
+// Connections idle behind stateful firewalls or NATs may be dropped before this timeout;
+// use WithTCPKeepAlive to keep them open.
 func WithIdleConnTimeout(timeout time.Duration) ClientOrHTTPClientParam {
 	return clientOrHTTPClientParamFunc(func(b *httpClientBuilder) error {
 		b.TransportParams = refreshingclient.ConfigureTransport(b.TransportParams, func(p refreshingclient.TransportParams) refreshingclient.TransportParams {
@@ -400,6 +953,28 @@ func WithIdleConnTimeout(timeout time.Duration) ClientOrHTTPClientParam {
 	})
 }
 
+// WithTCPKeepAlive sets the interval between TCP keep-alive probes sent by the OS on idle connections, which keeps
+// the state of stateful firewalls and NATs between the client and the server fresh so that idle connections are
+// not silently dropped. An interval of zero disables keep-alive probes.
+// If unset, the client defaults to 30 seconds. It replaces the value set by WithKeepAlive.
+func WithTCPKeepAlive(interval time.Duration) ClientOrHTTPClientParam {
+	return clientOrHTTPClientParamFunc(func(b *httpClientBuilder) error {
+		if interval < 0 {
+			return werror.Error("httpclient: TCP keep-alive interval must not be negative",
+				werror.SafeParam("interval", interval.String()))
+		}
+		if interval == 0 {
+			// a negative net.Dialer.KeepAlive disables keep-alives, while zero enables them with the default interval.
+			interval = -1
+		}
+		b.DialerParams = refreshingclient.ConfigureDialer(b.DialerParams, func(p refreshingclient.DialerParams) refreshingclient.DialerParams {
+			p.KeepAlive = interval
+			return p
+		})
+		return nil
+	})
+}
+
 // WithTLSHandshakeTimeout sets the timeout for TLS handshakes.
 // If unset, the client defaults to 10 seconds.
 func WithTLSHandshakeTimeout(timeout time.Duration) ClientOrHTTPClientParam {
@@ -438,8 +1013,27 @@ func WithResponseHeaderTimeout(timeout time.Duration) ClientOrHTTPClientParam {
 	})
 }
 
-// WithKeepAlive sets the keep alive frequency on the Dialer.
-// If unset, the client defaults to 30 seconds.
+// WithMaxResponseHeaderBytes limits the size of the response headers the client will read, protecting it from
+// servers sending unbounded headers. Responses with larger headers fail with an error. If unset or 0, the client
+// uses the default limit of net/http (currently 1MB).
+func WithMaxResponseHeaderBytes(n int64) ClientOrHTTPClientParam {
+	return clientOrHTTPClientParamFunc(func(b *httpClientBuilder) error {
+		if n < 0 {
+			return werror.Error("httpclient: max response header bytes must not be negative",
+				werror.SafeParam("maxResponseHeaderBytes", n))
+		}
+		b.TransportParams = refreshingclient.ConfigureTransport(b.TransportParams, func(p refreshingclient.TransportParams) refreshingclient.TransportParams {
+			p.MaxResponseHeaderBytes = n
+			return p
+		})
+		return nil
+	})
+}
+
+// WithKeepAlive sets the keep alive frequency on the Dialer. It is passed to net.Dialer unchanged, so a negative
+// value disables keep-alive probes and zero enables them with the default interval of the net package. Prefer
+// WithTCPKeepAlive, for which zero disables keep-alive probes.
+// If unset, the client defaults to 30 seconds. It replaces the value set by WithTCPKeepAlive.
 func WithKeepAlive(keepAlive time.Duration) ClientOrHTTPClientParam {
 	return clientOrHTTPClientParamFunc(func(b *httpClientBuilder) error {
 		b.DialerParams = refreshingclient.ConfigureDialer(b.DialerParams, func(p refreshingclient.DialerParams) refreshingclient.DialerParams {
@@ -450,6 +1044,21 @@ func WithKeepAlive(keepAlive time.Duration) ClientOrHTTPClientParam {
 	})
 }
 
+// WithConnectionWarmup opens n connections to each base URL in the background when the client is constructed, so
+// that the first requests reuse warm connections rather than paying for dialing and the TLS handshake. Connections
+// are opened by HEAD requests to the base URLs, sent without the client's middlewares after a random delay of up
+// to 100ms. Failures are logged and do not fail construction. n should not exceed the max idle connections per host.
+// Connections are not warmed up when responses are replayed by WithReplayer.
+func WithConnectionWarmup(n int) ClientParam {
+	return clientParamFunc(func(b *clientBuilder) error {
+		if n < 0 {
+			return werror.Error("httpclient: connection warmup must not be negative", werror.SafeParam("connectionWarmup", n))
+		}
+		b.ConnectionWarmup = n
+		return nil
+	})
+}
+
 // WithBaseURLs sets the base URLs for every request. This is meant to be used in conjunction with WithPath.
 func WithBaseURLs(urls []string) ClientParam {
 	return clientParamFunc(func(b *clientBuilder) error {
@@ -499,6 +1108,16 @@ func WithInitialBackoff(initialBackoff time.Duration) ClientParam {
 	})
 }
 
+// WithBackoffStrategy sets the strategy which determines the backoff between retried calls, e.g.
+// NewExponentialBackoffStrategy or NewConstantBackoffStrategy. If set, the backoffs configured by WithInitialBackoff,
+// WithMaxBackoff and endpoint configuration are ignored. If nil, those backoffs are used.
+func WithBackoffStrategy(strategy BackoffStrategy) ClientParam {
+	return clientParamFunc(func(b *clientBuilder) error {
+		b.BackoffStrategy = strategy
+		return nil
+	})
+}
+
 // WithMaxRetries sets the maximum number of retries on transport errors for every request. Backoffs are
 // also capped at this.
 // If unset, the client defaults to 2 * size of URIs
@@ -531,7 +1150,11 @@ func WithDisableRestErrors() ClientParam {
 	})
 }
 
-// WithDisableKeepAlives disables keep alives on the http transport
+// WithDisableKeepAlives disables keep alives on the http transport, so each request uses a new connection which
+// is closed once the response is read. This avoids failures caused by reusing connections which an intermediary
+// (e.g. a load balancer) has closed, at the cost of a TCP (and TLS) handshake for every request, which
+// significantly increases latency and load on both client and server. The setting is retained when the transport
+// is rebuilt due to a configuration refresh.
 func WithDisableKeepAlives() ClientOrHTTPClientParam {
 	return clientOrHTTPClientParamFunc(func(b *httpClientBuilder) error {
 		b.TransportParams = refreshingclient.ConfigureTransport(b.TransportParams, func(p refreshingclient.TransportParams) refreshingclient.TransportParams {
@@ -549,6 +1172,28 @@ func WithErrorDecoder(errorDecoder ErrorDecoder) ClientParam {
 	})
 }
 
+// WithErrorBodyDrainLimit sets the most bytes read from the body of an error response after it was decoded, which
+// allows the connection to be reused if the ErrorDecoder did not read the body to the end. The connection of a
+// response with a larger remaining body is closed rather than reused. Defaults to 64KiB.
+func WithErrorBodyDrainLimit(limit int64) ClientParam {
+	return clientParamFunc(func(b *clientBuilder) error {
+		if limit < 0 {
+			return werror.Error("httpclient: error body drain limit must not be negative",
+				werror.SafeParam("limit", limit))
+		}
+		b.ErrorBodyDrainLimit = limit
+		return nil
+	})
+}
+
+// WithConjureErrorDecoder replaces the default error decoder with one which decodes conjure error response bodies
+// using ced, so registered error types are returned as their concrete types. The decoded error can be retrieved
+// from the error returned by Do using errors.As with a target of type *errors.Error, or errors.GetConjureError.
+// Responses whose body is not a conjure error are handled as by the default error decoder.
+func WithConjureErrorDecoder(ced errors.ConjureErrorDecoder) ClientParam {
+	return WithErrorDecoder(restErrorDecoder{conjureErrorDecoder: ced})
+}
+
 // WithBasicAuth sets the request's Authorization header to use HTTP Basic Authentication with the provided username and
 // password.
 func WithBasicAuth(user, password string) ClientOrHTTPClientParam {
@@ -600,6 +1245,21 @@ func WithBalancedURIScoring() ClientParam {
 	})
 }
 
+// WithConsistentHashURIScoring prioritizes URIs for requests with a shard key (see WithShardKey) by consistent
+// (rendezvous) hashing of the key, so that requests with the same key prefer the same URI while the URIs are
+// unchanged, and adding or removing a URI only moves the keys which preferred that URI. Requests without a shard
+// key are prioritized like the default, balanced URI scoring.
+func WithConsistentHashURIScoring() ClientParam {
+	return clientParamFunc(func(b *clientBuilder) error {
+		b.URIScorerBuilder = func(uris []string) internal.URIScoringMiddleware {
+			return internal.NewRendezvousURIScoringMiddleware(uris, internal.NewBalancedURIScoringMiddleware(uris, func() int64 {
+				return time.Now().UnixNano()
+			}))
+		}
+		return nil
+	})
+}
+
 // WithRandomURIScoring adds middleware that randomizes the order URIs are prioritized in for each request.
 func WithRandomURIScoring() ClientParam {
 	return clientParamFunc(func(b *clientBuilder) error {
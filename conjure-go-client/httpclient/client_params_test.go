@@ -137,6 +137,14 @@ func TestBuilder(t *testing.T) {
 				assert.Equal(t, 0, *client.maxAttempts.CurrentIntPtr())
 			},
 		},
+		{
+			Name:  "MaxResponseHeaderBytes",
+			Param: WithMaxResponseHeaderBytes(1024),
+			Test: func(t *testing.T, client *clientImpl) {
+				transport, _ := unwrapTransport(client.client.CurrentHTTPClient().Transport)
+				assert.EqualValues(t, 1024, transport.MaxResponseHeaderBytes)
+			},
+		},
 		{
 			Name:  "TLSInsecureSkipVerify",
 			Param: WithTLSInsecureSkipVerify(),
@@ -185,3 +193,106 @@ func unwrapTransport(rt http.RoundTripper) (*http.Transport, []Middleware) {
 		}
 	}
 }
+
+func TestTCPKeepAlive(t *testing.T) {
+	for _, test := range []struct {
+		Name              string
+		Interval          time.Duration
+		ExpectedKeepAlive time.Duration
+		ExpectedErr       string
+	}{
+		{Name: "interval", Interval: time.Minute, ExpectedKeepAlive: time.Minute},
+		{Name: "zero disables keep-alives", Interval: 0, ExpectedKeepAlive: -1},
+		{Name: "negative", Interval: -time.Second, ExpectedErr: "httpclient: TCP keep-alive interval must not be negative"},
+	} {
+		t.Run(test.Name, func(t *testing.T) {
+			b := newClientBuilder()
+			assert.Equal(t, defaultKeepAlive, b.HTTP.DialerParams.CurrentDialerParams().KeepAlive)
+			err := WithTCPKeepAlive(test.Interval).apply(b)
+			if test.ExpectedErr != "" {
+				assert.EqualError(t, err, test.ExpectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.ExpectedKeepAlive, b.HTTP.DialerParams.CurrentDialerParams().KeepAlive)
+		})
+	}
+}
+
+func TestTLSConfigMappers(t *testing.T) {
+	clientCert := &tls.Certificate{Certificate: [][]byte{[]byte("cert")}}
+	getClientCert := func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+		return clientCert, nil
+	}
+	staticConfig := &tls.Config{ServerName: "static.example.com", MinVersion: tls.VersionTLS12, InsecureSkipVerify: true}
+	for _, test := range []struct {
+		Name        string
+		Params      []ClientParam
+		ExpectedErr string
+		Test        func(*testing.T, *tls.Config)
+	}{
+		{
+			Name:   "server name",
+			Params: []ClientParam{WithTLSServerName("internal.example.com")},
+			Test: func(t *testing.T, tlsConfig *tls.Config) {
+				assert.Equal(t, "internal.example.com", tlsConfig.ServerName)
+			},
+		},
+		{
+			Name:   "min version",
+			Params: []ClientParam{WithTLSMinVersion(tls.VersionTLS13)},
+			Test: func(t *testing.T, tlsConfig *tls.Config) {
+				assert.Equal(t, uint16(tls.VersionTLS13), tlsConfig.MinVersion)
+			},
+		},
+		{
+			Name:   "client cert from callback",
+			Params: []ClientParam{WithTLSClientCertFromCallback(getClientCert)},
+			Test: func(t *testing.T, tlsConfig *tls.Config) {
+				require.NotNil(t, tlsConfig.GetClientCertificate)
+				cert, err := tlsConfig.GetClientCertificate(&tls.CertificateRequestInfo{})
+				require.NoError(t, err)
+				assert.Equal(t, clientCert, cert)
+			},
+		},
+		{
+			Name: "chained with static config",
+			Params: []ClientParam{
+				WithTLSConfig(staticConfig),
+				WithTLSServerName("first.example.com"),
+				WithTLSMinVersion(tls.VersionTLS13),
+				WithTLSClientCertFromCallback(getClientCert),
+				WithTLSServerName("second.example.com"),
+			},
+			Test: func(t *testing.T, tlsConfig *tls.Config) {
+				assert.Equal(t, "second.example.com", tlsConfig.ServerName, "later helpers should override earlier ones")
+				assert.Equal(t, uint16(tls.VersionTLS13), tlsConfig.MinVersion)
+				assert.NotNil(t, tlsConfig.GetClientCertificate)
+				assert.True(t, tlsConfig.InsecureSkipVerify, "fields not set by helpers should be preserved")
+			},
+		},
+		{
+			Name:        "invalid min version",
+			Params:      []ClientParam{WithTLSMinVersion(0)},
+			ExpectedErr: "httpclient: invalid TLS min version",
+		},
+		{
+			Name:        "nil client cert callback",
+			Params:      []ClientParam{WithTLSClientCertFromCallback(nil)},
+			ExpectedErr: "httpclient: TLS client certificate callback can not be nil",
+		},
+	} {
+		t.Run(test.Name, func(t *testing.T) {
+			params := append([]ClientParam{WithBaseURLs([]string{"https://localhost"})}, test.Params...)
+			client, err := NewClient(params...)
+			if test.ExpectedErr != "" {
+				assert.EqualError(t, err, test.ExpectedErr)
+				return
+			}
+			require.NoError(t, err)
+			transport, _ := unwrapTransport(client.(*clientImpl).client.CurrentHTTPClient().Transport)
+			test.Test(t, transport.TLSClientConfig)
+			assert.Equal(t, "static.example.com", staticConfig.ServerName, "the provided tls config should not be modified")
+		})
+	}
+}
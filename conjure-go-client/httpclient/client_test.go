@@ -16,19 +16,33 @@ package httpclient_test
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math/big"
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/palantir/conjure-go-runtime/v2/conjure-go-client/httpclient"
 	"github.com/palantir/conjure-go-runtime/v2/conjure-go-contract/codecs"
 	"github.com/palantir/pkg/bytesbuffers"
+	"github.com/palantir/pkg/metrics"
+	"github.com/palantir/pkg/refreshable"
+	werror "github.com/palantir/witchcraft-go-error"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -332,6 +346,45 @@ func BenchmarkAllocWithBytesBufferPool(b *testing.B) {
 	})
 }
 
+func BenchmarkResponseBodyWithResponseBufferPool(b *testing.B) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte(`"` + strings.Repeat("v", 10000) + `"`))
+	}))
+	defer server.Close()
+
+	// A client with a response buffer pool reads response bodies into a reused buffer, so decoders which would otherwise read
+	// the whole body into a new slice (e.g. codecs.Plain) allocate less. codecs.JSON decodes from a buffer of its own
+	// either way.
+	runBench := func(b *testing.B, client httpclient.Client) {
+		for _, codec := range []codecs.Codec{codecs.Plain, codecs.JSON} {
+			b.Run(codec.ContentType(), func(b *testing.B) {
+				ctx := context.Background()
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					var output string
+					_, err := client.Get(ctx, httpclient.WithResponseBody(&output, codec))
+					require.NoError(b, err)
+				}
+			})
+		}
+	}
+	b.Run("NoResponseBufferPool", func(b *testing.B) {
+		client, err := httpclient.NewClient(
+			httpclient.WithBaseURLs([]string{server.URL}),
+		)
+		require.NoError(b, err)
+		runBench(b, client)
+	})
+	b.Run("WithResponseBufferPool", func(b *testing.B) {
+		client, err := httpclient.NewClient(
+			httpclient.WithResponseBufferPool(bytesbuffers.NewSizedPool(1, 16*1024)),
+			httpclient.WithBaseURLs([]string{server.URL}),
+		)
+		require.NoError(b, err)
+		runBench(b, client)
+	})
+}
+
 func BenchmarkUnavailableURIs(b *testing.B) {
 	server1 := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
 		rw.WriteHeader(http.StatusOK)
@@ -417,3 +470,1302 @@ func BenchmarkUnavailableURIs(b *testing.B) {
 		runBench(b, client)
 	})
 }
+
+func TestRequestHeaders(t *testing.T) {
+	var received http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		received = req.Header
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client, err := httpclient.NewClient(
+		httpclient.WithBaseURLs([]string{server.URL}),
+		httpclient.WithUserAgent("client-agent"),
+		httpclient.WithAddHeader("X-Multi", "client"),
+		httpclient.WithSetHeader("X-Client", "client"),
+	)
+	require.NoError(t, err)
+
+	_, err = client.Get(context.Background(),
+		httpclient.WithHeader("User-Agent", "request-agent"),
+		httpclient.WithHeaders(http.Header{
+			"X-Set":    []string{"a", "b"},
+			"x-lower":  []string{"c"},
+			"X-Client": []string{"request"},
+		}),
+		httpclient.WithRequestAddHeader("X-Multi", "request-1"),
+		httpclient.WithRequestAddHeader("X-Multi", "request-2"),
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"request-agent"}, received.Values("User-Agent"))
+	assert.Equal(t, []string{"a", "b"}, received.Values("X-Set"))
+	assert.Equal(t, []string{"c"}, received.Values("X-Lower"))
+	assert.Equal(t, []string{"request"}, received.Values("X-Client"))
+	assert.Equal(t, []string{"request-1", "request-2", "client"}, received.Values("X-Multi"))
+}
+
+func TestRequestContentType(t *testing.T) {
+	var received *http.Request
+	var receivedBody []byte
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		received = req
+		receivedBody, _ = io.ReadAll(req.Body)
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client, err := httpclient.NewClient(httpclient.WithBaseURLs([]string{server.URL}))
+	require.NoError(t, err)
+
+	t.Run("empty body", func(t *testing.T) {
+		_, err := client.Post(context.Background(), httpclient.WithRequestContentType("application/json"))
+		require.NoError(t, err)
+		assert.Equal(t, "application/json", received.Header.Get("Content-Type"))
+		assert.EqualValues(t, 0, received.ContentLength)
+		assert.Empty(t, receivedBody)
+	})
+	const vendorContentType = "application/vnd.foo+json"
+	for _, test := range []struct {
+		Name      string
+		BodyParam func() httpclient.RequestParam
+	}{
+		{
+			Name:      "encoder",
+			BodyParam: func() httpclient.RequestParam { return httpclient.WithJSONRequest("value") },
+		},
+		{
+			Name: "raw",
+			BodyParam: func() httpclient.RequestParam {
+				return httpclient.WithRawRequestBody(io.NopCloser(strings.NewReader(`"value"`)))
+			},
+		},
+		{
+			Name: "raw provider",
+			BodyParam: func() httpclient.RequestParam {
+				return httpclient.WithRawRequestBodyProvider(func() io.ReadCloser {
+					return io.NopCloser(strings.NewReader(`"value"`))
+				})
+			},
+		},
+		{
+			Name: "stream",
+			BodyParam: func() httpclient.RequestParam {
+				return httpclient.WithBinaryRequestBody(httpclient.RequestBodyEncoderObjectStream("value", codecs.JSON))
+			},
+		},
+	} {
+		t.Run(test.Name, func(t *testing.T) {
+			for _, params := range [][]httpclient.RequestParam{
+				{httpclient.WithRequestContentType(vendorContentType), test.BodyParam()},
+				{test.BodyParam(), httpclient.WithRequestContentType(vendorContentType)},
+			} {
+				_, err := client.Post(context.Background(), params...)
+				require.NoError(t, err)
+				assert.Equal(t, vendorContentType, received.Header.Get("Content-Type"))
+				// the streaming encoder writes a trailing newline
+				assert.Equal(t, `"value"`, strings.TrimSuffix(string(receivedBody), "\n"))
+			}
+		})
+	}
+}
+
+func TestRequestQueryParams(t *testing.T) {
+	var received *url.URL
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		received = req.URL
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client, err := httpclient.NewClient(httpclient.WithBaseURLs([]string{server.URL}))
+	require.NoError(t, err)
+
+	_, err = client.Get(context.Background(),
+		httpclient.WithPath("/path?existing=a%26b"),
+		httpclient.WithQueryParam("existing", "c"),
+		httpclient.WithQueryParam("special", "a b&c=d/é"),
+		httpclient.WithQueryValues(url.Values{"multi": []string{"1", "2"}}),
+		httpclient.WithQueryValues(url.Values{"multi": []string{"3"}}),
+		httpclient.WithQueryParam("replaced", "1"),
+		httpclient.WithSetQueryParam("replaced", "2"),
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, "/path", received.Path)
+	query := received.Query()
+	assert.Equal(t, []string{"a&b", "c"}, query["existing"])
+	assert.Equal(t, []string{"a b&c=d/é"}, query["special"])
+	assert.Equal(t, []string{"1", "2", "3"}, query["multi"])
+	assert.Equal(t, []string{"2"}, query["replaced"])
+}
+
+func TestRequestBaseURL(t *testing.T) {
+	var defaultCalls, overrideCalls int
+	defaultServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		defaultCalls++
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer defaultServer.Close()
+	overrideServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		overrideCalls++
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer overrideServer.Close()
+
+	client, err := httpclient.NewClient(
+		httpclient.WithBaseURLs([]string{defaultServer.URL, overrideServer.URL}),
+		httpclient.WithMaxRetries(2),
+		httpclient.WithInitialBackoff(time.Millisecond),
+	)
+	require.NoError(t, err)
+
+	t.Run("retries only against base URL", func(t *testing.T) {
+		defaultCalls, overrideCalls = 0, 0
+		_, err := client.Get(context.Background(), httpclient.WithBaseURL(overrideServer.URL))
+		code, ok := httpclient.StatusCodeFromError(err)
+		require.True(t, ok)
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, 0, defaultCalls)
+		assert.Equal(t, 3, overrideCalls)
+	})
+
+	t.Run("strict base URL must be configured", func(t *testing.T) {
+		_, err := client.Get(context.Background(), httpclient.WithStrictBaseURL(overrideServer.URL+"/"))
+		code, _ := httpclient.StatusCodeFromError(err)
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+
+		_, err = client.Get(context.Background(), httpclient.WithStrictBaseURL("http://localhost:1"))
+		require.EqualError(t, err, "httpclient: base URL is not one of the configured URLs")
+	})
+
+	t.Run("non-strict base URL may be unconfigured", func(t *testing.T) {
+		otherServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			rw.WriteHeader(http.StatusOK)
+		}))
+		defer otherServer.Close()
+
+		resp, err := client.Get(context.Background(), httpclient.WithBaseURL(otherServer.URL))
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+}
+
+type channelRateLimiter chan struct{}
+
+func (l channelRateLimiter) Wait(ctx context.Context) error {
+	select {
+	case <-l:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if calls.Add(1) == 1 {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	limiter := make(channelRateLimiter, 2)
+	client, err := httpclient.NewClient(
+		httpclient.WithBaseURLs([]string{server.URL}),
+		httpclient.WithRateLimiter(limiter),
+		httpclient.WithInitialBackoff(time.Millisecond),
+	)
+	require.NoError(t, err)
+
+	t.Run("waits for each attempt", func(t *testing.T) {
+		limiter <- struct{}{}
+		limiter <- struct{}{}
+		resp, err := client.Get(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, int32(2), calls.Load())
+		assert.Empty(t, limiter)
+	})
+
+	t.Run("context cancelled while waiting", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_, err := client.Get(ctx)
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, context.DeadlineExceeded), "expected deadline exceeded, got %v", err)
+		assert.Equal(t, int32(2), calls.Load())
+	})
+}
+
+type allowingRateLimiter struct {
+	channelRateLimiter
+}
+
+func (l allowingRateLimiter) Allow() bool {
+	select {
+	case <-l.channelRateLimiter:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestRequestPriority(t *testing.T) {
+	var calls int
+	var priorityHeader string
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		calls++
+		priorityHeader = req.Header.Get("Priority")
+	}))
+	defer server.Close()
+
+	limiter := allowingRateLimiter{channelRateLimiter: make(channelRateLimiter, 1)}
+	client, err := httpclient.NewClient(
+		httpclient.WithBaseURLs([]string{server.URL}),
+		httpclient.WithRateLimiter(limiter),
+	)
+	require.NoError(t, err)
+
+	t.Run("high priority does not wait", func(t *testing.T) {
+		calls = 0
+		_, err := client.Get(context.Background(), httpclient.WithRequestPriority(httpclient.RequestPriorityHigh))
+		require.NoError(t, err)
+		assert.Equal(t, 1, calls)
+		assert.Equal(t, "u=1", priorityHeader)
+	})
+
+	t.Run("low priority fails fast", func(t *testing.T) {
+		calls = 0
+		_, err := client.Get(context.Background(), httpclient.WithRequestPriority(httpclient.RequestPriorityLow))
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, httpclient.ErrRequestShed), "expected ErrRequestShed, got %v", err)
+		assert.Equal(t, 0, calls)
+
+		limiter.channelRateLimiter <- struct{}{}
+		_, err = client.Get(context.Background(), httpclient.WithRequestPriority(httpclient.RequestPriorityLow))
+		require.NoError(t, err)
+		assert.Equal(t, 1, calls)
+		assert.Equal(t, "u=5", priorityHeader)
+	})
+
+	t.Run("normal priority waits", func(t *testing.T) {
+		calls = 0
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_, err := client.Get(ctx, httpclient.WithRequestPriority(httpclient.RequestPriorityNormal))
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, context.DeadlineExceeded), "expected deadline exceeded, got %v", err)
+		assert.Equal(t, 0, calls)
+
+		limiter.channelRateLimiter <- struct{}{}
+		_, err = client.Get(context.Background(), httpclient.WithRequestPriority(httpclient.RequestPriorityNormal))
+		require.NoError(t, err)
+		assert.Equal(t, "u=3", priorityHeader)
+	})
+
+	t.Run("no priority", func(t *testing.T) {
+		limiter.channelRateLimiter <- struct{}{}
+		_, err := client.Get(context.Background())
+		require.NoError(t, err)
+		assert.Empty(t, priorityHeader)
+	})
+
+	t.Run("invalid priority", func(t *testing.T) {
+		_, err := client.Get(context.Background(), httpclient.WithRequestPriority(0))
+		require.EqualError(t, err, "httpclient: invalid request priority")
+	})
+}
+
+func TestRetryBudget(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		calls++
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client, err := httpclient.NewClient(
+		httpclient.WithBaseURLs([]string{server.URL}),
+		httpclient.WithServiceName("my-service"),
+		httpclient.WithMaxRetries(10),
+		httpclient.WithInitialBackoff(time.Millisecond),
+		httpclient.WithMaxBackoff(time.Millisecond),
+		httpclient.WithRetryBudget(0, 1),
+	)
+	require.NoError(t, err)
+
+	registry := metrics.NewRootMetricsRegistry()
+	ctx := metrics.WithRegistry(context.Background(), registry)
+
+	_, err = client.Get(ctx)
+	require.Error(t, err)
+	assert.Equal(t, 2, calls, "expected one retry funded by the per-second minimum")
+
+	_, err = client.Get(ctx)
+	require.Error(t, err)
+	assert.Equal(t, 3, calls, "expected no retries once the budget is exhausted")
+
+	serviceNameTag := metrics.MustNewTag(httpclient.MetricTagServiceName, "my-service")
+	assert.Equal(t, int64(2), registry.Counter(httpclient.MetricRetryBudgetExhausted, serviceNameTag).Count())
+	assert.Equal(t, int64(0), registry.Gauge(httpclient.MetricRetryBudget, serviceNameTag).Value())
+
+	_, err = httpclient.NewClient(httpclient.WithBaseURLs([]string{server.URL}), httpclient.WithRetryBudget(-1, 0))
+	assert.EqualError(t, err, "httpclient: retry budget ratio and minimum per second must not be negative")
+}
+
+type recordingBackoffStrategy struct {
+	attempts    []int
+	statusCodes []int
+}
+
+func (s *recordingBackoffStrategy) NextBackoff(attempt int, resp *http.Response) time.Duration {
+	s.attempts = append(s.attempts, attempt)
+	s.statusCodes = append(s.statusCodes, resp.StatusCode)
+	return time.Millisecond
+}
+
+func TestBackoffStrategy(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		calls++
+		if calls < 3 {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	strategy := &recordingBackoffStrategy{}
+	client, err := httpclient.NewClient(
+		httpclient.WithBaseURLs([]string{server.URL}),
+		httpclient.WithMaxRetries(4),
+		// the strategy takes precedence over the configured backoff, which would time out the test
+		httpclient.WithInitialBackoff(time.Hour),
+		httpclient.WithBackoffStrategy(strategy),
+	)
+	require.NoError(t, err)
+
+	_, err = client.Get(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, []int{0, 1}, strategy.attempts)
+	assert.Equal(t, []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable}, strategy.statusCodes)
+
+	t.Run("exponential", func(t *testing.T) {
+		exponential := httpclient.NewExponentialBackoffStrategy(100*time.Millisecond, time.Second)
+		var backoffs []time.Duration
+		for attempt := 0; attempt < 5; attempt++ {
+			backoffs = append(backoffs, exponential.NextBackoff(attempt, nil))
+		}
+		assert.Equal(t, []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second}, backoffs)
+	})
+
+	t.Run("constant", func(t *testing.T) {
+		constant := httpclient.NewConstantBackoffStrategy(time.Second)
+		assert.Equal(t, time.Second, constant.NextBackoff(0, nil))
+		assert.Equal(t, time.Second, constant.NextBackoff(10, nil))
+	})
+}
+
+func TestAttemptCallback(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		calls++
+		if calls == 1 {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		rw.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	var attempts []httpclient.AttemptInfo
+	client, err := httpclient.NewClient(
+		httpclient.WithBaseURLs([]string{server.URL}),
+		httpclient.WithInitialBackoff(time.Millisecond),
+		httpclient.WithAttemptCallback(func(info httpclient.AttemptInfo) {
+			attempts = append(attempts, info)
+		}),
+	)
+	require.NoError(t, err)
+
+	_, err = client.Get(context.Background())
+	require.NoError(t, err)
+	require.Len(t, attempts, 2)
+
+	assert.Equal(t, 0, attempts[0].Attempt)
+	assert.Equal(t, server.URL, attempts[0].BaseURL)
+	assert.Equal(t, http.StatusServiceUnavailable, attempts[0].StatusCode)
+	assert.Error(t, attempts[0].Err)
+	assert.Positive(t, attempts[0].Duration)
+
+	assert.Equal(t, 1, attempts[1].Attempt)
+	assert.Equal(t, server.URL, attempts[1].BaseURL)
+	assert.Equal(t, http.StatusAccepted, attempts[1].StatusCode)
+	assert.NoError(t, attempts[1].Err)
+	assert.Positive(t, attempts[1].Duration)
+}
+
+func TestEndpointConfig(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		calls.Add(1)
+		if req.URL.Path == "/slow" {
+			time.Sleep(100 * time.Millisecond)
+		}
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client, err := httpclient.NewClient(
+		httpclient.WithBaseURLs([]string{server.URL}),
+		httpclient.WithMaxRetries(4),
+		httpclient.WithInitialBackoff(time.Millisecond),
+		httpclient.WithMaxBackoff(time.Millisecond),
+		httpclient.WithEndpointConfig("noRetries", httpclient.EndpointConfig{MaxNumRetries: &[]int{0}[0]}),
+		httpclient.WithEndpointConfig("slow", httpclient.EndpointConfig{
+			Timeout:       &[]time.Duration{10 * time.Millisecond}[0],
+			MaxNumRetries: &[]int{0}[0],
+		}),
+		httpclient.WithEndpointPathPrefixConfig("/items", httpclient.EndpointConfig{MaxNumRetries: &[]int{1}[0]}),
+		httpclient.WithEndpointPathPrefixConfig("/items/hot", httpclient.EndpointConfig{MaxNumRetries: &[]int{2}[0]}),
+	)
+	require.NoError(t, err)
+
+	for _, test := range []struct {
+		Name          string
+		Params        []httpclient.RequestParam
+		ExpectedCalls int
+	}{
+		{
+			Name:          "client config",
+			Params:        []httpclient.RequestParam{httpclient.WithPath("/other")},
+			ExpectedCalls: 5,
+		},
+		{
+			Name:          "endpoint name",
+			Params:        []httpclient.RequestParam{httpclient.WithEndpointName("noRetries"), httpclient.WithPath("/items")},
+			ExpectedCalls: 1,
+		},
+		{
+			Name:          "rpc method name",
+			Params:        []httpclient.RequestParam{httpclient.WithRPCMethodName("noRetries")},
+			ExpectedCalls: 1,
+		},
+		{
+			Name:          "path prefix",
+			Params:        []httpclient.RequestParam{httpclient.WithPath("/items/1")},
+			ExpectedCalls: 2,
+		},
+		{
+			Name:          "longest path prefix",
+			Params:        []httpclient.RequestParam{httpclient.WithPath("/items/hot/1")},
+			ExpectedCalls: 3,
+		},
+		{
+			Name:          "disable retry overrides client config",
+			Params:        []httpclient.RequestParam{httpclient.WithPath("/other"), httpclient.WithDisableRetry()},
+			ExpectedCalls: 1,
+		},
+		{
+			Name:          "disable retry overrides endpoint config",
+			Params:        []httpclient.RequestParam{httpclient.WithPath("/items/hot/1"), httpclient.WithDisableRetry()},
+			ExpectedCalls: 1,
+		},
+		{
+			Name: "disable retry with stream once body",
+			Params: []httpclient.RequestParam{
+				httpclient.WithPath("/other"),
+				httpclient.WithRawRequestBody(io.NopCloser(strings.NewReader("body"))),
+				httpclient.WithDisableRetry(),
+			},
+			ExpectedCalls: 1,
+		},
+	} {
+		t.Run(test.Name, func(t *testing.T) {
+			calls.Store(0)
+			_, err := client.Get(context.Background(), test.Params...)
+			require.Error(t, err)
+			assert.Equal(t, test.ExpectedCalls, int(calls.Load()))
+		})
+	}
+
+	t.Run("timeout", func(t *testing.T) {
+		calls.Store(0)
+		_, err := client.Get(context.Background(), httpclient.WithEndpointName("slow"), httpclient.WithPath("/slow"))
+		require.Error(t, err)
+		var timeoutErr *httpclient.RequestTimeoutError
+		require.True(t, errors.As(err, &timeoutErr), "expected a request timeout error but got %v", err)
+		assert.Equal(t, int32(1), calls.Load())
+	})
+}
+
+func TestRequestTimeoutBoundsRetries(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if req.URL.Path == "/stream" {
+			rw.WriteHeader(http.StatusOK)
+			_, _ = rw.Write([]byte("partial"))
+			rw.(http.Flusher).Flush()
+			<-req.Context().Done()
+			return
+		}
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client, err := httpclient.NewClient(
+		httpclient.WithBaseURLs([]string{server.URL}),
+		httpclient.WithUnlimitedRetries(),
+		httpclient.WithInitialBackoff(10*time.Millisecond),
+		httpclient.WithMaxBackoff(10*time.Millisecond),
+	)
+	require.NoError(t, err)
+
+	t.Run("bounds all attempts", func(t *testing.T) {
+		start := time.Now()
+		_, err := client.Get(context.Background(), httpclient.WithRequestTimeout(100*time.Millisecond))
+		require.Error(t, err)
+		assert.Less(t, time.Since(start), time.Second)
+		assert.Greater(t, atomic.LoadInt32(&calls), int32(1), "expected the request to be retried")
+		var timeoutErr *httpclient.RequestTimeoutError
+		require.True(t, errors.As(err, &timeoutErr), "expected RequestTimeoutError, got %v", err)
+		assert.Equal(t, 100*time.Millisecond, timeoutErr.Timeout)
+		assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	})
+
+	t.Run("bounds raw response body", func(t *testing.T) {
+		resp, err := client.Get(context.Background(), httpclient.WithPath("/stream"), httpclient.WithRawResponseBody(), httpclient.WithRequestTimeout(100*time.Millisecond))
+		require.NoError(t, err)
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+		body, err := io.ReadAll(resp.Body)
+		assert.Equal(t, "partial", string(body))
+		assert.True(t, errors.Is(err, context.DeadlineExceeded), "expected deadline exceeded, got %v", err)
+	})
+}
+
+func TestMaxResponseHeaderBytes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("X-Large", strings.Repeat("a", 8192))
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client, err := httpclient.NewClient(
+		httpclient.WithBaseURLs([]string{server.URL}),
+		httpclient.WithMaxRetries(0),
+		httpclient.WithMaxResponseHeaderBytes(4096),
+	)
+	require.NoError(t, err)
+	_, err = client.Get(context.Background())
+	assert.ErrorContains(t, err, "server response headers exceeded 4096 bytes")
+
+	client, err = httpclient.NewClient(httpclient.WithBaseURLs([]string{server.URL}))
+	require.NoError(t, err)
+	_, err = client.Get(context.Background())
+	assert.NoError(t, err)
+
+	_, err = httpclient.NewClient(httpclient.WithBaseURLs([]string{server.URL}), httpclient.WithMaxResponseHeaderBytes(-1))
+	assert.EqualError(t, err, "httpclient: max response header bytes must not be negative")
+}
+
+type stubTransport struct {
+	requests []*http.Request
+	statuses []int
+}
+
+func (s *stubTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	s.requests = append(s.requests, req)
+	status := http.StatusOK
+	if len(s.statuses) > 0 {
+		status, s.statuses = s.statuses[0], s.statuses[1:]
+	}
+	return &http.Response{
+		StatusCode:    status,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          io.NopCloser(strings.NewReader(`{"key":"value"}`)),
+		ContentLength: -1,
+		Request:       req,
+	}, nil
+}
+
+func TestBaseTransport(t *testing.T) {
+	t.Run("middlewares wrap the base transport", func(t *testing.T) {
+		stub := &stubTransport{statuses: []int{http.StatusServiceUnavailable}}
+		var middlewareCalls int
+		client, err := httpclient.NewClient(
+			httpclient.WithBaseURLs([]string{"https://stub.example.com"}),
+			httpclient.WithBaseTransport(stub),
+			httpclient.WithBackoffStrategy(httpclient.NewConstantBackoffStrategy(0)),
+			httpclient.WithMiddleware(httpclient.MiddlewareFunc(func(req *http.Request, next http.RoundTripper) (*http.Response, error) {
+				middlewareCalls++
+				return next.RoundTrip(req)
+			})),
+		)
+		require.NoError(t, err)
+
+		var output map[string]string
+		resp, err := client.Get(context.Background(), httpclient.WithPath("/path"), httpclient.WithJSONResponse(&output))
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, map[string]string{"key": "value"}, output)
+		assert.Equal(t, 2, middlewareCalls, "expected the 503 to be retried through the middleware")
+		require.Len(t, stub.requests, 2)
+		assert.Equal(t, "https://stub.example.com/path", stub.requests[1].URL.String())
+	})
+
+	t.Run("error responses are decoded", func(t *testing.T) {
+		stub := &stubTransport{statuses: []int{http.StatusBadRequest}}
+		client, err := httpclient.NewClient(
+			httpclient.WithBaseURLs([]string{"https://stub.example.com"}),
+			httpclient.WithBaseTransport(stub),
+		)
+		require.NoError(t, err)
+
+		_, err = client.Get(context.Background())
+		require.Error(t, err)
+		status, ok := httpclient.StatusCodeFromError(err)
+		require.True(t, ok)
+		assert.Equal(t, http.StatusBadRequest, status)
+	})
+
+	t.Run("http client", func(t *testing.T) {
+		stub := &stubTransport{}
+		client, err := httpclient.NewHTTPClient(httpclient.WithBaseTransport(stub))
+		require.NoError(t, err)
+
+		resp, err := client.Get("https://stub.example.com")
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		require.Len(t, stub.requests, 1)
+	})
+
+	t.Run("tls config is rejected", func(t *testing.T) {
+		_, err := httpclient.NewClient(
+			httpclient.WithBaseURLs([]string{"https://stub.example.com"}),
+			httpclient.WithBaseTransport(&stubTransport{}),
+			httpclient.WithTLSConfig(&tls.Config{}),
+		)
+		require.EqualError(t, err, "httpclient: TLS configuration params can not be used with WithBaseTransport")
+	})
+}
+
+func TestClientWithOptions(t *testing.T) {
+	var newConns int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/slow" {
+			time.Sleep(100 * time.Millisecond)
+		}
+		rw.Header().Set("X-Echo", req.Header.Get("X-Derived"))
+	}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&newConns, 1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	parent, err := httpclient.NewClient(httpclient.WithBaseURLs([]string{server.URL}), httpclient.WithMaxRetries(0))
+	require.NoError(t, err)
+	derivable, ok := parent.(httpclient.DerivableClient)
+	require.True(t, ok)
+
+	derived, err := derivable.WithOptions(
+		httpclient.WithHTTPTimeout(10*time.Millisecond),
+		httpclient.WithSetHeader("X-Derived", "true"),
+	)
+	require.NoError(t, err)
+
+	resp, err := parent.Get(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, resp.Header.Get("X-Echo"), "parent should not be modified by the derived client's params")
+	resp, err = derived.Get(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "true", resp.Header.Get("X-Echo"))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&newConns), "derived client should share the parent's connection pool")
+
+	_, err = derived.Get(context.Background(), httpclient.WithPath("/slow"))
+	require.Error(t, err, "derived client should use its own timeout")
+	_, err = parent.Get(context.Background(), httpclient.WithPath("/slow"))
+	require.NoError(t, err)
+
+	t.Run("transport params build a new transport", func(t *testing.T) {
+		atomic.StoreInt32(&newConns, 0)
+		derived, err := derivable.WithOptions(httpclient.WithMaxIdleConnsPerHost(1))
+		require.NoError(t, err)
+		_, err = derived.Get(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&newConns))
+	})
+}
+
+func TestConnectionWarmup(t *testing.T) {
+	var newConns, idleConns int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		switch state {
+		case http.StateNew:
+			atomic.AddInt32(&newConns, 1)
+		case http.StateIdle:
+			atomic.AddInt32(&idleConns, 1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	client, err := httpclient.NewClient(
+		httpclient.WithBaseURLs([]string{server.URL}),
+		httpclient.WithConnectionWarmup(3),
+	)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&idleConns) == 3
+	}, time.Second, time.Millisecond)
+	// the server marks connections idle just before the client returns them to its pool.
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&newConns))
+
+	_, err = client.Get(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&newConns), "expected the request to reuse a warm connection")
+
+	t.Run("failures are not fatal", func(t *testing.T) {
+		_, err := httpclient.NewClient(
+			httpclient.WithBaseURLs([]string{"http://localhost:1"}),
+			httpclient.WithConnectionWarmup(1),
+		)
+		require.NoError(t, err)
+	})
+	t.Run("skipped when replaying", func(t *testing.T) {
+		atomic.StoreInt32(&newConns, 0)
+		_, err := httpclient.NewClient(
+			httpclient.WithBaseURLs([]string{server.URL}),
+			httpclient.WithConnectionWarmup(1),
+			httpclient.WithReplayer(httpclient.NewInMemoryInteractionStore()),
+		)
+		require.NoError(t, err)
+		time.Sleep(200 * time.Millisecond)
+		assert.Equal(t, int32(0), atomic.LoadInt32(&newConns))
+	})
+}
+
+func TestEarlyHintsCallback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Link", "</style.css>; rel=preload; as=style")
+		rw.WriteHeader(http.StatusEarlyHints)
+		rw.Header().Del("Link")
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client, err := httpclient.NewClient(httpclient.WithBaseURLs([]string{server.URL}))
+	require.NoError(t, err)
+
+	var hints []http.Header
+	resp, err := client.Get(context.Background(), httpclient.WithEarlyHintsCallback(func(header http.Header) {
+		hints = append(hints, header)
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Len(t, hints, 1)
+	assert.Equal(t, "</style.css>; rel=preload; as=style", hints[0].Get("Link"))
+
+	// the callback is optional
+	_, err = client.Get(context.Background())
+	require.NoError(t, err)
+}
+
+func TestPerAttemptTimeout(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 || req.URL.Path == "/slow" {
+			select {
+			case <-req.Context().Done():
+			case <-time.After(time.Second):
+			}
+			return
+		}
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client, err := httpclient.NewClient(
+		httpclient.WithBaseURLs([]string{server.URL}),
+		httpclient.WithMaxRetries(2),
+		httpclient.WithInitialBackoff(time.Millisecond),
+		httpclient.WithMaxBackoff(time.Millisecond),
+	)
+	require.NoError(t, err)
+
+	t.Run("timed out attempt is retried", func(t *testing.T) {
+		resp, err := client.Get(context.Background(), httpclient.WithPerAttemptTimeout(50*time.Millisecond))
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("last attempt timed out", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 0)
+		_, err := client.Get(context.Background(), httpclient.WithPath("/slow"), httpclient.WithPerAttemptTimeout(50*time.Millisecond))
+		require.Error(t, err)
+		assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+		var attemptErr *httpclient.AttemptTimeoutError
+		require.True(t, errors.As(err, &attemptErr), "expected AttemptTimeoutError, got %v", err)
+		assert.Equal(t, 50*time.Millisecond, attemptErr.Timeout)
+		assert.True(t, errors.Is(err, context.DeadlineExceeded))
+		assert.True(t, httpclient.IsConnectionError(err), "expected ConnectionError, got %v", err)
+	})
+
+	t.Run("timed out POST is not retried", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 0)
+		_, err := client.Post(context.Background(), httpclient.WithPath("/slow"), httpclient.WithPerAttemptTimeout(50*time.Millisecond))
+		require.Error(t, err)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+		var attemptErr *httpclient.AttemptTimeoutError
+		assert.True(t, errors.As(err, &attemptErr), "expected AttemptTimeoutError, got %v", err)
+	})
+
+	t.Run("timed out POST is retried by predicate", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 0)
+		retryingClient, err := httpclient.NewClient(
+			httpclient.WithBaseURLs([]string{server.URL}),
+			httpclient.WithMaxRetries(2),
+			httpclient.WithInitialBackoff(time.Millisecond),
+			httpclient.WithMaxBackoff(time.Millisecond),
+			httpclient.WithConnectionErrorRetryPredicate(func(*http.Request, error) bool { return true }),
+		)
+		require.NoError(t, err)
+		_, err = retryingClient.Post(context.Background(), httpclient.WithPath("/slow"), httpclient.WithPerAttemptTimeout(50*time.Millisecond))
+		require.Error(t, err)
+		assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("request timeout expired", func(t *testing.T) {
+		_, err := client.Get(context.Background(), httpclient.WithPath("/slow"),
+			httpclient.WithPerAttemptTimeout(500*time.Millisecond),
+			httpclient.WithRequestTimeout(50*time.Millisecond))
+		require.Error(t, err)
+		var requestErr *httpclient.RequestTimeoutError
+		assert.True(t, errors.As(err, &requestErr), "expected RequestTimeoutError, got %v", err)
+		var attemptErr *httpclient.AttemptTimeoutError
+		assert.False(t, errors.As(err, &attemptErr), "expected no AttemptTimeoutError, got %v", err)
+	})
+}
+
+func TestAppendRuntimeUserAgent(t *testing.T) {
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		received = append(received, req.Header.Get("User-Agent"))
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client, err := httpclient.NewClient(
+		httpclient.WithBaseURLs([]string{server.URL}),
+		httpclient.WithUserAgent("client-agent/1.0.0"),
+		httpclient.WithAppendRuntimeUserAgent(),
+	)
+	require.NoError(t, err)
+
+	_, err = client.Get(context.Background())
+	require.NoError(t, err)
+	_, err = client.Get(context.Background(), httpclient.WithHeader("User-Agent", "request-agent/2.0.0"))
+	require.NoError(t, err)
+
+	require.Len(t, received, 2)
+	assert.Regexp(t, `^client-agent/1\.0\.0 conjure-go-runtime(/\S+)?$`, received[0])
+	assert.Regexp(t, `^request-agent/2\.0\.0 conjure-go-runtime(/\S+)?$`, received[1])
+}
+
+func TestRequestMutator(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		calls++
+		if req.Header.Get("X-Attempt") == "1" {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = rw.Write([]byte(req.Header.Get("X-Url") + " " + req.Header.Get("X-Body")))
+	}))
+	defer server.Close()
+
+	client, err := httpclient.NewClient(
+		httpclient.WithBaseURLs([]string{server.URL}),
+		httpclient.WithMiddleware(httpclient.MiddlewareFunc(func(req *http.Request, next http.RoundTripper) (*http.Response, error) {
+			req.URL.Path += "/middleware"
+			return next.RoundTrip(req)
+		})),
+		httpclient.WithInitialBackoff(time.Millisecond),
+		httpclient.WithMaxBackoff(time.Millisecond),
+	)
+	require.NoError(t, err)
+
+	t.Run("called on each attempt with the final request", func(t *testing.T) {
+		calls = 0
+		var attempts int
+		var output string
+		_, err := client.Post(context.Background(),
+			httpclient.WithPath("/path"),
+			httpclient.WithQueryValues(map[string][]string{"q": {"1"}}),
+			httpclient.WithRequestBody("body", codecs.Plain),
+			httpclient.WithRequestMutator(func(req *http.Request) error {
+				attempts++
+				req.Header.Set("X-Attempt", strconv.Itoa(attempts))
+				return nil
+			}),
+			httpclient.WithRequestMutator(func(req *http.Request) error {
+				body, err := req.GetBody()
+				if err != nil {
+					return err
+				}
+				defer func() {
+					_ = body.Close()
+				}()
+				content, err := io.ReadAll(body)
+				if err != nil {
+					return err
+				}
+				req.Header.Set("X-Url", req.URL.RequestURI())
+				req.Header.Set("X-Body", string(content))
+				return nil
+			}),
+			httpclient.WithResponseBody(&output, codecs.Plain))
+		require.NoError(t, err)
+		assert.Equal(t, "/path/middleware?q=1 body", output)
+		assert.Equal(t, 2, attempts)
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("error aborts without retry", func(t *testing.T) {
+		calls = 0
+		var attempts int
+		_, err := client.Get(context.Background(), httpclient.WithRequestMutator(func(req *http.Request) error {
+			attempts++
+			return fmt.Errorf("mutator failed")
+		}))
+		require.EqualError(t, err, "httpclient request failed: mutator failed")
+		assert.Equal(t, 1, attempts)
+		assert.Equal(t, 0, calls)
+	})
+}
+
+func TestHostHeader(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte(req.Host))
+	}))
+	defer server.Close()
+	serverURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	for _, test := range []struct {
+		Name         string
+		ClientParams []httpclient.ClientParam
+		Params       []httpclient.RequestParam
+		ExpectedHost string
+	}{
+		{
+			Name:         "default",
+			ExpectedHost: serverURL.Host,
+		},
+		{
+			Name:         "request host",
+			Params:       []httpclient.RequestParam{httpclient.WithHostHeader("virtual.example.com")},
+			ExpectedHost: "virtual.example.com",
+		},
+		{
+			Name:         "request host overrides client host",
+			ClientParams: []httpclient.ClientParam{httpclient.WithOverrideRequestHost("client.example.com")},
+			Params:       []httpclient.RequestParam{httpclient.WithHostHeader("virtual.example.com")},
+			ExpectedHost: "virtual.example.com",
+		},
+		{
+			Name:         "host header entry is ignored",
+			Params:       []httpclient.RequestParam{httpclient.WithHeader("Host", "header.example.com")},
+			ExpectedHost: serverURL.Host,
+		},
+	} {
+		t.Run(test.Name, func(t *testing.T) {
+			client, err := httpclient.NewClient(append(test.ClientParams, httpclient.WithBaseURLs([]string{server.URL}))...)
+			require.NoError(t, err)
+			var host string
+			_, err = client.Get(context.Background(), append(test.Params, httpclient.WithResponseBody(&host, codecs.Plain))...)
+			require.NoError(t, err)
+			assert.Equal(t, test.ExpectedHost, host)
+		})
+	}
+
+	client, err := httpclient.NewClient(httpclient.WithBaseURLs([]string{server.URL}))
+	require.NoError(t, err)
+	_, err = client.Get(context.Background(), httpclient.WithHostHeader(""))
+	require.EqualError(t, err, "host can not be empty")
+}
+
+func TestConsistentHashURIScoring(t *testing.T) {
+	var urls []string
+	for i := 0; i < 3; i++ {
+		name := fmt.Sprintf("server-%d", i)
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			_, _ = rw.Write([]byte(name))
+		}))
+		defer server.Close()
+		urls = append(urls, server.URL)
+	}
+
+	getServer := func(t *testing.T, client httpclient.Client, params ...httpclient.RequestParam) string {
+		var name string
+		_, err := client.Get(context.Background(), append(params, httpclient.WithResponseBody(&name, codecs.Plain))...)
+		require.NoError(t, err)
+		return name
+	}
+
+	t.Run("same key prefers same URI", func(t *testing.T) {
+		client, err := httpclient.NewClient(httpclient.WithBaseURLs(urls), httpclient.WithConsistentHashURIScoring())
+		require.NoError(t, err)
+		// clients with the same URIs map keys identically
+		otherClient, err := httpclient.NewClient(httpclient.WithBaseURLs(urls), httpclient.WithConsistentHashURIScoring())
+		require.NoError(t, err)
+
+		servers := make(map[string]struct{})
+		for i := 0; i < 30; i++ {
+			key := fmt.Sprintf("key-%d", i)
+			server := getServer(t, client, httpclient.WithShardKey(key))
+			for j := 0; j < 3; j++ {
+				assert.Equal(t, server, getServer(t, client, httpclient.WithShardKey(key)), key)
+			}
+			assert.Equal(t, server, getServer(t, otherClient, httpclient.WithShardKey(key)), key)
+			servers[server] = struct{}{}
+		}
+		assert.Len(t, servers, 3, "keys should be spread across all URIs")
+
+		// requests without a key use balanced scoring
+		_ = getServer(t, client)
+	})
+
+	t.Run("default scoring ignores key", func(t *testing.T) {
+		client, err := httpclient.NewClient(httpclient.WithBaseURLs(urls))
+		require.NoError(t, err)
+		servers := make(map[string]struct{})
+		for i := 0; i < 50; i++ {
+			servers[getServer(t, client, httpclient.WithShardKey("key"))] = struct{}{}
+		}
+		assert.Greater(t, len(servers), 1)
+	})
+}
+
+func TestDNSCache(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	serverURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	serverURL.Host = net.JoinHostPort("localhost", serverURL.Port())
+
+	client, err := httpclient.NewClient(
+		httpclient.WithBaseURLs([]string{serverURL.String()}),
+		httpclient.WithDNSCache(time.Minute),
+		httpclient.WithDisableKeepAlives())
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	_, err = httpclient.NewClient(httpclient.WithDNSCache(-time.Second))
+	assert.EqualError(t, err, "httpclient: DNS cache TTL must not be negative")
+}
+
+func TestLocalAddr(t *testing.T) {
+	var remoteAddr string
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		remoteAddr = req.RemoteAddr
+	}))
+	defer server.Close()
+
+	// binding to another interface depends on the environment, so bind to the loopback address of the server.
+	client, err := httpclient.NewClient(
+		httpclient.WithBaseURLs([]string{server.URL}),
+		httpclient.WithLocalAddr(&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)}))
+	require.NoError(t, err)
+	_, err = client.Get(context.Background())
+	require.NoError(t, err)
+	host, _, err := net.SplitHostPort(remoteAddr)
+	require.NoError(t, err)
+	assert.Equal(t, "127.0.0.1", host)
+
+	client, err = httpclient.NewClient(
+		httpclient.WithBaseURLs([]string{server.URL}),
+		httpclient.WithLocalAddr(&net.TCPAddr{IP: net.ParseIP("192.0.2.1")}),
+		httpclient.WithMaxRetries(0))
+	require.NoError(t, err)
+	_, err = client.Get(context.Background())
+	assert.True(t, httpclient.IsConnectionError(err), "binding to an address not on this host should fail: %v", err)
+}
+
+func TestErrorParams(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	client, err := httpclient.NewClient(httpclient.WithBaseURLs([]string{server.URL}))
+	require.NoError(t, err)
+
+	_, err = client.Get(context.Background(),
+		httpclient.WithPath("/resources/123"),
+		httpclient.WithErrorSafeParam("endpoint", "getResource"),
+		httpclient.WithErrorUnsafeParam("resourceID", "123"))
+	require.Error(t, err)
+	safeParams, unsafeParams := werror.ParamsFromError(err)
+	assert.Equal(t, "getResource", safeParams["endpoint"])
+	assert.Equal(t, "123", unsafeParams["resourceID"])
+	assert.Equal(t, "httpclient request failed: 404 Not Found", err.Error())
+	statusCode, ok := httpclient.StatusCodeFromError(err)
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusNotFound, statusCode)
+
+	resp, err := client.Get(context.Background(), httpclient.WithPath("/"), httpclient.WithErrorSafeParam("endpoint", "root"),
+		httpclient.WithRawResponseBodyOnError())
+	require.NoError(t, err, "params are only added to errors")
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestTLSVerifyPeerCertificate(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(server.Certificate())
+
+	errRejected := errors.New("peer certificate rejected")
+	for _, test := range []struct {
+		Name        string
+		TLSParam    httpclient.ClientOrHTTPClientParam
+		Reject      bool
+		VerifiedLen int
+	}{
+		{Name: "accepted with verified chain", TLSParam: httpclient.WithTLSConfig(&tls.Config{RootCAs: rootCAs}), VerifiedLen: 1},
+		{Name: "rejected with verified chain", TLSParam: httpclient.WithTLSConfig(&tls.Config{RootCAs: rootCAs}), Reject: true, VerifiedLen: 1},
+		{Name: "accepted without verification", TLSParam: httpclient.WithTLSInsecureSkipVerify()},
+		{Name: "rejected without verification", TLSParam: httpclient.WithTLSInsecureSkipVerify(), Reject: true},
+	} {
+		t.Run(test.Name, func(t *testing.T) {
+			var rawCertsLen, verifiedLen int
+			client, err := httpclient.NewClient(
+				httpclient.WithBaseURLs([]string{server.URL}),
+				httpclient.WithMaxRetries(0),
+				test.TLSParam,
+				httpclient.WithTLSVerifyPeerCertificate(func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+					rawCertsLen, verifiedLen = len(rawCerts), len(verifiedChains)
+					if test.Reject {
+						return errRejected
+					}
+					return nil
+				}),
+			)
+			require.NoError(t, err)
+			resp, err := client.Get(context.Background())
+			assert.Equal(t, 1, rawCertsLen)
+			assert.Equal(t, test.VerifiedLen, verifiedLen)
+			if test.Reject {
+				assert.ErrorIs(t, err, errRejected)
+				return
+			}
+			require.NoError(t, err)
+			require.NoError(t, resp.Body.Close())
+		})
+	}
+}
+
+func TestTLSClientCertFromRefreshable(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = fmt.Fprint(rw, req.TLS.PeerCertificates[0].Subject.CommonName)
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	firstCert, secondCert := newTestClientCert(t, "first"), newTestClientCert(t, "second")
+	cert := refreshable.NewDefaultRefreshable(firstCert)
+	client, err := httpclient.NewClient(
+		httpclient.WithBaseURLs([]string{server.URL}),
+		httpclient.WithTLSInsecureSkipVerify(),
+		httpclient.WithDisableKeepAlives(),
+		httpclient.WithMaxRetries(0),
+		httpclient.WithTLSClientCertFromRefreshable(cert),
+	)
+	require.NoError(t, err)
+	getCommonName := func() string {
+		resp, err := client.Get(context.Background(), httpclient.WithRawResponseBody())
+		require.NoError(t, err)
+		defer func() { _ = resp.Body.Close() }()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return string(body)
+	}
+	assert.Equal(t, "first", getCommonName())
+	require.NoError(t, cert.Update(secondCert))
+	assert.Equal(t, "second", getCommonName(), "expected the rotated certificate on the next handshake")
+
+	_, err = httpclient.NewClient(httpclient.WithTLSClientCertFromRefreshable(refreshable.NewDefaultRefreshable("cert")))
+	assert.EqualError(t, err, "httpclient: TLS client certificate refreshable must contain a *tls.Certificate")
+}
+
+func newTestClientCert(t *testing.T, commonName string) *tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestRefreshableTLSConfig(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	trustedCAs := x509.NewCertPool()
+	trustedCAs.AddCert(server.Certificate())
+
+	tlsConfig := refreshable.NewDefaultRefreshable(&tls.Config{RootCAs: x509.NewCertPool()})
+	client, err := httpclient.NewClient(
+		httpclient.WithBaseURLs([]string{server.URL}),
+		httpclient.WithMaxRetries(0),
+		httpclient.WithRefreshableTLSConfig(tlsConfig),
+	)
+	require.NoError(t, err)
+
+	_, err = client.Get(context.Background())
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	require.ErrorAs(t, err, &unknownAuthorityErr, "expected the server to be untrusted by the initial config")
+
+	require.NoError(t, tlsConfig.Update(&tls.Config{RootCAs: trustedCAs}))
+	resp, err := client.Get(context.Background())
+	require.NoError(t, err, "expected the transport to be rebuilt with the updated config")
+	require.NoError(t, resp.Body.Close())
+
+	_, err = httpclient.NewClient(httpclient.WithRefreshableTLSConfig(refreshable.NewDefaultRefreshable("config")))
+	assert.EqualError(t, err, "httpclient: refreshable TLS config must contain a *tls.Config")
+}
@@ -0,0 +1,125 @@
+// Copyright (c) 2024 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// RequestTimings are the durations of the network phases of a single request attempt.
+// Phases which did not occur are zero: for example, DNSLookup when dialing an IP address,
+// TLSHandshake for plaintext connections, and all connection phases when a connection is reused.
+type RequestTimings struct {
+	// DNSLookup is the time spent resolving the host name.
+	DNSLookup time.Duration
+	// Connect is the time spent establishing the TCP connection.
+	Connect time.Duration
+	// TLSHandshake is the time spent performing the TLS handshake.
+	TLSHandshake time.Duration
+	// TimeToFirstByte is the time from the start of the attempt until the first response byte was received.
+	// It includes the other phases and the time taken by the server to respond.
+	TimeToFirstByte time.Duration
+	// ConnReused is true if the attempt was sent on a previously used connection.
+	ConnReused bool
+}
+
+// ClientTraceFunc is called once per request attempt with the timings of that attempt and the error
+// of the attempt, if any.
+type ClientTraceFunc func(req *http.Request, timings RequestTimings, err error)
+
+// clientTraceMiddleware installs an httptrace.ClientTrace on each attempt and reports the recorded timings.
+// Hooks installed by other middleware on the request context are preserved.
+type clientTraceMiddleware struct {
+	callback ClientTraceFunc
+}
+
+func (m clientTraceMiddleware) RoundTrip(req *http.Request, next http.RoundTripper) (*http.Response, error) {
+	recorder := &requestTimingsRecorder{start: time.Now()}
+	resp, err := next.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), recorder.clientTrace())))
+	m.callback(req, recorder.timings(), err)
+	return resp, err
+}
+
+// requestTimingsRecorder records the phases of an attempt. The hooks of a ClientTrace may be called
+// concurrently, e.g. when dialing multiple addresses, so all fields are guarded by mu.
+type requestTimingsRecorder struct {
+	mu sync.Mutex
+
+	start          time.Time
+	dnsStart       time.Time
+	connectStart   time.Time
+	tlsStart       time.Time
+	recordedTiming RequestTimings
+}
+
+func (r *requestTimingsRecorder) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.dnsStart = time.Now()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.recordedTiming.DNSLookup = time.Since(r.dnsStart)
+		},
+		ConnectStart: func(string, string) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			// Keep the first start when dialing multiple addresses in parallel.
+			if r.connectStart.IsZero() {
+				r.connectStart = time.Now()
+			}
+		},
+		ConnectDone: func(_, _ string, err error) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			if err == nil {
+				r.recordedTiming.Connect = time.Since(r.connectStart)
+			}
+		},
+		TLSHandshakeStart: func() {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.recordedTiming.TLSHandshake = time.Since(r.tlsStart)
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.recordedTiming.ConnReused = info.Reused
+		},
+		GotFirstResponseByte: func() {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.recordedTiming.TimeToFirstByte = time.Since(r.start)
+		},
+	}
+}
+
+func (r *requestTimingsRecorder) timings() RequestTimings {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.recordedTiming
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2024 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/palantir/conjure-go-runtime/v2/conjure-go-client/httpclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientTrace(t *testing.T) {
+	var requests int32
+	server := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	var timings []httpclient.RequestTimings
+	var errs []error
+	client, err := httpclient.NewClient(
+		httpclient.WithBaseURLs([]string{server.URL}),
+		httpclient.WithTLSInsecureSkipVerify(),
+		httpclient.WithInitialBackoff(time.Millisecond),
+		httpclient.WithMaxBackoff(time.Millisecond),
+		httpclient.WithClientTrace(func(req *http.Request, reqTimings httpclient.RequestTimings, err error) {
+			timings = append(timings, reqTimings)
+			errs = append(errs, err)
+		}),
+	)
+	require.NoError(t, err)
+
+	_, err = client.Get(context.Background())
+	require.NoError(t, err)
+
+	require.Len(t, timings, 2, "expected one callback per attempt")
+	assert.Error(t, errs[0], "expected the unavailable error of the first attempt")
+	assert.NoError(t, errs[1])
+
+	first := timings[0]
+	assert.False(t, first.ConnReused)
+	assert.Zero(t, first.DNSLookup, "dialing an IP address should not resolve DNS")
+	assert.Positive(t, first.Connect)
+	assert.Positive(t, first.TLSHandshake)
+	assert.GreaterOrEqual(t, first.TimeToFirstByte, first.Connect+first.TLSHandshake)
+
+	second := timings[1]
+	assert.True(t, second.ConnReused)
+	assert.Zero(t, second.Connect)
+	assert.Zero(t, second.TLSHandshake)
+	assert.Positive(t, second.TimeToFirstByte)
+}
+
+func TestClientTraceNilCallback(t *testing.T) {
+	_, err := httpclient.NewClient(
+		httpclient.WithBaseURLs([]string{"https://localhost"}),
+		httpclient.WithClientTrace(nil),
+	)
+	require.EqualError(t, err, "callback can not be nil")
+}
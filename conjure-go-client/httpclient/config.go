@@ -61,6 +61,8 @@ type ClientConfig struct {
 	ProxyFromEnvironment *bool `json:"proxy-from-environment,omitempty" yaml:"proxy-from-environment,omitempty"`
 	// ProxyURL uses the provided URL for proxying the request. Schemes http, https, and socks5 are supported.
 	ProxyURL *string `json:"proxy-url,omitempty" yaml:"proxy-url,omitempty"`
+	// UserAgent is the User-Agent header sent on each request, unless the request or a client param sets one.
+	UserAgent *string `json:"user-agent,omitempty" yaml:"user-agent,omitempty"`
 
 	// MaxNumRetries controls the number of times the client will retry retryable failures.
 	// If unset, this defaults to twice the number of URIs provided.
@@ -137,6 +139,15 @@ type SecurityConfig struct {
 	// InsecureSkipVerify sets the InsecureSkipVerify field for the HTTP client's tls config.
 	// This option should only be used in clients that have other ways to establish trust with servers.
 	InsecureSkipVerify *bool `json:"insecure-skip-verify,omitempty" yaml:"insecure-skip-verify,omitempty"`
+
+	// PKCS12File is the path to a PKCS#12 (.p12/.pfx) bundle containing the client certificate and private key,
+	// decrypted with PKCS12Password. It must not be set with CertFile or KeyFile.
+	PKCS12File     string `json:"pkcs12-file,omitempty" yaml:"pkcs12-file,omitempty"`
+	PKCS12Password string `json:"pkcs12-password,omitempty" yaml:"pkcs12-password,omitempty"`
+
+	// AppendSystemCAs trusts the system root CAs in addition to CAFiles, rather than only CAFiles.
+	// This is useful for clients which call both internal services and public endpoints.
+	AppendSystemCAs *bool `json:"append-system-cas,omitempty" yaml:"append-system-cas,omitempty"`
 }
 
 // MustClientConfig returns an error if the service name is not configured.
@@ -231,6 +242,9 @@ func MergeClientConfig(conf, defaults ClientConfig) ClientConfig {
 	if conf.ProxyURL == nil {
 		conf.ProxyURL = defaults.ProxyURL
 	}
+	if conf.UserAgent == nil {
+		conf.UserAgent = defaults.UserAgent
+	}
 
 	if len(defaults.Metrics.Tags) != 0 {
 		if conf.Metrics.Tags == nil {
@@ -245,15 +259,20 @@ func MergeClientConfig(conf, defaults ClientConfig) ClientConfig {
 	if conf.Security.CAFiles == nil {
 		conf.Security.CAFiles = defaults.Security.CAFiles
 	}
-	if conf.Security.CertFile == "" {
+	// The client certificate is inherited as a unit so that a service's PEM certificate is never mixed with
+	// a default PKCS#12 bundle, or vice versa.
+	if conf.Security.CertFile == "" && conf.Security.KeyFile == "" && conf.Security.PKCS12File == "" && conf.Security.PKCS12Password == "" {
 		conf.Security.CertFile = defaults.Security.CertFile
-	}
-	if conf.Security.KeyFile == "" {
 		conf.Security.KeyFile = defaults.Security.KeyFile
+		conf.Security.PKCS12File = defaults.Security.PKCS12File
+		conf.Security.PKCS12Password = defaults.Security.PKCS12Password
 	}
 	if conf.Security.InsecureSkipVerify == nil {
 		conf.Security.InsecureSkipVerify = defaults.Security.InsecureSkipVerify
 	}
+	if conf.Security.AppendSystemCAs == nil {
+		conf.Security.AppendSystemCAs = defaults.Security.AppendSystemCAs
+	}
 	return conf
 }
 
@@ -282,6 +301,10 @@ func configToParams(c ClientConfig) ([]ClientParam, error) {
 		params = append(params, WithBasicAuth(c.BasicAuth.User, c.BasicAuth.Password))
 	}
 
+	if c.UserAgent != nil && *c.UserAgent != "" {
+		params = append(params, WithUserAgent(*c.UserAgent))
+	}
+
 	// Disable HTTP2 (http2 is enabled by default)
 	if c.DisableHTTP2 != nil && *c.DisableHTTP2 {
 		params = append(params, WithDisableHTTP2())
@@ -370,6 +393,9 @@ func configToParams(c ClientConfig) ([]ClientParam, error) {
 		CertFile:           c.Security.CertFile,
 		KeyFile:            c.Security.KeyFile,
 		InsecureSkipVerify: derefPtr(c.Security.InsecureSkipVerify, false),
+		PKCS12File:         c.Security.PKCS12File,
+		PKCS12Password:     c.Security.PKCS12Password,
+		AppendSystemCAs:    derefPtr(c.Security.AppendSystemCAs, false),
 	}); err != nil {
 		return nil, err
 	} else if tlsConfig != nil {
@@ -407,6 +433,9 @@ func newValidatedClientParamsFromConfig(ctx context.Context, config ClientConfig
 			CertFile:           config.Security.CertFile,
 			KeyFile:            config.Security.KeyFile,
 			InsecureSkipVerify: derefPtr(config.Security.InsecureSkipVerify, false),
+			PKCS12File:         config.Security.PKCS12File,
+			PKCS12Password:     config.Security.PKCS12Password,
+			AppendSystemCAs:    derefPtr(config.Security.AppendSystemCAs, false),
 		},
 	}
 
@@ -497,6 +526,7 @@ func newValidatedClientParamsFromConfig(ctx context.Context, config ClientConfig
 		Timeout:        timeout,
 		Transport:      transport,
 		URIs:           uris,
+		UserAgent:      config.UserAgent,
 	}, nil
 }
 
@@ -19,6 +19,7 @@ import (
 	"crypto/tls"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
@@ -50,7 +51,7 @@ func TestRefreshableClientConfig(t *testing.T) {
 		assert.Equal(t, false, initialTransport.DisableKeepAlives)
 		assert.NotNil(t, initialTransport.Proxy)
 
-		if assert.Len(t, initialMiddlewares, 3) {
+		if assert.Len(t, initialMiddlewares, 4) {
 			assert.IsType(t, recoveryMiddleware{}, initialMiddlewares[0])
 			if assert.IsType(t, traceMiddleware{}, initialMiddlewares[1]) {
 				traceM := initialMiddlewares[1].(traceMiddleware)
@@ -62,6 +63,7 @@ func TestRefreshableClientConfig(t *testing.T) {
 				assert.False(t, metricsM.Disabled.CurrentBool())
 				assert.Equal(t, serviceName, metricsM.ServiceName.CurrentString())
 			}
+			assert.IsType(t, connectionErrorMiddleware{}, initialMiddlewares[3])
 		}
 
 		if tlsConfig := initialTransport.TLSClientConfig; assert.NotNil(t, tlsConfig) {
@@ -283,3 +285,78 @@ func TestRefreshableClientConfig(t *testing.T) {
 func newDurationPtr(dur time.Duration) *time.Duration {
 	return &dur
 }
+
+func TestNewClientFromConfig(t *testing.T) {
+	var userAgent string
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		userAgent = req.UserAgent()
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	var config ClientConfig
+	require.NoError(t, yaml.Unmarshal([]byte(`
+uris:
+  - `+server.URL+`
+user-agent: my-service/1.0.0
+max-num-retries: 1
+`), &config))
+	configRefreshable := refreshable.NewDefaultRefreshable(config)
+
+	client, err := NewClientFromConfig(context.Background(), configRefreshable)
+	require.NoError(t, err)
+	_, err = client.Get(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "my-service/1.0.0", userAgent)
+
+	t.Run("param takes precedence over config", func(t *testing.T) {
+		client, err := NewClientFromConfig(context.Background(), configRefreshable, WithUserAgent("override/1.0.0"))
+		require.NoError(t, err)
+		_, err = client.Get(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "override/1.0.0", userAgent)
+	})
+
+	t.Run("valid update is applied", func(t *testing.T) {
+		updated := config
+		updated.UserAgent = &[]string{"my-service/2.0.0"}[0]
+		require.NoError(t, configRefreshable.Update(updated))
+		_, err = client.Get(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "my-service/2.0.0", userAgent)
+	})
+
+	t.Run("invalid update keeps last valid config", func(t *testing.T) {
+		invalid := config
+		invalid.UserAgent = &[]string{"my-service/3.0.0"}[0]
+		invalid.ProxyURL = &[]string{"not a url"}[0]
+		require.NoError(t, configRefreshable.Update(invalid))
+		_, err = client.Get(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "my-service/2.0.0", userAgent)
+	})
+}
+
+func TestDisableKeepAlivesAndHTTP2AcrossRefresh(t *testing.T) {
+	config := refreshable.NewDefaultRefreshable(ClientConfig{ServiceName: "serviceName", URIs: []string{"https://localhost"}})
+	client, err := NewClientFromRefreshableConfig(context.Background(), NewRefreshingClientConfig(config),
+		WithDisableKeepAlives(),
+		WithDisableHTTP2())
+	require.NoError(t, err)
+	httpClient := client.(*clientImpl).client
+
+	transport, _ := unwrapTransport(httpClient.CurrentHTTPClient().Transport)
+	assert.True(t, transport.DisableKeepAlives)
+	assert.NotContains(t, transport.TLSNextProto, "h2")
+
+	require.NoError(t, config.Update(ClientConfig{
+		ServiceName:     "serviceName",
+		URIs:            []string{"https://localhost"},
+		IdleConnTimeout: &[]time.Duration{time.Second}[0],
+	}))
+	newTransport, _ := unwrapTransport(httpClient.CurrentHTTPClient().Transport)
+	assert.NotSame(t, transport, newTransport, "expected transport to be rebuilt")
+	assert.Equal(t, time.Second, newTransport.IdleConnTimeout)
+	assert.True(t, newTransport.DisableKeepAlives)
+	assert.NotContains(t, newTransport.TLSNextProto, "h2")
+}
@@ -49,6 +49,58 @@ func TestServicesConfig(t *testing.T) {
 				ReadTimeout: &[]time.Duration{time.Minute}[0],
 			},
 		},
+		{
+			Name:        "service PKCS12 file overrides default cert and key files",
+			ServiceName: "my-service",
+			Config: ServicesConfig{
+				Default: ClientConfig{
+					Security: SecurityConfig{CertFile: "default.crt", KeyFile: "default.key"},
+				},
+				Services: map[string]ClientConfig{
+					"my-service": {
+						Security: SecurityConfig{PKCS12File: "service.p12", PKCS12Password: "password"},
+					},
+				},
+			},
+			ExpectedConfig: ClientConfig{
+				ServiceName: "my-service",
+				Security:    SecurityConfig{PKCS12File: "service.p12", PKCS12Password: "password"},
+			},
+		},
+		{
+			Name:        "service cert and key files override default PKCS12 file",
+			ServiceName: "my-service",
+			Config: ServicesConfig{
+				Default: ClientConfig{
+					Security: SecurityConfig{PKCS12File: "default.p12", PKCS12Password: "password"},
+				},
+				Services: map[string]ClientConfig{
+					"my-service": {
+						Security: SecurityConfig{CertFile: "service.crt", KeyFile: "service.key"},
+					},
+				},
+			},
+			ExpectedConfig: ClientConfig{
+				ServiceName: "my-service",
+				Security:    SecurityConfig{CertFile: "service.crt", KeyFile: "service.key"},
+			},
+		},
+		{
+			Name:        "default client certificate is inherited",
+			ServiceName: "my-service",
+			Config: ServicesConfig{
+				Default: ClientConfig{
+					Security: SecurityConfig{PKCS12File: "default.p12", PKCS12Password: "password"},
+				},
+				Services: map[string]ClientConfig{
+					"my-service": {},
+				},
+			},
+			ExpectedConfig: ClientConfig{
+				ServiceName: "my-service",
+				Security:    SecurityConfig{PKCS12File: "default.p12", PKCS12Password: "password"},
+			},
+		},
 	} {
 		t.Run(test.Name, func(t *testing.T) {
 			actual := test.Config.ClientConfig(test.ServiceName)
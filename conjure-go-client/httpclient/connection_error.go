@@ -0,0 +1,137 @@
+// Copyright (c) 2024 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+)
+
+// ConnectionError is returned when a request attempt fails before a response is received from the server, e.g.
+// because the host could not be resolved, the connection was refused or reset, or the TLS handshake failed.
+// Errors returned because the request context was canceled or its deadline expired are not ConnectionErrors, while
+// an attempt which fails because the timeout set by WithPerAttemptTimeout expired returns a ConnectionError.
+//
+// Use IsConnectionError to distinguish such failures from error responses of the server,
+// whose status code is returned by StatusCodeFromError.
+type ConnectionError struct {
+	Err error
+}
+
+func (e *ConnectionError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ConnectionError) Unwrap() error {
+	return e.Err
+}
+
+// Cause implements werror.Causer so that werror.RootCause returns the underlying transport error.
+func (e *ConnectionError) Cause() error {
+	return e.Err
+}
+
+// IsConnectionError returns true if err or any error it wraps is a *ConnectionError.
+func IsConnectionError(err error) bool {
+	var connErr *ConnectionError
+	return errors.As(err, &connErr)
+}
+
+// IsRetryableConnectionError is the default predicate used by the client to decide whether a request whose attempt
+// failed with a *ConnectionError is retried. err is the error wrapped by the *ConnectionError.
+//
+// Failures to connect, such as DNS errors and refused connections, are retried for all requests because the request
+// was not sent. Transient failures after the connection was established, such as timeouts, connection resets, broken
+// pipes and unexpected EOFs, are only retried for idempotent requests: those with an idempotent method (GET, HEAD,
+// OPTIONS, TRACE, PUT or DELETE) or an Idempotency-Key or X-Idempotency-Key header.
+//
+// Use WithConnectionErrorRetryPredicate to override it.
+func IsRetryableConnectionError(req *http.Request, err error) bool {
+	if isDialError(err) {
+		return true
+	}
+	return isIdempotentRequest(req) && isTransientConnectionError(err)
+}
+
+func isDialError(err error) bool {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+func isIdempotentRequest(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	// net/http treats requests with these headers as idempotent, see http.Transport.
+	_, hasIdempotencyKey := req.Header["Idempotency-Key"]
+	_, hasXIdempotencyKey := req.Header["X-Idempotency-Key"]
+	return hasIdempotencyKey || hasXIdempotencyKey
+}
+
+func isTransientConnectionError(err error) bool {
+	for _, target := range []error{io.EOF, io.ErrUnexpectedEOF, syscall.ECONNRESET, syscall.ECONNABORTED, syscall.EPIPE, syscall.ECONNREFUSED} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	var temporaryErr interface{ Temporary() bool }
+	if errors.As(err, &temporaryErr) && temporaryErr.Temporary() {
+		return true
+	}
+	// Some transports, e.g. HTTP/2 and proxies, return these errors without wrapping the underlying error.
+	msg := err.Error()
+	return strings.Contains(msg, "connection reset by peer") ||
+		strings.Contains(msg, "broken pipe") ||
+		strings.HasSuffix(msg, "EOF")
+}
+
+// newAttemptTimeoutConnectionError wraps the error of an attempt which failed because the timeout set by
+// WithPerAttemptTimeout expired in a *ConnectionError, which connectionErrorMiddleware does not do because the
+// context of the attempt is done. A *url.Error returned by the http.Client remains the outermost error.
+func newAttemptTimeoutConnectionError(err error) error {
+	if IsConnectionError(err) {
+		return err
+	}
+	if urlErr, ok := err.(*url.Error); ok {
+		return &url.Error{Op: urlErr.Op, URL: urlErr.URL, Err: &ConnectionError{Err: urlErr.Err}}
+	}
+	return &ConnectionError{Err: err}
+}
+
+// connectionErrorMiddleware wraps errors returned by the transport in a *ConnectionError.
+// It must directly wrap the transport so errors returned by other middleware are not wrapped.
+type connectionErrorMiddleware struct{}
+
+func (connectionErrorMiddleware) RoundTrip(req *http.Request, next http.RoundTripper) (*http.Response, error) {
+	resp, err := next.RoundTrip(req)
+	if err != nil && req.Context().Err() == nil {
+		return resp, &ConnectionError{Err: err}
+	}
+	return resp, err
+}
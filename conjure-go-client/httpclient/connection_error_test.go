@@ -0,0 +1,229 @@
+// Copyright (c) 2024 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/palantir/conjure-go-runtime/v2/conjure-go-client/httpclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnectionError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+	closedServer := httptest.NewServer(http.NotFoundHandler())
+	closedServer.Close()
+
+	canceledCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for _, test := range []struct {
+		Name               string
+		URL                string
+		Ctx                context.Context
+		ExpectedConnErr    bool
+		ExpectedStatusCode int
+	}{
+		{
+			Name:            "connection refused",
+			URL:             closedServer.URL,
+			Ctx:             context.Background(),
+			ExpectedConnErr: true,
+		},
+		{
+			Name:               "error status",
+			URL:                server.URL,
+			Ctx:                context.Background(),
+			ExpectedStatusCode: http.StatusInternalServerError,
+		},
+		{
+			Name: "canceled context",
+			URL:  server.URL,
+			Ctx:  canceledCtx,
+		},
+	} {
+		t.Run(test.Name, func(t *testing.T) {
+			client, err := httpclient.NewClient(
+				httpclient.WithBaseURLs([]string{test.URL}),
+				httpclient.WithMaxRetries(0),
+			)
+			require.NoError(t, err)
+
+			_, err = client.Get(test.Ctx)
+			require.Error(t, err)
+			assert.Equal(t, test.ExpectedConnErr, httpclient.IsConnectionError(err))
+			statusCode, ok := httpclient.StatusCodeFromError(err)
+			assert.Equal(t, test.ExpectedStatusCode != 0, ok)
+			assert.Equal(t, test.ExpectedStatusCode, statusCode)
+		})
+	}
+}
+
+func TestConnectionErrorRetries(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if calls.Add(1) == 1 {
+			// close the connection after the request is received without writing a response.
+			conn, _, err := rw.(http.Hijacker).Hijack()
+			require.NoError(t, err)
+			_ = conn.Close()
+			return
+		}
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	for _, test := range []struct {
+		Name          string
+		Method        string
+		Params        []httpclient.RequestParam
+		Predicate     func(req *http.Request, err error) bool
+		ExpectedCalls int
+	}{
+		{
+			Name:          "idempotent method is retried",
+			Method:        http.MethodGet,
+			ExpectedCalls: 2,
+		},
+		{
+			Name:          "non-idempotent method is not retried",
+			Method:        http.MethodPost,
+			ExpectedCalls: 1,
+		},
+		{
+			Name:          "idempotency key is retried",
+			Method:        http.MethodPost,
+			Params:        []httpclient.RequestParam{httpclient.WithHeader("Idempotency-Key", "key")},
+			ExpectedCalls: 2,
+		},
+		{
+			Name:   "custom predicate retries",
+			Method: http.MethodPost,
+			Predicate: func(req *http.Request, err error) bool {
+				return true
+			},
+			ExpectedCalls: 2,
+		},
+		{
+			Name:   "custom predicate does not retry",
+			Method: http.MethodGet,
+			Predicate: func(req *http.Request, err error) bool {
+				return false
+			},
+			ExpectedCalls: 1,
+		},
+	} {
+		t.Run(test.Name, func(t *testing.T) {
+			calls.Store(0)
+			params := []httpclient.ClientParam{
+				httpclient.WithBaseURLs([]string{server.URL}),
+				httpclient.WithInitialBackoff(time.Millisecond),
+				httpclient.WithMaxBackoff(time.Millisecond),
+				// a new connection is made for each attempt so the transport does not retry on its own.
+				httpclient.WithDisableKeepAlives(),
+			}
+			if test.Predicate != nil {
+				params = append(params, httpclient.WithConnectionErrorRetryPredicate(test.Predicate))
+			}
+			client, err := httpclient.NewClient(params...)
+			require.NoError(t, err)
+
+			_, err = client.Do(context.Background(), append(test.Params, httpclient.WithRequestMethod(test.Method))...)
+			if test.ExpectedCalls == 1 {
+				require.Error(t, err)
+				assert.True(t, httpclient.IsConnectionError(err))
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, test.ExpectedCalls, int(calls.Load()))
+		})
+	}
+}
+
+func TestIsRetryableConnectionError(t *testing.T) {
+	get, err := http.NewRequest(http.MethodGet, "http://localhost", nil)
+	require.NoError(t, err)
+	post, err := http.NewRequest(http.MethodPost, "http://localhost", nil)
+	require.NoError(t, err)
+
+	for _, test := range []struct {
+		Name         string
+		Err          error
+		ExpectedGet  bool
+		ExpectedPost bool
+	}{
+		{
+			Name:         "dial error",
+			Err:          &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED},
+			ExpectedGet:  true,
+			ExpectedPost: true,
+		},
+		{
+			Name:         "DNS error",
+			Err:          &net.DNSError{Err: "no such host", Name: "localhost"},
+			ExpectedGet:  true,
+			ExpectedPost: true,
+		},
+		{
+			Name:        "connection reset",
+			Err:         &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET},
+			ExpectedGet: true,
+		},
+		{
+			Name:        "unexpected EOF",
+			Err:         fmt.Errorf("read response: %w", io.ErrUnexpectedEOF),
+			ExpectedGet: true,
+		},
+		{
+			Name:        "unwrapped connection reset",
+			Err:         errors.New("read tcp 127.0.0.1:1234: read: connection reset by peer"),
+			ExpectedGet: true,
+		},
+		{
+			Name:        "timeout",
+			Err:         &net.OpError{Op: "read", Net: "tcp", Err: timeoutError{}},
+			ExpectedGet: true,
+		},
+		{
+			Name: "other error",
+			Err:  errors.New("tls: failed to verify certificate"),
+		},
+	} {
+		t.Run(test.Name, func(t *testing.T) {
+			assert.Equal(t, test.ExpectedGet, httpclient.IsRetryableConnectionError(get, test.Err))
+			assert.Equal(t, test.ExpectedPost, httpclient.IsRetryableConnectionError(post, test.Err))
+		})
+	}
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
@@ -0,0 +1,89 @@
+// Copyright (c) 2024 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/palantir/conjure-go-runtime/v2/conjure-go-client/httpclient/internal"
+	"github.com/palantir/witchcraft-go-logging/wlog/svclog/svc1log"
+)
+
+// connectionWarmupMaxJitter is the longest warm-up is delayed, so that clients constructed at the same time do not
+// all dial at once.
+const connectionWarmupMaxJitter = 100 * time.Millisecond
+
+// warmUpConnections sends n concurrent HEAD requests to each of the base URLs using transport, so that the transport
+// pools a connection for each request. The responses are discarded and failures are logged. Nothing is sent if ctx
+// is done before the warm-up starts.
+func warmUpConnections(ctx context.Context, serviceName string, transport http.RoundTripper, uris []string, n int, timeout time.Duration) {
+	timer := time.NewTimer(time.Duration(rand.Int63n(int64(connectionWarmupMaxJitter))))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return
+	case <-timer.C:
+	}
+	var wg sync.WaitGroup
+	for _, uri := range uris {
+		wg.Add(1)
+		go func(uri string) {
+			defer wg.Done()
+			if err := warmUpURI(ctx, transport, uri, n, timeout); err != nil {
+				svc1log.FromContext(ctx).Warn("Failed to warm up connections",
+					svc1log.SafeParam("serviceName", serviceName),
+					svc1log.UnsafeParam("url", uri),
+					svc1log.Stacktrace(err))
+			}
+		}(uri)
+	}
+	wg.Wait()
+}
+
+// warmUpURI sends n concurrent HEAD requests to uri. The responses are only closed once all requests completed,
+// so that no request reuses the connection of another, and then returned to the transport's pool.
+func warmUpURI(ctx context.Context, transport http.RoundTripper, uri string, n int, timeout time.Duration) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	responses := make([]*http.Response, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			req, err := http.NewRequestWithContext(ctx, http.MethodHead, uri, nil)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			responses[i], errs[i] = transport.RoundTrip(req)
+		}(i)
+	}
+	wg.Wait()
+	for _, resp := range responses {
+		// the body must be read to the end for the connection to be reused.
+		internal.DrainBody(ctx, resp)
+	}
+	return errors.Join(errs...)
+}
@@ -23,6 +23,8 @@ type ctxKey string
 const (
 	// context-key for the RPC method name associated with the HTTP request call
 	rpcMethodName ctxKey = "rpcMethodName"
+	// context-key for the endpoint name set by WithEndpointName
+	endpointName ctxKey = "endpointName"
 )
 
 // ContextWithRPCMethodName returns a copy of ctx with the rpcMethodName key set.
@@ -38,3 +40,11 @@ func getRPCMethodName(ctx context.Context) string {
 	}
 	return e.(string)
 }
+
+// getEndpointName returns the endpoint name set by WithEndpointName, or otherwise the RPC method name.
+func getEndpointName(ctx context.Context) string {
+	if e, ok := ctx.Value(endpointName).(string); ok && e != "" {
+		return e
+	}
+	return getRPCMethodName(ctx)
+}
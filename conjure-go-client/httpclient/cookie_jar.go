@@ -0,0 +1,46 @@
+// Copyright (c) 2024 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient
+
+import (
+	"net/http"
+)
+
+// cookieJarMiddleware adds cookies from the jar to each request and stores cookies from each response.
+// It is used instead of http.Client.Jar so that cookies are captured from responses which are converted
+// to errors before reaching the http.Client, like the 307 and 308 redirects handled by the request retrier.
+type cookieJarMiddleware struct {
+	jar http.CookieJar
+}
+
+func newCookieJarMiddleware(jar http.CookieJar) Middleware {
+	if jar == nil {
+		return nil
+	}
+	return cookieJarMiddleware{jar: jar}
+}
+
+func (c cookieJarMiddleware) RoundTrip(req *http.Request, next http.RoundTripper) (*http.Response, error) {
+	for _, cookie := range c.jar.Cookies(req.URL) {
+		req.AddCookie(cookie)
+	}
+	resp, err := next.RoundTrip(req)
+	if resp != nil {
+		if cookies := resp.Cookies(); len(cookies) > 0 {
+			c.jar.SetCookies(req.URL, cookies)
+		}
+	}
+	return resp, err
+}
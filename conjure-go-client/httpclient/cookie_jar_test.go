@@ -0,0 +1,80 @@
+// Copyright (c) 2024 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/palantir/conjure-go-runtime/v2/conjure-go-client/httpclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCookieJar(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/login":
+			http.SetCookie(rw, &http.Cookie{Name: "session", Value: "abc", Path: "/"})
+			rw.Header().Set("Location", "/home")
+			rw.WriteHeader(http.StatusTemporaryRedirect)
+		default:
+			if cookie, err := req.Cookie("session"); err != nil || cookie.Value != "abc" {
+				rw.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			rw.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer server.Close()
+
+	t.Run("Client", func(t *testing.T) {
+		client, err := httpclient.NewClient(
+			httpclient.WithBaseURLs([]string{server.URL}),
+			httpclient.WithInMemoryCookieJar(),
+		)
+		require.NoError(t, err)
+
+		resp, err := client.Get(context.Background(), httpclient.WithPath("/login"))
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp, err = client.Get(context.Background(), httpclient.WithPath("/other"))
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("HTTPClient", func(t *testing.T) {
+		client, err := httpclient.NewHTTPClient(httpclient.WithInMemoryCookieJar())
+		require.NoError(t, err)
+
+		resp, err := client.Get(server.URL + "/login")
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("NoJar", func(t *testing.T) {
+		client, err := httpclient.NewClient(httpclient.WithBaseURLs([]string{server.URL}))
+		require.NoError(t, err)
+
+		_, err = client.Get(context.Background(), httpclient.WithPath("/login"))
+		code, ok := httpclient.StatusCodeFromError(err)
+		require.True(t, ok)
+		assert.Equal(t, http.StatusUnauthorized, code)
+	})
+}
@@ -0,0 +1,57 @@
+// Copyright (c) 2024 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient
+
+import (
+	"strings"
+	"time"
+)
+
+// EndpointConfig overrides the client's configuration for requests to a single endpoint.
+// Unset fields use the client's configuration.
+type EndpointConfig struct {
+	// Timeout bounds requests to the endpoint as described by WithRequestTimeout, which takes precedence.
+	Timeout *time.Duration
+	// MaxNumRetries overrides the number of times a retryable failure is retried.
+	MaxNumRetries *int
+	// InitialBackoff overrides the initial backoff between retries.
+	InitialBackoff *time.Duration
+	// MaxBackoff overrides the maximum backoff between retries.
+	MaxBackoff *time.Duration
+}
+
+// endpointConfigs holds the EndpointConfig registered for endpoint names and request path prefixes.
+type endpointConfigs struct {
+	byName       map[string]EndpointConfig
+	byPathPrefix map[string]EndpointConfig
+}
+
+// get returns the config registered for the endpoint name if any, otherwise the config registered for the
+// longest prefix of path. The zero EndpointConfig, which overrides nothing, is returned if neither matches.
+func (e endpointConfigs) get(name, path string) EndpointConfig {
+	if conf, ok := e.byName[name]; ok && name != "" {
+		return conf
+	}
+	var match string
+	for prefix := range e.byPathPrefix {
+		if strings.HasPrefix(path, prefix) && len(prefix) > len(match) {
+			match = prefix
+		}
+	}
+	if match == "" {
+		return EndpointConfig{}
+	}
+	return e.byPathPrefix[match]
+}
@@ -16,6 +16,7 @@ package httpclient_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -23,6 +24,7 @@ import (
 
 	"github.com/palantir/conjure-go-runtime/v2/conjure-go-client/httpclient"
 	"github.com/palantir/conjure-go-runtime/v2/conjure-go-client/httpclient/internal"
+	conjureerrors "github.com/palantir/conjure-go-runtime/v2/conjure-go-contract/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -134,3 +136,50 @@ func (ced *customErrorDecoder) Handles(resp *http.Response) bool {
 func (ced *customErrorDecoder) DecodeError(_ *http.Response) error {
 	return fmt.Errorf(ced.message)
 }
+
+func TestConjureErrorDecoder(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/conjure" {
+			conjureerrors.WriteErrorResponse(rw, conjureerrors.NewNotFound())
+			return
+		}
+		rw.WriteHeader(statusCode)
+		_, _ = fmt.Fprint(rw, body)
+	}))
+	defer ts.Close()
+
+	decoder := &recordingConjureErrorDecoder{}
+	client, err := httpclient.NewClient(
+		httpclient.WithBaseURLs([]string{ts.URL}),
+		httpclient.WithConjureErrorDecoder(decoder),
+	)
+	require.NoError(t, err)
+
+	t.Run("conjure error", func(t *testing.T) {
+		_, err := client.Get(context.Background(), httpclient.WithPath("/conjure"))
+		require.Error(t, err)
+		var conjureErr conjureerrors.Error
+		require.True(t, errors.As(err, &conjureErr), "expected a conjure error, got %v", err)
+		assert.Equal(t, conjureerrors.DefaultNotFound.Name(), conjureErr.Name())
+		assert.Equal(t, []string{conjureerrors.DefaultNotFound.Name()}, decoder.names)
+	})
+	t.Run("non-conjure error", func(t *testing.T) {
+		_, err := client.Get(context.Background())
+		assert.EqualError(t, err, errPrefix+defaultStatusMsg)
+		var conjureErr conjureerrors.Error
+		assert.False(t, errors.As(err, &conjureErr))
+		gotStatusCode, ok := httpclient.StatusCodeFromError(err)
+		assert.True(t, ok)
+		assert.Equal(t, statusCode, gotStatusCode)
+	})
+}
+
+// recordingConjureErrorDecoder records the names of the errors it decodes.
+type recordingConjureErrorDecoder struct {
+	names []string
+}
+
+func (d *recordingConjureErrorDecoder) DecodeConjureError(name string, body []byte) (conjureerrors.Error, error) {
+	d.names = append(d.names, name)
+	return conjureerrors.UnmarshalError(body)
+}
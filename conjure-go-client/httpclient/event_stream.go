@@ -0,0 +1,163 @@
+// Copyright (c) 2024 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	werror "github.com/palantir/witchcraft-go-error"
+)
+
+const (
+	eventStreamContentType = "text/event-stream"
+	// maxEventStreamLineSize is the maximum length of a single line in an event stream.
+	maxEventStreamLineSize = 1 << 20
+)
+
+// SSEvent is a single event parsed from a server-sent event stream.
+// See https://html.spec.whatwg.org/multipage/server-sent-events.html for details.
+type SSEvent struct {
+	// ID is the most recent event ID received on the stream, which may have been set by a previous event.
+	ID string
+	// Event is the event type. If the event did not specify a type, it is "message".
+	Event string
+	// Data is the event payload. Multiple data lines are joined with "\n".
+	Data string
+	// Retry is the most recent reconnection time received on the stream, or zero if none has been received.
+	Retry time.Duration
+}
+
+// EventStreamHandler is called for each event received on a server-sent event stream.
+// If it returns a non-nil error, no further events are read and the request returns an *EventHandlerError.
+type EventStreamHandler func(event SSEvent) error
+
+// EventHandlerError is returned when an EventStreamHandler returns an error. Errors reading the stream itself
+// (e.g. connection failures or context cancellation) are not wrapped in this type.
+type EventHandlerError struct {
+	Err error
+}
+
+func (e *EventHandlerError) Error() string {
+	return "httpclient: event stream handler failed: " + e.Err.Error()
+}
+
+func (e *EventHandlerError) Unwrap() error {
+	return e.Err
+}
+
+// readEventStream parses the server-sent events in r, calling handler for each dispatched event
+// until the end of the stream is reached or the context is done.
+func readEventStream(ctx context.Context, r io.Reader, handler EventStreamHandler) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxEventStreamLineSize)
+	scanner.Split(scanEventStreamLines)
+
+	var (
+		lastID    string
+		retry     time.Duration
+		eventType string
+		data      strings.Builder
+		firstLine = true
+	)
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return werror.WrapWithContextParams(ctx, err, "event stream canceled")
+		}
+		line := scanner.Text()
+		if firstLine {
+			line = strings.TrimPrefix(line, "\ufeff")
+			firstLine = false
+		}
+		if line == "" {
+			// dispatch the event
+			if data.Len() > 0 {
+				event := SSEvent{
+					ID:    lastID,
+					Event: eventType,
+					Data:  strings.TrimSuffix(data.String(), "\n"),
+					Retry: retry,
+				}
+				if event.Event == "" {
+					event.Event = "message"
+				}
+				if err := handler(event); err != nil {
+					return &EventHandlerError{Err: err}
+				}
+			}
+			eventType = ""
+			data.Reset()
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			// comment
+			continue
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			eventType = value
+		case "data":
+			data.WriteString(value)
+			data.WriteByte('\n')
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				lastID = value
+			}
+		case "retry":
+			if millis, err := strconv.ParseUint(value, 10, 63); err == nil {
+				retry = time.Duration(millis) * time.Millisecond
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return werror.WrapWithContextParams(ctx, err, "failed to read event stream")
+	}
+	// an incomplete event at the end of the stream is discarded.
+	return nil
+}
+
+// scanEventStreamLines is a bufio.SplitFunc for lines terminated by "\r\n", "\n", or "\r".
+func scanEventStreamLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		if data[i] == '\n' {
+			return i + 1, data[:i], nil
+		}
+		// data[i] is '\r': consume a following '\n' if present, requesting more data if it may follow.
+		if i+1 < len(data) {
+			if data[i+1] == '\n' {
+				return i + 2, data[:i], nil
+			}
+			return i + 1, data[:i], nil
+		}
+		if atEOF {
+			return i + 1, data[:i], nil
+		}
+		return 0, nil, nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
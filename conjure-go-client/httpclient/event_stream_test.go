@@ -0,0 +1,91 @@
+// Copyright (c) 2024 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadEventStream(t *testing.T) {
+	for _, test := range []struct {
+		Name     string
+		Input    string
+		Expected []SSEvent
+	}{
+		{
+			Name:     "single event",
+			Input:    "data: hello\n\n",
+			Expected: []SSEvent{{Event: "message", Data: "hello"}},
+		},
+		{
+			Name:  "all fields",
+			Input: "\ufeff: comment\nid: 1\nevent: update\nretry: 1500\ndata: first\ndata:second\n\ndata: third\n\n",
+			Expected: []SSEvent{
+				{ID: "1", Event: "update", Data: "first\nsecond", Retry: 1500 * time.Millisecond},
+				{ID: "1", Event: "message", Data: "third", Retry: 1500 * time.Millisecond},
+			},
+		},
+		{
+			Name:     "CR and CRLF line endings",
+			Input:    "data: a\r\rdata: b\r\n\r\n",
+			Expected: []SSEvent{{Event: "message", Data: "a"}, {Event: "message", Data: "b"}},
+		},
+		{
+			Name:     "event without data is not dispatched",
+			Input:    "event: empty\n\ndata: x\n\n",
+			Expected: []SSEvent{{Event: "message", Data: "x"}},
+		},
+		{
+			Name:     "invalid retry is ignored",
+			Input:    "retry: soon\ndata: x\n\n",
+			Expected: []SSEvent{{Event: "message", Data: "x"}},
+		},
+		{
+			Name:     "incomplete event at EOF is discarded",
+			Input:    "data: x\n\ndata: incomplete",
+			Expected: []SSEvent{{Event: "message", Data: "x"}},
+		},
+	} {
+		t.Run(test.Name, func(t *testing.T) {
+			var events []SSEvent
+			err := readEventStream(context.Background(), strings.NewReader(test.Input), func(event SSEvent) error {
+				events = append(events, event)
+				return nil
+			})
+			require.NoError(t, err)
+			assert.Equal(t, test.Expected, events)
+		})
+	}
+
+	t.Run("handler error", func(t *testing.T) {
+		handlerErr := errors.New("handler failed")
+		var count int
+		err := readEventStream(context.Background(), strings.NewReader("data: a\n\ndata: b\n\n"), func(SSEvent) error {
+			count++
+			return handlerErr
+		})
+		var eventHandlerErr *EventHandlerError
+		require.True(t, errors.As(err, &eventHandlerErr))
+		assert.True(t, errors.Is(err, handlerErr))
+		assert.Equal(t, 1, count)
+	})
+}
@@ -0,0 +1,70 @@
+// Copyright (c) 2024 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient
+
+import (
+	"fmt"
+	"net/http"
+
+	werror "github.com/palantir/witchcraft-go-error"
+)
+
+// PropagationKey maps a value carried by a request's context to an outbound request header.
+// See WithContextHeaderPropagation.
+type PropagationKey struct {
+	// ContextKey is the key of the value in the request context, as passed to context.WithValue.
+	ContextKey any
+	// Header is the name of the header set to the value.
+	Header string
+}
+
+type headerPropagationMiddleware struct {
+	keys []PropagationKey
+}
+
+func (m headerPropagationMiddleware) RoundTrip(req *http.Request, next http.RoundTripper) (*http.Response, error) {
+	for _, key := range m.keys {
+		if req.Header.Get(key.Header) != "" {
+			continue
+		}
+		switch v := req.Context().Value(key.ContextKey).(type) {
+		case nil:
+		case string:
+			if v != "" {
+				req.Header.Set(key.Header, v)
+			}
+		case fmt.Stringer:
+			if s := v.String(); s != "" {
+				req.Header.Set(key.Header, s)
+			}
+		default:
+			req.Header.Set(key.Header, fmt.Sprint(v))
+		}
+	}
+	return next.RoundTrip(req)
+}
+
+func newHeaderPropagationMiddleware(keys []PropagationKey) (Middleware, error) {
+	for _, key := range keys {
+		if key.ContextKey == nil {
+			return nil, werror.Error("httpclient: propagation context key can not be nil",
+				werror.SafeParam("header", key.Header))
+		}
+		if key.Header == "" {
+			return nil, werror.Error("httpclient: propagation header can not be empty")
+		}
+	}
+	return headerPropagationMiddleware{keys: keys}, nil
+}
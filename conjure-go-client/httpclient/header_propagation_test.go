@@ -0,0 +1,95 @@
+// Copyright (c) 2024 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/palantir/conjure-go-runtime/v2/conjure-go-client/httpclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testContextKey string
+
+type testTenantID struct{ id string }
+
+func (t testTenantID) String() string { return "tenant-" + t.id }
+
+func TestContextHeaderPropagation(t *testing.T) {
+	var headers http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		headers = req.Header
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	keys := []httpclient.PropagationKey{
+		{ContextKey: testContextKey("correlation"), Header: "X-Correlation-Id"},
+		{ContextKey: testContextKey("tenant"), Header: "X-Tenant-Id"},
+		{ContextKey: testContextKey("priority"), Header: "X-Priority"},
+	}
+	client, err := httpclient.NewClient(httpclient.WithBaseURLs([]string{server.URL}), httpclient.WithContextHeaderPropagation(keys...))
+	require.NoError(t, err)
+	httpClient, err := httpclient.NewHTTPClient(httpclient.WithContextHeaderPropagation(keys...))
+	require.NoError(t, err)
+
+	ctx := context.WithValue(context.Background(), testContextKey("correlation"), "abc")
+	ctx = context.WithValue(ctx, testContextKey("tenant"), testTenantID{id: "1"})
+	ctx = context.WithValue(ctx, testContextKey("priority"), 5)
+
+	t.Run("client", func(t *testing.T) {
+		_, err := client.Get(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "abc", headers.Get("X-Correlation-Id"))
+		assert.Equal(t, "tenant-1", headers.Get("X-Tenant-Id"))
+		assert.Equal(t, "5", headers.Get("X-Priority"))
+	})
+
+	t.Run("request header takes precedence", func(t *testing.T) {
+		_, err := client.Get(ctx, httpclient.WithHeader("X-Tenant-Id", "explicit"))
+		require.NoError(t, err)
+		assert.Equal(t, "abc", headers.Get("X-Correlation-Id"))
+		assert.Equal(t, "explicit", headers.Get("X-Tenant-Id"))
+	})
+
+	t.Run("missing values are not propagated", func(t *testing.T) {
+		_, err := client.Get(context.WithValue(context.Background(), testContextKey("correlation"), ""))
+		require.NoError(t, err)
+		assert.NotContains(t, headers, "X-Correlation-Id")
+		assert.NotContains(t, headers, "X-Tenant-Id")
+		assert.NotContains(t, headers, "X-Priority")
+	})
+
+	t.Run("http client", func(t *testing.T) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+		require.NoError(t, err)
+		resp, err := httpClient.Do(req)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		assert.Equal(t, "abc", headers.Get("X-Correlation-Id"))
+		assert.Equal(t, "tenant-1", headers.Get("X-Tenant-Id"))
+	})
+
+	t.Run("invalid keys", func(t *testing.T) {
+		_, err := httpclient.NewHTTPClient(httpclient.WithContextHeaderPropagation(httpclient.PropagationKey{Header: "X-Tenant-Id"}))
+		assert.EqualError(t, err, "httpclient: propagation context key can not be nil")
+		_, err = httpclient.NewHTTPClient(httpclient.WithContextHeaderPropagation(httpclient.PropagationKey{ContextKey: testContextKey("tenant")}))
+		assert.EqualError(t, err, "httpclient: propagation header can not be empty")
+	})
+}
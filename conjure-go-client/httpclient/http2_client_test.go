@@ -19,6 +19,7 @@ package httpclient_test
 
 import (
 	"context"
+	"crypto/tls"
 	"io"
 	"net"
 	"net/http"
@@ -162,3 +163,55 @@ func (p *proxyServer) handleConnection(t *testing.T, in net.Conn, stopCh chan st
 func (p *proxyServer) DialCount() int {
 	return int(atomic.LoadInt32(&p.dialCount))
 }
+
+func TestHTTP2NegotiatedProtocol(t *testing.T) {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+	defer ts.Close()
+
+	for _, test := range []struct {
+		Name          string
+		Params        []httpclient.ClientParam
+		ExpectedProto string
+	}{
+		{
+			Name:          "default",
+			ExpectedProto: "HTTP/2.0",
+		},
+		{
+			Name: "enabled",
+			Params: []httpclient.ClientParam{
+				httpclient.WithHTTP2(true),
+				httpclient.WithHTTP2StrictMaxConcurrentStreams(true),
+			},
+			ExpectedProto: "HTTP/2.0",
+		},
+		{
+			Name:          "disabled",
+			Params:        []httpclient.ClientParam{httpclient.WithHTTP2(false)},
+			ExpectedProto: "HTTP/1.1",
+		},
+		{
+			Name: "disabled with h2 in NextProtos",
+			Params: []httpclient.ClientParam{
+				httpclient.WithTLSConfig(&tls.Config{NextProtos: []string{"h2", "http/1.1"}, InsecureSkipVerify: true}),
+				httpclient.WithHTTP2(false),
+			},
+			ExpectedProto: "HTTP/1.1",
+		},
+	} {
+		t.Run(test.Name, func(t *testing.T) {
+			client, err := httpclient.NewClient(append([]httpclient.ClientParam{
+				httpclient.WithBaseURLs([]string{ts.URL}),
+				httpclient.WithTLSInsecureSkipVerify(),
+			}, test.Params...)...)
+			require.NoError(t, err)
+			resp, err := client.Get(context.Background())
+			require.NoError(t, err)
+			require.Equal(t, test.ExpectedProto, resp.Proto)
+		})
+	}
+}
@@ -43,3 +43,23 @@ func DrainBody(ctx context.Context, resp *http.Response) {
 		}
 	}
 }
+
+// DrainBodyLimit is like DrainBody, but reads at most limit bytes of the body before closing it.
+// An HTTP/1.x connection is only reused if its response body was read to the end.
+func DrainBodyLimit(ctx context.Context, resp *http.Response, limit int64) {
+	if resp != nil && resp.Body != nil {
+		if bytes, err := io.CopyN(io.Discard, resp.Body, limit); err == nil {
+			svc1log.FromContext(ctx).Debug("Discarded response body without reading it to the end",
+				svc1log.SafeParam("bytes", bytes))
+		} else if err != io.EOF {
+			svc1log.FromContext(ctx).Warn("Failed to drain response body",
+				svc1log.SafeParam("bytes", bytes),
+				svc1log.Stacktrace(err))
+		}
+
+		if err := resp.Body.Close(); err != nil {
+			svc1log.FromContext(ctx).Warn("Failed to close response body",
+				svc1log.Stacktrace(err))
+		}
+	}
+}
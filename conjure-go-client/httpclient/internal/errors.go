@@ -46,3 +46,17 @@ func LocationFromError(err error) (location string, ok bool) {
 	location, ok = locationI.(string)
 	return location, ok
 }
+
+// ResponseBodyFromError retrieves the 'responseBody' parameter from the provided werror.
+// If the error is not a werror or does not have the responseBody param, ok is false.
+//
+// The default client error decoder sets the responseBody parameter on its returned errors
+// if the response body is small and could not be decoded as a conjure error.
+func ResponseBodyFromError(err error) (body string, ok bool) {
+	bodyI, _ := werror.ParamFromError(err, "responseBody")
+	if bodyI == nil {
+		return "", false
+	}
+	body, ok = bodyI.(string)
+	return body, ok
+}
@@ -103,3 +103,34 @@ func TestGetLocationFromError(t *testing.T) {
 		})
 	}
 }
+
+func TestGetResponseBodyFromError(t *testing.T) {
+	for _, tc := range []struct {
+		name            string
+		err             error
+		expectBodyExist bool
+		expectBody      string
+	}{
+		{
+			name: "404 no body",
+			err: werror.Error("404",
+				werror.SafeParam("statusCode", 404)),
+			expectBodyExist: false,
+			expectBody:      "",
+		},
+		{
+			name: "404 with body",
+			err: werror.Error("404",
+				werror.SafeParam("statusCode", 404),
+				werror.UnsafeParam("responseBody", "route does not exist")),
+			expectBodyExist: true,
+			expectBody:      "route does not exist",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			body, exist := ResponseBodyFromError(tc.err)
+			assert.Equal(t, tc.expectBodyExist, exist)
+			assert.Equal(t, tc.expectBody, body)
+		})
+	}
+}
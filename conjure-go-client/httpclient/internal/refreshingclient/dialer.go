@@ -29,6 +29,10 @@ type DialerParams struct {
 	DialTimeout   time.Duration
 	KeepAlive     time.Duration
 	SocksProxyURL *url.URL `refreshables:",exclude"`
+	// LocalAddr is the local address used to dial connections. If nil, it is chosen automatically.
+	LocalAddr net.Addr `refreshables:",exclude"`
+	// DNSCacheTTL is the duration for which the addresses of hostnames are cached. If zero, they are not cached.
+	DNSCacheTTL time.Duration
 }
 
 // ContextDialer is the interface implemented by net.Dialer, proxy.Dialer, and others
@@ -37,14 +41,19 @@ type ContextDialer interface {
 }
 
 func NewRefreshableDialer(ctx context.Context, p RefreshableDialerParams) ContextDialer {
+	cache := newDNSCache(net.DefaultResolver)
 	return &RefreshableDialer{
 		Refreshable: p.MapDialerParams(func(p DialerParams) interface{} {
 			svc1log.FromContext(ctx).Debug("Reconstructing HTTP Dialer")
 			dialer := &net.Dialer{
 				Timeout:   p.DialTimeout,
 				KeepAlive: p.KeepAlive,
+				LocalAddr: p.LocalAddr,
 			}
 			if p.SocksProxyURL == nil {
+				if p.DNSCacheTTL > 0 {
+					return &dnsCachingDialer{dialer: dialer, cache: cache, ttl: p.DNSCacheTTL}
+				}
 				return dialer
 			}
 			proxyDialer, err := proxy.FromURL(p.SocksProxyURL, dialer)
@@ -0,0 +1,47 @@
+// Copyright (c) 2024 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package refreshingclient
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/palantir/pkg/refreshable"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefreshableDialer(t *testing.T) {
+	localAddr := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)}
+	params := refreshable.NewDefaultRefreshable(DialerParams{
+		DialTimeout: time.Second,
+		KeepAlive:   time.Minute,
+		LocalAddr:   localAddr,
+	})
+	dialer := NewRefreshableDialer(context.Background(), NewRefreshingDialerParams(params)).(*RefreshableDialer)
+	netDialer, ok := dialer.Current().(*net.Dialer)
+	require.True(t, ok, "expected a *net.Dialer but got %T", dialer.Current())
+	assert.Equal(t, time.Second, netDialer.Timeout)
+	assert.Equal(t, time.Minute, netDialer.KeepAlive)
+	assert.Equal(t, localAddr, netDialer.LocalAddr)
+
+	params = refreshable.NewDefaultRefreshable(DialerParams{DNSCacheTTL: time.Minute, LocalAddr: localAddr})
+	dialer = NewRefreshableDialer(context.Background(), NewRefreshingDialerParams(params)).(*RefreshableDialer)
+	cachingDialer, ok := dialer.Current().(*dnsCachingDialer)
+	require.True(t, ok, "expected a *dnsCachingDialer but got %T", dialer.Current())
+	assert.Equal(t, localAddr, cachingDialer.dialer.(*net.Dialer).LocalAddr)
+}
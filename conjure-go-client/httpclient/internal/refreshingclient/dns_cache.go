@@ -0,0 +1,221 @@
+// Copyright (c) 2024 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package refreshingclient
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// dnsCacheRefreshTimeout bounds the lookups made in the background to refresh expired entries.
+	dnsCacheRefreshTimeout = 10 * time.Second
+	// dnsCacheMaxEntries bounds the number of hostnames cached. When it is reached, expired entries are evicted
+	// before caching another hostname, or an arbitrary entry if none have expired.
+	dnsCacheMaxEntries = 1024
+	// minDialAttemptTimeout is the least time given to dialing each address when the remaining time is split across
+	// them, matching the minimum used by net.Dialer.
+	minDialAttemptTimeout = 2 * time.Second
+)
+
+// hostResolver is the interface implemented by net.Resolver.
+type hostResolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// dnsCache caches the IP addresses of hostnames. It is shared by the dialers built when the dialer params are
+// refreshed so that refreshing does not clear it.
+type dnsCache struct {
+	resolver   hostResolver
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string]*dnsCacheEntry
+}
+
+type dnsCacheEntry struct {
+	addrs      []net.IPAddr
+	expiresAt  time.Time
+	refreshing bool
+	next       int
+}
+
+func newDNSCache(resolver hostResolver) *dnsCache {
+	return &dnsCache{
+		resolver:   resolver,
+		maxEntries: dnsCacheMaxEntries,
+		entries:    map[string]*dnsCacheEntry{},
+	}
+}
+
+// lookup returns the addresses of host and whether they were cached. Each call starts with the next address of the
+// entry so that connections are spread across all addresses. Expired entries are returned while they are refreshed in
+// the background, and cache misses are resolved with a live lookup.
+func (c *dnsCache) lookup(ctx context.Context, host string, ttl time.Duration) ([]net.IPAddr, bool, error) {
+	c.mu.Lock()
+	if e, ok := c.entries[host]; ok {
+		if !e.refreshing && !time.Now().Before(e.expiresAt) {
+			e.refreshing = true
+			go c.refresh(host, ttl)
+		}
+		addrs := make([]net.IPAddr, 0, len(e.addrs))
+		addrs = append(addrs, e.addrs[e.next:]...)
+		addrs = append(addrs, e.addrs[:e.next]...)
+		e.next = (e.next + 1) % len(e.addrs)
+		c.mu.Unlock()
+		return addrs, true, nil
+	}
+	c.mu.Unlock()
+
+	addrs, err := c.resolve(ctx, host, ttl)
+	return addrs, false, err
+}
+
+// resolve looks up the addresses of host and caches them for ttl. The returned addresses start with the first,
+// so the next lookup starts with the second.
+func (c *dnsCache) resolve(ctx context.Context, host string, ttl time.Duration) ([]net.IPAddr, error) {
+	addrs, err := c.resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	c.mu.Lock()
+	if _, ok := c.entries[host]; !ok && len(c.entries) >= c.maxEntries {
+		c.evict()
+	}
+	c.entries[host] = &dnsCacheEntry{addrs: addrs, expiresAt: time.Now().Add(ttl), next: 1 % len(addrs)}
+	c.mu.Unlock()
+	return addrs, nil
+}
+
+// evict removes the expired entries which are not being refreshed, or an arbitrary entry if there are none.
+// c.mu must be held.
+func (c *dnsCache) evict() {
+	now := time.Now()
+	evicted := false
+	for host, e := range c.entries {
+		if !e.refreshing && !now.Before(e.expiresAt) {
+			delete(c.entries, host)
+			evicted = true
+		}
+	}
+	if evicted {
+		return
+	}
+	for host := range c.entries {
+		delete(c.entries, host)
+		return
+	}
+}
+
+// refresh resolves host in the background. If the lookup fails, the expired entry is kept and refreshed again on its
+// next use.
+func (c *dnsCache) refresh(host string, ttl time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), dnsCacheRefreshTimeout)
+	defer cancel()
+	if _, err := c.resolve(ctx, host, ttl); err != nil {
+		c.mu.Lock()
+		if e, ok := c.entries[host]; ok {
+			e.refreshing = false
+		}
+		c.mu.Unlock()
+	}
+}
+
+func (c *dnsCache) invalidate(host string) {
+	c.mu.Lock()
+	delete(c.entries, host)
+	c.mu.Unlock()
+}
+
+// dnsCachingDialer resolves hostnames using a dnsCache and dials the resulting addresses in turn until a connection
+// is established. Like net.Dialer, the time remaining before the deadline of the context is split across the
+// addresses so that an unresponsive address does not prevent the others from being dialed. Addresses which are
+// already IPs are dialed directly.
+type dnsCachingDialer struct {
+	dialer ContextDialer
+	cache  *dnsCache
+	ttl    time.Duration
+}
+
+func (d *dnsCachingDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil || net.ParseIP(host) != nil {
+		return d.dialer.DialContext(ctx, network, address)
+	}
+	addrs, cached, err := d.cache.lookup(ctx, host, d.ttl)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := d.dialAddrs(ctx, network, host, port, addrs)
+	if err != nil && cached && ctx.Err() == nil {
+		// the cached addresses may be stale, so fall back to a live lookup.
+		d.cache.invalidate(host)
+		if addrs, err = d.cache.resolve(ctx, host, d.ttl); err != nil {
+			return nil, err
+		}
+		return d.dialAddrs(ctx, network, host, port, addrs)
+	}
+	return conn, err
+}
+
+func (d *dnsCachingDialer) dialAddrs(ctx context.Context, network, host, port string, addrs []net.IPAddr) (net.Conn, error) {
+	suitable := make([]net.IPAddr, 0, len(addrs))
+	for _, addr := range addrs {
+		if (network == "tcp4" && addr.IP.To4() == nil) || (network == "tcp6" && addr.IP.To4() != nil) {
+			continue
+		}
+		suitable = append(suitable, addr)
+	}
+	var lastErr error
+	for i, addr := range suitable {
+		conn, err := d.dialAddr(ctx, network, net.JoinHostPort(addr.String(), port), len(suitable)-i)
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	if lastErr == nil {
+		lastErr = &net.AddrError{Err: "no suitable address found", Addr: host}
+	}
+	return nil, lastErr
+}
+
+// dialAddr dials address with an equal share of the time remaining for the addrsRemaining addresses still to be
+// dialed, or all of it if that share would be less than minDialAttemptTimeout.
+func (d *dnsCachingDialer) dialAddr(ctx context.Context, network, address string, addrsRemaining int) (net.Conn, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok || addrsRemaining == 1 {
+		return d.dialer.DialContext(ctx, network, address)
+	}
+	remaining := time.Until(deadline)
+	timeout := remaining / time.Duration(addrsRemaining)
+	if timeout < minDialAttemptTimeout {
+		timeout = minDialAttemptTimeout
+	}
+	if timeout >= remaining {
+		return d.dialer.DialContext(ctx, network, address)
+	}
+	dialCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return d.dialer.DialContext(dialCtx, network, address)
+}
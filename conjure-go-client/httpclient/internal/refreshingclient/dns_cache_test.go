@@ -0,0 +1,203 @@
+// Copyright (c) 2024 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package refreshingclient
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeResolver struct {
+	mu      sync.Mutex
+	addrs   []net.IPAddr
+	err     error
+	lookups int
+}
+
+func (r *fakeResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lookups++
+	return r.addrs, r.err
+}
+
+func (r *fakeResolver) set(addrs []net.IPAddr, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.addrs, r.err = addrs, err
+}
+
+func (r *fakeResolver) lookupCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.lookups
+}
+
+// fakeDialer records the addresses dialed and the deadlines they were dialed with, and fails to dial the addresses
+// in failing.
+type fakeDialer struct {
+	dialed    []string
+	deadlines []time.Time
+	failing   map[string]bool
+}
+
+func (d *fakeDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	d.dialed = append(d.dialed, address)
+	deadline, _ := ctx.Deadline()
+	d.deadlines = append(d.deadlines, deadline)
+	if d.failing[address] {
+		return nil, errors.New("connection refused")
+	}
+	client, server := net.Pipe()
+	_ = server.Close()
+	return client, nil
+}
+
+func ipAddrs(ips ...string) []net.IPAddr {
+	var addrs []net.IPAddr
+	for _, ip := range ips {
+		addrs = append(addrs, net.IPAddr{IP: net.ParseIP(ip)})
+	}
+	return addrs
+}
+
+func TestDNSCachingDialer(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("caches and rotates addresses", func(t *testing.T) {
+		resolver := &fakeResolver{addrs: ipAddrs("10.0.0.1", "10.0.0.2")}
+		dialer := &fakeDialer{}
+		d := &dnsCachingDialer{dialer: dialer, cache: newDNSCache(resolver), ttl: time.Hour}
+		for i := 0; i < 3; i++ {
+			conn, err := d.DialContext(ctx, "tcp", "example.com:443")
+			require.NoError(t, err)
+			_ = conn.Close()
+		}
+		assert.Equal(t, 1, resolver.lookupCount())
+		assert.Equal(t, []string{"10.0.0.1:443", "10.0.0.2:443", "10.0.0.1:443"}, dialer.dialed)
+	})
+	t.Run("dials IP addresses directly", func(t *testing.T) {
+		resolver := &fakeResolver{}
+		dialer := &fakeDialer{}
+		d := &dnsCachingDialer{dialer: dialer, cache: newDNSCache(resolver), ttl: time.Hour}
+		conn, err := d.DialContext(ctx, "tcp", "[::1]:443")
+		require.NoError(t, err)
+		_ = conn.Close()
+		assert.Equal(t, 0, resolver.lookupCount())
+		assert.Equal(t, []string{"[::1]:443"}, dialer.dialed)
+	})
+	t.Run("falls back to other addresses and filters by network", func(t *testing.T) {
+		resolver := &fakeResolver{addrs: ipAddrs("::2", "10.0.0.1", "10.0.0.2")}
+		dialer := &fakeDialer{failing: map[string]bool{"10.0.0.1:443": true}}
+		d := &dnsCachingDialer{dialer: dialer, cache: newDNSCache(resolver), ttl: time.Hour}
+		conn, err := d.DialContext(ctx, "tcp4", "example.com:443")
+		require.NoError(t, err)
+		_ = conn.Close()
+		assert.Equal(t, []string{"10.0.0.1:443", "10.0.0.2:443"}, dialer.dialed)
+	})
+	t.Run("resolves again when cached addresses fail", func(t *testing.T) {
+		resolver := &fakeResolver{addrs: ipAddrs("10.0.0.1")}
+		dialer := &fakeDialer{failing: map[string]bool{"10.0.0.1:443": true}}
+		d := &dnsCachingDialer{dialer: dialer, cache: newDNSCache(resolver), ttl: time.Hour}
+		_, err := d.DialContext(ctx, "tcp", "example.com:443")
+		require.Error(t, err)
+		assert.Equal(t, 1, resolver.lookupCount())
+
+		resolver.set(ipAddrs("10.0.0.3"), nil)
+		conn, err := d.DialContext(ctx, "tcp", "example.com:443")
+		require.NoError(t, err)
+		_ = conn.Close()
+		assert.Equal(t, 2, resolver.lookupCount())
+		assert.Equal(t, []string{"10.0.0.1:443", "10.0.0.1:443", "10.0.0.3:443"}, dialer.dialed)
+	})
+	t.Run("refreshes expired entries in the background", func(t *testing.T) {
+		resolver := &fakeResolver{addrs: ipAddrs("10.0.0.1")}
+		dialer := &fakeDialer{}
+		d := &dnsCachingDialer{dialer: dialer, cache: newDNSCache(resolver), ttl: time.Nanosecond}
+		_, err := d.DialContext(ctx, "tcp", "example.com:443")
+		require.NoError(t, err)
+
+		// the expired address is used while the failed refresh keeps it cached.
+		resolver.set(nil, &net.DNSError{Err: "server misbehaving", Name: "example.com"})
+		_, err = d.DialContext(ctx, "tcp", "example.com:443")
+		require.NoError(t, err)
+		require.Eventually(t, func() bool { return resolver.lookupCount() == 2 }, time.Second, time.Millisecond)
+
+		resolver.set(ipAddrs("10.0.0.2"), nil)
+		require.Eventually(t, func() bool {
+			_, err := d.DialContext(ctx, "tcp", "example.com:443")
+			require.NoError(t, err)
+			return dialer.dialed[len(dialer.dialed)-1] == "10.0.0.2:443"
+		}, time.Second, time.Millisecond)
+	})
+	t.Run("splits the remaining time across addresses", func(t *testing.T) {
+		resolver := &fakeResolver{addrs: ipAddrs("10.0.0.1", "10.0.0.2", "10.0.0.3")}
+		dialer := &fakeDialer{failing: map[string]bool{"10.0.0.1:443": true, "10.0.0.2:443": true}}
+		d := &dnsCachingDialer{dialer: dialer, cache: newDNSCache(resolver), ttl: time.Hour}
+		deadline := time.Now().Add(30 * time.Second)
+		dialCtx, cancel := context.WithDeadline(ctx, deadline)
+		defer cancel()
+		conn, err := d.DialContext(dialCtx, "tcp", "example.com:443")
+		require.NoError(t, err)
+		_ = conn.Close()
+		require.Len(t, dialer.deadlines, 3)
+		assert.WithinDuration(t, time.Now().Add(10*time.Second), dialer.deadlines[0], time.Second)
+		// the first address failed without waiting, so the second is given half of the remaining time.
+		assert.WithinDuration(t, time.Now().Add(15*time.Second), dialer.deadlines[1], time.Second)
+		assert.Equal(t, deadline, dialer.deadlines[2])
+	})
+	t.Run("lookup error", func(t *testing.T) {
+		resolver := &fakeResolver{err: &net.DNSError{Err: "no such host", Name: "example.com", IsNotFound: true}}
+		d := &dnsCachingDialer{dialer: &fakeDialer{}, cache: newDNSCache(resolver), ttl: time.Hour}
+		_, err := d.DialContext(ctx, "tcp", "example.com:443")
+		var dnsErr *net.DNSError
+		require.True(t, errors.As(err, &dnsErr))
+	})
+}
+
+func TestDNSCacheEviction(t *testing.T) {
+	ctx := context.Background()
+	resolver := &fakeResolver{addrs: ipAddrs("10.0.0.1")}
+	cache := newDNSCache(resolver)
+	cache.maxEntries = 2
+
+	_, err := cache.resolve(ctx, "expired.example.com", time.Nanosecond)
+	require.NoError(t, err)
+	_, err = cache.resolve(ctx, "a.example.com", time.Hour)
+	require.NoError(t, err)
+	// expired entries are evicted first.
+	_, err = cache.resolve(ctx, "b.example.com", time.Hour)
+	require.NoError(t, err)
+	assert.Len(t, cache.entries, 2)
+	assert.NotContains(t, cache.entries, "expired.example.com")
+
+	// an arbitrary entry is evicted if none have expired.
+	_, err = cache.resolve(ctx, "c.example.com", time.Hour)
+	require.NoError(t, err)
+	assert.Len(t, cache.entries, 2)
+	assert.Contains(t, cache.entries, "c.example.com")
+
+	// resolving a cached hostname again does not evict another.
+	_, err = cache.resolve(ctx, "c.example.com", time.Hour)
+	require.NoError(t, err)
+	assert.Len(t, cache.entries, 2)
+}
@@ -17,11 +17,15 @@ package refreshingclient
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"sync"
 
 	"github.com/palantir/pkg/refreshable"
 	"github.com/palantir/pkg/tlsconfig"
 	werror "github.com/palantir/witchcraft-go-error"
 	"github.com/palantir/witchcraft-go-logging/wlog/svclog/svc1log"
+	"software.sslmate.com/src/go-pkcs12"
 )
 
 // TLSParams contains the parameters needed to build a *tls.Config.
@@ -31,6 +35,13 @@ type TLSParams struct {
 	CertFile           string
 	KeyFile            string
 	InsecureSkipVerify bool
+	// PKCS12File is the path to a PKCS#12 (.p12/.pfx) bundle containing the client certificate, its private key and
+	// optionally its CA chain. It is decrypted with PKCS12Password. It must not be set with CertFile or KeyFile.
+	PKCS12File     string
+	PKCS12Password string
+	// AppendSystemCAs configures the root CAs to contain the system roots in addition to CAFiles.
+	// If unset, the roots contain only CAFiles. It has no effect if CAFiles is empty, as the system roots are used.
+	AppendSystemCAs bool
 }
 
 type TLSProvider interface {
@@ -48,25 +59,94 @@ func (p *StaticTLSConfigProvider) GetTLSConfig(context.Context) *tls.Config {
 	return (*tls.Config)(p)
 }
 
+// RefreshingTLSConfigProvider is a TLSProvider backed by a refreshable.Refreshable containing a *tls.Config, which is
+// updated independently of the TransportParams. A transport built with it is rebuilt whenever the *tls.Config changes.
+type RefreshingTLSConfigProvider struct {
+	r refreshable.Refreshable // contains *tls.Config
+}
+
+func NewRefreshingTLSConfigProvider(r refreshable.Refreshable) RefreshingTLSConfigProvider {
+	return RefreshingTLSConfigProvider{r: r}
+}
+
+// GetTLSConfig returns a copy of the current *tls.Config, as the transport may modify it.
+func (p RefreshingTLSConfigProvider) GetTLSConfig(context.Context) *tls.Config {
+	if tlsConfig := p.r.Current().(*tls.Config); tlsConfig != nil {
+		return tlsConfig.Clone()
+	}
+	return nil
+}
+
+func (p RefreshingTLSConfigProvider) subscribeToTLSConfig(consumer func()) (unsubscribe func()) {
+	return p.r.Subscribe(func(interface{}) {
+		consumer()
+	})
+}
+
+// independentTLSProvider is implemented by TLSProviders whose *tls.Config changes independently of the
+// TransportParams, so that the transport must also be rebuilt when it changes.
+type independentTLSProvider interface {
+	TLSProvider
+	subscribeToTLSConfig(consumer func()) (unsubscribe func())
+}
+
+// asIndependentTLSProvider returns the independentTLSProvider underlying provider, if any.
+func asIndependentTLSProvider(provider TLSProvider) (independentTLSProvider, bool) {
+	for {
+		switch p := provider.(type) {
+		case independentTLSProvider:
+			return p, true
+		case mappedTLSProvider:
+			provider = p.provider
+		default:
+			return nil, false
+		}
+	}
+}
+
+// ConfigureTLSConfig accepts a mapping function which will be applied to a copy of each *tls.Config returned by
+// provider. This can be used to layer configuration which is not comparable, such as verification callbacks, on top of
+// a TLSProvider. If provider returns a nil *tls.Config, mapFn is applied to an empty *tls.Config.
+func ConfigureTLSConfig(provider TLSProvider, mapFn func(*tls.Config) *tls.Config) TLSProvider {
+	return mappedTLSProvider{provider: provider, mapFn: mapFn}
+}
+
+type mappedTLSProvider struct {
+	provider TLSProvider
+	mapFn    func(*tls.Config) *tls.Config
+}
+
+func (p mappedTLSProvider) GetTLSConfig(ctx context.Context) *tls.Config {
+	tlsConfig := p.provider.GetTLSConfig(ctx)
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{}
+	} else {
+		tlsConfig = tlsConfig.Clone()
+	}
+	return p.mapFn(tlsConfig)
+}
+
 type RefreshableTLSConfig struct {
-	r *refreshable.ValidatingRefreshable // contains *tls.Config
+	r         *refreshable.ValidatingRefreshable // contains *tls.Config
+	onInvalid func(ctx context.Context, err error)
 }
 
 // NewRefreshableTLSConfig evaluates the provided TLSParams and returns a RefreshableTLSConfig that will update the
 // underlying *tls.Config when the TLSParams change.
 // IF the initial TLSParams are invalid, NewRefreshableTLSConfig will return an error.
 // If the updated TLSParams are invalid, the RefreshableTLSConfig will continue to use the previous value and log the error.
+// If onInvalid is non-nil, it is also called with the error each time the previous value is returned.
 //
 // N.B. This subscription only fires when the paths are updated, not when the contents of the files are updated.
 // We could consider adding a file refreshable to watch the key and cert files.
-func NewRefreshableTLSConfig(ctx context.Context, params RefreshableTLSParams) (TLSProvider, error) {
+func NewRefreshableTLSConfig(ctx context.Context, params RefreshableTLSParams, onInvalid func(ctx context.Context, err error)) (TLSProvider, error) {
 	r, err := refreshable.NewMapValidatingRefreshable(params, func(i interface{}) (interface{}, error) {
 		return NewTLSConfig(ctx, i.(TLSParams))
 	})
 	if err != nil {
 		return nil, werror.WrapWithContextParams(ctx, err, "failed to build RefreshableTLSConfig")
 	}
-	return RefreshableTLSConfig{r: r}, nil
+	return RefreshableTLSConfig{r: r, onInvalid: onInvalid}, nil
 }
 
 // GetTLSConfig returns the most recent valid *tls.Config.
@@ -75,19 +155,64 @@ func NewRefreshableTLSConfig(ctx context.Context, params RefreshableTLSParams) (
 func (r RefreshableTLSConfig) GetTLSConfig(ctx context.Context) *tls.Config {
 	if err := r.r.LastValidateErr(); err != nil {
 		svc1log.FromContext(ctx).Warn("Invalid TLS config. Using previous value.", svc1log.Stacktrace(err))
+		if r.onInvalid != nil {
+			r.onInvalid(ctx, err)
+		}
 	}
 	return r.r.Current().(*tls.Config)
 }
 
+// SubscribeToTLSConfig calls consumer with each new valid *tls.Config.
+// The consumer is not called with the current value; use SubscribeToTLSConfigWithCurrent to receive it.
+func (r RefreshableTLSConfig) SubscribeToTLSConfig(consumer func(*tls.Config)) (unsubscribe func()) {
+	return r.r.Subscribe(func(i interface{}) {
+		consumer(i.(*tls.Config))
+	})
+}
+
+// SubscribeToTLSConfigWithCurrent behaves like SubscribeToTLSConfig, but also calls consumer with the current
+// *tls.Config before returning. The consumer is never called concurrently, and the first call is always with the
+// current value, so a subscriber cannot miss a config which is updated while subscribing. An update which races
+// with the subscription may result in the consumer being called again with the same value.
+// The consumer must not synchronously update the TLSParams it is subscribed to.
+func (r RefreshableTLSConfig) SubscribeToTLSConfigWithCurrent(consumer func(*tls.Config)) (unsubscribe func()) {
+	var mu sync.Mutex
+	mu.Lock()
+	defer mu.Unlock()
+	unsubscribe = r.SubscribeToTLSConfig(func(tlsConfig *tls.Config) {
+		mu.Lock()
+		defer mu.Unlock()
+		consumer(tlsConfig)
+	})
+	consumer(r.r.Current().(*tls.Config))
+	return unsubscribe
+}
+
 // NewTLSConfig returns a *tls.Config built from the provided TLSParams.
 func NewTLSConfig(ctx context.Context, p TLSParams) (*tls.Config, error) {
 	var tlsParams []tlsconfig.ClientParam
 	if len(p.CAFiles) != 0 {
-		tlsParams = append(tlsParams, tlsconfig.ClientRootCAFiles(p.CAFiles...))
+		if p.AppendSystemCAs {
+			tlsParams = append(tlsParams, tlsconfig.ClientRootCAs(systemCertPoolWithCAFiles(p.CAFiles...)))
+		} else {
+			tlsParams = append(tlsParams, tlsconfig.ClientRootCAFiles(p.CAFiles...))
+		}
+	}
+	if p.PKCS12File != "" && (p.CertFile != "" || p.KeyFile != "") {
+		return nil, werror.ErrorWithContextParams(ctx, "only one of a PKCS#12 file or a certificate and key file may be configured")
 	}
 	if p.CertFile != "" && p.KeyFile != "" {
 		tlsParams = append(tlsParams, tlsconfig.ClientKeyPairFiles(p.CertFile, p.KeyFile))
 	}
+	if p.PKCS12File != "" {
+		cert, err := loadPKCS12KeyPair(p.PKCS12File, p.PKCS12Password)
+		if err != nil {
+			return nil, werror.WrapWithContextParams(ctx, err, "failed to build tlsConfig")
+		}
+		tlsParams = append(tlsParams, tlsconfig.ClientKeyPair(func() (tls.Certificate, error) {
+			return cert, nil
+		}))
+	}
 	if p.InsecureSkipVerify {
 		tlsParams = append(tlsParams, tlsconfig.ClientInsecureSkipVerify())
 	}
@@ -97,3 +222,41 @@ func NewTLSConfig(ctx context.Context, p TLSParams) (*tls.Config, error) {
 	}
 	return tlsConfig, nil
 }
+
+// loadPKCS12KeyPair returns the certificate chain and private key in the PKCS#12 bundle at path.
+func loadPKCS12KeyPair(path, password string) (tls.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return tls.Certificate{}, werror.Wrap(err, "failed to read PKCS#12 file", werror.SafeParam("pkcs12File", path))
+	}
+	key, cert, caCerts, err := pkcs12.DecodeChain(data, password)
+	if err != nil {
+		return tls.Certificate{}, werror.Wrap(err, "failed to decode PKCS#12 file", werror.SafeParam("pkcs12File", path))
+	}
+	chain := [][]byte{cert.Raw}
+	for _, caCert := range caCerts {
+		chain = append(chain, caCert.Raw)
+	}
+	return tls.Certificate{Certificate: chain, PrivateKey: key, Leaf: cert}, nil
+}
+
+// systemCertPoolWithCAFiles returns a CertPoolProvider which appends the certificates in caFiles to a copy of the
+// system cert pool.
+func systemCertPoolWithCAFiles(caFiles ...string) tlsconfig.CertPoolProvider {
+	return func() (*x509.CertPool, error) {
+		certPool, err := x509.SystemCertPool()
+		if err != nil {
+			return nil, werror.Wrap(err, "failed to load system certificates")
+		}
+		for _, caFile := range caFiles {
+			cert, err := os.ReadFile(caFile)
+			if err != nil {
+				return nil, werror.Wrap(err, "failed to load certificates from file", werror.SafeParam("caFile", caFile))
+			}
+			if ok := certPool.AppendCertsFromPEM(cert); !ok {
+				return nil, werror.Error("no certificates detected in file", werror.SafeParam("caFile", caFile))
+			}
+		}
+		return certPool, nil
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright (c) 2024 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package refreshingclient
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/palantir/pkg/refreshable"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+func TestNewTLSConfig_AppendSystemCAs(t *testing.T) {
+	server := httptest.NewTLSServer(nil)
+	defer server.Close()
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	require.NoError(t, os.WriteFile(caFile, caPEM, 0644))
+
+	systemPool, err := x509.SystemCertPool()
+	require.NoError(t, err)
+	appendedPool := systemPool.Clone()
+	require.True(t, appendedPool.AppendCertsFromPEM(caPEM))
+	caOnlyPool := x509.NewCertPool()
+	require.True(t, caOnlyPool.AppendCertsFromPEM(caPEM))
+
+	t.Run("CA files only", func(t *testing.T) {
+		tlsConfig, err := NewTLSConfig(context.Background(), TLSParams{CAFiles: []string{caFile}})
+		require.NoError(t, err)
+		assert.True(t, caOnlyPool.Equal(tlsConfig.RootCAs), "expected only the configured CA")
+	})
+	t.Run("CA files appended to system CAs", func(t *testing.T) {
+		tlsConfig, err := NewTLSConfig(context.Background(), TLSParams{CAFiles: []string{caFile}, AppendSystemCAs: true})
+		require.NoError(t, err)
+		assert.True(t, appendedPool.Equal(tlsConfig.RootCAs), "expected the system CAs and the configured CA")
+	})
+	t.Run("no CA files", func(t *testing.T) {
+		tlsConfig, err := NewTLSConfig(context.Background(), TLSParams{AppendSystemCAs: true})
+		require.NoError(t, err)
+		assert.Nil(t, tlsConfig.RootCAs, "expected the system CAs to be used by default")
+	})
+	t.Run("missing CA file", func(t *testing.T) {
+		_, err := NewTLSConfig(context.Background(), TLSParams{CAFiles: []string{filepath.Join(t.TempDir(), "missing.pem")}, AppendSystemCAs: true})
+		assert.ErrorContains(t, err, "failed to load certificates from file")
+	})
+}
+
+func TestRefreshableTLSConfig_SubscribeToTLSConfigWithCurrent(t *testing.T) {
+	params := refreshable.NewDefaultRefreshable(TLSParams{})
+	provider, err := NewRefreshableTLSConfig(context.Background(), NewRefreshingTLSParams(params), nil)
+	require.NoError(t, err)
+	refreshableTLSConfig := provider.(RefreshableTLSConfig)
+
+	var withCurrent, withoutCurrent []*tls.Config
+	unsubscribeWithCurrent := refreshableTLSConfig.SubscribeToTLSConfigWithCurrent(func(tlsConfig *tls.Config) {
+		withCurrent = append(withCurrent, tlsConfig)
+	})
+	unsubscribeWithoutCurrent := refreshableTLSConfig.SubscribeToTLSConfig(func(tlsConfig *tls.Config) {
+		withoutCurrent = append(withoutCurrent, tlsConfig)
+	})
+	initial := refreshableTLSConfig.GetTLSConfig(context.Background())
+	assert.Equal(t, []*tls.Config{initial}, withCurrent, "expected the current value on subscribe")
+	assert.Empty(t, withoutCurrent)
+
+	require.NoError(t, params.Update(TLSParams{InsecureSkipVerify: true}))
+	updated := refreshableTLSConfig.GetTLSConfig(context.Background())
+	assert.True(t, updated.InsecureSkipVerify)
+	assert.Equal(t, []*tls.Config{initial, updated}, withCurrent)
+	assert.Equal(t, []*tls.Config{updated}, withoutCurrent)
+
+	unsubscribeWithCurrent()
+	unsubscribeWithoutCurrent()
+	require.NoError(t, params.Update(TLSParams{}))
+	assert.Len(t, withCurrent, 2)
+	assert.Len(t, withoutCurrent, 1)
+}
+
+func TestNewTLSConfig_PKCS12(t *testing.T) {
+	server := httptest.NewTLSServer(nil)
+	defer server.Close()
+	serverCert := server.TLS.Certificates[0]
+	leaf, err := x509.ParseCertificate(serverCert.Certificate[0])
+	require.NoError(t, err)
+	pfxData, err := pkcs12.Modern.Encode(serverCert.PrivateKey, leaf, nil, "password")
+	require.NoError(t, err)
+	pkcs12File := filepath.Join(t.TempDir(), "client.p12")
+	require.NoError(t, os.WriteFile(pkcs12File, pfxData, 0600))
+
+	t.Run("valid bundle", func(t *testing.T) {
+		tlsConfig, err := NewTLSConfig(context.Background(), TLSParams{PKCS12File: pkcs12File, PKCS12Password: "password"})
+		require.NoError(t, err)
+		require.NotNil(t, tlsConfig.GetClientCertificate)
+		clientCert, err := tlsConfig.GetClientCertificate(&tls.CertificateRequestInfo{})
+		require.NoError(t, err)
+		assert.Equal(t, serverCert.Certificate, clientCert.Certificate)
+		assert.Equal(t, serverCert.PrivateKey, clientCert.PrivateKey)
+	})
+	t.Run("wrong password", func(t *testing.T) {
+		_, err := NewTLSConfig(context.Background(), TLSParams{PKCS12File: pkcs12File, PKCS12Password: "wrong"})
+		assert.ErrorContains(t, err, "failed to decode PKCS#12 file")
+	})
+	t.Run("missing file", func(t *testing.T) {
+		_, err := NewTLSConfig(context.Background(), TLSParams{PKCS12File: filepath.Join(t.TempDir(), "missing.p12")})
+		assert.ErrorContains(t, err, "failed to read PKCS#12 file")
+	})
+	t.Run("conflicting cert sources", func(t *testing.T) {
+		_, err := NewTLSConfig(context.Background(), TLSParams{PKCS12File: pkcs12File, CertFile: "cert.pem", KeyFile: "key.pem"})
+		assert.EqualError(t, err, "only one of a PKCS#12 file or a certificate and key file may be configured")
+	})
+}
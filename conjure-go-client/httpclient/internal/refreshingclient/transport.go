@@ -18,6 +18,7 @@ import (
 	"context"
 	"net/http"
 	"net/url"
+	"slices"
 	"time"
 
 	"github.com/palantir/pkg/refreshable"
@@ -26,28 +27,41 @@ import (
 )
 
 type TransportParams struct {
-	MaxIdleConns          int
-	MaxIdleConnsPerHost   int
-	DisableHTTP2          bool
-	DisableKeepAlives     bool
-	IdleConnTimeout       time.Duration
-	ExpectContinueTimeout time.Duration
-	ResponseHeaderTimeout time.Duration
-	TLSHandshakeTimeout   time.Duration
-	HTTPProxyURL          *url.URL `refreshables:",exclude"`
-	ProxyFromEnvironment  bool
-	HTTP2ReadIdleTimeout  time.Duration
-	HTTP2PingTimeout      time.Duration
+	MaxIdleConns                    int
+	MaxIdleConnsPerHost             int
+	DisableHTTP2                    bool
+	DisableKeepAlives               bool
+	IdleConnTimeout                 time.Duration
+	ExpectContinueTimeout           time.Duration
+	ResponseHeaderTimeout           time.Duration
+	MaxResponseHeaderBytes          int64
+	TLSHandshakeTimeout             time.Duration
+	HTTPProxyURL                    *url.URL `refreshables:",exclude"`
+	ProxyFromEnvironment            bool
+	HTTP2ReadIdleTimeout            time.Duration
+	HTTP2PingTimeout                time.Duration
+	HTTP2StrictMaxConcurrentStreams bool
 
 	TLS TLSParams
 }
 
 func NewRefreshableTransport(ctx context.Context, p RefreshableTransportParams, tlsProvider TLSProvider, dialer ContextDialer) http.RoundTripper {
-	return &RefreshableTransport{
-		Refreshable: p.MapTransportParams(func(p TransportParams) interface{} {
-			return newTransport(ctx, p, tlsProvider, dialer)
-		}),
+	transport := p.MapTransportParams(func(p TransportParams) interface{} {
+		return newTransport(ctx, p, tlsProvider, dialer)
+	})
+	independentProvider, ok := asIndependentTLSProvider(tlsProvider)
+	if !ok {
+		return &RefreshableTransport{Refreshable: transport}
 	}
+	// The transport must also be rebuilt when the *tls.Config changes without the params changing.
+	rebuilt := refreshable.NewDefaultRefreshable(transport.Current())
+	transport.Subscribe(func(i interface{}) {
+		_ = rebuilt.Update(i)
+	})
+	independentProvider.subscribeToTLSConfig(func() {
+		_ = rebuilt.Update(newTransport(ctx, p.CurrentTransportParams(), tlsProvider, dialer))
+	})
+	return &RefreshableTransport{Refreshable: rebuilt}
 }
 
 // ConfigureTransport accepts a mapping function which will be applied to the params value as it is evaluated.
@@ -79,17 +93,25 @@ func newTransport(ctx context.Context, p TransportParams, tlsProvider TLSProvide
 	}
 
 	tlsConfig := tlsProvider.GetTLSConfig(ctx)
+	if p.DisableHTTP2 && tlsConfig != nil && slices.Contains(tlsConfig.NextProtos, http2.NextProtoTLS) {
+		// Do not advertise h2 via ALPN if the transport is not configured to speak it.
+		tlsConfig = tlsConfig.Clone()
+		tlsConfig.NextProtos = slices.DeleteFunc(slices.Clone(tlsConfig.NextProtos), func(proto string) bool {
+			return proto == http2.NextProtoTLS
+		})
+	}
 	transport := &http.Transport{
-		Proxy:                 transportProxy,
-		DialContext:           dialer.DialContext,
-		MaxIdleConns:          p.MaxIdleConns,
-		MaxIdleConnsPerHost:   p.MaxIdleConnsPerHost,
-		TLSClientConfig:       tlsConfig,
-		DisableKeepAlives:     p.DisableKeepAlives,
-		ExpectContinueTimeout: p.ExpectContinueTimeout,
-		IdleConnTimeout:       p.IdleConnTimeout,
-		TLSHandshakeTimeout:   p.TLSHandshakeTimeout,
-		ResponseHeaderTimeout: p.ResponseHeaderTimeout,
+		Proxy:                  transportProxy,
+		DialContext:            dialer.DialContext,
+		MaxIdleConns:           p.MaxIdleConns,
+		MaxIdleConnsPerHost:    p.MaxIdleConnsPerHost,
+		TLSClientConfig:        tlsConfig,
+		DisableKeepAlives:      p.DisableKeepAlives,
+		ExpectContinueTimeout:  p.ExpectContinueTimeout,
+		IdleConnTimeout:        p.IdleConnTimeout,
+		TLSHandshakeTimeout:    p.TLSHandshakeTimeout,
+		ResponseHeaderTimeout:  p.ResponseHeaderTimeout,
+		MaxResponseHeaderBytes: p.MaxResponseHeaderBytes,
 	}
 
 	if !p.DisableHTTP2 {
@@ -113,6 +135,10 @@ func newTransport(ctx context.Context, p TransportParams, tlsProvider TLSProvide
 			// before closing an HTTP/2 connection. The PingTimeout is only valid if
 			// the above ReadIdleTimeout is > 0.
 			http2Transport.PingTimeout = p.HTTP2PingTimeout
+
+			// StrictMaxConcurrentStreams limits the number of concurrent streams to each host to the server's
+			// SETTINGS_MAX_CONCURRENT_STREAMS, rather than opening new connections once a connection's limit is reached.
+			http2Transport.StrictMaxConcurrentStreams = p.HTTP2StrictMaxConcurrentStreams
 		}
 	}
 
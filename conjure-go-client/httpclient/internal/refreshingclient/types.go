@@ -36,6 +36,7 @@ type ValidatedClientParams struct {
 	Timeout        time.Duration
 	Transport      TransportParams
 	URIs           []string
+	UserAgent      *string
 }
 
 // BasicAuth represents the configuration for HTTP Basic Authorization
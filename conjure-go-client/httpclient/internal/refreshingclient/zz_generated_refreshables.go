@@ -24,6 +24,7 @@ type RefreshableValidatedClientParams interface {
 	Timeout() refreshable.Duration
 	Transport() RefreshableTransportParams
 	URIs() refreshable.StringSlice
+	UserAgent() refreshable.StringPtr
 }
 
 type RefreshingValidatedClientParams struct {
@@ -116,6 +117,12 @@ func (r RefreshingValidatedClientParams) URIs() refreshable.StringSlice {
 	}))
 }
 
+func (r RefreshingValidatedClientParams) UserAgent() refreshable.StringPtr {
+	return refreshable.NewStringPtr(r.MapValidatedClientParams(func(i ValidatedClientParams) interface{} {
+		return i.UserAgent
+	}))
+}
+
 type RefreshableBasicAuthPtr interface {
 	refreshable.Refreshable
 	CurrentBasicAuthPtr() *BasicAuth
@@ -216,6 +223,7 @@ type RefreshableDialerParams interface {
 
 	DialTimeout() refreshable.Duration
 	KeepAlive() refreshable.Duration
+	DNSCacheTTL() refreshable.Duration
 }
 
 type RefreshingDialerParams struct {
@@ -254,6 +262,12 @@ func (r RefreshingDialerParams) KeepAlive() refreshable.Duration {
 	}))
 }
 
+func (r RefreshingDialerParams) DNSCacheTTL() refreshable.Duration {
+	return refreshable.NewDuration(r.MapDialerParams(func(i DialerParams) interface{} {
+		return i.DNSCacheTTL
+	}))
+}
+
 type RefreshableTags interface {
 	refreshable.Refreshable
 	CurrentTags() metrics.Tags
@@ -406,10 +420,12 @@ type RefreshableTransportParams interface {
 	IdleConnTimeout() refreshable.Duration
 	ExpectContinueTimeout() refreshable.Duration
 	ResponseHeaderTimeout() refreshable.Duration
+	MaxResponseHeaderBytes() refreshable.Int64
 	TLSHandshakeTimeout() refreshable.Duration
 	ProxyFromEnvironment() refreshable.Bool
 	HTTP2ReadIdleTimeout() refreshable.Duration
 	HTTP2PingTimeout() refreshable.Duration
+	HTTP2StrictMaxConcurrentStreams() refreshable.Bool
 	TLS() RefreshableTLSParams
 }
 
@@ -479,6 +495,12 @@ func (r RefreshingTransportParams) ResponseHeaderTimeout() refreshable.Duration
 	}))
 }
 
+func (r RefreshingTransportParams) MaxResponseHeaderBytes() refreshable.Int64 {
+	return refreshable.NewInt64(r.MapTransportParams(func(i TransportParams) interface{} {
+		return i.MaxResponseHeaderBytes
+	}))
+}
+
 func (r RefreshingTransportParams) TLSHandshakeTimeout() refreshable.Duration {
 	return refreshable.NewDuration(r.MapTransportParams(func(i TransportParams) interface{} {
 		return i.TLSHandshakeTimeout
@@ -503,6 +525,12 @@ func (r RefreshingTransportParams) HTTP2PingTimeout() refreshable.Duration {
 	}))
 }
 
+func (r RefreshingTransportParams) HTTP2StrictMaxConcurrentStreams() refreshable.Bool {
+	return refreshable.NewBool(r.MapTransportParams(func(i TransportParams) interface{} {
+		return i.HTTP2StrictMaxConcurrentStreams
+	}))
+}
+
 func (r RefreshingTransportParams) TLS() RefreshableTLSParams {
 	return NewRefreshingTLSParams(r.MapTransportParams(func(i TransportParams) interface{} {
 		return i.TLS
@@ -519,6 +547,9 @@ type RefreshableTLSParams interface {
 	CertFile() refreshable.String
 	KeyFile() refreshable.String
 	InsecureSkipVerify() refreshable.Bool
+	PKCS12File() refreshable.String
+	PKCS12Password() refreshable.String
+	AppendSystemCAs() refreshable.Bool
 }
 
 type RefreshingTLSParams struct {
@@ -568,3 +599,21 @@ func (r RefreshingTLSParams) InsecureSkipVerify() refreshable.Bool {
 		return i.InsecureSkipVerify
 	}))
 }
+
+func (r RefreshingTLSParams) PKCS12File() refreshable.String {
+	return refreshable.NewString(r.MapTLSParams(func(i TLSParams) interface{} {
+		return i.PKCS12File
+	}))
+}
+
+func (r RefreshingTLSParams) PKCS12Password() refreshable.String {
+	return refreshable.NewString(r.MapTLSParams(func(i TLSParams) interface{} {
+		return i.PKCS12Password
+	}))
+}
+
+func (r RefreshingTLSParams) AppendSystemCAs() refreshable.Bool {
+	return refreshable.NewBool(r.MapTLSParams(func(i TLSParams) interface{} {
+		return i.AppendSystemCAs
+	}))
+}
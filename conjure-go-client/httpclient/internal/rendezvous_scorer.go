@@ -0,0 +1,78 @@
+// Copyright (c) 2024 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"hash/fnv"
+	"sort"
+)
+
+// KeyedURIScoringMiddleware is a URIScoringMiddleware which can also order URIs based on a key carried by the
+// request, such as a shard key.
+type KeyedURIScoringMiddleware interface {
+	URIScoringMiddleware
+	GetURIsInOrderOfIncreasingScoreForKey(key string) []string
+}
+
+type rendezvousScorer struct {
+	// URIScoringMiddleware orders URIs for requests without a key and observes all requests.
+	URIScoringMiddleware
+	uris []string
+}
+
+// NewRendezvousURIScoringMiddleware returns a URI scorer which orders URIs for a key by rendezvous (highest random
+// weight) hashing, so requests with the same key prefer the same URI, and adding or removing a URI only changes
+// the preferred URI of the keys which preferred that URI. Requests without a key are scored by fallback.
+func NewRendezvousURIScoringMiddleware(uris []string, fallback URIScoringMiddleware) KeyedURIScoringMiddleware {
+	return &rendezvousScorer{
+		URIScoringMiddleware: fallback,
+		uris:                 uris,
+	}
+}
+
+func (s *rendezvousScorer) GetURIsInOrderOfIncreasingScoreForKey(key string) []string {
+	uris := make([]string, len(s.uris))
+	copy(uris, s.uris)
+	weights := make(map[string]uint64, len(uris))
+	for _, uri := range uris {
+		weights[uri] = rendezvousWeight(key, uri)
+	}
+	sort.Slice(uris, func(i, j int) bool {
+		if weights[uris[i]] != weights[uris[j]] {
+			return weights[uris[i]] > weights[uris[j]]
+		}
+		return uris[i] < uris[j]
+	})
+	return uris
+}
+
+func rendezvousWeight(key, uri string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(uri))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(key))
+	// FNV-1a mixes its final bytes poorly, so finalize the hash to spread similar keys across URIs.
+	return mix64(h.Sum64())
+}
+
+// mix64 is the finalizer of the SplitMix64 generator.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,56 @@
+// Copyright (c) 2024 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRendezvousScorer(t *testing.T) {
+	uris := []string{"uri1", "uri2", "uri3", "uri4", "uri5"}
+	nanoClock := func() int64 { return time.Now().UnixNano() }
+	scorer := NewRendezvousURIScoringMiddleware(uris, NewBalancedURIScoringMiddleware(uris, nanoClock))
+
+	counts := make(map[string]int)
+	preferred := make(map[string]string)
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		scored := scorer.GetURIsInOrderOfIncreasingScoreForKey(key)
+		assert.ElementsMatch(t, uris, scored)
+		assert.Equal(t, scored, scorer.GetURIsInOrderOfIncreasingScoreForKey(key), "order must be deterministic")
+		counts[scored[0]]++
+		preferred[key] = scored[0]
+	}
+	for _, uri := range uris {
+		assert.InDelta(t, 200, counts[uri], 60, "keys should be spread evenly across URIs: %v", counts)
+	}
+
+	// removing a URI only remaps the keys which preferred it
+	removed := NewRendezvousURIScoringMiddleware(uris[1:], NewBalancedURIScoringMiddleware(uris[1:], nanoClock))
+	for key, uri := range preferred {
+		scored := removed.GetURIsInOrderOfIncreasingScoreForKey(key)
+		if uri != uris[0] {
+			assert.Equal(t, uri, scored[0], key)
+		} else {
+			assert.NotEqual(t, uri, scored[0], key)
+		}
+	}
+
+	assert.ElementsMatch(t, uris, scorer.GetURIsInOrderOfIncreasingScore())
+}
@@ -0,0 +1,93 @@
+// Copyright (c) 2024 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// maxRetryBudgetTokens bounds the tokens accumulated while requests are succeeding,
+// so a long healthy period can not fund an unbounded burst of retries.
+const maxRetryBudgetTokens = 100
+
+// RetryBudget is a token bucket shared by all requests of a client which limits retries
+// to a ratio of initial requests, plus a minimum number of retries per second.
+// Each initial request deposits ratio tokens and each retry withdraws one token.
+type RetryBudget struct {
+	ratio     float64
+	minPerSec float64
+	maxTokens float64
+	now       func() time.Time
+
+	mutex      sync.Mutex
+	tokens     float64
+	lastRefill time.Time
+}
+
+// NewRetryBudget returns a RetryBudget which allows ratio retries per request and minPerSec retries per second
+// regardless of request volume.
+func NewRetryBudget(ratio, minPerSec float64) *RetryBudget {
+	return newRetryBudget(ratio, minPerSec, time.Now)
+}
+
+func newRetryBudget(ratio, minPerSec float64, now func() time.Time) *RetryBudget {
+	return &RetryBudget{
+		ratio:      ratio,
+		minPerSec:  minPerSec,
+		maxTokens:  math.Max(maxRetryBudgetTokens, minPerSec),
+		now:        now,
+		tokens:     minPerSec,
+		lastRefill: now(),
+	}
+}
+
+// Deposit records an initial (non-retry) request.
+func (b *RetryBudget) Deposit() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.refill()
+	b.tokens = math.Min(b.tokens+b.ratio, b.maxTokens)
+}
+
+// TryWithdraw returns true and consumes a token if a retry is allowed.
+func (b *RetryBudget) TryWithdraw() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.refill()
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// Balance returns the number of retries currently allowed.
+func (b *RetryBudget) Balance() float64 {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.refill()
+	return b.tokens
+}
+
+func (b *RetryBudget) refill() {
+	now := b.now()
+	elapsed := now.Sub(b.lastRefill).Seconds()
+	b.lastRefill = now
+	if elapsed > 0 {
+		b.tokens = math.Min(b.tokens+elapsed*b.minPerSec, b.maxTokens)
+	}
+}
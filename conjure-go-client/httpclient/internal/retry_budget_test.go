@@ -0,0 +1,49 @@
+// Copyright (c) 2024 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryBudget(t *testing.T) {
+	now := time.Unix(0, 0)
+	budget := newRetryBudget(0.5, 1, func() time.Time { return now })
+
+	// starts with the per-second minimum
+	assert.True(t, budget.TryWithdraw())
+	assert.False(t, budget.TryWithdraw())
+
+	// each request deposits the ratio
+	budget.Deposit()
+	assert.False(t, budget.TryWithdraw())
+	budget.Deposit()
+	assert.True(t, budget.TryWithdraw())
+	assert.False(t, budget.TryWithdraw())
+
+	// the minimum refills over time
+	now = now.Add(2 * time.Second)
+	assert.Equal(t, 2.0, budget.Balance())
+	assert.True(t, budget.TryWithdraw())
+	assert.True(t, budget.TryWithdraw())
+	assert.False(t, budget.TryWithdraw())
+
+	// tokens are capped
+	now = now.Add(time.Hour)
+	assert.Equal(t, float64(maxRetryBudgetTokens), budget.Balance())
+}
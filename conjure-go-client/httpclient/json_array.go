@@ -0,0 +1,61 @@
+// Copyright (c) 2024 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"reflect"
+
+	"github.com/palantir/pkg/safejson"
+	werror "github.com/palantir/witchcraft-go-error"
+)
+
+// jsonArrayHandler decodes each element of a JSON array response into elem and calls fn.
+type jsonArrayHandler struct {
+	elem any
+	fn   func() error
+}
+
+// readJSONArray decodes the JSON array in r one element at a time. A JSON null is treated as an empty array.
+func readJSONArray(ctx context.Context, r io.Reader, handler *jsonArrayHandler) error {
+	dec := safejson.Decoder(r)
+	tok, err := dec.Token()
+	if err != nil {
+		return werror.WrapWithContextParams(ctx, err, "httpclient: failed to decode JSON array")
+	}
+	if tok == nil {
+		return nil
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		return werror.ErrorWithContextParams(ctx, "httpclient: response body is not a JSON array",
+			werror.SafeParam("token", tok))
+	}
+	elemValue := reflect.ValueOf(handler.elem).Elem()
+	for dec.More() {
+		elemValue.SetZero()
+		if err := dec.Decode(handler.elem); err != nil {
+			return werror.WrapWithContextParams(ctx, err, "httpclient: failed to decode JSON array element")
+		}
+		if err := handler.fn(); err != nil {
+			return err
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return werror.WrapWithContextParams(ctx, err, "httpclient: failed to decode JSON array")
+	}
+	return nil
+}
@@ -18,6 +18,7 @@ import (
 	"context"
 	"crypto/tls"
 	"errors"
+	"io"
 	"net"
 	"net/http"
 	"net/http/httptrace"
@@ -44,6 +45,14 @@ const (
 
 	MetricConnCreate      = "client.connection.create" // monotonic counter of each new request, tagged with reused:true or reused:false
 	MetricRequestInFlight = "client.request.in-flight"
+
+	MetricRequestBodySize  = "client.request.body.size"  // monotonic counter of request body bytes sent, tagged with method-name
+	MetricResponseBodySize = "client.response.body.size" // monotonic counter of response body bytes received, tagged with method-name
+
+	MetricRetryBudget          = "client.retry.budget"           // gauge of the retries currently allowed by the retry budget
+	MetricRetryBudgetExhausted = "client.retry.budget.exhausted" // monotonic counter of retries rejected by the retry budget
+
+	MetricTLSConfigInvalid = "client.tls.config.invalid" // monotonic counter of transport rebuilds which kept the previous TLS config because the updated TLS config was invalid
 )
 
 var (
@@ -115,22 +124,62 @@ func (h *metricsMiddleware) RoundTrip(req *http.Request, next http.RoundTripper)
 	}
 	serviceNameTag := metrics.NewTagWithFallbackValue(MetricTagServiceName, h.ServiceName.CurrentString(), "unknown")
 
-	metrics.FromContext(req.Context()).Counter(MetricRequestInFlight, serviceNameTag).Inc(1)
+	registry := metrics.FromContext(req.Context())
+	bodySizeTags := append(metrics.Tags{serviceNameTag}, tagRequestMethodName(req, nil, nil)...)
+
+	registry.Counter(MetricRequestInFlight, serviceNameTag).Inc(1)
 	start := time.Now()
 	tlsMetricsContext := h.tlsTraceContext(req.Context(), serviceNameTag)
-	resp, err := next.RoundTrip(req.WithContext(tlsMetricsContext))
+	reqWithMetrics := req.WithContext(tlsMetricsContext)
+	reqContentLength := req.ContentLength
+	if reqContentLength == 0 {
+		// A zero ContentLength with a non-nil body means the length is unknown.
+		reqContentLength = -1
+	}
+	reqWithMetrics.Body = countBodySize(req.Body, reqContentLength, registry.Counter(MetricRequestBodySize, bodySizeTags...).Inc)
+	resp, err := next.RoundTrip(reqWithMetrics)
 	duration := time.Since(start)
-	metrics.FromContext(req.Context()).Counter(MetricRequestInFlight, serviceNameTag).Dec(1)
+	registry.Counter(MetricRequestInFlight, serviceNameTag).Dec(1)
+	if resp != nil && req.Method != http.MethodHead && resp.StatusCode != http.StatusSwitchingProtocols {
+		resp.Body = countBodySize(resp.Body, resp.ContentLength, registry.Counter(MetricResponseBodySize, bodySizeTags...).Inc)
+	}
 
 	tags := []metrics.Tag{serviceNameTag}
 	for _, tagProvider := range h.Tags {
 		tags = append(tags, tagProvider.Tags(req, resp, err)...)
 	}
 
-	metrics.FromContext(req.Context()).Timer(metricClientResponse, tags...).Update(duration / time.Microsecond)
+	registry.Timer(metricClientResponse, tags...).Update(duration / time.Microsecond)
 	return resp, err
 }
 
+// countBodySize records the size of body using inc. If the length is known, it is recorded immediately and
+// body is returned unchanged. Otherwise, body is wrapped to record bytes as they are read.
+func countBodySize(body io.ReadCloser, contentLength int64, inc func(int64)) io.ReadCloser {
+	switch {
+	case body == nil || body == http.NoBody:
+		return body
+	case contentLength >= 0:
+		inc(contentLength)
+		return body
+	default:
+		return &countingReadCloser{ReadCloser: body, inc: inc}
+	}
+}
+
+type countingReadCloser struct {
+	io.ReadCloser
+	inc func(int64)
+}
+
+func (c *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	if n > 0 {
+		c.inc(int64(n))
+	}
+	return n, err
+}
+
 func tagStatusFamily(_ *http.Request, resp *http.Response, respErr error) metrics.Tags {
 	switch {
 	case isTimeoutError(respErr):
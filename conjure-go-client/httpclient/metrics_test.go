@@ -17,13 +17,16 @@ package httpclient_test
 import (
 	"context"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/palantir/conjure-go-runtime/v2/conjure-go-client/httpclient"
 	"github.com/palantir/pkg/metrics"
+	"github.com/palantir/pkg/refreshable"
 	"github.com/palantir/pkg/tlsconfig"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -412,3 +415,68 @@ func TestMetricsMiddleware_InFlightRequests(t *testing.T) {
 	clientMetric := rootRegistry.Counter(httpclient.MetricRequestInFlight, serviceNameTag)
 	assert.Equal(t, int64(0), clientMetric.Count(), "%s should be zero after a request", httpclient.MetricRequestInFlight)
 }
+
+func TestMetricsMiddleware_BodySize(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, _ = io.Copy(io.Discard, req.Body)
+		if req.URL.Path == "/chunked" {
+			// Flushing before the handler returns forces a chunked response of unknown length.
+			_, _ = w.Write([]byte("hello "))
+			w.(http.Flusher).Flush()
+			_, _ = w.Write([]byte("world"))
+			return
+		}
+		_, _ = w.Write([]byte("hello"))
+	}))
+	defer srv.Close()
+
+	rootRegistry := metrics.NewRootMetricsRegistry()
+	ctx := metrics.WithRegistry(context.Background(), rootRegistry)
+
+	client, err := httpclient.NewHTTPClient(httpclient.WithServiceName("test-service"), httpclient.WithMetrics())
+	require.NoError(t, err)
+
+	doRequest := func(path string, body io.Reader) {
+		req, err := http.NewRequestWithContext(httpclient.ContextWithRPCMethodName(ctx, "test-endpoint"), http.MethodPost, srv.URL+path, body)
+		require.NoError(t, err)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		_, err = io.Copy(io.Discard, resp.Body)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+	}
+	// The length of a strings.Reader is known, while the length of a MultiReader is not.
+	doRequest("/", strings.NewReader("0123456789"))
+	doRequest("/chunked", io.MultiReader(strings.NewReader("01234"), strings.NewReader("567")))
+
+	tags := metrics.Tags{metrics.MustNewTag("service-name", "test-service"), metrics.MustNewTag("method-name", "test-endpoint")}
+	assert.Equal(t, int64(18), rootRegistry.Counter(httpclient.MetricRequestBodySize, tags...).Count())
+	assert.Equal(t, int64(16), rootRegistry.Counter(httpclient.MetricResponseBodySize, tags...).Count())
+}
+
+func TestMetricTLSConfigInvalid(t *testing.T) {
+	rootRegistry := metrics.NewRootMetricsRegistry()
+	ctx := metrics.WithRegistry(context.Background(), rootRegistry)
+
+	config := refreshable.NewDefaultRefreshable(httpclient.ClientConfig{ServiceName: "test-service", URIs: []string{"https://localhost"}})
+	var callbackErrs []error
+	_, err := httpclient.NewHTTPClientFromRefreshableConfig(ctx, httpclient.NewRefreshingClientConfig(config),
+		httpclient.WithTLSConfigErrorCallback(func(_ context.Context, err error) {
+			callbackErrs = append(callbackErrs, err)
+		}))
+	require.NoError(t, err)
+
+	tags := metrics.Tags{metrics.MustNewTag(httpclient.MetricTagServiceName, "test-service")}
+	assert.Equal(t, int64(0), rootRegistry.Counter(httpclient.MetricTLSConfigInvalid, tags...).Count())
+	assert.Empty(t, callbackErrs)
+
+	require.NoError(t, config.Update(httpclient.ClientConfig{
+		ServiceName: "test-service",
+		URIs:        []string{"https://localhost"},
+		Security:    httpclient.SecurityConfig{CAFiles: []string{"does-not-exist.pem"}},
+	}))
+	assert.Equal(t, int64(1), rootRegistry.Counter(httpclient.MetricTLSConfigInvalid, tags...).Count())
+	if assert.Len(t, callbackErrs, 1) {
+		assert.ErrorContains(t, callbackErrs[0], "failed to build tlsConfig")
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright (c) 2024 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient
+
+import (
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+
+	werror "github.com/palantir/witchcraft-go-error"
+)
+
+const multipartMixedContentType = "multipart/mixed"
+
+// readMultipart parses the multipart response body using the boundary of its Content-Type and calls handler
+// for each part.
+func readMultipart(ctx context.Context, resp *http.Response, handler func(part *multipart.Part) error) error {
+	mediaType, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil {
+		return werror.WrapWithContextParams(ctx, err, "httpclient: failed to parse multipart response Content-Type")
+	}
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		return werror.ErrorWithContextParams(ctx, "httpclient: response is not multipart",
+			werror.SafeParam("contentType", mediaType))
+	}
+	boundary := params["boundary"]
+	if boundary == "" {
+		return werror.ErrorWithContextParams(ctx, "httpclient: multipart response Content-Type has no boundary",
+			werror.SafeParam("contentType", mediaType))
+	}
+	reader := multipart.NewReader(resp.Body, boundary)
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return werror.WrapWithContextParams(ctx, err, "httpclient: failed to read multipart response part")
+		}
+		if err := handler(part); err != nil {
+			return err
+		}
+	}
+}
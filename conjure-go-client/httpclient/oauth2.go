@@ -0,0 +1,171 @@
+// Copyright (c) 2024 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/palantir/conjure-go-runtime/v2/conjure-go-client/httpclient/internal"
+	"github.com/palantir/conjure-go-runtime/v2/conjure-go-contract/codecs"
+	werror "github.com/palantir/witchcraft-go-error"
+	"github.com/palantir/witchcraft-go-logging/wlog/svclog/svc1log"
+)
+
+// maxOAuth2TokenRefreshMargin bounds how long before its expiry a token is refreshed.
+// Tokens are refreshed after three quarters of their lifetime, or this long before they expire, whichever is later.
+const maxOAuth2TokenRefreshMargin = time.Minute
+
+// oauth2ClientCredentials configures the OAuth2 client credentials grant (RFC 6749 section 4.4).
+// See WithOAuth2ClientCredentials.
+type oauth2ClientCredentials struct {
+	tokenURL     string
+	clientID     string
+	clientSecret string
+	scopes       []string
+}
+
+// oauth2TokenResponse is a successful access token response (RFC 6749 section 5.1).
+type oauth2TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// oauth2Middleware sets the Authorization header to a bearer token fetched from the token endpoint.
+// The token is cached until it is due to be refreshed or is rejected by the server.
+type oauth2Middleware struct {
+	credentials oauth2ClientCredentials
+	tokenClient *http.Client
+	now         func() time.Time
+
+	mu    sync.Mutex
+	token string
+	// refreshAt is the time after which token is refreshed, or zero if the token does not expire.
+	refreshAt time.Time
+	// expiresAt is the time at which token expires, or zero if the token does not expire.
+	expiresAt time.Time
+}
+
+func newOAuth2Middleware(credentials *oauth2ClientCredentials, tokenTransport http.RoundTripper) Middleware {
+	if credentials == nil {
+		return nil
+	}
+	return &oauth2Middleware{
+		credentials: *credentials,
+		tokenClient: &http.Client{Transport: tokenTransport},
+		now:         time.Now,
+	}
+}
+
+func (m *oauth2Middleware) RoundTrip(req *http.Request, next http.RoundTripper) (*http.Response, error) {
+	token, err := m.getToken(req.Context(), "")
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := next.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	// The token may have been revoked or expired early, so refresh it and retry once if the body can be replayed.
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return resp, nil
+	}
+	internal.DrainBody(req.Context(), resp)
+	token, err = m.getToken(req.Context(), token)
+	if err != nil {
+		return nil, err
+	}
+	retryReq := req.Clone(req.Context())
+	if req.GetBody != nil {
+		if retryReq.Body, err = req.GetBody(); err != nil {
+			return nil, werror.WrapWithContextParams(req.Context(), err, "failed to get request body to retry request")
+		}
+	}
+	retryReq.Header.Set("Authorization", "Bearer "+token)
+	return next.RoundTrip(retryReq)
+}
+
+// getToken returns the cached token, or fetches a new one if the cached token is due to be refreshed or
+// equals rejected, i.e. it was rejected by the server.
+func (m *oauth2Middleware) getToken(ctx context.Context, rejected string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.token != "" && m.token != rejected && (m.refreshAt.IsZero() || m.now().Before(m.refreshAt)) {
+		return m.token, nil
+	}
+	requestedAt := m.now()
+	tokenResp, err := m.fetchToken(ctx)
+	if err != nil {
+		if m.token != "" && m.token != rejected && m.now().Before(m.expiresAt) {
+			svc1log.FromContext(ctx).Warn("Failed to refresh OAuth2 token, using cached token until it expires.", svc1log.Stacktrace(err))
+			return m.token, nil
+		}
+		// the cached token is expired or was rejected, so do not use it again.
+		m.token = ""
+		return "", werror.WrapWithContextParams(ctx, err, "httpclient: failed to fetch OAuth2 token",
+			werror.UnsafeParam("tokenURL", m.credentials.tokenURL))
+	}
+	m.token = tokenResp.AccessToken
+	m.refreshAt, m.expiresAt = time.Time{}, time.Time{}
+	if tokenResp.ExpiresIn > 0 {
+		lifetime := time.Duration(tokenResp.ExpiresIn) * time.Second
+		m.refreshAt = requestedAt.Add(lifetime - min(lifetime/4, maxOAuth2TokenRefreshMargin))
+		m.expiresAt = requestedAt.Add(lifetime)
+	}
+	return m.token, nil
+}
+
+func (m *oauth2Middleware) fetchToken(ctx context.Context) (*oauth2TokenResponse, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(m.credentials.scopes) > 0 {
+		form.Set("scope", strings.Join(m.credentials.scopes, " "))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.credentials.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", codecs.JSON.Accept())
+	// client credentials are form-encoded before being used as the basic auth username and password (RFC 6749 section 2.3.1).
+	setBasicAuth(req.Header, url.QueryEscape(m.credentials.clientID), url.QueryEscape(m.credentials.clientSecret))
+
+	resp, err := m.tokenClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer internal.DrainBody(ctx, resp)
+	if resp.StatusCode != http.StatusOK {
+		return nil, werror.ErrorWithContextParams(ctx, "token endpoint returned an error",
+			werror.SafeParam("tokenStatusCode", resp.StatusCode))
+	}
+	var tokenResp oauth2TokenResponse
+	if err := codecs.JSON.Decode(resp.Body, &tokenResp); err != nil {
+		return nil, err
+	}
+	if tokenResp.AccessToken == "" {
+		return nil, werror.ErrorWithContextParams(ctx, "token response does not contain an access token")
+	}
+	if !strings.EqualFold(tokenResp.TokenType, "bearer") {
+		return nil, werror.ErrorWithContextParams(ctx, "token response has unsupported token type",
+			werror.SafeParam("tokenType", tokenResp.TokenType))
+	}
+	return &tokenResp, nil
+}
@@ -0,0 +1,210 @@
+// Copyright (c) 2024 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testOAuth2Server issues tokens "token-1", "token-2", ... with the configured expiry.
+type testOAuth2Server struct {
+	mu        sync.Mutex
+	issued    int
+	expiresIn int
+	status    int
+}
+
+func (s *testOAuth2Server) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user, password, _ := req.BasicAuth()
+	if req.Method != http.MethodPost || user != "client%2Fid" || password != "secret" ||
+		req.Header.Get("Content-Type") != "application/x-www-form-urlencoded" ||
+		req.FormValue("grant_type") != "client_credentials" || req.FormValue("scope") != "read write" {
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if s.status != 0 {
+		rw.WriteHeader(s.status)
+		return
+	}
+	s.issued++
+	rw.Header().Set("Content-Type", "application/json")
+	_, _ = fmt.Fprintf(rw, `{"access_token":"token-%d","token_type":"Bearer","expires_in":%d}`, s.issued, s.expiresIn)
+}
+
+func TestOAuth2ClientCredentials(t *testing.T) {
+	tokenServer := &testOAuth2Server{expiresIn: 3600}
+	tokenHTTPServer := httptest.NewServer(tokenServer)
+	defer tokenHTTPServer.Close()
+
+	var authorizations []string
+	rejected := map[string]bool{}
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		authorization := req.Header.Get("Authorization")
+		authorizations = append(authorizations, authorization+" "+string(body))
+		if rejected[authorization] {
+			rw.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	client, err := NewClient(
+		WithBaseURLs([]string{server.URL}),
+		WithAuthToken("static"),
+		WithOAuth2ClientCredentials(tokenHTTPServer.URL+"/token", "client/id", "secret", []string{"read", "write"}),
+	)
+	require.NoError(t, err)
+
+	t.Run("token is cached", func(t *testing.T) {
+		authorizations = nil
+		for i := 0; i < 2; i++ {
+			_, err := client.Post(context.Background(), WithBinaryRequestBody(RequestBodyInMemory(strings.NewReader("body"))))
+			require.NoError(t, err)
+		}
+		assert.Equal(t, []string{"Bearer token-1 body", "Bearer token-1 body"}, authorizations)
+		assert.Equal(t, 1, tokenServer.issued)
+	})
+
+	t.Run("rejected token is refreshed once", func(t *testing.T) {
+		authorizations = nil
+		rejected["Bearer token-1"] = true
+		_, err := client.Post(context.Background(), WithBinaryRequestBody(RequestBodyInMemory(strings.NewReader("body"))))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Bearer token-1 body", "Bearer token-2 body"}, authorizations)
+
+		rejected["Bearer token-2"], rejected["Bearer token-3"] = true, true
+		authorizations = nil
+		_, err = client.Get(context.Background())
+		require.Error(t, err)
+		statusCode, _ := StatusCodeFromError(err)
+		assert.Equal(t, http.StatusUnauthorized, statusCode)
+		assert.Equal(t, []string{"Bearer token-2 ", "Bearer token-3 "}, authorizations)
+	})
+
+	t.Run("stream once body is not retried", func(t *testing.T) {
+		authorizations = nil
+		_, err := client.Post(context.Background(), WithRawRequestBody(io.NopCloser(strings.NewReader("stream"))))
+		require.Error(t, err)
+		assert.Equal(t, []string{"Bearer token-3 stream"}, authorizations)
+	})
+
+	t.Run("token endpoint error", func(t *testing.T) {
+		authorizations = nil
+		rejected["Bearer token-3"] = true
+		tokenServer.status = http.StatusInternalServerError
+		defer func() { tokenServer.status = 0 }()
+		_, err := client.Get(context.Background())
+		require.EqualError(t, err, "httpclient request failed: httpclient: failed to fetch OAuth2 token: token endpoint returned an error")
+		assert.Equal(t, []string{"Bearer token-3 "}, authorizations)
+	})
+
+	t.Run("invalid token URL", func(t *testing.T) {
+		_, err := NewClient(WithBaseURLs([]string{server.URL}), WithOAuth2ClientCredentials("/token", "id", "secret", nil))
+		require.EqualError(t, err, "httpclient: OAuth2 token URL must be an absolute URL")
+	})
+}
+
+func TestOAuth2Middleware_Refresh(t *testing.T) {
+	tokenServer := &testOAuth2Server{}
+	tokenHTTPServer := httptest.NewServer(tokenServer)
+	defer tokenHTTPServer.Close()
+
+	for _, test := range []struct {
+		Name      string
+		ExpiresIn int
+		Elapsed   []time.Duration
+		// Unavailable is the index of the first request after which the token endpoint fails, if positive.
+		Unavailable int
+		Tokens      []string
+		Err         string
+	}{
+		{
+			Name:      "long lived token refreshed a minute before expiry",
+			ExpiresIn: 3600,
+			Elapsed:   []time.Duration{0, 58 * time.Minute, 59 * time.Minute, 60 * time.Minute},
+			Tokens:    []string{"token-1", "token-1", "token-2", "token-2"},
+		},
+		{
+			Name:      "short lived token refreshed after three quarters of its lifetime",
+			ExpiresIn: 60,
+			Elapsed:   []time.Duration{0, 44 * time.Second, 45 * time.Second},
+			Tokens:    []string{"token-1", "token-1", "token-2"},
+		},
+		{
+			Name:        "cached token used until expiry if refresh fails",
+			ExpiresIn:   3600,
+			Elapsed:     []time.Duration{0, 59 * time.Minute, 60 * time.Minute},
+			Unavailable: 1,
+			Tokens:      []string{"token-1", "token-1"},
+			Err:         "httpclient: failed to fetch OAuth2 token: token endpoint returned an error",
+		},
+		{
+			Name:      "token without expiry is not refreshed",
+			ExpiresIn: 0,
+			Elapsed:   []time.Duration{0, 24 * time.Hour},
+			Tokens:    []string{"token-1", "token-1"},
+		},
+	} {
+		t.Run(test.Name, func(t *testing.T) {
+			tokenServer.issued, tokenServer.expiresIn = 0, test.ExpiresIn
+			start := time.Now()
+			var now time.Time
+			m := newOAuth2Middleware(&oauth2ClientCredentials{
+				tokenURL:     tokenHTTPServer.URL,
+				clientID:     "client/id",
+				clientSecret: "secret",
+				scopes:       []string{"read", "write"},
+			}, http.DefaultTransport).(*oauth2Middleware)
+			m.now = func() time.Time { return now }
+
+			defer func() { tokenServer.status = 0 }()
+
+			var tokens []string
+			var err error
+			for i, elapsed := range test.Elapsed {
+				if test.Unavailable > 0 && i == test.Unavailable {
+					tokenServer.status = http.StatusServiceUnavailable
+				}
+				now = start.Add(elapsed)
+				var token string
+				if token, err = m.getToken(context.Background(), ""); err != nil {
+					break
+				}
+				tokens = append(tokens, token)
+			}
+			assert.Equal(t, test.Tokens, tokens)
+			if test.Err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, test.Err)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2024 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/palantir/pkg/metrics"
+	"github.com/palantir/pkg/refreshable"
+	"github.com/palantir/witchcraft-go-logging/wlog/svclog/svc1log"
+	"github.com/palantir/witchcraft-go-tracing/wtracing"
+)
+
+const (
+	MetricTagEndpoint = "endpoint"
+	MetricTagHost     = "host"
+)
+
+// ObservabilityConfig configures the instrumentation installed by WithObservability.
+type ObservabilityConfig struct {
+	// TagProviders add tags to the client.response metric in addition to the shared endpoint and host tags.
+	TagProviders []TagsProvider
+	// LogRequests logs each request attempt at info level.
+	LogRequests bool
+}
+
+// tagEndpointAndHost tags the endpoint name and host of the request, which WithObservability shares between
+// metrics and request logs.
+func tagEndpointAndHost(req *http.Request, _ *http.Response, _ error) metrics.Tags {
+	return metrics.Tags{
+		metrics.NewTagWithFallbackValue(MetricTagEndpoint, endpointNameOrUnknown(req), "unknown"),
+		metrics.NewTagWithFallbackValue(MetricTagHost, req.URL.Host, "unknown"),
+	}
+}
+
+func endpointNameOrUnknown(req *http.Request) string {
+	if name := getEndpointName(req.Context()); name != "" {
+		return name
+	}
+	return "unknown"
+}
+
+// requestLogMiddleware logs each request attempt with the same endpoint and host as tagEndpointAndHost.
+// It must be wrapped by the trace middleware to log the ID of the request's span.
+type requestLogMiddleware struct {
+	serviceName refreshable.String
+}
+
+func (m requestLogMiddleware) RoundTrip(req *http.Request, next http.RoundTripper) (*http.Response, error) {
+	start := time.Now()
+	resp, err := next.RoundTrip(req)
+	ctx := req.Context()
+	params := []svc1log.Param{
+		svc1log.SafeParam("serviceName", m.serviceName.CurrentString()),
+		svc1log.SafeParam("endpoint", endpointNameOrUnknown(req)),
+		svc1log.SafeParam("host", req.URL.Host),
+		svc1log.SafeParam("method", req.Method),
+		svc1log.SafeParam("durationMillis", time.Since(start).Milliseconds()),
+	}
+	if span := wtracing.SpanFromContext(ctx); span != nil {
+		params = append(params, svc1log.SafeParam("spanId", string(span.Context().ID)))
+	}
+	if err != nil {
+		svc1log.FromContext(ctx).Info("HTTP request failed", append(params, svc1log.Stacktrace(err))...)
+		return resp, err
+	}
+	svc1log.FromContext(ctx).Info("HTTP request completed", append(params, svc1log.SafeParam("statusCode", resp.StatusCode))...)
+	return resp, nil
+}
@@ -0,0 +1,88 @@
+// Copyright (c) 2024 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/palantir/conjure-go-runtime/v2/conjure-go-client/httpclient"
+	"github.com/palantir/pkg/metrics"
+	"github.com/palantir/witchcraft-go-logging/wlog"
+	"github.com/palantir/witchcraft-go-logging/wlog/svclog/svc1log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObservability(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+	serverURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	registry := metrics.NewRootMetricsRegistry()
+	var logOutput bytes.Buffer
+	logger := svc1log.NewFromCreator(&logOutput, wlog.InfoLevel, wlog.NewJSONMarshalLoggerProvider().NewLeveledLogger)
+	ctx := svc1log.WithLogger(metrics.WithRegistry(context.Background(), registry), logger)
+
+	client, err := httpclient.NewClient(
+		httpclient.WithBaseURLs([]string{server.URL}),
+		httpclient.WithServiceName("test-service"),
+		httpclient.WithObservability(httpclient.ObservabilityConfig{
+			TagProviders: []httpclient.TagsProvider{httpclient.StaticTagsProvider{metrics.MustNewTag("team", "core")}},
+			LogRequests:  true,
+		}),
+	)
+	require.NoError(t, err)
+
+	_, err = client.Get(ctx, httpclient.WithEndpointName("getThing"))
+	require.NoError(t, err)
+
+	var found bool
+	registry.Each(func(name string, tags metrics.Tags, _ metrics.MetricVal) {
+		if name != "client.response" {
+			return
+		}
+		found = true
+		assert.Equal(t, map[metrics.Tag]struct{}{
+			metrics.MustNewTag("family", "2xx"):                       {},
+			metrics.MustNewTag("method", "get"):                       {},
+			metrics.MustNewTag("method-name", "RPCMethodNameMissing"): {},
+			metrics.MustNewTag("service-name", "test-service"):        {},
+			metrics.MustNewTag("endpoint", "getThing"):                {},
+			metrics.MustNewTag("host", serverURL.Host):                {},
+			metrics.MustNewTag("team", "core"):                        {},
+		}, tags.ToSet())
+	})
+	assert.True(t, found, "did not find client.response metric")
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal(logOutput.Bytes(), &entry), logOutput.String())
+	assert.Equal(t, "HTTP request completed", entry["message"])
+	params, ok := entry["params"].(map[string]interface{})
+	require.True(t, ok, "expected params in %v", entry)
+	assert.Equal(t, "test-service", params["serviceName"])
+	assert.Equal(t, "getThing", params["endpoint"])
+	assert.Equal(t, serverURL.Host, params["host"])
+	assert.Equal(t, "GET", params["method"])
+	assert.Equal(t, float64(http.StatusAccepted), params["statusCode"])
+}
@@ -0,0 +1,88 @@
+// Copyright (c) 2024 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	werror "github.com/palantir/witchcraft-go-error"
+)
+
+var (
+	// ErrRangeIgnored is returned when a request set a Range header using WithRange or WithRangeFrom
+	// but the server responded with the full content instead of a 206 Partial Content response.
+	ErrRangeIgnored = fmt.Errorf("httpclient: server ignored range request and returned the full content")
+)
+
+// ContentRange represents the value of a Content-Range response header.
+type ContentRange struct {
+	// Start is the offset of the first byte in the response body, or -1 if the range is unsatisfied ("*").
+	Start int64
+	// End is the offset of the last byte (inclusive) in the response body, or -1 if the range is unsatisfied ("*").
+	End int64
+	// Size is the total size of the resource, or -1 if it is unknown ("*").
+	Size int64
+}
+
+// ContentRangeFromResponse parses the Content-Range header of the response.
+// It returns an error if the header is missing or malformed.
+func ContentRangeFromResponse(resp *http.Response) (ContentRange, error) {
+	if resp == nil {
+		return ContentRange{}, werror.Error("httpclient: response is nil")
+	}
+	return parseContentRange(resp.Header.Get("Content-Range"))
+}
+
+// parseContentRange parses values of the form "bytes 0-499/1234", "bytes 0-499/*", or "bytes */1234".
+func parseContentRange(value string) (ContentRange, error) {
+	invalid := func() (ContentRange, error) {
+		return ContentRange{}, werror.Error("httpclient: invalid Content-Range header", werror.SafeParam("contentRange", value))
+	}
+	rangeSpec, ok := strings.CutPrefix(value, "bytes ")
+	if !ok {
+		return invalid()
+	}
+	byteRange, size, ok := strings.Cut(rangeSpec, "/")
+	if !ok {
+		return invalid()
+	}
+	result := ContentRange{Start: -1, End: -1, Size: -1}
+	if size != "*" {
+		n, err := strconv.ParseInt(size, 10, 64)
+		if err != nil || n < 0 {
+			return invalid()
+		}
+		result.Size = n
+	}
+	if byteRange != "*" {
+		start, end, ok := strings.Cut(byteRange, "-")
+		if !ok {
+			return invalid()
+		}
+		var err error
+		if result.Start, err = strconv.ParseInt(start, 10, 64); err != nil || result.Start < 0 {
+			return invalid()
+		}
+		if result.End, err = strconv.ParseInt(end, 10, 64); err != nil || result.End < result.Start {
+			return invalid()
+		}
+	} else if result.Size < 0 {
+		return invalid()
+	}
+	return result, nil
+}
@@ -0,0 +1,115 @@
+// Copyright (c) 2024 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/palantir/conjure-go-runtime/v2/conjure-go-client/httpclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRange(t *testing.T) {
+	const content = "0123456789"
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		calls++
+		if req.URL.Path == "/ignored" {
+			_, _ = rw.Write([]byte(content))
+			return
+		}
+		http.ServeContent(rw, req, "content", time.Time{}, strings.NewReader(content))
+	}))
+	defer server.Close()
+
+	client, err := httpclient.NewClient(httpclient.WithBaseURLs([]string{server.URL}))
+	require.NoError(t, err)
+
+	t.Run("range", func(t *testing.T) {
+		resp, err := client.Get(context.Background(), httpclient.WithRange(2, 4), httpclient.WithRawResponseBody())
+		require.NoError(t, err)
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+		assert.Equal(t, http.StatusPartialContent, resp.StatusCode)
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "234", string(body))
+		contentRange, err := httpclient.ContentRangeFromResponse(resp)
+		require.NoError(t, err)
+		assert.Equal(t, httpclient.ContentRange{Start: 2, End: 4, Size: 10}, contentRange)
+	})
+	t.Run("range from", func(t *testing.T) {
+		resp, err := client.Get(context.Background(), httpclient.WithRangeFrom(7), httpclient.WithRawResponseBody())
+		require.NoError(t, err)
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "789", string(body))
+	})
+	t.Run("range ignored", func(t *testing.T) {
+		calls = 0
+		_, err := client.Get(context.Background(), httpclient.WithPath("/ignored"), httpclient.WithRange(2, 4), httpclient.WithRawResponseBody())
+		assert.True(t, errors.Is(err, httpclient.ErrRangeIgnored), "expected ErrRangeIgnored, got %v", err)
+		assert.Equal(t, 1, calls, "request should not be retried")
+	})
+	t.Run("range not satisfiable", func(t *testing.T) {
+		_, err := client.Get(context.Background(), httpclient.WithRangeFrom(20))
+		code, ok := httpclient.StatusCodeFromError(err)
+		require.True(t, ok)
+		assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, code)
+	})
+	t.Run("invalid range", func(t *testing.T) {
+		_, err := client.Get(context.Background(), httpclient.WithRange(4, 2))
+		require.EqualError(t, err, "httpclient: invalid range")
+	})
+}
+
+func TestContentRangeFromResponse(t *testing.T) {
+	for _, test := range []struct {
+		Value    string
+		Expected httpclient.ContentRange
+		Err      bool
+	}{
+		{Value: "bytes 0-499/1234", Expected: httpclient.ContentRange{Start: 0, End: 499, Size: 1234}},
+		{Value: "bytes 0-499/*", Expected: httpclient.ContentRange{Start: 0, End: 499, Size: -1}},
+		{Value: "bytes */1234", Expected: httpclient.ContentRange{Start: -1, End: -1, Size: 1234}},
+		{Value: "", Err: true},
+		{Value: "bytes */*", Err: true},
+		{Value: "bytes 5-1/10", Err: true},
+		{Value: "items 0-1/2", Err: true},
+	} {
+		t.Run(test.Value, func(t *testing.T) {
+			resp := &http.Response{Header: http.Header{"Content-Range": []string{test.Value}}}
+			contentRange, err := httpclient.ContentRangeFromResponse(resp)
+			if test.Err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.Expected, contentRange)
+		})
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright (c) 2024 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	werror "github.com/palantir/witchcraft-go-error"
+)
+
+var (
+	// ErrInteractionNotRecorded is returned by a client using WithReplayer when the store has no interaction
+	// recorded for the request. It is not retried.
+	ErrInteractionNotRecorded = fmt.Errorf("httpclient: no recorded interaction matches the request")
+)
+
+// InteractionStore stores the interactions recorded by WithRecorder and served by WithReplayer.
+// Implementations must be safe for concurrent use.
+type InteractionStore interface {
+	Get(key InteractionKey) (RecordedInteraction, bool)
+	Set(key InteractionKey, value RecordedInteraction)
+}
+
+// InteractionKey identifies the request of a RecordedInteraction.
+type InteractionKey struct {
+	Method string
+	URL    string
+	// BodySHA256 is the hex-encoded SHA-256 digest of the request body.
+	BodySHA256 string
+}
+
+// RecordedInteraction is a request and the response received for it. It can be encoded as JSON, e.g. to persist
+// the interactions recorded by a test run.
+type RecordedInteraction struct {
+	Method         string      `json:"method"`
+	URL            string      `json:"url"`
+	RequestHeader  http.Header `json:"requestHeader,omitempty"`
+	RequestBody    []byte      `json:"requestBody,omitempty"`
+	StatusCode     int         `json:"statusCode"`
+	ResponseHeader http.Header `json:"responseHeader,omitempty"`
+	ResponseBody   []byte      `json:"responseBody,omitempty"`
+}
+
+// Key returns the key matching the request of the interaction.
+func (i RecordedInteraction) Key() InteractionKey {
+	return newInteractionKey(i.Method, i.URL, i.RequestBody)
+}
+
+func newInteractionKey(method, url string, body []byte) InteractionKey {
+	sum := sha256.Sum256(body)
+	return InteractionKey{Method: method, URL: url, BodySHA256: hex.EncodeToString(sum[:])}
+}
+
+// NewInMemoryInteractionStore returns an InteractionStore which stores interactions in an unbounded map.
+func NewInMemoryInteractionStore() InteractionStore {
+	return &inMemoryInteractionStore{interactions: make(map[InteractionKey]RecordedInteraction)}
+}
+
+type inMemoryInteractionStore struct {
+	mutex        sync.RWMutex
+	interactions map[InteractionKey]RecordedInteraction
+}
+
+func (s *inMemoryInteractionStore) Get(key InteractionKey) (RecordedInteraction, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	value, ok := s.interactions[key]
+	return value, ok
+}
+
+func (s *inMemoryInteractionStore) Set(key InteractionKey, value RecordedInteraction) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.interactions[key] = value
+}
+
+// interactionRecorderMiddleware records each request sent and the response received for it.
+type interactionRecorderMiddleware struct {
+	store InteractionStore
+}
+
+func newInteractionRecorderMiddleware(store InteractionStore) Middleware {
+	if store == nil {
+		return nil
+	}
+	return interactionRecorderMiddleware{store: store}
+}
+
+func (m interactionRecorderMiddleware) RoundTrip(req *http.Request, next http.RoundTripper) (*http.Response, error) {
+	req, reqBody, err := readInteractionRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := next.RoundTrip(req)
+	if err != nil || resp == nil {
+		return resp, err
+	}
+	var respBody []byte
+	if resp.Body != nil {
+		respBody, err = io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(respBody))
+	}
+	reqHeader := req.Header.Clone()
+	// credentials are not recorded.
+	reqHeader.Del("Authorization")
+	interaction := RecordedInteraction{
+		Method:         req.Method,
+		URL:            req.URL.String(),
+		RequestHeader:  reqHeader,
+		RequestBody:    reqBody,
+		StatusCode:     resp.StatusCode,
+		ResponseHeader: resp.Header.Clone(),
+		ResponseBody:   respBody,
+	}
+	m.store.Set(interaction.Key(), interaction)
+	return resp, nil
+}
+
+// interactionReplayerMiddleware serves recorded responses without sending requests.
+type interactionReplayerMiddleware struct {
+	store InteractionStore
+}
+
+func newInteractionReplayerMiddleware(store InteractionStore) Middleware {
+	if store == nil {
+		return nil
+	}
+	return interactionReplayerMiddleware{store: store}
+}
+
+func (m interactionReplayerMiddleware) RoundTrip(req *http.Request, _ http.RoundTripper) (*http.Response, error) {
+	req, reqBody, err := readInteractionRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	if req.Body != nil {
+		_ = req.Body.Close()
+	}
+	interaction, ok := m.store.Get(newInteractionKey(req.Method, req.URL.String(), reqBody))
+	if !ok {
+		return nil, werror.WrapWithContextParams(req.Context(), ErrInteractionNotRecorded, "",
+			werror.SafeParam("method", req.Method),
+			werror.UnsafeParam("url", req.URL.String()))
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", interaction.StatusCode, http.StatusText(interaction.StatusCode)),
+		StatusCode:    interaction.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        interaction.ResponseHeader.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(interaction.ResponseBody)),
+		ContentLength: int64(len(interaction.ResponseBody)),
+		Request:       req,
+	}, nil
+}
+
+// readInteractionRequestBody returns the content of the request body. If the body can not be replayed using
+// GetBody, it is read into memory and a copy of the request with the buffered body is returned.
+func readInteractionRequestBody(req *http.Request) (*http.Request, []byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return req, nil, nil
+	}
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, nil, err
+		}
+		defer func() {
+			_ = body.Close()
+		}()
+		content, err := io.ReadAll(body)
+		if err != nil {
+			return nil, nil, err
+		}
+		return req, content, nil
+	}
+	content, err := io.ReadAll(req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+	req = req.Clone(req.Context())
+	req.Body = io.NopCloser(bytes.NewReader(content))
+	return req, content, nil
+}
@@ -0,0 +1,110 @@
+// Copyright (c) 2024 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/palantir/conjure-go-runtime/v2/conjure-go-client/httpclient"
+	"github.com/palantir/conjure-go-runtime/v2/conjure-go-contract/codecs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type countingInteractionStore struct {
+	httpclient.InteractionStore
+	gets int
+}
+
+func (s *countingInteractionStore) Get(key httpclient.InteractionKey) (httpclient.RecordedInteraction, bool) {
+	s.gets++
+	return s.InteractionStore.Get(key)
+}
+
+func TestRecorderAndReplayer(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		requests++
+		body, err := io.ReadAll(req.Body)
+		assert.NoError(t, err)
+		if req.URL.Path == "/missing" {
+			rw.WriteHeader(http.StatusNotFound)
+			return
+		}
+		rw.Header().Set("Content-Type", "application/json")
+		_, _ = rw.Write([]byte(`{"method":"` + req.Method + `","body":"` + string(body) + `"}`))
+	}))
+	baseURL := server.URL
+
+	store := httpclient.NewInMemoryInteractionStore()
+	recordingClient, err := httpclient.NewClient(
+		httpclient.WithBaseURLs([]string{baseURL}),
+		httpclient.WithRecorder(store),
+		httpclient.WithAuthToken("secret"),
+	)
+	require.NoError(t, err)
+
+	doRequests := func(t *testing.T, client httpclient.Client) {
+		var getOutput map[string]string
+		_, err := client.Get(context.Background(), httpclient.WithPath("/path"), httpclient.WithJSONResponse(&getOutput))
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"method": "GET", "body": ""}, getOutput)
+
+		var postOutput map[string]string
+		_, err = client.Post(context.Background(),
+			httpclient.WithPath("/path"),
+			httpclient.WithRequestBody("a", codecs.Plain),
+			httpclient.WithJSONResponse(&postOutput))
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"method": "POST", "body": "a"}, postOutput)
+
+		_, err = client.Get(context.Background(), httpclient.WithPath("/missing"))
+		status, ok := httpclient.StatusCodeFromError(err)
+		require.True(t, ok, "expected a status code error, got %v", err)
+		assert.Equal(t, http.StatusNotFound, status)
+	}
+
+	doRequests(t, recordingClient)
+	assert.Equal(t, 3, requests)
+
+	interaction, ok := store.Get(httpclient.RecordedInteraction{Method: http.MethodGet, URL: baseURL + "/path"}.Key())
+	require.True(t, ok)
+	assert.Equal(t, http.StatusOK, interaction.StatusCode)
+	assert.Empty(t, interaction.RequestHeader.Get("Authorization"))
+
+	// the recorded interactions are replayed without a server
+	server.Close()
+	replayStore := &countingInteractionStore{InteractionStore: store}
+	replayingClient, err := httpclient.NewClient(
+		httpclient.WithBaseURLs([]string{baseURL}),
+		httpclient.WithReplayer(replayStore),
+	)
+	require.NoError(t, err)
+	doRequests(t, replayingClient)
+	assert.Equal(t, 3, requests)
+
+	t.Run("unmatched request is not retried", func(t *testing.T) {
+		replayStore.gets = 0
+		_, err := replayingClient.Post(context.Background(), httpclient.WithPath("/path"), httpclient.WithJSONRequest("b"))
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, httpclient.ErrInteractionNotRecorded), "expected ErrInteractionNotRecorded, got %v", err)
+		assert.Equal(t, 1, replayStore.gets)
+	})
+}
@@ -21,6 +21,7 @@ import (
 	"time"
 
 	"github.com/palantir/pkg/bytesbuffers"
+	werror "github.com/palantir/witchcraft-go-error"
 )
 
 type requestBuilder struct {
@@ -33,8 +34,32 @@ type requestBuilder struct {
 	bufferPool     bytesbuffers.Pool
 
 	errorDecoderMiddleware Middleware
+	errorBodyDrainLimit    int64
 	configureCtx           []func(context.Context) context.Context
 	requestTimeout         *time.Duration
+	perAttemptTimeout      *time.Duration
+	disableRetry           bool
+
+	baseURL       string
+	baseURLStrict bool
+	shardKey      string
+
+	forceRequestCompression bool
+	acceptGzip              bool
+	endpointName            string
+	priority                RequestPriority
+
+	// errorParams are added to the error returned by Do, if any.
+	errorParams []werror.Param
+
+	// requestMutators are called with the request of each attempt immediately before it is sent.
+	requestMutators []func(*http.Request) error
+	// requestMutatorFailed is set when a request mutator returned an error, so the request is not retried.
+	requestMutatorFailed bool
+
+	// headerFuncs are re-applied to the request after client middlewares have run
+	// so that request-scoped headers take precedence over client-scoped headers.
+	headerFuncs []func(http.Header)
 }
 
 const traceIDHeaderKey = "X-B3-TraceId"
@@ -48,3 +73,43 @@ type requestParamFunc func(*requestBuilder) error
 func (f requestParamFunc) apply(b *requestBuilder) error {
 	return f(b)
 }
+
+// setHeaders applies fn to the request headers. fn is applied again after client middlewares
+// have run, so it must be idempotent.
+func (b *requestBuilder) setHeaders(fn func(http.Header)) {
+	fn(b.headers)
+	b.headerFuncs = append(b.headerFuncs, fn)
+}
+
+// headerMiddleware returns a middleware which re-applies request-scoped headers, or nil if there are none.
+func (b *requestBuilder) headerMiddleware() Middleware {
+	if len(b.headerFuncs) == 0 {
+		return nil
+	}
+	return MiddlewareFunc(func(req *http.Request, next http.RoundTripper) (*http.Response, error) {
+		for _, fn := range b.headerFuncs {
+			fn(req.Header)
+		}
+		return next.RoundTrip(req)
+	})
+}
+
+// requestMutatorMiddleware returns a middleware which calls the request mutators, or nil if there are none.
+func (b *requestBuilder) requestMutatorMiddleware() Middleware {
+	if len(b.requestMutators) == 0 {
+		return nil
+	}
+	return MiddlewareFunc(func(req *http.Request, next http.RoundTripper) (*http.Response, error) {
+		for _, mutate := range b.requestMutators {
+			if err := mutate(req); err != nil {
+				b.requestMutatorFailed = true
+				// the request is not sent, so the transport does not close its body.
+				if req.Body != nil {
+					_ = req.Body.Close()
+				}
+				return nil, err
+			}
+		}
+		return next.RoundTrip(req)
+	})
+}
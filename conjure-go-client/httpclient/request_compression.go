@@ -0,0 +1,164 @@
+// Copyright (c) 2024 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// RequestCompression is a Content-Encoding applied to request bodies by WithRequestCompression.
+type RequestCompression string
+
+const (
+	RequestCompressionGZIP    RequestCompression = "gzip"
+	RequestCompressionDeflate RequestCompression = "deflate"
+)
+
+// compressedContentTypePrefixes are media types whose content is already compressed,
+// so compressing them again only costs CPU.
+var compressedContentTypePrefixes = []string{
+	"application/gzip",
+	"application/x-gzip",
+	"application/zip",
+	"application/zstd",
+	"application/x-bzip2",
+	"application/x-xz",
+	"application/x-7z-compressed",
+	"image/",
+	"audio/",
+	"video/",
+}
+
+type requestCompressionMiddleware struct {
+	compression  RequestCompression
+	minSizeBytes int64
+	force        bool
+}
+
+func newRequestCompressionMiddleware(compression RequestCompression, minSizeBytes int64, force bool) Middleware {
+	if compression == "" {
+		return nil
+	}
+	return requestCompressionMiddleware{compression: compression, minSizeBytes: minSizeBytes, force: force}
+}
+
+func (m requestCompressionMiddleware) RoundTrip(req *http.Request, next http.RoundTripper) (*http.Response, error) {
+	if m.shouldCompress(req) {
+		body, getBody := req.Body, req.GetBody
+		req.Body = m.compress(body)
+		if getBody != nil {
+			req.GetBody = func() (io.ReadCloser, error) {
+				body, err := getBody()
+				if err != nil {
+					return nil, err
+				}
+				return m.compress(body), nil
+			}
+		}
+		req.ContentLength = -1
+		req.Header.Set("Content-Encoding", string(m.compression))
+	}
+	return next.RoundTrip(req)
+}
+
+func (m requestCompressionMiddleware) shouldCompress(req *http.Request) bool {
+	if req.Body == nil || req.Body == http.NoBody {
+		return false
+	}
+	// the body is already encoded, or its digest was computed over the uncompressed content
+	if req.Header.Get("Content-Encoding") != "" || req.Header.Get("Content-MD5") != "" {
+		return false
+	}
+	if m.force {
+		return true
+	}
+	if req.ContentLength < 0 || req.ContentLength < m.minSizeBytes {
+		return false
+	}
+	return !isCompressedContentType(req.Header.Get("Content-Type"))
+}
+
+// compress returns a reader of the compressed body. The body is compressed as it is read so that
+// bodies of unknown size are not buffered in memory.
+func (m requestCompressionMiddleware) compress(body io.ReadCloser) io.ReadCloser {
+	return &compressingReadCloser{body: body, compression: m.compression}
+}
+
+// compressingReadCloser compresses body into a pipe from a new goroutine once it is first read.
+// Deferring the compression ensures no goroutine is left blocked if the body is closed without being read,
+// e.g. because a middleware failed before the request was sent.
+type compressingReadCloser struct {
+	body        io.ReadCloser
+	compression RequestCompression
+
+	once sync.Once
+	pr   *io.PipeReader
+}
+
+func (r *compressingReadCloser) Read(p []byte) (int, error) {
+	r.once.Do(func() {
+		pr, pw := io.Pipe()
+		go func() {
+			var w io.WriteCloser
+			switch r.compression {
+			case RequestCompressionDeflate:
+				w = zlib.NewWriter(pw)
+			default:
+				w = gzip.NewWriter(pw)
+			}
+			_, err := io.Copy(w, r.body)
+			if closeErr := w.Close(); err == nil {
+				err = closeErr
+			}
+			_ = r.body.Close()
+			_ = pw.CloseWithError(err)
+		}()
+		r.pr = pr
+	})
+	return r.pr.Read(p)
+}
+
+func (r *compressingReadCloser) Close() error {
+	var neverRead bool
+	r.once.Do(func() {
+		// never read, so there is no compressing goroutine to close the body
+		neverRead = true
+		r.pr, _ = io.Pipe()
+		_ = r.pr.Close()
+	})
+	if neverRead {
+		return r.body.Close()
+	}
+	return r.pr.Close()
+}
+
+func isCompressedContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, prefix := range compressedContentTypePrefixes {
+		if strings.HasPrefix(mediaType, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,159 @@
+// Copyright (c) 2024 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"runtime/pprof"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/palantir/conjure-go-runtime/v2/conjure-go-client/httpclient"
+	"github.com/palantir/conjure-go-runtime/v2/conjure-go-contract/codecs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestCompression(t *testing.T) {
+	largeBody := strings.Repeat("a", 1024)
+	var contentEncoding, receivedBody string
+	// handlerErr is asserted by the test goroutine, as FailNow must not be called from the handler goroutine.
+	var handlerErr error
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		contentEncoding = req.Header.Get("Content-Encoding")
+		var r io.Reader = req.Body
+		switch contentEncoding {
+		case "gzip":
+			r, handlerErr = gzip.NewReader(req.Body)
+		case "deflate":
+			r, handlerErr = zlib.NewReader(req.Body)
+		}
+		if handlerErr != nil {
+			return
+		}
+		var body []byte
+		body, handlerErr = io.ReadAll(r)
+		receivedBody = string(body)
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	for _, test := range []struct {
+		Name             string
+		Compression      httpclient.RequestCompression
+		Params           []httpclient.RequestParam
+		ExpectedEncoding string
+		ExpectedBody     string
+	}{
+		{
+			Name:             "large body is compressed",
+			Compression:      httpclient.RequestCompressionGZIP,
+			Params:           []httpclient.RequestParam{httpclient.WithBinaryRequestBody(httpclient.RequestBodyInMemory(strings.NewReader(largeBody)))},
+			ExpectedEncoding: "gzip",
+			ExpectedBody:     largeBody,
+		},
+		{
+			Name:             "deflate",
+			Compression:      httpclient.RequestCompressionDeflate,
+			Params:           []httpclient.RequestParam{httpclient.WithRequestBody(largeBody, codecs.Plain)},
+			ExpectedEncoding: "deflate",
+			ExpectedBody:     largeBody,
+		},
+		{
+			Name:         "small body is not compressed",
+			Compression:  httpclient.RequestCompressionGZIP,
+			Params:       []httpclient.RequestParam{httpclient.WithRequestBody("small", codecs.Plain)},
+			ExpectedBody: "small",
+		},
+		{
+			Name:        "already compressed content type is not compressed",
+			Compression: httpclient.RequestCompressionGZIP,
+			Params: []httpclient.RequestParam{
+				httpclient.WithBinaryRequestBody(httpclient.RequestBodyInMemory(strings.NewReader(largeBody))),
+				httpclient.WithHeader("Content-Type", "image/png"),
+			},
+			ExpectedBody: largeBody,
+		},
+		{
+			Name:        "unknown size is not compressed",
+			Compression: httpclient.RequestCompressionGZIP,
+			Params: []httpclient.RequestParam{
+				httpclient.WithBinaryRequestBody(httpclient.RequestBodyStreamOnce(func() io.ReadCloser { return io.NopCloser(strings.NewReader(largeBody)) })),
+			},
+			ExpectedBody: largeBody,
+		},
+		{
+			Name:        "unknown size is compressed when forced",
+			Compression: httpclient.RequestCompressionGZIP,
+			Params: []httpclient.RequestParam{
+				httpclient.WithBinaryRequestBody(httpclient.RequestBodyStreamOnce(func() io.ReadCloser { return io.NopCloser(strings.NewReader(largeBody)) })),
+				httpclient.WithForceRequestCompression(),
+			},
+			ExpectedEncoding: "gzip",
+			ExpectedBody:     largeBody,
+		},
+	} {
+		t.Run(test.Name, func(t *testing.T) {
+			contentEncoding, receivedBody, handlerErr = "", "", nil
+			client, err := httpclient.NewClient(
+				httpclient.WithBaseURLs([]string{server.URL}),
+				httpclient.WithRequestCompression(test.Compression, 512),
+			)
+			require.NoError(t, err)
+			_, err = client.Post(context.Background(), test.Params...)
+			require.NoError(t, err)
+			require.NoError(t, handlerErr)
+			assert.Equal(t, test.ExpectedEncoding, contentEncoding)
+			assert.Equal(t, test.ExpectedBody, receivedBody)
+		})
+	}
+}
+
+func TestRequestCompressionMutatorFailureDoesNotLeak(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	client, err := httpclient.NewClient(
+		httpclient.WithBaseURLs([]string{server.URL}),
+		httpclient.WithRequestCompression(httpclient.RequestCompressionGZIP, 0),
+	)
+	require.NoError(t, err)
+
+	before := runtime.NumGoroutine()
+	for i := 0; i < 50; i++ {
+		_, err := client.Post(context.Background(),
+			httpclient.WithRequestBody("body", codecs.Plain),
+			httpclient.WithRequestMutator(func(req *http.Request) error {
+				return fmt.Errorf("mutator failed")
+			}),
+		)
+		require.EqualError(t, err, "httpclient request failed: mutator failed")
+	}
+
+	time.Sleep(100 * time.Millisecond) // leave some time for the goroutines to reasonably exit
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, pprof.Lookup("goroutine").WriteTo(buf, 1))
+	assert.LessOrEqual(t, runtime.NumGoroutine(), before, buf.String())
+}
@@ -18,7 +18,13 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptrace"
+	"net/textproto"
 	"net/url"
+	"reflect"
+	"slices"
 	"strings"
 	"time"
 
@@ -38,6 +44,18 @@ func WithRPCMethodName(name string) RequestParam {
 	})
 }
 
+// WithEndpointName names the endpoint of the request, selecting the EndpointConfig registered for the name
+// with WithEndpointConfig. If not set, the name provided to WithRPCMethodName is used.
+func WithEndpointName(name string) RequestParam {
+	return requestParamFunc(func(b *requestBuilder) error {
+		b.endpointName = name
+		b.configureCtx = append(b.configureCtx, func(ctx context.Context) context.Context {
+			return context.WithValue(ctx, endpointName, name)
+		})
+		return nil
+	})
+}
+
 // WithRequestMethod sets the HTTP method of the request, e.g. GET or POST.
 func WithRequestMethod(method string) RequestParam {
 	return requestParamFunc(func(b *requestBuilder) error {
@@ -49,6 +67,43 @@ func WithRequestMethod(method string) RequestParam {
 	})
 }
 
+// WithBaseURL sets the base URL for the request, overriding the BaseURLs set on the client.
+// Retries are attempted only against the provided URL. The URL does not need to be one of the client's BaseURLs;
+// use WithStrictBaseURL to require that it is.
+func WithBaseURL(baseURL string) RequestParam {
+	return requestParamFunc(func(b *requestBuilder) error {
+		if baseURL == "" {
+			return werror.Error("httpclient: base URL can not be empty")
+		}
+		b.baseURL = baseURL
+		b.baseURLStrict = false
+		return nil
+	})
+}
+
+// WithStrictBaseURL is like WithBaseURL, but the request returns an error if the provided URL
+// is not one of the BaseURLs currently set on the client.
+func WithStrictBaseURL(baseURL string) RequestParam {
+	return requestParamFunc(func(b *requestBuilder) error {
+		if baseURL == "" {
+			return werror.Error("httpclient: base URL can not be empty")
+		}
+		b.baseURL = baseURL
+		b.baseURLStrict = true
+		return nil
+	})
+}
+
+// WithShardKey sets the shard key of the request, which clients configured with WithConsistentHashURIScoring use to
+// consistently prefer the same base URL for requests with the same key, e.g. for cache locality. Retries use the
+// remaining base URLs in an order which is also determined by the key. Other clients ignore the key.
+func WithShardKey(key string) RequestParam {
+	return requestParamFunc(func(b *requestBuilder) error {
+		b.shardKey = key
+		return nil
+	})
+}
+
 // WithPath sets the path for the request. This will be joined with
 // one of the BaseURLs set on the client
 func WithPath(path string) RequestParam {
@@ -64,18 +119,127 @@ func WithPathf(format string, args ...interface{}) RequestParam {
 	return WithPath(fmt.Sprintf(format, args...))
 }
 
-// WithHeader sets a header on a request.
+// WithPathParams sets the path for the request by substituting each "{name}" placeholder in the template
+// with the percent-encoded value of params[name]. This will be joined with one of the BaseURLs set on the client.
+// An error is returned if a placeholder has no corresponding param, if a param does not match any placeholder, or
+// if a param value is empty, "." or "..", which would change the path segments of the request.
+// Example:
+//
+//	resp, err := client.Do(..., WithPathParams("/users/{userId}/items/{itemId}", map[string]string{
+//		"userId": userID,
+//		"itemId": itemID,
+//	}), ...)
+func WithPathParams(template string, params map[string]string) RequestParam {
+	return requestParamFunc(func(b *requestBuilder) error {
+		path, err := expandPathTemplate(template, params)
+		if err != nil {
+			return err
+		}
+		b.path = path
+		return nil
+	})
+}
+
+func expandPathTemplate(template string, params map[string]string) (string, error) {
+	var path strings.Builder
+	used := make(map[string]struct{}, len(params))
+	for remaining := template; remaining != ""; {
+		start := strings.IndexByte(remaining, '{')
+		if start < 0 {
+			path.WriteString(remaining)
+			break
+		}
+		end := strings.IndexByte(remaining[start:], '}')
+		if end < 0 {
+			return "", werror.Error("path template has unterminated placeholder", werror.SafeParam("template", template))
+		}
+		name := remaining[start+1 : start+end]
+		value, ok := params[name]
+		if !ok {
+			return "", werror.Error("path template placeholder has no param",
+				werror.SafeParam("template", template),
+				werror.SafeParam("placeholder", name))
+		}
+		if value == "" || value == "." || value == ".." {
+			// these values are not escaped and would change the path segments of the request.
+			return "", werror.Error("path param value must not be empty, \".\" or \"..\"",
+				werror.SafeParam("template", template),
+				werror.SafeParam("param", name))
+		}
+		used[name] = struct{}{}
+		path.WriteString(remaining[:start])
+		path.WriteString(url.PathEscape(value))
+		remaining = remaining[start+end+1:]
+	}
+	for name := range params {
+		if _, ok := used[name]; !ok {
+			return "", werror.Error("path param does not match a placeholder in path template",
+				werror.SafeParam("template", template),
+				werror.SafeParam("param", name))
+		}
+	}
+	return path.String(), nil
+}
+
+// WithHeader sets a header on a request, replacing any existing values for the key.
+// Request-scoped headers take precedence over headers set by client params like WithSetHeader.
 func WithHeader(key, value string) RequestParam {
 	return requestParamFunc(func(b *requestBuilder) error {
-		b.headers.Set(key, value)
+		b.setHeaders(func(h http.Header) {
+			h.Set(key, value)
+		})
+		return nil
+	})
+}
+
+// WithHeaders sets each of the provided headers on a request, replacing any existing values for the same keys.
+// Request-scoped headers take precedence over headers set by client params like WithSetHeader.
+func WithHeaders(headers http.Header) RequestParam {
+	headers = headers.Clone()
+	return requestParamFunc(func(b *requestBuilder) error {
+		b.setHeaders(func(h http.Header) {
+			for key, values := range headers {
+				h[http.CanonicalHeaderKey(key)] = slices.Clone(values)
+			}
+		})
+		return nil
+	})
+}
+
+// WithRequestAddHeader adds a header value to a request, retaining any existing values for the key.
+func WithRequestAddHeader(key, value string) RequestParam {
+	return requestParamFunc(func(b *requestBuilder) error {
+		// not applied via setHeaders, as adding a value is not idempotent.
+		b.headers.Add(key, value)
 		return nil
 	})
 }
 
-// WithQueryValues sets a header on a request.
+// WithQueryValues adds the provided query parameters to the request URL.
+// Values are appended to any values already set for the same keys, including any query provided by WithPath.
 func WithQueryValues(query url.Values) RequestParam {
 	return requestParamFunc(func(b *requestBuilder) error {
-		b.query = query
+		for key, values := range query {
+			b.query[key] = append(b.query[key], values...)
+		}
+		return nil
+	})
+}
+
+// WithQueryParam adds a query parameter to the request URL.
+// The value is appended to any values already set for the key, including any query provided by WithPath.
+func WithQueryParam(key, value string) RequestParam {
+	return requestParamFunc(func(b *requestBuilder) error {
+		b.query.Add(key, value)
+		return nil
+	})
+}
+
+// WithSetQueryParam sets a query parameter on the request URL, replacing any values
+// previously set for the key by query params. A query provided by WithPath is not modified.
+func WithSetQueryParam(key, value string) RequestParam {
+	return requestParamFunc(func(b *requestBuilder) error {
+		b.query.Set(key, value)
 		return nil
 	})
 }
@@ -115,6 +279,7 @@ func WithRawRequestBody(input io.ReadCloser) RequestParam {
 }
 
 // WithBinaryRequestBody sets the request body to the input without encoding.
+// The Content-Type is set to application/octet-stream unless the input has its own, e.g. RequestBodyJSON.
 // See the documentation and constructors for RequestBody for details.
 func WithBinaryRequestBody(input RequestBody) RequestParam {
 	return requestParamFunc(func(b *requestBuilder) error {
@@ -123,11 +288,19 @@ func WithBinaryRequestBody(input RequestBody) RequestParam {
 		}
 		b.bodyMiddleware.requestInput = input
 		b.bodyMiddleware.requestEncoder = nil
-		b.headers.Set("Content-Type", "application/octet-stream")
+		b.headers.Set("Content-Type", requestBodyContentType(input))
 		return nil
 	})
 }
 
+// WithRequestContentType sets the Content-Type header of the request, e.g. to send JSON with a vendor-specific
+// content type like "application/vnd.foo+json". It takes precedence over the Content-Type set by any request body
+// param (WithRequestBody, WithRawRequestBody, WithBinaryRequestBody, etc.) regardless of the order of the params.
+// It can also be used without a request body for servers which require a Content-Type even on empty requests.
+func WithRequestContentType(contentType string) RequestParam {
+	return WithHeader("Content-Type", contentType)
+}
+
 // WithRawRequestBodyProvider uses the io.ReadCloser provided by
 // getBody as the request body.
 //
@@ -145,7 +318,146 @@ func WithRawRequestBodyProvider(getBody func() io.ReadCloser) RequestParam {
 	})
 }
 
+// WithExpectContinue sets the "Expect: 100-continue" header on the request. The transport will send the request
+// headers and wait for the server's interim response before sending the body, so a server that rejects the request
+// based on its headers does not need to receive the body. This is useful for large uploads, e.g. with RequestBodyStreamOnce.
+//
+// If the server does not respond within the client's ExpectContinueTimeout (see WithExpectContinueTimeout), the body
+// is sent anyway. The header has no effect on requests without a body, and applies to bodies with both a known
+// ContentLength and an unknown ContentLength (sent with chunked transfer encoding).
+func WithExpectContinue() RequestParam {
+	return requestParamFunc(func(b *requestBuilder) error {
+		b.setHeaders(func(h http.Header) {
+			h.Set("Expect", "100-continue")
+		})
+		return nil
+	})
+}
+
+// WithForceContentLength sends the request body with a Content-Length header rather than chunked transfer
+// encoding, for servers which reject chunked requests. Bodies of unknown length, such as RequestBodyStreamOnce or
+// RequestBodyEncoderObjectStream, are read into memory before the request is sent to determine their length,
+// so it must not be used for unbounded streams. It has no effect on bodies compressed by WithRequestCompression,
+// whose compressed length is unknown. It replaces WithForceChunked.
+func WithForceContentLength() RequestParam {
+	return requestParamFunc(func(b *requestBuilder) error {
+		b.bodyMiddleware.forceContentLength = true
+		b.bodyMiddleware.forceChunked = false
+		return nil
+	})
+}
+
+// WithForceChunked sends the request body with chunked transfer encoding even if its length is known, for servers
+// which require chunked requests. It only applies to HTTP/1.1 requests, as HTTP/2 does not use chunked transfer
+// encoding. It replaces WithForceContentLength.
+func WithForceChunked() RequestParam {
+	return requestParamFunc(func(b *requestBuilder) error {
+		b.bodyMiddleware.forceChunked = true
+		b.bodyMiddleware.forceContentLength = false
+		return nil
+	})
+}
+
+// WithEarlyHintsCallback calls fn with the headers of each 103 Early Hints response received before the final
+// response, e.g. to start preloading the resources linked by the Link header while the server prepares the response.
+// fn is called synchronously by the transport, so it should not block, and is called again for each attempt of
+// the request. Other informational responses, such as 100 Continue, are ignored.
+func WithEarlyHintsCallback(fn func(header http.Header)) RequestParam {
+	return requestParamFunc(func(b *requestBuilder) error {
+		if fn == nil {
+			return werror.Error("fn can not be nil")
+		}
+		b.configureCtx = append(b.configureCtx, func(ctx context.Context) context.Context {
+			return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+				Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+					if code == http.StatusEarlyHints {
+						fn(http.Header(header).Clone())
+					}
+					return nil
+				},
+			})
+		})
+		return nil
+	})
+}
+
+// WithRequestPriority sets the priority of the request, which is sent in the Priority header defined by RFC 9218
+// and used to prioritize the request locally when the client is saturated. With a limiter set by WithRateLimiter,
+// RequestPriorityHigh requests are sent without waiting on the limiter, and RequestPriorityLow requests fail with
+// an error wrapping ErrRequestShed instead of waiting if the limiter does not allow them immediately. Requests
+// without a priority are sent as if RequestPriorityNormal, but without the header.
+func WithRequestPriority(priority RequestPriority) RequestParam {
+	return requestParamFunc(func(b *requestBuilder) error {
+		if priority < RequestPriorityHigh || priority > RequestPriorityLow {
+			return werror.Error("httpclient: invalid request priority", werror.SafeParam("priority", int(priority)))
+		}
+		b.priority = priority
+		b.setHeaders(func(h http.Header) {
+			h.Set("Priority", fmt.Sprintf("u=%d", priority.urgency()))
+		})
+		return nil
+	})
+}
+
+// WithRequestContentMD5 sets the Content-MD5 header to the base64-encoded MD5 digest of the request body.
+// Computing the digest requires reading the body before it is sent, so the request body must be replayable,
+// e.g. an encoded object, RequestBodyInMemory, or RequestBodyStreamWithReplay. The request returns an error
+// if the body is a stream which can only be read once.
+func WithRequestContentMD5() RequestParam {
+	return requestParamFunc(func(b *requestBuilder) error {
+		b.bodyMiddleware.requestContentMD5 = true
+		return nil
+	})
+}
+
+// WithVerifyResponseDigest verifies the response body against the digest provided in the response's Content-MD5
+// header or Digest header (with the MD5 or SHA-256 algorithm). If the digest does not match, the request returns
+// a *DigestMismatchError. If the response has neither header, the body is not verified.
+//
+// When used with WithRawResponseBody, the error is instead returned by the response body's Read method upon
+// reaching the end of the body.
+func WithVerifyResponseDigest() RequestParam {
+	return requestParamFunc(func(b *requestBuilder) error {
+		b.bodyMiddleware.verifyResponseDigest = true
+		return nil
+	})
+}
+
+// WithRange sets the Range header to request the bytes from start to end (inclusive) of the resource.
+// If the server responds with the full content instead of a 206 Partial Content response, the request returns
+// an error wrapping ErrRangeIgnored. Use ContentRangeFromResponse to read the Content-Range of the response.
+func WithRange(start, end int64) RequestParam {
+	return requestParamFunc(func(b *requestBuilder) error {
+		if start < 0 || end < start {
+			return werror.Error("httpclient: invalid range", werror.SafeParam("start", start), werror.SafeParam("end", end))
+		}
+		value := fmt.Sprintf("bytes=%d-%d", start, end)
+		b.setHeaders(func(h http.Header) {
+			h.Set("Range", value)
+		})
+		b.bodyMiddleware.requirePartialContent = true
+		return nil
+	})
+}
+
+// WithRangeFrom sets the Range header to request the bytes from start to the end of the resource.
+// See WithRange for details.
+func WithRangeFrom(start int64) RequestParam {
+	return requestParamFunc(func(b *requestBuilder) error {
+		if start < 0 {
+			return werror.Error("httpclient: invalid range", werror.SafeParam("start", start))
+		}
+		value := fmt.Sprintf("bytes=%d-", start)
+		b.setHeaders(func(h http.Header) {
+			h.Set("Range", value)
+		})
+		b.bodyMiddleware.requirePartialContent = true
+		return nil
+	})
+}
+
 // WithJSONRequest sets the request body to the input marshaled using the JSON codec.
+// Use WithRequestBody to encode the input with any other codecs.Encoder.
 func WithJSONRequest(input interface{}) RequestParam {
 	return WithRequestBody(input, codecs.JSON)
 }
@@ -162,6 +474,7 @@ func WithJSONRequest(input interface{}) RequestParam {
 // In the case of an empty response, output will be unmodified (left nil).
 func WithResponseBody(output interface{}, decoder codecs.Decoder) RequestParam {
 	return requestParamFunc(func(b *requestBuilder) error {
+		b.bodyMiddleware.resetResponseMode()
 		b.bodyMiddleware.responseOutput = output
 		b.bodyMiddleware.responseDecoder = decoder
 		b.headers.Set("Accept", decoder.Accept())
@@ -182,16 +495,351 @@ func WithResponseBody(output interface{}, decoder codecs.Decoder) RequestParam {
 // In the case of an empty response, output will be unmodified (left nil).
 func WithRawResponseBody() RequestParam {
 	return requestParamFunc(func(b *requestBuilder) error {
+		b.bodyMiddleware.resetResponseMode()
 		b.bodyMiddleware.rawOutput = true
-		b.bodyMiddleware.responseOutput = nil
-		b.bodyMiddleware.responseDecoder = nil
 		b.headers.Set("Accept", "application/octet-stream")
 		return nil
 	})
 }
 
+// WithDiscardResponseBody discards the body of a successful response without decoding it, for requests whose
+// caller only needs the status code and headers. Instead of reading the body to the end, as is done for other
+// responses, at most 4KiB of it are read before it is closed.
+//
+// An HTTP/1.x connection is only reused if the body of its response was read to the end, so discarding a larger
+// body closes the connection and a new one has to be established for a later request. Draining a body is
+// therefore still worthwhile if it is small or if establishing connections is expensive, e.g. for TLS, while
+// discarding saves bandwidth and time for large bodies. HTTP/2 connections are reused regardless.
+func WithDiscardResponseBody() RequestParam {
+	return requestParamFunc(func(b *requestBuilder) error {
+		b.bodyMiddleware.resetResponseMode()
+		b.bodyMiddleware.discardResponseBody = true
+		return nil
+	})
+}
+
+// WithRawResponseBodyOnError behaves like WithRawResponseBody, but also returns responses with non-2xx status codes
+// with their body intact instead of converting them to errors, leaving their interpretation to the caller.
+// Neither the request nor the client ErrorDecoder is invoked, so such responses are not retried either.
+// Example:
+//
+//	resp, err := client.Do(..., WithRawResponseBodyOnError(), ...)
+//	if err != nil {
+//		return err
+//	}
+//	defer resp.Body.Close()
+//	if resp.StatusCode != http.StatusOK {
+//		return decodeMyError(resp.Body)
+//	}
+func WithRawResponseBodyOnError() RequestParam {
+	return requestParamFunc(func(b *requestBuilder) error {
+		if err := WithRawResponseBody().apply(b); err != nil {
+			return err
+		}
+		b.bodyMiddleware.rawOutputOnError = true
+		return nil
+	})
+}
+
+// WithRawResponseBodyOnErrorStatus behaves like WithRawResponseBody, but also returns responses with non-2xx status
+// codes for which handles returns true with their body open instead of converting them to errors, e.g. to stream
+// large error diagnostics. Responses with other non-2xx status codes are handled by the error decoders and retried
+// as usual, and their bodies are drained and closed by the client.
+//
+// The caller takes full responsibility for closing the body of every response returned without an error, whatever
+// its status code. A body which is not closed leaks the connection, and the goroutines and memory associated with it,
+// and the connection can not be reused until the body is read to the end and closed. Example:
+//
+//	resp, err := client.Do(..., WithRawResponseBodyOnErrorStatus(func(statusCode int) bool {
+//		return statusCode == http.StatusInternalServerError
+//	}), ...)
+//	if err != nil {
+//		return err
+//	}
+//	defer resp.Body.Close()
+//	if resp.StatusCode == http.StatusInternalServerError {
+//		return streamDiagnostics(resp.Body)
+//	}
+func WithRawResponseBodyOnErrorStatus(handles func(statusCode int) bool) RequestParam {
+	return requestParamFunc(func(b *requestBuilder) error {
+		if handles == nil {
+			return werror.Error("handles can not be nil")
+		}
+		if err := WithRawResponseBody().apply(b); err != nil {
+			return err
+		}
+		b.bodyMiddleware.rawOutputOnErrorStatus = handles
+		return nil
+	})
+}
+
+// WithResponseSpillToFile behaves like WithRawResponseBody, but reads the body of a successful response to the end
+// before Do returns so that it can be read again: resp.Body implements io.ReadSeekCloser. Bodies of at most
+// threshold bytes are kept in memory, while larger bodies are written to a temporary file in os.TempDir. The caller
+// must close resp.Body to remove the file; it is otherwise only removed once the body is garbage collected.
+// Example:
+//
+//	resp, err := client.Do(..., WithResponseSpillToFile(64<<20), ...)
+//	defer resp.Body.Close()
+//	body := resp.Body.(io.ReadSeekCloser)
+func WithResponseSpillToFile(threshold int64) RequestParam {
+	return requestParamFunc(func(b *requestBuilder) error {
+		if threshold < 0 {
+			return werror.Error("httpclient: response spill threshold must not be negative",
+				werror.SafeParam("threshold", threshold))
+		}
+		if err := WithRawResponseBody().apply(b); err != nil {
+			return err
+		}
+		b.bodyMiddleware.spillResponseBody = true
+		b.bodyMiddleware.spillThreshold = threshold
+		return nil
+	})
+}
+
+// WithEventStreamHandler parses the response body as a server-sent event stream ("text/event-stream"), calling
+// handler for each event until the end of the stream is reached or the request context is done. The response body
+// is fully read and closed by the time Do returns.
+//
+// Responses handled by the error decoder are returned as errors without invoking the handler. If the handler returns
+// an error, the request returns an *EventHandlerError wrapping it; errors reading the stream are returned unwrapped.
+// Once the stream has started being read, the request is not retried.
+func WithEventStreamHandler(handler EventStreamHandler) RequestParam {
+	return requestParamFunc(func(b *requestBuilder) error {
+		if handler == nil {
+			return werror.Error("handler can not be nil")
+		}
+		b.bodyMiddleware.resetResponseMode()
+		b.bodyMiddleware.eventStreamHandler = handler
+		b.headers.Set("Accept", eventStreamContentType)
+		return nil
+	})
+}
+
+// WithJSONArrayResponseHandler decodes a JSON array response body one element at a time, so arbitrarily large
+// arrays can be processed with bounded memory. Each element is decoded into elem, which must be a non-nil pointer
+// and is reset to its zero value before each element, and then fn is called. A JSON null or empty body is treated
+// as an empty array.
+//
+// Responses handled by the error decoder are returned as errors without invoking fn. If fn returns an error,
+// no further elements are decoded and the request returns that error. Once the array has started being read,
+// the request is not retried.
+func WithJSONArrayResponseHandler(elem any, fn func() error) RequestParam {
+	return requestParamFunc(func(b *requestBuilder) error {
+		if fn == nil {
+			return werror.Error("fn can not be nil")
+		}
+		if v := reflect.ValueOf(elem); v.Kind() != reflect.Pointer || v.IsNil() {
+			return werror.Error("elem must be a non-nil pointer", werror.SafeParam("elemType", fmt.Sprintf("%T", elem)))
+		}
+		b.bodyMiddleware.resetResponseMode()
+		b.bodyMiddleware.jsonArrayHandler = &jsonArrayHandler{elem: elem, fn: fn}
+		b.headers.Set("Accept", codecs.JSON.Accept())
+		return nil
+	})
+}
+
+// WithMultipartResponseHandler parses a multipart response body, such as "multipart/mixed", using the boundary of
+// its Content-Type, calling handler for each part as it is read so that the response is never buffered in full.
+// The part's headers, including its own Content-Type, are available from part.Header; a nested multipart part can
+// be parsed with multipart.NewReader and the boundary of its Content-Type. handler does not need to read the whole
+// part. The response body is fully read and closed by the time Do returns.
+//
+// Responses handled by the error decoder are returned as errors without invoking handler. If handler returns an
+// error, no further parts are read and the request returns that error. Once the body has started being read,
+// the request is not retried.
+func WithMultipartResponseHandler(handler func(part *multipart.Part) error) RequestParam {
+	return requestParamFunc(func(b *requestBuilder) error {
+		if handler == nil {
+			return werror.Error("handler can not be nil")
+		}
+		b.bodyMiddleware.resetResponseMode()
+		b.bodyMiddleware.multipartHandler = handler
+		b.headers.Set("Accept", multipartMixedContentType)
+		return nil
+	})
+}
+
+// WithResponseUnmarshalFunc calls fn with a successful response to decode its body in a way no codec supports.
+// Unlike WithRawResponseBody, the response body is drained and closed by the time Do returns, so fn does not need
+// to read the whole body or close it. Responses handled by the error decoder are returned as errors without
+// invoking fn.
+//
+// If fn returns an error, the request returns that error. Once fn has been called, the request is not retried.
+func WithResponseUnmarshalFunc(fn func(resp *http.Response) error) RequestParam {
+	return requestParamFunc(func(b *requestBuilder) error {
+		if fn == nil {
+			return werror.Error("fn can not be nil")
+		}
+		b.bodyMiddleware.resetResponseMode()
+		b.bodyMiddleware.responseUnmarshalFunc = fn
+		return nil
+	})
+}
+
+// WithResponseForStatus decodes the body of responses with the given status into output using decoder, e.g. to decode
+// a structured 422 error body. status is either a status code, e.g. 422, or a status class from 1 to 5, e.g. 4 for
+// all 4xx responses. A status code takes precedence over its class, and the body params, e.g. WithJSONResponse,
+// still apply to other statuses. Matching responses are not considered errors by the error decoders, so the request
+// returns the response and a nil error, and they are not retried. The param can be repeated for different statuses.
+func WithResponseForStatus(status int, output interface{}, decoder codecs.Decoder) RequestParam {
+	return requestParamFunc(func(b *requestBuilder) error {
+		if !(status >= 1 && status <= 5) && !(status >= 100 && status <= 599) {
+			return werror.Error("httpclient: status must be a status code or a status class from 1 to 5", werror.SafeParam("status", status))
+		}
+		if output == nil || decoder == nil {
+			return werror.Error("output and decoder can not be nil")
+		}
+		if b.bodyMiddleware.statusResponseOutputs == nil {
+			b.bodyMiddleware.statusResponseOutputs = make(map[int]statusResponseOutput)
+		}
+		b.bodyMiddleware.statusResponseOutputs[status] = statusResponseOutput{output: output, decoder: decoder}
+		return nil
+	})
+}
+
+// WithResponseHeaderCallback calls fn with a successful response after its status and headers are received but
+// before its body is read, decoded or drained, e.g. to inspect the Content-Disposition or Content-Length header of
+// a download. fn must not read or close the response body. Responses handled by the error decoder are returned as
+// errors without invoking fn.
+//
+// If fn returns an error, the response body is closed without being read and the request returns that error
+// without being retried.
+func WithResponseHeaderCallback(fn func(resp *http.Response) error) RequestParam {
+	return requestParamFunc(func(b *requestBuilder) error {
+		if fn == nil {
+			return werror.Error("fn can not be nil")
+		}
+		b.bodyMiddleware.responseHeaderCallback = fn
+		return nil
+	})
+}
+
+// WithResponseBodyTransformer sets fn to wrap the body of a successful response before it is read, e.g. to strip the
+// ")]}'" prefix of XSSI-protected JSON before it is decoded. It applies to every way the body is read, including
+// WithJSONResponse and WithRawResponseBody, and to bodies decompressed by WithAcceptGzip, which are transformed after
+// they are decompressed. The response body is still closed when the request completes, or by the caller of a raw
+// response. Responses handled by the error decoder are returned as errors without being transformed.
+func WithResponseBodyTransformer(fn func(body io.Reader) io.Reader) RequestParam {
+	return requestParamFunc(func(b *requestBuilder) error {
+		if fn == nil {
+			return werror.Error("fn can not be nil")
+		}
+		b.bodyMiddleware.responseBodyTransformer = fn
+		return nil
+	})
+}
+
+// WithResponseTrailerCallback calls fn with the trailers of a successful response, e.g. a status sent after a
+// streamed body. Trailers are only populated once the body has been read to the end, so the remainder of the body
+// is read after it is decoded or handled and before fn is called, even if WithDiscardResponseBody is set.
+// fn is not called for responses returned with WithRawResponseBody, which must be read by the caller.
+func WithResponseTrailerCallback(fn func(trailer http.Header)) RequestParam {
+	return requestParamFunc(func(b *requestBuilder) error {
+		if fn == nil {
+			return werror.Error("fn can not be nil")
+		}
+		b.bodyMiddleware.responseTrailerCallback = fn
+		return nil
+	})
+}
+
+// WithErrorSafeParam adds a safe param with the provided key and value to the error returned by Do, if any, e.g.
+// the name of the endpoint or the ID of the requested resource, so that it is included when the error is logged.
+// The error is otherwise unchanged, so errors.Is, errors.As and StatusCodeFromError behave as without the param.
+func WithErrorSafeParam(key string, value interface{}) RequestParam {
+	return requestParamFunc(func(b *requestBuilder) error {
+		b.errorParams = append(b.errorParams, werror.SafeParam(key, value))
+		return nil
+	})
+}
+
+// WithErrorUnsafeParam adds an unsafe param with the provided key and value to the error returned by Do, if any.
+// See WithErrorSafeParam.
+func WithErrorUnsafeParam(key string, value interface{}) RequestParam {
+	return requestParamFunc(func(b *requestBuilder) error {
+		b.errorParams = append(b.errorParams, werror.UnsafeParam(key, value))
+		return nil
+	})
+}
+
+// WithHostHeader sets the Host header of the request, i.e. req.Host, to host while the connection is still made to
+// the host of the request URL, e.g. to select a virtual host behind a proxy. It takes precedence over
+// WithOverrideRequestHost. Setting "Host" with WithHeader has no effect, because net/http ignores that header.
+func WithHostHeader(host string) RequestParam {
+	return requestParamFunc(func(b *requestBuilder) error {
+		if host == "" {
+			return werror.Error("host can not be empty")
+		}
+		b.requestMutators = append(b.requestMutators, func(req *http.Request) error {
+			req.Host = host
+			return nil
+		})
+		return nil
+	})
+}
+
+// WithRequestMutator calls mutate with the request of each attempt after its URL, headers and body are set and the
+// middlewares provided by WithMiddleware have run, e.g. to set a header computed from the final URL. Request signing
+// (see WithRequestSigner) happens afterwards, so that the signature covers the changes of mutate, as do the headers
+// set by the HTTP client's own middlewares, such as trace headers and cookies.
+// Mutators are called in the order they are provided. If mutate returns an error, the request fails with that
+// error without being sent or retried.
+func WithRequestMutator(mutate func(req *http.Request) error) RequestParam {
+	return requestParamFunc(func(b *requestBuilder) error {
+		if mutate == nil {
+			return werror.Error("mutate can not be nil")
+		}
+		b.requestMutators = append(b.requestMutators, mutate)
+		return nil
+	})
+}
+
+// WithResponseStatusValidator replaces the status checks of the client-scoped and request-scoped error decoders
+// with validator, which decides whether a response is successful. It is called with every response before its body
+// is read or decoded. If validator returns nil, the response is handled like any other successful response, e.g. its
+// body is decoded by WithJSONResponse. If it returns an error, the response body is closed and the request returns
+// that error without being retried.
+//
+// validator may read the response body, e.g. to inspect a status field, but must then replace resp.Body with a
+// reader of the same content for the body to be decoded.
+func WithResponseStatusValidator(validator func(resp *http.Response) error) RequestParam {
+	return requestParamFunc(func(b *requestBuilder) error {
+		if validator == nil {
+			return werror.Error("validator can not be nil")
+		}
+		b.bodyMiddleware.responseStatusValidator = validator
+		return nil
+	})
+}
+
+// WithPooledResponseBody decodes the response body using decoder into a value taken from pool and sets output to
+// it, so that hot paths decoding large responses can recycle the values rather than allocating one per request.
+// The caller owns the value once the request returns and signals that it is done with it by returning it to the
+// pool with pool.Put, after which neither the value nor anything it references may be used.
+// Example:
+//
+//	var output *api.LargeResponse
+//	_, err := client.Do(..., WithPooledResponseBody(&output, pool, codecs.JSON), ...)
+//	if err != nil {
+//		return err
+//	}
+//	defer pool.Put(output)
+//
+// output is left unmodified if the response is empty or the request fails, in which case the value taken from the
+// pool, if any, is returned to it.
+func WithPooledResponseBody[T any](output **T, pool *ResponsePool[T], decoder codecs.Decoder) RequestParam {
+	if output == nil || pool == nil || decoder == nil {
+		return requestParamFunc(func(*requestBuilder) error {
+			return werror.Error("output, pool and decoder can not be nil")
+		})
+	}
+	return WithResponseBody(output, pooledDecoder[T]{decoder: decoder, pool: pool, output: output})
+}
+
 // WithJSONResponse unmarshals the response body using the JSON codec.
 // The request will return an error if decoding fails.
+// Use WithResponseBody to decode the response with any other codecs.Decoder.
 func WithJSONResponse(output interface{}) RequestParam {
 	return WithResponseBody(output, codecs.JSON)
 }
@@ -218,12 +866,38 @@ func WithSnappyCompressedRequest(input interface{}, codec codecs.Codec) RequestP
 	})
 }
 
+// WithForceRequestCompression compresses the request body using the compression configured by WithRequestCompression
+// even if its size is unknown or below the configured minimum, or its Content-Type is already compressed.
+// It has no effect if the client does not configure request compression.
+func WithForceRequestCompression() RequestParam {
+	return requestParamFunc(func(b *requestBuilder) error {
+		b.forceRequestCompression = true
+		return nil
+	})
+}
+
+// WithAcceptGzip sets the Accept-Encoding header of the request to gzip and decompresses gzip-encoded responses,
+// including error responses, before they are decoded. The body of a response returned by WithRawResponseBody is
+// also decompressed, and its Content-Encoding and Content-Length headers are removed.
+//
+// The transport only requests and decompresses gzip-encoded responses itself if the request does not set the
+// Accept-Encoding header and is not sent through a custom transport.
+func WithAcceptGzip() RequestParam {
+	return requestParamFunc(func(b *requestBuilder) error {
+		b.acceptGzip = true
+		b.setHeaders(func(h http.Header) {
+			h.Set("Accept-Encoding", "gzip")
+		})
+		return nil
+	})
+}
+
 // WithRequestErrorDecoder sets an ErrorDecoder to use for this request only. It will take precedence over any
 // ErrorDecoder set on the client. If this request-scoped ErrorDecoder does not handle the response, the client-scoped
 // ErrorDecoder will be consulted in the usual way.
 func WithRequestErrorDecoder(errorDecoder ErrorDecoder) RequestParam {
 	return requestParamFunc(func(b *requestBuilder) error {
-		b.errorDecoderMiddleware = errorDecoderMiddleware{errorDecoder: errorDecoder}
+		b.errorDecoderMiddleware = errorDecoderMiddleware{errorDecoder: errorDecoder, drainLimit: b.errorBodyDrainLimit}
 		return nil
 	})
 }
@@ -232,12 +906,19 @@ func WithRequestErrorDecoder(errorDecoder ErrorDecoder) RequestParam {
 // username and password for this request only and takes precedence over any client-scoped authorization.
 func WithRequestBasicAuth(username, password string) RequestParam {
 	return requestParamFunc(func(b *requestBuilder) error {
-		setBasicAuth(b.headers, username, password)
+		b.setHeaders(func(h http.Header) {
+			setBasicAuth(h, username, password)
+		})
 		return nil
 	})
 }
 
-// WithRequestTimeout uses the provided value instead of the client's configured timeout.
+// WithRequestTimeout bounds the request, including all retries and the backoff between them, by the provided
+// timeout. The timeout is used instead of the client's configured timeout, which otherwise bounds each attempt.
+// If the timeout expires, the returned error is a *RequestTimeoutError, which wraps context.DeadlineExceeded.
+//
+// When used with WithRawResponseBody, the timeout also bounds reading the response body, and its resources
+// are released when the body is closed.
 func WithRequestTimeout(timeout time.Duration) RequestParam {
 	return requestParamFunc(func(b *requestBuilder) error {
 		b.requestTimeout = &timeout
@@ -245,12 +926,39 @@ func WithRequestTimeout(timeout time.Duration) RequestParam {
 	})
 }
 
+// WithPerAttemptTimeout bounds each attempt of the request, including reading the response body, by the provided
+// timeout, so a single slow attempt does not consume the whole request timeout (see WithRequestTimeout).
+// An attempt which times out fails with a *ConnectionError and is retried like other connection failures, so by
+// default only idempotent requests are retried (see IsRetryableConnectionError). If the last attempt timed out,
+// the returned error is an *AttemptTimeoutError, while a *RequestTimeoutError indicates that the overall
+// request timeout expired.
+func WithPerAttemptTimeout(timeout time.Duration) RequestParam {
+	return requestParamFunc(func(b *requestBuilder) error {
+		if timeout <= 0 {
+			return werror.Error("httpclient: per-attempt timeout must be positive", werror.SafeParam("timeout", timeout.String()))
+		}
+		b.perAttemptTimeout = &timeout
+		return nil
+	})
+}
+
+// WithDisableRetry makes a single attempt of the request regardless of the client's or endpoint's retry
+// configuration, e.g. for non-idempotent operations. As for request bodies which can not be replayed, such as
+// WithRawRequestBody, the request is also not relocated by 307 or 308 responses.
+func WithDisableRetry() RequestParam {
+	return requestParamFunc(func(b *requestBuilder) error {
+		b.disableRetry = true
+		return nil
+	})
+}
+
 func WithRequestConjureErrorDecoder(ced errors.ConjureErrorDecoder) RequestParam {
 	return requestParamFunc(func(b *requestBuilder) error {
 		b.errorDecoderMiddleware = errorDecoderMiddleware{
 			errorDecoder: restErrorDecoder{
 				conjureErrorDecoder: ced,
 			},
+			drainLimit: b.errorBodyDrainLimit,
 		}
 		return nil
 	})
@@ -0,0 +1,102 @@
+// Copyright (c) 2024 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandPathTemplate(t *testing.T) {
+	for _, test := range []struct {
+		Name     string
+		Template string
+		Params   map[string]string
+		Expected string
+		Err      string
+	}{
+		{
+			Name:     "no placeholders",
+			Template: "/users",
+			Expected: "/users",
+		},
+		{
+			Name:     "multiple placeholders",
+			Template: "/users/{userId}/items/{itemId}",
+			Params:   map[string]string{"userId": "u1", "itemId": "i1"},
+			Expected: "/users/u1/items/i1",
+		},
+		{
+			Name:     "values are escaped",
+			Template: "/users/{userId}",
+			Params:   map[string]string{"userId": "../a b/%2F?#"},
+			Expected: "/users/..%2Fa%20b%2F%252F%3F%23",
+		},
+		{
+			Name:     "missing param",
+			Template: "/users/{userId}/items/{itemId}",
+			Params:   map[string]string{"userId": "u1"},
+			Err:      "path template placeholder has no param",
+		},
+		{
+			Name:     "extra param",
+			Template: "/users/{userId}",
+			Params:   map[string]string{"userId": "u1", "itemId": "i1"},
+			Err:      "path param does not match a placeholder in path template",
+		},
+		{
+			Name:     "empty value",
+			Template: "/users/{userId}/items",
+			Params:   map[string]string{"userId": ""},
+			Err:      `path param value must not be empty, "." or ".."`,
+		},
+		{
+			Name:     "dot value",
+			Template: "/users/{userId}/items",
+			Params:   map[string]string{"userId": "."},
+			Err:      `path param value must not be empty, "." or ".."`,
+		},
+		{
+			Name:     "dot dot value",
+			Template: "/users/{userId}/items",
+			Params:   map[string]string{"userId": ".."},
+			Err:      `path param value must not be empty, "." or ".."`,
+		},
+		{
+			Name:     "dots within value",
+			Template: "/users/{userId}",
+			Params:   map[string]string{"userId": "..."},
+			Expected: "/users/...",
+		},
+		{
+			Name:     "unterminated placeholder",
+			Template: "/users/{userId",
+			Params:   map[string]string{"userId": "u1"},
+			Err:      "path template has unterminated placeholder",
+		},
+	} {
+		t.Run(test.Name, func(t *testing.T) {
+			path, err := expandPathTemplate(test.Template, test.Params)
+			if test.Err != "" {
+				require.EqualError(t, err, test.Err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.Expected, path)
+		})
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2024 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient
+
+import (
+	"context"
+	"fmt"
+
+	werror "github.com/palantir/witchcraft-go-error"
+)
+
+// RequestPriority is the priority of a request set by WithRequestPriority.
+type RequestPriority int
+
+const (
+	// RequestPriorityHigh requests are not throttled by the client's RateLimiter.
+	RequestPriorityHigh RequestPriority = iota + 1
+	// RequestPriorityNormal requests wait on the client's RateLimiter, like requests without a priority.
+	RequestPriorityNormal
+	// RequestPriorityLow requests fail fast with ErrRequestShed when the client's RateLimiter is saturated.
+	RequestPriorityLow
+)
+
+var (
+	// ErrRequestShed is returned for a request with RequestPriorityLow which was not sent because the client's
+	// RateLimiter did not allow it immediately.
+	ErrRequestShed = fmt.Errorf("httpclient: low priority request shed by rate limiter")
+)
+
+// urgency returns the urgency parameter of the Priority header defined by RFC 9218, where lower values are more
+// urgent and 3 is the default.
+func (p RequestPriority) urgency() int {
+	switch p {
+	case RequestPriorityHigh:
+		return 1
+	case RequestPriorityLow:
+		return 5
+	default:
+		return 3
+	}
+}
+
+// rateLimiterAllower is implemented by rate limiters which can report whether a request may be sent without waiting,
+// such as *rate.Limiter.
+type rateLimiterAllower interface {
+	Allow() bool
+}
+
+// waitRateLimiter waits on limiter, if any, unless priority allows the request to proceed or fail without waiting.
+func waitRateLimiter(ctx context.Context, limiter RateLimiter, priority RequestPriority) error {
+	if limiter == nil || priority == RequestPriorityHigh {
+		return nil
+	}
+	if allower, ok := limiter.(rateLimiterAllower); ok && priority == RequestPriorityLow {
+		if !allower.Allow() {
+			return werror.WrapWithContextParams(ctx, ErrRequestShed, "")
+		}
+		return nil
+	}
+	if err := limiter.Wait(ctx); err != nil {
+		return werror.WrapWithContextParams(ctx, err, "httpclient: failed to wait for rate limiter")
+	}
+	return nil
+}
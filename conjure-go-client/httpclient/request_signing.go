@@ -0,0 +1,82 @@
+// Copyright (c) 2024 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient
+
+import (
+	"io"
+	"net/http"
+
+	werror "github.com/palantir/witchcraft-go-error"
+)
+
+// RequestSigner signs requests, e.g. by setting a header to an HMAC of the request's method, path, headers and
+// body hash. See WithRequestSigner.
+type RequestSigner interface {
+	// SignRequest adds a signature to req. body is the content of the request body as it will be sent, or nil if
+	// the request has no body. SignRequest must not read or replace req.Body.
+	SignRequest(req *http.Request, body []byte) error
+}
+
+// RequestSignerFunc is a function which implements RequestSigner.
+type RequestSignerFunc func(req *http.Request, body []byte) error
+
+func (f RequestSignerFunc) SignRequest(req *http.Request, body []byte) error {
+	return f(req, body)
+}
+
+type requestSigningMiddleware struct {
+	signer RequestSigner
+}
+
+func newRequestSigningMiddleware(signer RequestSigner) Middleware {
+	if signer == nil {
+		return nil
+	}
+	return requestSigningMiddleware{signer: signer}
+}
+
+func (m requestSigningMiddleware) RoundTrip(req *http.Request, next http.RoundTripper) (*http.Response, error) {
+	body, err := requestSigningBody(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.signer.SignRequest(req, body); err != nil {
+		return nil, werror.WrapWithContextParams(req.Context(), err, "httpclient: failed to sign request")
+	}
+	return next.RoundTrip(req)
+}
+
+// requestSigningBody returns the content of the request body, if there is one.
+// The body must be replayable (i.e. GetBody is set) so that it can be read without consuming req.Body.
+func requestSigningBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	if req.GetBody == nil {
+		return nil, werror.ErrorWithContextParams(req.Context(), "httpclient: request signing requires a replayable request body")
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, werror.WrapWithContextParams(req.Context(), err, "failed to get request body to sign request")
+	}
+	defer func() {
+		_ = body.Close()
+	}()
+	content, err := io.ReadAll(body)
+	if err != nil {
+		return nil, werror.WrapWithContextParams(req.Context(), err, "failed to read request body to sign request")
+	}
+	return content, nil
+}
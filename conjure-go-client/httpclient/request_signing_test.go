@@ -0,0 +1,180 @@
+// Copyright (c) 2024 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/palantir/conjure-go-runtime/v2/conjure-go-client/httpclient"
+	"github.com/palantir/conjure-go-runtime/v2/conjure-go-contract/codecs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testSignature computes an HMAC-SHA256 over the method, path, selected headers and body hash.
+func testSignature(key []byte, method, path string, header http.Header, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, key)
+	_, _ = fmt.Fprintf(mac, "%s\n%s\n%s\n%s\n%s\n%s",
+		method, path, header.Get("Content-Type"), header.Get("Content-Encoding"), header.Get("X-Date"), hex.EncodeToString(bodyHash[:]))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestRequestSigner(t *testing.T) {
+	key := []byte("secret")
+	var attempts int
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		attempts++
+		body, err := io.ReadAll(req.Body)
+		require.NoError(t, err)
+		if req.Header.Get("X-Signature") != testSignature(key, req.Method, req.URL.Path, req.Header, body) {
+			rw.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if req.URL.Path == "/unavailable" && attempts == 1 {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	var signed int
+	signer := httpclient.RequestSignerFunc(func(req *http.Request, body []byte) error {
+		signed++
+		req.Header.Set("X-Date", fmt.Sprintf("attempt-%d", signed))
+		req.Header.Set("X-Signature", testSignature(key, req.Method, req.URL.Path, req.Header, body))
+		return nil
+	})
+	client, err := httpclient.NewClient(
+		httpclient.WithBaseURLs([]string{server.URL}),
+		httpclient.WithRequestSigner(signer),
+		httpclient.WithRequestCompression(httpclient.RequestCompressionGZIP, 0),
+	)
+	require.NoError(t, err)
+
+	for _, test := range []struct {
+		Name     string
+		Params   []httpclient.RequestParam
+		Err      string
+		Attempts int
+		Signed   int
+	}{
+		{
+			Name:     "no body",
+			Params:   []httpclient.RequestParam{httpclient.WithRequestMethod(http.MethodGet), httpclient.WithPath("/get")},
+			Attempts: 1,
+			Signed:   1,
+		},
+		{
+			Name: "encoded and compressed body",
+			Params: []httpclient.RequestParam{
+				httpclient.WithRequestMethod(http.MethodPost),
+				httpclient.WithPath("/post"),
+				httpclient.WithJSONRequest(map[string]string{"key": strings.Repeat("value", 100)}),
+			},
+			Attempts: 1,
+			Signed:   1,
+		},
+		{
+			Name: "each attempt is signed",
+			Params: []httpclient.RequestParam{
+				httpclient.WithRequestMethod(http.MethodPut),
+				httpclient.WithPath("/unavailable"),
+				httpclient.WithRequestBody("value", codecs.Plain),
+			},
+			Attempts: 2,
+			Signed:   2,
+		},
+		{
+			Name: "replayable binary body",
+			Params: []httpclient.RequestParam{
+				httpclient.WithRequestMethod(http.MethodPost),
+				httpclient.WithPath("/binary"),
+				httpclient.WithBinaryRequestBody(httpclient.RequestBodyInMemory(bytes.NewReader([]byte("binary")))),
+			},
+			Attempts: 1,
+			Signed:   1,
+		},
+		{
+			Name: "stream once body",
+			Params: []httpclient.RequestParam{
+				httpclient.WithRequestMethod(http.MethodPost),
+				httpclient.WithPath("/stream"),
+				httpclient.WithRawRequestBody(io.NopCloser(strings.NewReader("stream"))),
+			},
+			Err:      "httpclient request failed: httpclient: request signing requires a replayable request body",
+			Attempts: 0,
+			Signed:   0,
+		},
+	} {
+		t.Run(test.Name, func(t *testing.T) {
+			attempts, signed = 0, 0
+			_, err := client.Do(context.Background(), test.Params...)
+			if test.Err == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, test.Err)
+			}
+			assert.Equal(t, test.Attempts, attempts)
+			assert.Equal(t, test.Signed, signed)
+		})
+	}
+
+	t.Run("signer error", func(t *testing.T) {
+		client, err := httpclient.NewClient(
+			httpclient.WithBaseURLs([]string{server.URL}),
+			httpclient.WithRequestSigner(httpclient.RequestSignerFunc(func(*http.Request, []byte) error {
+				return fmt.Errorf("key unavailable")
+			})),
+		)
+		require.NoError(t, err)
+		attempts = 0
+		_, err = client.Get(context.Background())
+		require.EqualError(t, err, "httpclient request failed: httpclient: failed to sign request: key unavailable")
+		assert.Equal(t, 0, attempts)
+	})
+
+	t.Run("signature covers headers of client middlewares", func(t *testing.T) {
+		var signedHeader http.Header
+		client, err := httpclient.NewClient(
+			httpclient.WithBaseURLs([]string{server.URL}),
+			httpclient.WithAuthToken("token"),
+			httpclient.WithMiddleware(httpclient.MiddlewareFunc(func(req *http.Request, next http.RoundTripper) (*http.Response, error) {
+				req.Header.Set("X-Client", "value")
+				return next.RoundTrip(req)
+			})),
+			httpclient.WithRequestSigner(httpclient.RequestSignerFunc(func(req *http.Request, body []byte) error {
+				signedHeader = req.Header.Clone()
+				return signer(req, body)
+			})),
+		)
+		require.NoError(t, err)
+		_, err = client.Get(context.Background(), httpclient.WithPath("/get"))
+		require.NoError(t, err)
+		assert.Equal(t, "value", signedHeader.Get("X-Client"))
+		assert.Equal(t, "Bearer token", signedHeader.Get("Authorization"))
+	})
+}
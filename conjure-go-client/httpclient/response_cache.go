@@ -0,0 +1,218 @@
+// Copyright (c) 2024 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient
+
+import (
+	"bytes"
+	"container/list"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// ResponseCache stores responses keyed by request URL and Accept header. See WithResponseCache for details.
+// Implementations must be safe for concurrent use.
+type ResponseCache interface {
+	Get(key string) (CachedResponse, bool)
+	Set(key string, value CachedResponse)
+}
+
+// CachedResponse is a successful response stored in a ResponseCache.
+type CachedResponse struct {
+	ETag       string
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	// VaryHeader holds the values of the request headers named by the Vary header of the response.
+	// The response is only used for requests with the same values.
+	VaryHeader http.Header
+}
+
+// NewInMemoryResponseCache returns a ResponseCache which stores at most maxEntries responses in memory, evicting
+// the least recently used response when it is full.
+func NewInMemoryResponseCache(maxEntries int) ResponseCache {
+	return &inMemoryResponseCache{
+		maxEntries: maxEntries,
+		entries:    list.New(),
+		responses:  make(map[string]*list.Element),
+	}
+}
+
+type inMemoryResponseCache struct {
+	mutex      sync.Mutex
+	maxEntries int
+	// entries holds the *inMemoryResponseCacheEntry values, most recently used first.
+	entries   *list.List
+	responses map[string]*list.Element
+}
+
+type inMemoryResponseCacheEntry struct {
+	key   string
+	value CachedResponse
+}
+
+func (c *inMemoryResponseCache) Get(key string) (CachedResponse, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	elem, ok := c.responses[key]
+	if !ok {
+		return CachedResponse{}, false
+	}
+	c.entries.MoveToFront(elem)
+	return elem.Value.(*inMemoryResponseCacheEntry).value, true
+}
+
+func (c *inMemoryResponseCache) Set(key string, value CachedResponse) {
+	if c.maxEntries <= 0 {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if elem, ok := c.responses[key]; ok {
+		elem.Value.(*inMemoryResponseCacheEntry).value = value
+		c.entries.MoveToFront(elem)
+		return
+	}
+	c.responses[key] = c.entries.PushFront(&inMemoryResponseCacheEntry{key: key, value: value})
+	for c.entries.Len() > c.maxEntries {
+		oldest := c.entries.Back()
+		c.entries.Remove(oldest)
+		delete(c.responses, oldest.Value.(*inMemoryResponseCacheEntry).key)
+	}
+}
+
+// responseCacheMiddleware sends conditional GET requests for cached responses and
+// resolves 304 Not Modified responses to the cached response.
+type responseCacheMiddleware struct {
+	cache        ResponseCache
+	maxBodyBytes int64
+}
+
+func newResponseCacheMiddleware(cache ResponseCache, maxBodyBytes int64) Middleware {
+	if cache == nil {
+		return nil
+	}
+	return responseCacheMiddleware{cache: cache, maxBodyBytes: maxBodyBytes}
+}
+
+func (m responseCacheMiddleware) RoundTrip(req *http.Request, next http.RoundTripper) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return next.RoundTrip(req)
+	}
+	key := responseCacheKey(req)
+	cached, hasCached := m.cache.Get(key)
+	hasCached = hasCached && matchesVaryHeader(req, cached.VaryHeader)
+	if hasCached && req.Header.Get("If-None-Match") == "" {
+		req.Header.Set("If-None-Match", cached.ETag)
+	}
+
+	resp, err := next.RoundTrip(req)
+	if err != nil || resp == nil {
+		return resp, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotModified && hasCached:
+		_ = resp.Body.Close()
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", cached.StatusCode, http.StatusText(cached.StatusCode)),
+			StatusCode:    cached.StatusCode,
+			Proto:         resp.Proto,
+			ProtoMajor:    resp.ProtoMajor,
+			ProtoMinor:    resp.ProtoMinor,
+			Header:        cached.Header.Clone(),
+			Body:          io.NopCloser(bytes.NewReader(cached.Body)),
+			ContentLength: int64(len(cached.Body)),
+			Request:       req,
+		}, nil
+	case resp.StatusCode == http.StatusOK && resp.Header.Get("ETag") != "" && !isNoStore(resp.Header) &&
+		resp.ContentLength <= m.maxBodyBytes:
+		varyHeader, ok := responseVaryHeader(req, resp.Header)
+		if !ok {
+			return resp, nil
+		}
+		// read one byte more than maxBodyBytes to detect a body of unknown length exceeding the limit
+		body, err := io.ReadAll(io.LimitReader(resp.Body, m.maxBodyBytes+1))
+		if err != nil {
+			_ = resp.Body.Close()
+			return nil, err
+		}
+		if int64(len(body)) > m.maxBodyBytes {
+			// the body is too large to cache, so return it as it is streamed.
+			resp.Body = &multiReadCloser{Reader: io.MultiReader(bytes.NewReader(body), resp.Body), closers: []io.Closer{resp.Body}}
+			return resp, nil
+		}
+		_ = resp.Body.Close()
+		m.cache.Set(key, CachedResponse{
+			ETag:       resp.Header.Get("ETag"),
+			StatusCode: resp.StatusCode,
+			Header:     resp.Header.Clone(),
+			Body:       body,
+			VaryHeader: varyHeader,
+		})
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		resp.ContentLength = int64(len(body))
+	}
+	return resp, nil
+}
+
+// responseCacheKey returns the key of the response to req, which is its URL and Accept header.
+func responseCacheKey(req *http.Request) string {
+	return req.URL.String() + "\n" + strings.Join(req.Header.Values("Accept"), ",")
+}
+
+// responseVaryHeader returns the values of the request headers named by the Vary header of the response. It
+// returns false if the response varies by "*", so it can not be cached.
+func responseVaryHeader(req *http.Request, respHeader http.Header) (http.Header, bool) {
+	var varyHeader http.Header
+	for _, value := range respHeader.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			if name == "*" {
+				return nil, false
+			}
+			if varyHeader == nil {
+				varyHeader = make(http.Header)
+			}
+			varyHeader[http.CanonicalHeaderKey(name)] = req.Header.Values(name)
+		}
+	}
+	return varyHeader, true
+}
+
+// matchesVaryHeader returns true if req has the values of varyHeader.
+func matchesVaryHeader(req *http.Request, varyHeader http.Header) bool {
+	for name, values := range varyHeader {
+		if strings.Join(req.Header.Values(name), ",") != strings.Join(values, ",") {
+			return false
+		}
+	}
+	return true
+}
+
+func isNoStore(header http.Header) bool {
+	for _, value := range header.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(directive), "no-store") {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,189 @@
+// Copyright (c) 2024 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/palantir/conjure-go-runtime/v2/conjure-go-client/httpclient"
+	"github.com/palantir/conjure-go-runtime/v2/conjure-go-contract/codecs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponseCache(t *testing.T) {
+	const etag = `"v1"`
+	var notModified int
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/no-store" {
+			rw.Header().Set("Cache-Control", "private, no-store")
+		}
+		if req.Header.Get("If-None-Match") == etag {
+			notModified++
+			rw.WriteHeader(http.StatusNotModified)
+			return
+		}
+		rw.Header().Set("ETag", etag)
+		rw.Header().Set("Content-Type", "application/json")
+		_, _ = rw.Write([]byte(`{"key":"value"}`))
+	}))
+	defer server.Close()
+
+	for _, test := range []struct {
+		Name                string
+		Method              string
+		Path                string
+		ExpectedNotModified int
+	}{
+		{
+			Name:                "GET is cached",
+			Method:              http.MethodGet,
+			Path:                "/",
+			ExpectedNotModified: 2,
+		},
+		{
+			Name:                "no-store is not cached",
+			Method:              http.MethodGet,
+			Path:                "/no-store",
+			ExpectedNotModified: 0,
+		},
+		{
+			Name:                "POST is not cached",
+			Method:              http.MethodPost,
+			Path:                "/",
+			ExpectedNotModified: 0,
+		},
+	} {
+		t.Run(test.Name, func(t *testing.T) {
+			notModified = 0
+			client, err := httpclient.NewClient(
+				httpclient.WithBaseURLs([]string{server.URL}),
+				httpclient.WithResponseCache(httpclient.NewInMemoryResponseCache(10)),
+			)
+			require.NoError(t, err)
+			for i := 0; i < 3; i++ {
+				var out map[string]string
+				_, err := client.Do(context.Background(),
+					httpclient.WithRequestMethod(test.Method),
+					httpclient.WithPath(test.Path),
+					httpclient.WithJSONResponse(&out))
+				require.NoError(t, err)
+				assert.Equal(t, map[string]string{"key": "value"}, out)
+			}
+			assert.Equal(t, test.ExpectedNotModified, notModified)
+		})
+	}
+}
+
+func TestResponseCacheConditions(t *testing.T) {
+	var requests, notModified int
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		requests++
+		accept := req.Header.Get("Accept")
+		etag := `"` + accept + `"`
+		if req.URL.Path == "/vary" {
+			rw.Header().Set("Vary", "X-Tenant")
+			etag = `"` + req.Header.Get("X-Tenant") + `"`
+		}
+		if req.Header.Get("If-None-Match") == etag {
+			notModified++
+			rw.WriteHeader(http.StatusNotModified)
+			return
+		}
+		rw.Header().Set("ETag", etag)
+		rw.Header().Set("Content-Type", accept)
+		if req.URL.Path == "/large" {
+			_, _ = rw.Write([]byte(strings.Repeat("a", 100)))
+			return
+		}
+		_, _ = rw.Write([]byte(accept))
+	}))
+	defer server.Close()
+
+	newClient := func(t *testing.T, cache httpclient.ResponseCache) httpclient.Client {
+		client, err := httpclient.NewClient(
+			httpclient.WithBaseURLs([]string{server.URL}),
+			httpclient.WithResponseCache(cache),
+			httpclient.WithResponseCacheMaxBodyBytes(50),
+		)
+		require.NoError(t, err)
+		return client
+	}
+	get := func(t *testing.T, client httpclient.Client, params ...httpclient.RequestParam) (*http.Response, string) {
+		var out string
+		resp, err := client.Get(context.Background(), append([]httpclient.RequestParam{httpclient.WithResponseBody(&out, codecs.Plain)}, params...)...)
+		require.NoError(t, err)
+		return resp, out
+	}
+	reset := func() {
+		requests, notModified = 0, 0
+	}
+
+	t.Run("keyed by Accept header", func(t *testing.T) {
+		reset()
+		client := newClient(t, httpclient.NewInMemoryResponseCache(10))
+		for i := 0; i < 2; i++ {
+			for _, accept := range []string{"text/plain", "application/json"} {
+				resp, out := get(t, client, httpclient.WithHeader("Accept", accept))
+				assert.Equal(t, accept, out)
+				assert.Equal(t, "200 OK", resp.Status)
+			}
+		}
+		assert.Equal(t, 2, notModified)
+	})
+	t.Run("matches Vary header", func(t *testing.T) {
+		reset()
+		client := newClient(t, httpclient.NewInMemoryResponseCache(10))
+		for _, tenant := range []string{"a", "a", "b", "b"} {
+			get(t, client, httpclient.WithPath("/vary"), httpclient.WithHeader("X-Tenant", tenant))
+		}
+		assert.Equal(t, 2, notModified)
+	})
+	t.Run("large body is not cached", func(t *testing.T) {
+		reset()
+		client := newClient(t, httpclient.NewInMemoryResponseCache(10))
+		for i := 0; i < 2; i++ {
+			_, out := get(t, client, httpclient.WithPath("/large"))
+			assert.Len(t, out, 100)
+		}
+		assert.Equal(t, 0, notModified)
+	})
+	t.Run("raw response is not cached", func(t *testing.T) {
+		reset()
+		client := newClient(t, httpclient.NewInMemoryResponseCache(10))
+		for i := 0; i < 2; i++ {
+			resp, err := client.Get(context.Background(), httpclient.WithRawResponseBody())
+			require.NoError(t, err)
+			_, err = io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.NoError(t, resp.Body.Close())
+		}
+		assert.Equal(t, 0, notModified)
+	})
+	t.Run("evicts least recently used response", func(t *testing.T) {
+		reset()
+		client := newClient(t, httpclient.NewInMemoryResponseCache(1))
+		for _, accept := range []string{"text/plain", "application/json", "text/plain"} {
+			get(t, client, httpclient.WithHeader("Accept", accept))
+		}
+		assert.Equal(t, 0, notModified)
+		assert.Equal(t, 3, requests)
+	})
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2024 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient_test
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/palantir/conjure-go-runtime/v2/conjure-go-client/httpclient"
+	"github.com/palantir/conjure-go-runtime/v2/conjure-go-contract/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcceptGzip(t *testing.T) {
+	var acceptEncoding string
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		acceptEncoding = req.Header.Get("Accept-Encoding")
+		if acceptEncoding != "gzip" {
+			rw.Header().Set("Content-Type", "application/json")
+			_, _ = rw.Write([]byte(`{"key":"plain"}`))
+			return
+		}
+		rw.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(rw)
+		defer func() {
+			_ = zw.Close()
+		}()
+		if req.URL.Path == "/error" {
+			rw.Header().Set("Content-Type", "application/json")
+			rw.WriteHeader(http.StatusNotFound)
+			_, _ = zw.Write([]byte(`{"errorCode":"NOT_FOUND","errorName":"Default:NotFound","errorInstanceId":"00000000-0000-0000-0000-000000000000"}`))
+			return
+		}
+		rw.Header().Set("Content-Type", "application/json")
+		_, _ = zw.Write([]byte(`{"key":"gzip"}`))
+	}))
+	defer server.Close()
+
+	client, err := httpclient.NewClient(httpclient.WithBaseURLs([]string{server.URL}))
+	require.NoError(t, err)
+
+	t.Run("decodes gzip response", func(t *testing.T) {
+		var output map[string]string
+		_, err := client.Get(context.Background(), httpclient.WithAcceptGzip(), httpclient.WithJSONResponse(&output))
+		require.NoError(t, err)
+		assert.Equal(t, "gzip", acceptEncoding)
+		assert.Equal(t, map[string]string{"key": "gzip"}, output)
+	})
+
+	t.Run("raw response", func(t *testing.T) {
+		resp, err := client.Get(context.Background(), httpclient.WithAcceptGzip(), httpclient.WithRawResponseBody())
+		require.NoError(t, err)
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+		assert.Empty(t, resp.Header.Get("Content-Encoding"))
+		assert.True(t, resp.Uncompressed)
+		content, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, `{"key":"gzip"}`, string(content))
+	})
+
+	t.Run("decodes gzip error response", func(t *testing.T) {
+		_, err := client.Get(context.Background(), httpclient.WithPath("/error"), httpclient.WithAcceptGzip())
+		require.Error(t, err)
+		assert.True(t, errors.IsNotFound(errors.GetConjureError(err)), "expected a not found conjure error, got %v", err)
+	})
+
+	t.Run("uncompressed response", func(t *testing.T) {
+		var output map[string]string
+		_, err := client.Get(context.Background(),
+			httpclient.WithAcceptGzip(),
+			httpclient.WithHeader("Accept-Encoding", "identity"),
+			httpclient.WithJSONResponse(&output))
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"key": "plain"}, output)
+	})
+}
@@ -38,8 +38,11 @@ type ErrorDecoder interface {
 // errorDecoderMiddleware intercepts a round trip's response.
 // If the supplied ErrorDecoder handles the response, we return the error as decoded by ErrorDecoder.
 // In this case, the *http.Response returned will be nil.
+// The body of the response is then drained up to drainLimit bytes and closed, so that the connection can be reused
+// if the ErrorDecoder did not read the body to the end.
 type errorDecoderMiddleware struct {
 	errorDecoder ErrorDecoder
+	drainLimit   int64
 }
 
 func (e errorDecoderMiddleware) RoundTrip(req *http.Request, next http.RoundTripper) (*http.Response, error) {
@@ -49,12 +52,33 @@ func (e errorDecoderMiddleware) RoundTrip(req *http.Request, next http.RoundTrip
 		return nil, err
 	}
 	if e.errorDecoder.Handles(resp) {
-		defer internal.DrainBody(req.Context(), resp)
+		defer internal.DrainBodyLimit(req.Context(), resp, e.drainLimit)
 		return nil, e.errorDecoder.DecodeError(resp)
 	}
 	return resp, nil
 }
 
+// withExemptStatuses returns a copy of m whose error decoder does not handle responses for which exempt returns true.
+// m is returned unchanged if it is not an errorDecoderMiddleware.
+func withExemptStatuses(m Middleware, exempt func(statusCode int) bool) Middleware {
+	edm, ok := m.(errorDecoderMiddleware)
+	if !ok {
+		return m
+	}
+	edm.errorDecoder = exemptStatusErrorDecoder{ErrorDecoder: edm.errorDecoder, exempt: exempt}
+	return edm
+}
+
+// exemptStatusErrorDecoder does not handle responses for which exempt returns true.
+type exemptStatusErrorDecoder struct {
+	ErrorDecoder
+	exempt func(statusCode int) bool
+}
+
+func (d exemptStatusErrorDecoder) Handles(resp *http.Response) bool {
+	return !d.exempt(resp.StatusCode) && d.ErrorDecoder.Handles(resp)
+}
+
 // restErrorDecoder is our default error decoder.
 // It handles responses of status code >= 307. In this case,
 // we create and return a werror with the 'statusCode' parameter
@@ -102,7 +126,7 @@ func (d restErrorDecoder) DecodeError(resp *http.Response) error {
 
 	// If JSON, try to unmarshal as conjure error
 	if isJSON := strings.Contains(resp.Header.Get("Content-Type"), codecs.JSON.ContentType()); !isJSON {
-		return werror.Error(resp.Status, wSafeParams, wUnsafeParams, werror.UnsafeParam("responseBody", string(body)))
+		return werror.Error(resp.Status, wSafeParams, wUnsafeParams, responseBodyParam(body))
 	}
 	var conjureErr errors.Error
 	var jsonErr error
@@ -112,16 +136,34 @@ func (d restErrorDecoder) DecodeError(resp *http.Response) error {
 		conjureErr, jsonErr = errors.UnmarshalError(body)
 	}
 	if jsonErr != nil {
-		return werror.Error(resp.Status, wSafeParams, wUnsafeParams, werror.UnsafeParam("responseBody", string(body)))
+		return werror.Error(resp.Status, wSafeParams, wUnsafeParams, responseBodyParam(body))
 	}
 	return werror.Wrap(conjureErr, "", wSafeParams, wUnsafeParams)
 }
 
+// maxErrorResponseBodyParamBytes is the largest error response body attached to errors as the responseBody param.
+const maxErrorResponseBodyParamBytes = 64 * 1024
+
+// responseBodyParam returns the responseBody param for an error response body, or no params if the body is too large.
+func responseBodyParam(body []byte) werror.Param {
+	if len(body) > maxErrorResponseBodyParamBytes {
+		return werror.UnsafeParams(nil)
+	}
+	return werror.UnsafeParam("responseBody", string(body))
+}
+
 // StatusCodeFromError wraps the internal StatusCodeFromError func. For behavior details, see its docs.
 func StatusCodeFromError(err error) (statusCode int, ok bool) {
 	return internal.StatusCodeFromError(err)
 }
 
+// ResponseBodyFromError returns the body of the error response which caused err, if it was returned by the
+// default error decoder, the body is at most 64KiB, and it was not decoded as a conjure error. For behavior details,
+// see the docs of the internal ResponseBodyFromError func.
+func ResponseBodyFromError(err error) (body string, ok bool) {
+	return internal.ResponseBodyFromError(err)
+}
+
 // LocationFromError wraps the internal LocationFromError func. For behavior details, see its docs.
 func LocationFromError(err error) (location string, ok bool) {
 	return internal.LocationFromError(err)
@@ -15,12 +15,15 @@
 package httpclient_test
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"sync/atomic"
 	"testing"
 
 	"github.com/palantir/conjure-go-runtime/v2/conjure-go-client/httpclient"
@@ -143,6 +146,23 @@ func TestErrorDecoderMiddlewares(t *testing.T) {
 				safeParams, unsafeParams := werror.ParamsFromError(err)
 				assert.Equal(t, map[string]interface{}{"requestHost": u.Host, "requestMethod": "Get", "statusCode": 404}, safeParams)
 				assert.Equal(t, map[string]interface{}{"requestPath": "/path", "responseBody": "route does not exist"}, unsafeParams)
+				body, ok := httpclient.ResponseBodyFromError(err)
+				assert.True(t, ok)
+				assert.Equal(t, "route does not exist", body)
+			},
+		},
+		{
+			name: "404 large plaintext",
+			handler: func(rw http.ResponseWriter, req *http.Request) {
+				rw.Header().Set("Content-Type", "text/plain")
+				rw.WriteHeader(404)
+				_, _ = rw.Write(bytes.Repeat([]byte("a"), 64*1024+1))
+			},
+			verify: func(t *testing.T, u *url.URL, err error) {
+				verify404(t, err)
+				assert.EqualError(t, err, "httpclient request failed: 404 Not Found")
+				_, ok := httpclient.ResponseBodyFromError(err)
+				assert.False(t, ok, "large response bodies should not be attached to the error")
 			},
 		},
 		{
@@ -220,6 +240,60 @@ func TestErrorDecoderMiddlewares(t *testing.T) {
 	}
 }
 
+func TestErrorBodyDrainLimit(t *testing.T) {
+	var newConns int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		size := 10
+		if req.URL.Path == "/large" {
+			size = 1 << 20
+		}
+		rw.WriteHeader(http.StatusInternalServerError)
+		_, _ = rw.Write(bytes.Repeat([]byte("a"), size))
+	}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&newConns, 1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	for _, test := range []struct {
+		Name          string
+		Path          string
+		Params        []httpclient.ClientParam
+		ExpectedConns int32
+	}{
+		{Name: "small body is drained to reuse connection", Path: "/small", ExpectedConns: 1},
+		{Name: "large body is not drained", Path: "/large", ExpectedConns: 3},
+		{
+			Name:          "large body is drained with higher limit",
+			Path:          "/large",
+			Params:        []httpclient.ClientParam{httpclient.WithErrorBodyDrainLimit(2 << 20)},
+			ExpectedConns: 1,
+		},
+	} {
+		t.Run(test.Name, func(t *testing.T) {
+			// fooErrorDecoder does not read the body, so the connection is only reused if the body is drained.
+			client, err := httpclient.NewClient(append([]httpclient.ClientParam{
+				httpclient.WithBaseURLs([]string{server.URL}),
+				httpclient.WithMaxRetries(0),
+				httpclient.WithErrorDecoder(fooErrorDecoder{}),
+			}, test.Params...)...)
+			require.NoError(t, err)
+			atomic.StoreInt32(&newConns, 0)
+			for i := 0; i < 3; i++ {
+				_, err := client.Get(context.Background(), httpclient.WithPath(test.Path))
+				require.EqualError(t, err, "httpclient request failed: foo error")
+			}
+			assert.Equal(t, test.ExpectedConns, atomic.LoadInt32(&newConns))
+		})
+	}
+
+	_, err := httpclient.NewClient(httpclient.WithBaseURLs([]string{server.URL}), httpclient.WithErrorBodyDrainLimit(-1))
+	assert.EqualError(t, err, "httpclient: error body drain limit must not be negative")
+}
+
 type fooErrorDecoder struct{}
 
 func (d fooErrorDecoder) Handles(resp *http.Response) bool {
@@ -0,0 +1,97 @@
+// Copyright (c) 2024 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient
+
+import (
+	"io"
+	"sync"
+
+	"github.com/palantir/conjure-go-runtime/v2/conjure-go-contract/codecs"
+)
+
+// ResponsePool recycles the values that responses are decoded into by WithPooledResponseBody, to avoid allocating
+// a value of a large response type for every request. It is safe for concurrent use.
+type ResponsePool[T any] struct {
+	pool  sync.Pool
+	reset func(*T)
+}
+
+// NewResponsePool returns a pool of values of type T. reset is called with each value returned to the pool by Put
+// to prepare it for the next response, e.g. by truncating slices to reuse their capacity. If reset is nil, values
+// are set to the zero value of T.
+func NewResponsePool[T any](reset func(*T)) *ResponsePool[T] {
+	if reset == nil {
+		reset = func(v *T) {
+			var zero T
+			*v = zero
+		}
+	}
+	return &ResponsePool[T]{
+		pool: sync.Pool{
+			New: func() interface{} {
+				return new(T)
+			},
+		},
+		reset: reset,
+	}
+}
+
+// Get returns a reset value from the pool, or a new zero value if the pool is empty.
+func (p *ResponsePool[T]) Get() *T {
+	return p.pool.Get().(*T)
+}
+
+// Put resets v and returns it to the pool. v must not be used after it is returned.
+func (p *ResponsePool[T]) Put(v *T) {
+	if v == nil {
+		return
+	}
+	p.reset(v)
+	p.pool.Put(v)
+}
+
+// pooledDecoder decodes into a value taken from pool and stores it in output once decoding succeeded.
+// The destination passed to Decode and Unmarshal is ignored.
+type pooledDecoder[T any] struct {
+	decoder codecs.Decoder
+	pool    *ResponsePool[T]
+	output  **T
+}
+
+func (d pooledDecoder[T]) Accept() string {
+	return d.decoder.Accept()
+}
+
+func (d pooledDecoder[T]) Decode(r io.Reader, _ interface{}) error {
+	return d.decodeInto(func(v *T) error {
+		return d.decoder.Decode(r, v)
+	})
+}
+
+func (d pooledDecoder[T]) Unmarshal(data []byte, _ interface{}) error {
+	return d.decodeInto(func(v *T) error {
+		return d.decoder.Unmarshal(data, v)
+	})
+}
+
+func (d pooledDecoder[T]) decodeInto(decode func(v *T) error) error {
+	v := d.pool.Get()
+	if err := decode(v); err != nil {
+		d.pool.Put(v)
+		return err
+	}
+	*d.output = v
+	return nil
+}
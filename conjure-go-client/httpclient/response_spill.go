@@ -0,0 +1,98 @@
+// Copyright (c) 2024 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"runtime"
+	"sync"
+
+	werror "github.com/palantir/witchcraft-go-error"
+)
+
+// spillResponseBody reads the response body to the end and replaces it with an io.ReadSeekCloser over its content,
+// which is kept in memory if it is at most threshold bytes and otherwise written to a temporary file.
+func spillResponseBody(ctx context.Context, resp *http.Response, threshold int64) error {
+	respBody := resp.Body
+	defer func() {
+		_ = respBody.Close()
+	}()
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(io.LimitReader(respBody, threshold+1)); err != nil {
+		return werror.WrapWithContextParams(ctx, err, "httpclient: failed to read response body")
+	}
+	if int64(buf.Len()) <= threshold {
+		resp.Body = &memoryResponseBody{Reader: bytes.NewReader(buf.Bytes())}
+		return nil
+	}
+
+	file, err := os.CreateTemp("", "httpclient-response-*")
+	if err != nil {
+		return werror.WrapWithContextParams(ctx, err, "httpclient: failed to create temporary file for response body")
+	}
+	body := newFileResponseBody(file)
+	if _, err := io.Copy(file, io.MultiReader(&buf, respBody)); err != nil {
+		_ = body.Close()
+		return werror.WrapWithContextParams(ctx, err, "httpclient: failed to write response body to temporary file",
+			werror.UnsafeParam("fileName", file.Name()))
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		_ = body.Close()
+		return werror.WrapWithContextParams(ctx, err, "httpclient: failed to write response body to temporary file",
+			werror.UnsafeParam("fileName", file.Name()))
+	}
+	resp.Body = body
+	return nil
+}
+
+// memoryResponseBody is a response body kept in memory.
+type memoryResponseBody struct {
+	*bytes.Reader
+}
+
+func (*memoryResponseBody) Close() error {
+	return nil
+}
+
+// fileResponseBody is a response body written to a temporary file, which is removed when the body is closed.
+type fileResponseBody struct {
+	*os.File
+	closeOnce sync.Once
+	closeErr  error
+}
+
+func newFileResponseBody(file *os.File) *fileResponseBody {
+	body := &fileResponseBody{File: file}
+	// remove the file if the caller does not close the body.
+	runtime.SetFinalizer(body, func(body *fileResponseBody) {
+		_ = body.Close()
+	})
+	return body
+}
+
+func (b *fileResponseBody) Close() error {
+	b.closeOnce.Do(func() {
+		runtime.SetFinalizer(b, nil)
+		b.closeErr = b.File.Close()
+		if err := os.Remove(b.File.Name()); err != nil && b.closeErr == nil {
+			b.closeErr = err
+		}
+	})
+	return b.closeErr
+}
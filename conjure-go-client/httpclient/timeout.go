@@ -0,0 +1,53 @@
+// Copyright (c) 2024 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// RequestTimeoutError is returned when the timeout set by WithRequestTimeout expires before the request completes.
+// It wraps context.DeadlineExceeded and the error of the last attempt.
+type RequestTimeoutError struct {
+	Timeout time.Duration
+	// Err is the error of the last attempt.
+	Err error
+}
+
+func (e *RequestTimeoutError) Error() string {
+	return fmt.Sprintf("httpclient: request timeout of %s exceeded: %v", e.Timeout, e.Err)
+}
+
+func (e *RequestTimeoutError) Unwrap() []error {
+	return []error{context.DeadlineExceeded, e.Err}
+}
+
+// AttemptTimeoutError is returned when the timeout set by WithPerAttemptTimeout expires during the last attempt
+// of a request. It wraps context.DeadlineExceeded and the error of the attempt.
+type AttemptTimeoutError struct {
+	Timeout time.Duration
+	// Err is the error of the attempt.
+	Err error
+}
+
+func (e *AttemptTimeoutError) Error() string {
+	return fmt.Sprintf("httpclient: per-attempt timeout of %s exceeded: %v", e.Timeout, e.Err)
+}
+
+func (e *AttemptTimeoutError) Unwrap() []error {
+	return []error{context.DeadlineExceeded, e.Err}
+}
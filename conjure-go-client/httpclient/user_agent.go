@@ -0,0 +1,141 @@
+// Copyright (c) 2024 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient
+
+import (
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"sync"
+
+	werror "github.com/palantir/witchcraft-go-error"
+)
+
+const (
+	runtimeModulePath  = "github.com/palantir/conjure-go-runtime/v2"
+	runtimeProductName = "conjure-go-runtime"
+)
+
+// UserAgentProduct is a product token of a User-Agent header, formatted as "name/version (comment)".
+// See RFC 7231 section 5.5.3.
+type UserAgentProduct struct {
+	Name string
+	// Version is optional.
+	Version string
+	// Comment is optional and must not contain parentheses.
+	Comment string
+}
+
+func (p UserAgentProduct) String() string {
+	s := p.Name
+	if p.Version != "" {
+		s += "/" + p.Version
+	}
+	if p.Comment != "" {
+		s += " (" + p.Comment + ")"
+	}
+	return s
+}
+
+func (p UserAgentProduct) validate() error {
+	if !isToken(p.Name) {
+		return werror.Error("httpclient: user agent product name must be a non-empty token",
+			werror.SafeParam("name", p.Name))
+	}
+	if p.Version != "" && !isToken(p.Version) {
+		return werror.Error("httpclient: user agent product version must be a token",
+			werror.SafeParam("name", p.Name),
+			werror.SafeParam("version", p.Version))
+	}
+	if strings.ContainsAny(p.Comment, "()") || strings.ContainsFunc(p.Comment, isControl) {
+		return werror.Error("httpclient: user agent product comment must not contain parentheses or control characters",
+			werror.SafeParam("name", p.Name))
+	}
+	return nil
+}
+
+// formatUserAgent returns the User-Agent header value for products followed by the runtime's product token.
+func formatUserAgent(products []UserAgentProduct) (string, error) {
+	tokens := make([]string, 0, len(products)+1)
+	for _, product := range products {
+		if err := product.validate(); err != nil {
+			return "", err
+		}
+		tokens = append(tokens, product.String())
+	}
+	tokens = append(tokens, runtimeUserAgentProduct().String())
+	return strings.Join(tokens, " "), nil
+}
+
+var runtimeVersion = sync.OnceValue(func() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == runtimeModulePath {
+			if dep.Replace != nil {
+				dep = dep.Replace
+			}
+			return strings.TrimPrefix(dep.Version, "v")
+		}
+	}
+	return ""
+})
+
+// runtimeUserAgentProduct returns the product token of this library. The version is read from the build info
+// and is omitted if it is not available, e.g. in tests of this module.
+func runtimeUserAgentProduct() UserAgentProduct {
+	return UserAgentProduct{Name: runtimeProductName, Version: runtimeVersion()}
+}
+
+// runtimeUserAgentMiddleware appends the product token of this library to the User-Agent header
+// unless a product with the same name is already present.
+type runtimeUserAgentMiddleware struct{}
+
+func (runtimeUserAgentMiddleware) RoundTrip(req *http.Request, next http.RoundTripper) (*http.Response, error) {
+	req.Header.Set("User-Agent", appendRuntimeUserAgent(req.Header.Get("User-Agent")))
+	return next.RoundTrip(req)
+}
+
+func appendRuntimeUserAgent(userAgent string) string {
+	for _, token := range strings.Fields(userAgent) {
+		if name, _, _ := strings.Cut(token, "/"); name == runtimeProductName {
+			return userAgent
+		}
+	}
+	product := runtimeUserAgentProduct().String()
+	if userAgent == "" {
+		return product
+	}
+	return userAgent + " " + product
+}
+
+// isToken returns true if s is a non-empty token as defined by RFC 7230 section 3.2.6.
+func isToken(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r >= 0x80 || isControl(r) || r == ' ' || strings.ContainsRune(`"(),/:;<=>?@[\]{}`, r) {
+			return false
+		}
+	}
+	return true
+}
+
+func isControl(r rune) bool {
+	return r < 0x20 || r == 0x7f
+}
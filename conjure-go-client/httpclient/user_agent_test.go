@@ -0,0 +1,108 @@
+// Copyright (c) 2024 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatUserAgent(t *testing.T) {
+	runtimeProduct := runtimeUserAgentProduct().String()
+	for _, test := range []struct {
+		Name          string
+		Products      []UserAgentProduct
+		Expected      string
+		ExpectedError string
+	}{
+		{
+			Name:     "no products",
+			Expected: runtimeProduct,
+		},
+		{
+			Name: "products",
+			Products: []UserAgentProduct{
+				{Name: "my-service", Version: "1.2.3", Comment: "linux; amd64"},
+				{Name: "my-library"},
+			},
+			Expected: "my-service/1.2.3 (linux; amd64) my-library " + runtimeProduct,
+		},
+		{
+			Name:          "invalid name",
+			Products:      []UserAgentProduct{{Name: "my service"}},
+			ExpectedError: "httpclient: user agent product name must be a non-empty token",
+		},
+		{
+			Name:          "invalid version",
+			Products:      []UserAgentProduct{{Name: "my-service", Version: "1/2"}},
+			ExpectedError: "httpclient: user agent product version must be a token",
+		},
+		{
+			Name:          "invalid comment",
+			Products:      []UserAgentProduct{{Name: "my-service", Comment: "(nested)"}},
+			ExpectedError: "httpclient: user agent product comment must not contain parentheses or control characters",
+		},
+	} {
+		t.Run(test.Name, func(t *testing.T) {
+			userAgent, err := formatUserAgent(test.Products)
+			if test.ExpectedError != "" {
+				require.EqualError(t, err, test.ExpectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.Expected, userAgent)
+		})
+	}
+}
+
+func TestAppendRuntimeUserAgent(t *testing.T) {
+	runtimeProduct := runtimeUserAgentProduct().String()
+	for _, test := range []struct {
+		Name      string
+		UserAgent string
+		Expected  string
+	}{
+		{
+			Name:     "empty",
+			Expected: runtimeProduct,
+		},
+		{
+			Name:      "appends",
+			UserAgent: "my-service/1.2.3",
+			Expected:  "my-service/1.2.3 " + runtimeProduct,
+		},
+		{
+			Name:      "already appended",
+			UserAgent: "my-service/1.2.3 " + runtimeProduct,
+			Expected:  "my-service/1.2.3 " + runtimeProduct,
+		},
+		{
+			Name:      "other runtime version",
+			UserAgent: "my-service/1.2.3 conjure-go-runtime/2.0.0",
+			Expected:  "my-service/1.2.3 conjure-go-runtime/2.0.0",
+		},
+		{
+			Name:      "product name prefix",
+			UserAgent: "conjure-go-runtime-extras/1.0.0",
+			Expected:  "conjure-go-runtime-extras/1.0.0 " + runtimeProduct,
+		},
+	} {
+		t.Run(test.Name, func(t *testing.T) {
+			assert.Equal(t, test.Expected, appendRuntimeUserAgent(test.UserAgent))
+		})
+	}
+}
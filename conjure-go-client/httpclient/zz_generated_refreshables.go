@@ -64,6 +64,7 @@ type RefreshableClientConfig interface {
 	DisableHTTP2() refreshable.BoolPtr
 	ProxyFromEnvironment() refreshable.BoolPtr
 	ProxyURL() refreshable.StringPtr
+	UserAgent() refreshable.StringPtr
 	MaxNumRetries() refreshable.IntPtr
 	InitialBackoff() refreshable.DurationPtr
 	MaxBackoff() refreshable.DurationPtr
@@ -155,6 +156,12 @@ func (r RefreshingClientConfig) ProxyURL() refreshable.StringPtr {
 	}))
 }
 
+func (r RefreshingClientConfig) UserAgent() refreshable.StringPtr {
+	return refreshable.NewStringPtr(r.MapClientConfig(func(i ClientConfig) interface{} {
+		return i.UserAgent
+	}))
+}
+
 func (r RefreshingClientConfig) MaxNumRetries() refreshable.IntPtr {
 	return refreshable.NewIntPtr(r.MapClientConfig(func(i ClientConfig) interface{} {
 		return i.MaxNumRetries
@@ -436,6 +443,9 @@ type RefreshableSecurityConfig interface {
 	CertFile() refreshable.String
 	KeyFile() refreshable.String
 	InsecureSkipVerify() refreshable.BoolPtr
+	PKCS12File() refreshable.String
+	PKCS12Password() refreshable.String
+	AppendSystemCAs() refreshable.BoolPtr
 }
 
 type RefreshingSecurityConfig struct {
@@ -486,6 +496,24 @@ func (r RefreshingSecurityConfig) InsecureSkipVerify() refreshable.BoolPtr {
 	}))
 }
 
+func (r RefreshingSecurityConfig) PKCS12File() refreshable.String {
+	return refreshable.NewString(r.MapSecurityConfig(func(i SecurityConfig) interface{} {
+		return i.PKCS12File
+	}))
+}
+
+func (r RefreshingSecurityConfig) PKCS12Password() refreshable.String {
+	return refreshable.NewString(r.MapSecurityConfig(func(i SecurityConfig) interface{} {
+		return i.PKCS12Password
+	}))
+}
+
+func (r RefreshingSecurityConfig) AppendSystemCAs() refreshable.BoolPtr {
+	return refreshable.NewBoolPtr(r.MapSecurityConfig(func(i SecurityConfig) interface{} {
+		return i.AppendSystemCAs
+	}))
+}
+
 type RefreshableStringToClientConfig interface {
 	refreshable.Refreshable
 	CurrentStringToClientConfig() map[string]ClientConfig
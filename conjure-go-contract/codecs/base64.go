@@ -0,0 +1,84 @@
+// Copyright (c) 2024 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codecs
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+)
+
+var _ Codec = codecBase64{}
+
+// Base64 wraps an existing Codec and uses standard base64 encoding (RFC 4648) for the serialized message.
+// The content type and accepted type are those of the wrapped codec.
+//
+// Decoding ignores carriage returns and newlines, so line-wrapped input is accepted.
+// Marshal encodes into a single allocation sized from the base64 expansion of the wrapped codec's output,
+// so request bodies built from it have a known content length.
+func Base64(codec Codec) Codec {
+	return codecBase64{contentCodec: codec}
+}
+
+type codecBase64 struct {
+	contentCodec Codec
+}
+
+func (c codecBase64) Accept() string {
+	return c.contentCodec.Accept()
+}
+
+func (c codecBase64) Decode(r io.Reader, v interface{}) error {
+	// Read the full input rather than streaming it to the wrapped codec, which may stop reading before
+	// invalid or truncated base64 data at the end of the input.
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return c.Unmarshal(data, v)
+}
+
+func (c codecBase64) Unmarshal(data []byte, v interface{}) error {
+	decoded := make([]byte, base64.StdEncoding.DecodedLen(len(data)))
+	n, err := base64.StdEncoding.Decode(decoded, data)
+	if err != nil {
+		return fmt.Errorf("failed to decode base64-encoded value: %s", err.Error())
+	}
+	return c.contentCodec.Unmarshal(decoded[:n], v)
+}
+
+func (c codecBase64) ContentType() string {
+	return c.contentCodec.ContentType()
+}
+
+func (c codecBase64) Encode(w io.Writer, v interface{}) (err error) {
+	base64Writer := base64.NewEncoder(base64.StdEncoding, w)
+	defer func() {
+		if closeErr := base64Writer.Close(); err == nil && closeErr != nil {
+			err = closeErr
+		}
+	}()
+	return c.contentCodec.Encode(base64Writer, v)
+}
+
+func (c codecBase64) Marshal(v interface{}) ([]byte, error) {
+	data, err := c.contentCodec.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	encoded := make([]byte, base64.StdEncoding.EncodedLen(len(data)))
+	base64.StdEncoding.Encode(encoded, data)
+	return encoded, nil
+}
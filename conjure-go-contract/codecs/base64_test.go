@@ -0,0 +1,79 @@
+// Copyright (c) 2024 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codecs_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/palantir/conjure-go-runtime/v2/conjure-go-contract/codecs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBase64Codec(t *testing.T) {
+	codec := codecs.Base64(codecs.JSON)
+	assert.Equal(t, codecs.JSON.ContentType(), codec.ContentType())
+	assert.Equal(t, codecs.JSON.Accept(), codec.Accept())
+
+	input := map[string]string{"key": "value"}
+	encodedJSON := base64.StdEncoding.EncodeToString([]byte(`{"key":"value"}`))
+
+	t.Run("Marshal/Unmarshal", func(t *testing.T) {
+		data, err := codec.Marshal(input)
+		require.NoError(t, err)
+		assert.Equal(t, encodedJSON, string(data))
+
+		var output map[string]string
+		require.NoError(t, codec.Unmarshal(data, &output))
+		assert.Equal(t, input, output)
+	})
+
+	t.Run("Encode/Decode", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, codec.Encode(&buf, input))
+		assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("{\"key\":\"value\"}\n")), buf.String())
+
+		var output map[string]string
+		require.NoError(t, codec.Decode(&buf, &output))
+		assert.Equal(t, input, output)
+	})
+
+	t.Run("Decode line-wrapped", func(t *testing.T) {
+		wrapped := encodedJSON[:8] + "\r\n" + encodedJSON[8:]
+		var output map[string]string
+		require.NoError(t, codec.Decode(strings.NewReader(wrapped), &output))
+		assert.Equal(t, input, output)
+	})
+
+	for _, test := range []struct {
+		Name string
+		Data string
+	}{
+		{Name: "invalid character", Data: "eyJrZXkiOiJ2YWx1ZSJ9!"},
+		{Name: "truncated", Data: encodedJSON[:len(encodedJSON)-1]},
+	} {
+		t.Run("invalid base64 "+test.Name, func(t *testing.T) {
+			var output map[string]string
+			err := codec.Unmarshal([]byte(test.Data), &output)
+			assert.ErrorContains(t, err, "failed to decode base64-encoded value: illegal base64 data")
+			err = codec.Decode(strings.NewReader(test.Data), &output)
+			assert.ErrorContains(t, err, "failed to decode base64-encoded value: illegal base64 data")
+			assert.Nil(t, output)
+		})
+	}
+}
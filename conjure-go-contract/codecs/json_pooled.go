@@ -0,0 +1,165 @@
+// Copyright (c) 2024 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codecs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/palantir/pkg/safejson"
+)
+
+// JSONPooled codec has the same semantics as the JSON codec, but reuses json.Encoders, json.Decoders and their
+// buffers across calls, reducing allocations when encoding and decoding many values of bounded size.
+// Encoders and decoders which have processed more than 64KiB are not reused, so occasional large values do not
+// stay in memory.
+var JSONPooled Codec = codecJSONPooled{}
+
+// maxPooledJSONBytes is the largest value size after which encoders and decoders are not returned to their pools.
+const maxPooledJSONBytes = 64 * 1024
+
+type codecJSONPooled struct{}
+
+func (codecJSONPooled) Accept() string {
+	return contentTypeJSON
+}
+
+func (codecJSONPooled) Decode(r io.Reader, v interface{}) error {
+	if err := decodePooledJSON(r, v); err != nil {
+		return fmt.Errorf("failed to decode JSON-encoded value: %s", err.Error())
+	}
+	return nil
+}
+
+func (codecJSONPooled) Unmarshal(data []byte, v interface{}) error {
+	return decodePooledJSON(bytes.NewReader(data), v)
+}
+
+func (codecJSONPooled) ContentType() string {
+	return contentTypeJSON
+}
+
+func (codecJSONPooled) Encode(w io.Writer, v interface{}) error {
+	e := jsonEncoderPool.Get().(*pooledJSONEncoder)
+	defer e.release()
+	if err := e.enc.Encode(v); err != nil {
+		return fmt.Errorf("failed to JSON-encode value: %s", err.Error())
+	}
+	_, err := w.Write(e.buf.Bytes())
+	return err
+}
+
+func (codecJSONPooled) Marshal(v interface{}) ([]byte, error) {
+	e := jsonEncoderPool.Get().(*pooledJSONEncoder)
+	defer e.release()
+	if err := e.enc.Encode(v); err != nil {
+		return nil, err
+	}
+	// copy the output since the buffer is reused, omitting the newline written by Encode like safejson.Marshal.
+	return append([]byte(nil), bytes.TrimSuffix(e.buf.Bytes(), []byte{'\n'})...), nil
+}
+
+var jsonEncoderPool = sync.Pool{
+	New: func() interface{} {
+		e := &pooledJSONEncoder{}
+		e.enc = safejson.Encoder(&e.buf)
+		return e
+	},
+}
+
+// pooledJSONEncoder is a json.Encoder which writes to a reusable buffer.
+type pooledJSONEncoder struct {
+	buf bytes.Buffer
+	enc *json.Encoder
+}
+
+func (e *pooledJSONEncoder) release() {
+	if e.buf.Cap() > maxPooledJSONBytes {
+		return
+	}
+	e.buf.Reset()
+	jsonEncoderPool.Put(e)
+}
+
+var jsonDecoderPool = sync.Pool{
+	New: func() interface{} {
+		d := &pooledJSONDecoder{}
+		d.dec = safejson.Decoder(&d.r)
+		return d
+	},
+}
+
+func decodePooledJSON(r io.Reader, v interface{}) error {
+	d := jsonDecoderPool.Get().(*pooledJSONDecoder)
+	d.r = countingReader{r: r}
+	err := d.dec.Decode(v)
+	d.release(err)
+	return err
+}
+
+// pooledJSONDecoder is a json.Decoder whose underlying reader can be replaced between values.
+// A json.Decoder can not be reset, so it is only reused if it is in the same state as a new decoder would be
+// after skipping whitespace: the previous Decode succeeded and no input other than whitespace is buffered.
+type pooledJSONDecoder struct {
+	r   countingReader
+	dec *json.Decoder
+}
+
+func (d *pooledJSONDecoder) release(decodeErr error) {
+	bytesRead := d.r.n
+	d.r = countingReader{}
+	if decodeErr != nil || bytesRead > maxPooledJSONBytes {
+		return
+	}
+	if !onlySpace(d.dec.Buffered()) {
+		return
+	}
+	jsonDecoderPool.Put(d)
+}
+
+// onlySpace returns true if r contains only JSON whitespace.
+func onlySpace(r io.Reader) bool {
+	br, ok := r.(io.ByteReader)
+	if !ok {
+		return false
+	}
+	for {
+		c, err := br.ReadByte()
+		if err != nil {
+			return err == io.EOF
+		}
+		if c != ' ' && c != '\t' && c != '\r' && c != '\n' {
+			return false
+		}
+	}
+}
+
+// countingReader counts the bytes read from r.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	if c.r == nil {
+		return 0, io.EOF
+	}
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
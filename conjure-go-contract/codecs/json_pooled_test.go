@@ -0,0 +1,148 @@
+// Copyright (c) 2024 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codecs_test
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/palantir/conjure-go-runtime/v2/conjure-go-contract/codecs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONPooledCodec(t *testing.T) {
+	// The pooled decoder must behave like a new decoder regardless of the input previously decoded with it,
+	// so every case is decoded with both codecs after each of the other cases.
+	for _, test := range []struct {
+		Name string
+		Data string
+	}{
+		{Name: "object", Data: `{"key":"<value>","number":12345678901234567890}`},
+		{Name: "number", Data: `42`},
+		{Name: "trailing whitespace", Data: "{\"key\":\"value\"}\n\n"},
+		{Name: "trailing value", Data: `{"key":"value"} {"key":"other"}`},
+		{Name: "unclosed object", Data: `{"key":"value"`},
+		{Name: "empty", Data: ``},
+		{Name: "invalid", Data: `{"key":value}`},
+		{Name: "large", Data: `"` + strings.Repeat("a", 100*1024) + `"`},
+	} {
+		t.Run(test.Name, func(t *testing.T) {
+			for _, previous := range []string{`{"key":"value"} [`, `{"key":`, `1`, `"a"   `} {
+				_ = codecs.JSONPooled.Decode(strings.NewReader(previous), new(interface{}))
+
+				var expected, actual interface{}
+				expectedErr := codecs.JSON.Decode(strings.NewReader(test.Data), &expected)
+				actualErr := codecs.JSONPooled.Decode(strings.NewReader(test.Data), &actual)
+				assert.Equal(t, expectedErr, actualErr, "Decode after %q", previous)
+				assert.Equal(t, expected, actual, "Decode after %q", previous)
+
+				expectedErr = codecs.JSON.Unmarshal([]byte(test.Data), &expected)
+				actualErr = codecs.JSONPooled.Unmarshal([]byte(test.Data), &actual)
+				assert.Equal(t, expectedErr, actualErr, "Unmarshal after %q", previous)
+				assert.Equal(t, expected, actual, "Unmarshal after %q", previous)
+			}
+
+			var value interface{}
+			if err := codecs.JSON.Unmarshal([]byte(test.Data), &value); err != nil {
+				return
+			}
+			var expected, actual bytes.Buffer
+			require.NoError(t, codecs.JSON.Encode(&expected, value))
+			require.NoError(t, codecs.JSONPooled.Encode(&actual, value))
+			assert.Equal(t, expected.String(), actual.String())
+
+			expectedBytes, err := codecs.JSON.Marshal(value)
+			require.NoError(t, err)
+			actualBytes, err := codecs.JSONPooled.Marshal(value)
+			require.NoError(t, err)
+			assert.Equal(t, expectedBytes, actualBytes)
+		})
+	}
+}
+
+func TestJSONPooledCodec_EncodeError(t *testing.T) {
+	var expected, actual bytes.Buffer
+	expectedErr := codecs.JSON.Encode(&expected, make(chan int))
+	actualErr := codecs.JSONPooled.Encode(&actual, make(chan int))
+	require.Error(t, actualErr)
+	assert.Equal(t, expectedErr.Error(), actualErr.Error())
+	assert.Empty(t, actual.String())
+}
+
+func TestJSONPooledCodec_Concurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				data, err := codecs.JSONPooled.Marshal(map[string]int{"i": i, "j": j})
+				assert.NoError(t, err)
+				var out map[string]int
+				assert.NoError(t, codecs.JSONPooled.Unmarshal(data, &out))
+				assert.Equal(t, map[string]int{"i": i, "j": j}, out)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func BenchmarkJSONCodec(b *testing.B) {
+	type value struct {
+		Name   string            `json:"name"`
+		Values []int             `json:"values"`
+		Tags   map[string]string `json:"tags"`
+	}
+	v := value{Name: strings.Repeat("n", 100), Values: make([]int, 100), Tags: map[string]string{}}
+	for i := 0; i < 20; i++ {
+		v.Tags[fmt.Sprintf("tag-%d", i)] = strings.Repeat("t", 50)
+	}
+	data, err := codecs.JSON.Marshal(v)
+	require.NoError(b, err)
+
+	for _, codec := range []struct {
+		Name  string
+		Codec codecs.Codec
+	}{
+		{Name: "JSON", Codec: codecs.JSON},
+		{Name: "JSONPooled", Codec: codecs.JSONPooled},
+	} {
+		b.Run(codec.Name, func(b *testing.B) {
+			b.Run("Encode", func(b *testing.B) {
+				var buf bytes.Buffer
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					buf.Reset()
+					if err := codec.Codec.Encode(&buf, v); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+			b.Run("Decode", func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					var out value
+					if err := codec.Codec.Decode(bytes.NewReader(data), &out); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright (c) 2024 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package jsonschema validates JSON documents against a subset of JSON Schema (draft 2020-12). Validating wraps a
+// compiled Schema in a codecs.Decoder which validates each document before decoding it with codecs.JSON.
+//
+// The supported keywords and their semantics are:
+//
+//   - true and false: boolean schemas which allow any value and no value respectively.
+//   - type: a type name or an array of type names, one of which the value must have. The type names are null,
+//     boolean, object, array, number, integer and string. A number is an integer if it has no fractional part,
+//     e.g. 2.0, and every integer is also a number.
+//   - enum: the value must equal one of the elements of the array.
+//   - const: the value must equal the keyword value.
+//   - properties: each property of an object which is named in the keyword is validated against its schema.
+//   - required: an object must have each of the named properties.
+//   - additionalProperties: each property of an object which is not named in properties is validated against
+//     the schema.
+//   - items: each element of an array is validated against the schema. The array form of items is not supported.
+//   - minItems and maxItems: bound the number of elements of an array.
+//   - minLength and maxLength: bound the length of a string in Unicode code points.
+//   - pattern: a string must contain a match of the regular expression. Patterns are RE2 expressions as
+//     implemented by the regexp package rather than ECMA-262 expressions, so features such as lookaround and
+//     backreferences are not supported and a schema using them fails to compile.
+//   - minimum and maximum: inclusive bounds of a number, compared exactly rather than as floating point values.
+//
+// Values are equal for enum and const if they have the same type and value, where numbers are compared by value so
+// that 1 equals 1.0. Keywords which do not apply to the type of a value, e.g. minLength for a number, are ignored.
+//
+// The annotation keywords $schema, $id, $comment, title, description, default and examples are ignored. Compile
+// returns an error for any other keyword, including $ref, allOf, anyOf, oneOf, not, format and exclusiveMinimum,
+// so that a document is never silently validated more loosely than its schema is written. Schemas requiring these
+// keywords should use a complete JSON Schema implementation instead.
+package jsonschema
@@ -0,0 +1,478 @@
+// Copyright (c) 2024 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/palantir/conjure-go-runtime/v2/conjure-go-contract/codecs"
+	"github.com/palantir/pkg/safejson"
+	werror "github.com/palantir/witchcraft-go-error"
+)
+
+// Schema is a compiled JSON Schema. See the package documentation for the supported keywords.
+type Schema struct {
+	// reject is set for the boolean schema false.
+	reject bool
+
+	types                []string
+	enum                 []interface{}
+	hasConst             bool
+	constValue           interface{}
+	properties           map[string]*Schema
+	required             []string
+	additionalProperties *Schema
+	items                *Schema
+	minItems, maxItems   *int
+	minLength, maxLength *int
+	pattern              *regexp.Regexp
+	minimum, maximum     *big.Rat
+}
+
+var annotationKeywords = map[string]struct{}{
+	"$schema":     {},
+	"$id":         {},
+	"$comment":    {},
+	"title":       {},
+	"description": {},
+	"default":     {},
+	"examples":    {},
+}
+
+var typeNames = map[string]struct{}{
+	"null":    {},
+	"boolean": {},
+	"object":  {},
+	"array":   {},
+	"number":  {},
+	"integer": {},
+	"string":  {},
+}
+
+// Compile compiles the JSON-encoded schema. It returns an error if the schema uses a keyword which is not supported.
+func Compile(schema []byte) (*Schema, error) {
+	var value interface{}
+	if err := safejson.Unmarshal(schema, &value); err != nil {
+		return nil, werror.Wrap(err, "failed to parse JSON schema")
+	}
+	return compile(value, "")
+}
+
+// MustCompile is like Compile but panics if the schema can not be compiled.
+func MustCompile(schema []byte) *Schema {
+	compiled, err := Compile(schema)
+	if err != nil {
+		panic(err)
+	}
+	return compiled
+}
+
+func compile(value interface{}, path string) (*Schema, error) {
+	switch v := value.(type) {
+	case bool:
+		return &Schema{reject: !v}, nil
+	case map[string]interface{}:
+	default:
+		return nil, werror.Error("JSON schema must be an object or a boolean", werror.SafeParam("schemaPath", jsonPointer(path)))
+	}
+	obj := value.(map[string]interface{})
+	schema := &Schema{}
+	for _, keyword := range sortedKeys(obj) {
+		keywordValue := obj[keyword]
+		keywordPath := path + "/" + escapeJSONPointer(keyword)
+		invalid := func(expected string) error {
+			return werror.Error("invalid JSON schema keyword value",
+				werror.SafeParam("schemaPath", jsonPointer(keywordPath)),
+				werror.SafeParam("expected", expected))
+		}
+		switch keyword {
+		case "type":
+			switch t := keywordValue.(type) {
+			case string:
+				schema.types = []string{t}
+			case []interface{}:
+				for _, elem := range t {
+					s, ok := elem.(string)
+					if !ok {
+						return nil, invalid("a string or an array of strings")
+					}
+					schema.types = append(schema.types, s)
+				}
+			default:
+				return nil, invalid("a string or an array of strings")
+			}
+			for _, t := range schema.types {
+				if _, ok := typeNames[t]; !ok {
+					return nil, invalid("a JSON schema type")
+				}
+			}
+		case "enum":
+			enum, ok := keywordValue.([]interface{})
+			if !ok {
+				return nil, invalid("an array")
+			}
+			schema.enum = enum
+		case "const":
+			schema.hasConst = true
+			schema.constValue = keywordValue
+		case "properties":
+			props, ok := keywordValue.(map[string]interface{})
+			if !ok {
+				return nil, invalid("an object")
+			}
+			schema.properties = make(map[string]*Schema, len(props))
+			for name, prop := range props {
+				compiled, err := compile(prop, keywordPath+"/"+escapeJSONPointer(name))
+				if err != nil {
+					return nil, err
+				}
+				schema.properties[name] = compiled
+			}
+		case "required":
+			required, ok := keywordValue.([]interface{})
+			if !ok {
+				return nil, invalid("an array of strings")
+			}
+			for _, elem := range required {
+				s, ok := elem.(string)
+				if !ok {
+					return nil, invalid("an array of strings")
+				}
+				schema.required = append(schema.required, s)
+			}
+		case "additionalProperties", "items":
+			compiled, err := compile(keywordValue, keywordPath)
+			if err != nil {
+				return nil, err
+			}
+			if keyword == "items" {
+				schema.items = compiled
+			} else {
+				schema.additionalProperties = compiled
+			}
+		case "minItems", "maxItems", "minLength", "maxLength":
+			n, ok := nonNegativeInt(keywordValue)
+			if !ok {
+				return nil, invalid("a non-negative integer")
+			}
+			switch keyword {
+			case "minItems":
+				schema.minItems = &n
+			case "maxItems":
+				schema.maxItems = &n
+			case "minLength":
+				schema.minLength = &n
+			case "maxLength":
+				schema.maxLength = &n
+			}
+		case "pattern":
+			s, ok := keywordValue.(string)
+			if !ok {
+				return nil, invalid("a regular expression")
+			}
+			re, err := regexp.Compile(s)
+			if err != nil {
+				return nil, invalid("a regular expression")
+			}
+			schema.pattern = re
+		case "minimum", "maximum":
+			n, ok := jsonNumber(keywordValue)
+			if !ok {
+				return nil, invalid("a number")
+			}
+			if keyword == "minimum" {
+				schema.minimum = n
+			} else {
+				schema.maximum = n
+			}
+		default:
+			if _, ok := annotationKeywords[keyword]; !ok {
+				return nil, werror.Error("unsupported JSON schema keyword",
+					werror.SafeParam("schemaPath", jsonPointer(keywordPath)),
+					werror.SafeParam("keyword", keyword))
+			}
+		}
+	}
+	return schema, nil
+}
+
+// Violation describes a value which does not conform to a JSON schema.
+type Violation struct {
+	// Path is the JSON pointer (RFC 6901) of the value within the document, e.g. "/items/0/name".
+	Path    string
+	Message string
+}
+
+func (v Violation) String() string {
+	return jsonPointer(v.Path) + ": " + v.Message
+}
+
+// ValidationError is returned by Validating when a document does not conform to the schema.
+type ValidationError struct {
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, violation := range e.Violations {
+		messages[i] = violation.String()
+	}
+	return "JSON document does not conform to schema: " + strings.Join(messages, "; ")
+}
+
+// Validate returns a *ValidationError if the JSON-encoded document does not conform to the schema.
+func (s *Schema) Validate(data []byte) error {
+	var value interface{}
+	if err := safejson.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("failed to decode JSON-encoded value: %s", err.Error())
+	}
+	var violations []Violation
+	s.validate(value, "", &violations)
+	if len(violations) > 0 {
+		return &ValidationError{Violations: violations}
+	}
+	return nil
+}
+
+func (s *Schema) validate(value interface{}, path string, violations *[]Violation) {
+	report := func(format string, args ...interface{}) {
+		*violations = append(*violations, Violation{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+	if s.reject {
+		report("no value is allowed")
+		return
+	}
+	if len(s.types) > 0 && !s.matchesType(value) {
+		report("expected %s but got %s", strings.Join(s.types, " or "), jsonTypeName(value))
+		return
+	}
+	if s.hasConst && !jsonEqual(value, s.constValue) {
+		report("expected constant value %s", jsonString(s.constValue))
+	}
+	if s.enum != nil && !s.inEnum(value) {
+		report("expected one of %s", jsonString(s.enum))
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, name := range s.required {
+			if _, ok := v[name]; !ok {
+				report("missing required property %q", name)
+			}
+		}
+		for _, name := range sortedKeys(v) {
+			propPath := path + "/" + escapeJSONPointer(name)
+			if prop, ok := s.properties[name]; ok {
+				prop.validate(v[name], propPath, violations)
+			} else if s.additionalProperties != nil {
+				s.additionalProperties.validate(v[name], propPath, violations)
+			}
+		}
+	case []interface{}:
+		if s.minItems != nil && len(v) < *s.minItems {
+			report("expected at least %d items but got %d", *s.minItems, len(v))
+		}
+		if s.maxItems != nil && len(v) > *s.maxItems {
+			report("expected at most %d items but got %d", *s.maxItems, len(v))
+		}
+		if s.items != nil {
+			for i, elem := range v {
+				s.items.validate(elem, path+"/"+strconv.Itoa(i), violations)
+			}
+		}
+	case string:
+		length := utf8.RuneCountInString(v)
+		if s.minLength != nil && length < *s.minLength {
+			report("expected at least %d characters but got %d", *s.minLength, length)
+		}
+		if s.maxLength != nil && length > *s.maxLength {
+			report("expected at most %d characters but got %d", *s.maxLength, length)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			report("expected to match pattern %q", s.pattern.String())
+		}
+	case json.Number:
+		n, _ := jsonNumber(v)
+		if s.minimum != nil && n.Cmp(s.minimum) < 0 {
+			report("expected a minimum of %s but got %s", s.minimum.RatString(), v)
+		}
+		if s.maximum != nil && n.Cmp(s.maximum) > 0 {
+			report("expected a maximum of %s but got %s", s.maximum.RatString(), v)
+		}
+	}
+}
+
+func (s *Schema) matchesType(value interface{}) bool {
+	actual := jsonTypeName(value)
+	for _, t := range s.types {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Schema) inEnum(value interface{}) bool {
+	for _, allowed := range s.enum {
+		if jsonEqual(value, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// Validating returns a codecs.Decoder which validates each document against schema before decoding it
+// with the JSON codec. A document which does not conform to the schema is not decoded, and a
+// *ValidationError describing every violation is returned.
+func Validating(schema *Schema) codecs.Decoder {
+	return validatingDecoder{schema: schema}
+}
+
+type validatingDecoder struct {
+	schema *Schema
+}
+
+func (c validatingDecoder) Accept() string {
+	return codecs.JSON.Accept()
+}
+
+func (c validatingDecoder) Decode(r io.Reader, v interface{}) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return c.Unmarshal(data, v)
+}
+
+func (c validatingDecoder) Unmarshal(data []byte, v interface{}) error {
+	if err := c.schema.Validate(data); err != nil {
+		return err
+	}
+	return codecs.JSON.Unmarshal(data, v)
+}
+
+// jsonTypeName returns the JSON schema type of a value decoded by safejson. Integral numbers are "integer".
+func jsonTypeName(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case json.Number:
+		if n, ok := jsonNumber(v); ok && n.IsInt() {
+			return "integer"
+		}
+		return "number"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// jsonEqual compares values decoded by safejson, treating numbers as equal if they have the same value.
+func jsonEqual(a, b interface{}) bool {
+	switch av := a.(type) {
+	case json.Number:
+		bv, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		an, aok := jsonNumber(av)
+		bn, bok := jsonNumber(bv)
+		return aok && bok && an.Cmp(bn) == 0
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for k, ae := range av {
+			be, ok := bv[k]
+			if !ok || !jsonEqual(ae, be) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for i := range av {
+			if !jsonEqual(av[i], bv[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a == b
+	}
+}
+
+func jsonNumber(value interface{}) (*big.Rat, bool) {
+	n, ok := value.(json.Number)
+	if !ok {
+		return nil, false
+	}
+	return new(big.Rat).SetString(string(n))
+}
+
+func nonNegativeInt(value interface{}) (int, bool) {
+	n, ok := value.(json.Number)
+	if !ok {
+		return 0, false
+	}
+	i, err := strconv.Atoi(string(n))
+	return i, err == nil && i >= 0
+}
+
+func jsonString(value interface{}) string {
+	data, err := safejson.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
+
+func jsonPointer(path string) string {
+	if path == "" {
+		return "/"
+	}
+	return path
+}
+
+func escapeJSONPointer(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
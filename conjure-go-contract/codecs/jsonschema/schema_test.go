@@ -0,0 +1,155 @@
+// Copyright (c) 2024 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jsonschema_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/palantir/conjure-go-runtime/v2/conjure-go-contract/codecs"
+	"github.com/palantir/conjure-go-runtime/v2/conjure-go-contract/codecs/jsonschema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testJSONSchema = `{
+	"$schema": "https://json-schema.org/draft/2020-12/schema",
+	"title": "user",
+	"type": "object",
+	"required": ["id", "name"],
+	"properties": {
+		"id": {"type": "integer", "minimum": 1},
+		"name": {"type": "string", "minLength": 1, "maxLength": 8, "pattern": "^[a-z]+$"},
+		"role": {"enum": ["admin", "user"]},
+		"version": {"const": 2},
+		"score": {"type": ["number", "null"], "maximum": 1.5},
+		"tags": {"type": "array", "items": {"type": "string"}, "minItems": 1, "maxItems": 2}
+	},
+	"additionalProperties": false
+}`
+
+type testUser struct {
+	ID   int      `json:"id"`
+	Name string   `json:"name"`
+	Tags []string `json:"tags"`
+}
+
+func TestValidating(t *testing.T) {
+	decoder := jsonschema.Validating(jsonschema.MustCompile([]byte(testJSONSchema)))
+	assert.Equal(t, codecs.JSON.Accept(), decoder.Accept())
+
+	for _, test := range []struct {
+		Name       string
+		Data       string
+		Violations []jsonschema.Violation
+	}{
+		{
+			Name: "valid",
+			Data: `{"id":1,"name":"alice","role":"admin","version":2.0,"score":null,"tags":["a"]}`,
+		},
+		{
+			Name: "wrong root type",
+			Data: `[]`,
+			Violations: []jsonschema.Violation{
+				{Path: "", Message: "expected object but got array"},
+			},
+		},
+		{
+			Name: "missing required properties",
+			Data: `{}`,
+			Violations: []jsonschema.Violation{
+				{Path: "", Message: `missing required property "id"`},
+				{Path: "", Message: `missing required property "name"`},
+			},
+		},
+		{
+			Name: "invalid properties",
+			Data: `{"id":1.5,"name":"Alice123456","role":"owner","version":3,"score":2,"tags":[],"extra/field":true}`,
+			Violations: []jsonschema.Violation{
+				{Path: "/extra~1field", Message: "no value is allowed"},
+				{Path: "/id", Message: "expected integer but got number"},
+				{Path: "/name", Message: "expected at most 8 characters but got 11"},
+				{Path: "/name", Message: `expected to match pattern "^[a-z]+$"`},
+				{Path: "/role", Message: `expected one of ["admin","user"]`},
+				{Path: "/score", Message: "expected a maximum of 3/2 but got 2"},
+				{Path: "/tags", Message: "expected at least 1 items but got 0"},
+				{Path: "/version", Message: "expected constant value 2"},
+			},
+		},
+		{
+			Name: "invalid nested items",
+			Data: `{"id":0,"name":"bob","tags":["a",1,"c"]}`,
+			Violations: []jsonschema.Violation{
+				{Path: "/id", Message: "expected a minimum of 1 but got 0"},
+				{Path: "/tags", Message: "expected at most 2 items but got 3"},
+				{Path: "/tags/1", Message: "expected string but got integer"},
+			},
+		},
+	} {
+		t.Run(test.Name, func(t *testing.T) {
+			var decoded, unmarshaled testUser
+			decodeErr := decoder.Decode(strings.NewReader(test.Data), &decoded)
+			unmarshalErr := decoder.Unmarshal([]byte(test.Data), &unmarshaled)
+			if test.Violations == nil {
+				require.NoError(t, decodeErr)
+				require.NoError(t, unmarshalErr)
+				assert.Equal(t, testUser{ID: 1, Name: "alice", Tags: []string{"a"}}, decoded)
+				assert.Equal(t, decoded, unmarshaled)
+				return
+			}
+			for _, err := range []error{decodeErr, unmarshalErr} {
+				var validationErr *jsonschema.ValidationError
+				require.True(t, errors.As(err, &validationErr), "expected a validation error but got %v", err)
+				assert.Equal(t, test.Violations, validationErr.Violations)
+			}
+			assert.Equal(t, testUser{}, decoded, "invalid documents must not be decoded")
+		})
+	}
+}
+
+func TestValidating_InvalidJSON(t *testing.T) {
+	decoder := jsonschema.Validating(jsonschema.MustCompile([]byte(`true`)))
+	err := decoder.Unmarshal([]byte(`{"id":`), new(interface{}))
+	require.Error(t, err)
+	var validationErr *jsonschema.ValidationError
+	assert.False(t, errors.As(err, &validationErr))
+}
+
+func TestCompile(t *testing.T) {
+	for _, test := range []struct {
+		Name   string
+		Schema string
+		Err    string
+	}{
+		{Name: "boolean", Schema: `false`},
+		{Name: "annotations", Schema: `{"description":"anything","default":1,"examples":[1]}`},
+		{Name: "invalid JSON", Schema: `{`, Err: "failed to parse JSON schema"},
+		{Name: "not an object", Schema: `1`, Err: "JSON schema must be an object or a boolean"},
+		{Name: "unsupported keyword", Schema: `{"properties":{"a":{"oneOf":[]}}}`, Err: "unsupported JSON schema keyword"},
+		{Name: "unknown type", Schema: `{"type":"date"}`, Err: "invalid JSON schema keyword value"},
+		{Name: "negative length", Schema: `{"minLength":-1}`, Err: "invalid JSON schema keyword value"},
+		{Name: "invalid pattern", Schema: `{"pattern":"("}`, Err: "invalid JSON schema keyword value"},
+	} {
+		t.Run(test.Name, func(t *testing.T) {
+			_, err := jsonschema.Compile([]byte(test.Schema))
+			if test.Err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, test.Err)
+			}
+		})
+	}
+}
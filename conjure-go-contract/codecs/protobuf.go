@@ -22,11 +22,19 @@ import (
 )
 
 const (
-	contentTypeProtobuf = "Application/x-protobuf"
+	contentTypeProtobuf = "application/x-protobuf"
 )
 
 // Protobuf codec encodes and decodes protobuf requests and responses using
-// google.golang.org/protobuf.
+// google.golang.org/protobuf. Values must implement proto.Message; a response is decoded
+// into the provided destination message, which determines the concrete message type.
+//
+// For example, with the httpclient package:
+//
+//	var out pb.Response
+//	_, err := client.Post(ctx,
+//		httpclient.WithRequestBody(&pb.Request{...}, codecs.Protobuf),
+//		httpclient.WithResponseBody(&out, codecs.Protobuf))
 var Protobuf Codec = codecProtobuf{}
 
 type codecProtobuf struct{}
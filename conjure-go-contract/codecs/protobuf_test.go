@@ -50,3 +50,13 @@ func TestCodecProtobuf_Serde(t *testing.T) {
 		require.True(t, proto.Equal(msg, actual))
 	})
 }
+
+func TestCodecProtobuf_NonProtoMessage(t *testing.T) {
+	require.Equal(t, "application/x-protobuf", codecs.Protobuf.ContentType())
+	require.Equal(t, "application/x-protobuf", codecs.Protobuf.Accept())
+
+	_, err := codecs.Protobuf.Marshal(map[string]string{"key": "value"})
+	require.EqualError(t, err, "failed to encode protobuf data from type which does not implement proto.Message")
+	err = codecs.Protobuf.Unmarshal([]byte{}, new(map[string]string))
+	require.EqualError(t, err, "failed to decode protobuf data from type which does not implement proto.Message")
+}
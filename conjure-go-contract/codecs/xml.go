@@ -0,0 +1,62 @@
+// Copyright (c) 2024 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codecs
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+const (
+	contentTypeXML = "application/xml"
+)
+
+// XML codec encodes and decodes XML requests and responses using encoding/xml.
+// Namespaces and attributes are controlled by struct tags as described by xml.Marshal.
+// Encode does not write an XML declaration; include xml.Header in the value's encoding if one is required.
+var XML Codec = codecXML{}
+
+type codecXML struct{}
+
+func (codecXML) Accept() string {
+	return contentTypeXML
+}
+
+func (codecXML) Decode(r io.Reader, v interface{}) error {
+	if err := xml.NewDecoder(r).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode XML-encoded value: %s", err.Error())
+	}
+	return nil
+}
+
+func (codecXML) Unmarshal(data []byte, v interface{}) error {
+	return xml.Unmarshal(data, v)
+}
+
+func (codecXML) ContentType() string {
+	return contentTypeXML
+}
+
+func (codecXML) Encode(w io.Writer, v interface{}) error {
+	if err := xml.NewEncoder(w).Encode(v); err != nil {
+		return fmt.Errorf("failed to XML-encode value: %s", err.Error())
+	}
+	return nil
+}
+
+func (codecXML) Marshal(v interface{}) ([]byte, error) {
+	return xml.Marshal(v)
+}
@@ -0,0 +1,102 @@
+// Copyright (c) 2024 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codecs_test
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/palantir/conjure-go-runtime/v2/conjure-go-contract/codecs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testSOAPEnvelope struct {
+	XMLName xml.Name        `xml:"http://schemas.xmlsoap.org/soap/envelope/ Envelope"`
+	Body    testSOAPBody    `xml:"http://schemas.xmlsoap.org/soap/envelope/ Body"`
+	Header  *testSOAPHeader `xml:"http://schemas.xmlsoap.org/soap/envelope/ Header,omitempty"`
+}
+
+type testSOAPHeader struct {
+	Token string `xml:"urn:example:auth Token"`
+}
+
+type testSOAPBody struct {
+	GetUser testGetUser `xml:"urn:example:users GetUser"`
+}
+
+type testGetUser struct {
+	ID     int      `xml:"id,attr"`
+	Name   string   `xml:"urn:example:users Name"`
+	Groups []string `xml:"urn:example:users Groups>Group"`
+}
+
+func TestXMLCodec(t *testing.T) {
+	assert.Equal(t, "application/xml", codecs.XML.ContentType())
+	assert.Equal(t, "application/xml", codecs.XML.Accept())
+
+	input := testSOAPEnvelope{
+		Header: &testSOAPHeader{Token: "secret"},
+		Body: testSOAPBody{GetUser: testGetUser{
+			ID:     42,
+			Name:   "alice",
+			Groups: []string{"admin", "users"},
+		}},
+	}
+
+	t.Run("Marshal/Unmarshal", func(t *testing.T) {
+		data, err := codecs.XML.Marshal(input)
+		require.NoError(t, err)
+		assert.Contains(t, string(data), `xmlns="http://schemas.xmlsoap.org/soap/envelope/"`)
+		assert.Contains(t, string(data), `<GetUser xmlns="urn:example:users" id="42">`)
+
+		var output testSOAPEnvelope
+		require.NoError(t, codecs.XML.Unmarshal(data, &output))
+		output.XMLName = xml.Name{}
+		assert.Equal(t, input, output)
+	})
+
+	t.Run("Encode/Decode", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, codecs.XML.Encode(&buf, input))
+
+		var output testSOAPEnvelope
+		require.NoError(t, codecs.XML.Decode(&buf, &output))
+		output.XMLName = xml.Name{}
+		assert.Equal(t, input, output)
+	})
+
+	t.Run("Decode prefixed namespaces", func(t *testing.T) {
+		data := xml.Header + `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/" xmlns:u="urn:example:users">` +
+			`<soap:Body><u:GetUser id="7"><u:Name>bob</u:Name></u:GetUser></soap:Body></soap:Envelope>`
+		var output testSOAPEnvelope
+		require.NoError(t, codecs.XML.Decode(strings.NewReader(data), &output))
+		assert.Equal(t, testGetUser{ID: 7, Name: "bob"}, output.Body.GetUser)
+		assert.Nil(t, output.Header)
+	})
+
+	t.Run("Decode wrong namespace", func(t *testing.T) {
+		data := `<Envelope xmlns="urn:other"></Envelope>`
+		err := codecs.XML.Decode(strings.NewReader(data), new(testSOAPEnvelope))
+		assert.ErrorContains(t, err, "failed to decode XML-encoded value")
+	})
+
+	t.Run("Encode unsupported type", func(t *testing.T) {
+		err := codecs.XML.Encode(&bytes.Buffer{}, map[string]string{"key": "value"})
+		assert.ErrorContains(t, err, "failed to XML-encode value")
+	})
+}
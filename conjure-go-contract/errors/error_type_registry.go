@@ -15,10 +15,15 @@
 package errors
 
 import (
+	"bytes"
+	"encoding/json"
+	stderrors "errors"
 	"fmt"
 	"reflect"
+	"sort"
 
 	"github.com/palantir/conjure-go-runtime/v2/conjure-go-contract/codecs"
+	"github.com/palantir/pkg/uuid"
 	werror "github.com/palantir/witchcraft-go-error"
 )
 
@@ -35,6 +40,27 @@ func RegisterErrorType(name string, typ reflect.Type) {
 	}
 }
 
+// RegisterErrorTypeStrict registers an error name and its go type in a global registry after checking that the type
+// round-trips a conjure error. See ReflectTypeConjureErrorDecoder.RegisterErrorTypeStrict.
+// Panics if name is already registered or the type is invalid.
+func RegisterErrorTypeStrict(name string, typ reflect.Type) {
+	if err := globalRegistry.RegisterErrorTypeStrict(name, typ); err != nil {
+		panic(err.Error())
+	}
+}
+
+// RegisterErrorTypes registers a batch of error names and their go types in the global registry.
+// See ReflectTypeConjureErrorDecoder.RegisterErrorTypes.
+func RegisterErrorTypes(types map[string]reflect.Type) error {
+	return globalRegistry.RegisterErrorTypes(types)
+}
+
+// CloneGlobalRegistry returns a copy of the global registry of error types, on which additional error types can be
+// registered without affecting the global registry, e.g. to use with UnmarshalErrorWithDecoder.
+func CloneGlobalRegistry() *ReflectTypeConjureErrorDecoder {
+	return globalRegistry.Clone()
+}
+
 // NewReflectTypeConjureErrorDecoder returns a new ConjureErrorDecoder that uses reflection to convert JSON errors to their go types.
 func NewReflectTypeConjureErrorDecoder() *ReflectTypeConjureErrorDecoder {
 	return &ReflectTypeConjureErrorDecoder{registry: make(map[string]reflect.Type)}
@@ -47,13 +73,125 @@ type ReflectTypeConjureErrorDecoder struct {
 }
 
 func (d *ReflectTypeConjureErrorDecoder) RegisterErrorType(name string, typ reflect.Type) error {
+	if err := d.checkErrorType(name, typ); err != nil {
+		return err
+	}
+	d.registry[name] = typ
+	return nil
+}
+
+// RegisterErrorTypeStrict behaves like RegisterErrorType, but also checks that the type can be decoded from a
+// conjure error: a conjure error with the provided name, the error code of the type's zero value and a random
+// instance ID is unmarshaled into the type, which must return the same code, name and instance ID, and marshal them
+// back to the same conjure error. This catches mistakes such as a missing or misspelled json tag at registration
+// rather than when an error is decoded.
+func (d *ReflectTypeConjureErrorDecoder) RegisterErrorTypeStrict(name string, typ reflect.Type) error {
+	if err := d.checkErrorType(name, typ); err != nil {
+		return err
+	}
+	if err := checkErrorTypeRoundTrip(name, typ); err != nil {
+		return err
+	}
+	d.registry[name] = typ
+	return nil
+}
+
+// RegisterErrorTypes registers a batch of error names and their go types. The types should be struct types whose
+// pointers implement Error. If any name is already registered or any type is invalid, none of the types are
+// registered and the returned error joins the errors of all invalid entries, ordered by name.
+func (d *ReflectTypeConjureErrorDecoder) RegisterErrorTypes(types map[string]reflect.Type) error {
+	names := make([]string, 0, len(types))
+	for name := range types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		if err := d.checkErrorType(name, types[name]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return stderrors.Join(errs...)
+	}
+	for name, typ := range types {
+		d.registry[name] = typ
+	}
+	return nil
+}
+
+// Clone returns a copy of the decoder with the same registered error types. Error types registered on the copy are
+// not registered on the original and vice versa.
+func (d *ReflectTypeConjureErrorDecoder) Clone() *ReflectTypeConjureErrorDecoder {
+	registry := make(map[string]reflect.Type, len(d.registry))
+	for name, typ := range d.registry {
+		registry[name] = typ
+	}
+	return &ReflectTypeConjureErrorDecoder{registry: registry}
+}
+
+// NewStrictConjureErrorDecoder returns a ConjureErrorDecoder which decodes errors like d, except that it returns an
+// *UnknownErrorNameError instead of falling back to a generic error for error names which are neither registered on d
+// nor the name of a default error type such as DefaultNotFound.
+func NewStrictConjureErrorDecoder(d *ReflectTypeConjureErrorDecoder) ConjureErrorDecoder {
+	return strictConjureErrorDecoder{decoder: d}
+}
+
+type strictConjureErrorDecoder struct {
+	decoder *ReflectTypeConjureErrorDecoder
+}
+
+func (d strictConjureErrorDecoder) DecodeConjureError(errorName string, body []byte) (Error, error) {
+	if _, ok := d.decoder.registry[errorName]; !ok && !isDefaultErrorName(errorName) {
+		return nil, &UnknownErrorNameError{ErrorName: errorName, Body: body}
+	}
+	return d.decoder.DecodeConjureError(errorName, body)
+}
+
+// UnknownErrorNameError is returned by a strict ConjureErrorDecoder for a conjure error whose name is not known,
+// which usually means that the server returned an error type which was added after the client was generated.
+type UnknownErrorNameError struct {
+	// ErrorName is the errorName of the conjure error.
+	ErrorName string
+	// Body is the serialized conjure error.
+	Body []byte
+}
+
+func (e *UnknownErrorNameError) Error() string {
+	return fmt.Sprintf("unknown conjure error name %q", e.ErrorName)
+}
+
+func isDefaultErrorName(name string) bool {
+	for _, errorType := range []ErrorType{
+		DefaultUnauthorized,
+		DefaultPermissionDenied,
+		DefaultInvalidArgument,
+		DefaultNotFound,
+		DefaultConflict,
+		DefaultRequestEntityTooLarge,
+		DefaultFailedPrecondition,
+		DefaultInternal,
+		DefaultTimeout,
+	} {
+		if errorType.Name() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// checkErrorType returns an error if name is already registered or typ can not be registered.
+func (d *ReflectTypeConjureErrorDecoder) checkErrorType(name string, typ reflect.Type) error {
 	if existing, exists := d.registry[name]; exists {
 		return fmt.Errorf("ErrorName %v already registered as %v", name, existing)
 	}
+	if typ == nil {
+		return fmt.Errorf("Error type for ErrorName %v can not be nil", name)
+	}
 	if ptr := reflect.PointerTo(typ); !ptr.Implements(errorInterfaceType) {
 		return fmt.Errorf("Error type %v does not implement errors.Error interface", ptr)
 	}
-	d.registry[name] = typ
 	return nil
 }
 
@@ -71,5 +209,50 @@ func (d *ReflectTypeConjureErrorDecoder) DecodeConjureError(errorName string, bo
 	if !ok {
 		return nil, werror.Error("unmarshaled type does not implement errors.Error interface", werror.SafeParam("type", typ.String()))
 	}
+	if generic, ok := cerr.(*genericError); ok {
+		generic.rawBody = bytes.Clone(body)
+	}
 	return cerr, nil
 }
+
+// checkErrorTypeRoundTrip returns an error if a conjure error with the provided name does not round-trip typ.
+func checkErrorTypeRoundTrip(name string, typ reflect.Type) error {
+	code := reflect.New(typ).Interface().(Error).Code()
+	if code == 0 {
+		// the zero value does not have a code, e.g. because it is read from the conjure error.
+		code = CustomServer
+	}
+	expected := SerializableError{
+		ErrorCode:       code,
+		ErrorName:       name,
+		ErrorInstanceID: uuid.NewUUID(),
+		Parameters:      json.RawMessage(`{}`),
+	}
+	body, err := codecs.JSON.Marshal(expected)
+	if err != nil {
+		return err
+	}
+
+	instance := reflect.New(typ).Interface()
+	if err := codecs.JSON.Unmarshal(body, instance); err != nil {
+		return fmt.Errorf("Error type %v failed to unmarshal conjure error %s: %v", typ, body, err)
+	}
+	cerr := instance.(Error)
+	if cerr.Code() != expected.ErrorCode || cerr.Name() != expected.ErrorName || cerr.InstanceID() != expected.ErrorInstanceID {
+		return fmt.Errorf("Error type %v unmarshaled conjure error %s as errorCode %v, errorName %v and errorInstanceId %v",
+			typ, body, cerr.Code(), cerr.Name(), cerr.InstanceID())
+	}
+
+	marshaled, err := codecs.JSON.Marshal(cerr)
+	if err != nil {
+		return fmt.Errorf("Error type %v failed to marshal: %v", typ, err)
+	}
+	var actual SerializableError
+	if err := codecs.JSON.Unmarshal(marshaled, &actual); err != nil {
+		return fmt.Errorf("Error type %v marshaled an invalid conjure error %s: %v", typ, marshaled, err)
+	}
+	if actual.ErrorCode != expected.ErrorCode || actual.ErrorName != expected.ErrorName || actual.ErrorInstanceID != expected.ErrorInstanceID {
+		return fmt.Errorf("Error type %v marshaled conjure error %s as %s", typ, body, marshaled)
+	}
+	return nil
+}
@@ -15,10 +15,13 @@
 package errors
 
 import (
+	"encoding/json"
 	"reflect"
 	"testing"
 
+	"github.com/palantir/pkg/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestRegisterErrorType_types(t *testing.T) {
@@ -49,3 +52,147 @@ func TestRegisterErrorType_types(t *testing.T) {
 			})
 	})
 }
+
+func TestReflectTypeConjureErrorDecoder_RegisterErrorTypes(t *testing.T) {
+	t.Run("registers all types", func(t *testing.T) {
+		decoder := NewReflectTypeConjureErrorDecoder()
+		require.NoError(t, decoder.RegisterErrorTypes(map[string]reflect.Type{
+			"Default:First":  reflect.TypeOf(genericError{}),
+			"Default:Second": reflect.TypeOf(genericError{}),
+		}))
+		assert.Equal(t, map[string]reflect.Type{
+			"Default:First":  reflect.TypeOf(genericError{}),
+			"Default:Second": reflect.TypeOf(genericError{}),
+		}, decoder.registry)
+	})
+	t.Run("registers no types if any is invalid", func(t *testing.T) {
+		decoder := NewReflectTypeConjureErrorDecoder()
+		require.NoError(t, decoder.RegisterErrorType("Default:Existing", reflect.TypeOf(genericError{})))
+		err := decoder.RegisterErrorTypes(map[string]reflect.Type{
+			"Default:Valid":    reflect.TypeOf(genericError{}),
+			"Default:Existing": reflect.TypeOf(genericError{}),
+			"Default:String":   reflect.TypeOf("string"),
+			"Default:Nil":      nil,
+		})
+		assert.EqualError(t, err, "ErrorName Default:Existing already registered as errors.genericError\n"+
+			"Error type for ErrorName Default:Nil can not be nil\n"+
+			"Error type *string does not implement errors.Error interface")
+		assert.Equal(t, map[string]reflect.Type{
+			"Default:Existing": reflect.TypeOf(genericError{}),
+		}, decoder.registry)
+	})
+	t.Run("global registry", func(t *testing.T) {
+		require.NoError(t, RegisterErrorTypes(map[string]reflect.Type{"name4": reflect.TypeOf(genericError{})}))
+		assert.EqualError(t, RegisterErrorTypes(map[string]reflect.Type{"name4": reflect.TypeOf(genericError{})}),
+			"ErrorName name4 already registered as errors.genericError")
+	})
+}
+
+func TestReflectTypeConjureErrorDecoder_Clone(t *testing.T) {
+	original := NewReflectTypeConjureErrorDecoder()
+	require.NoError(t, original.RegisterErrorType("Default:Original", reflect.TypeOf(genericError{})))
+
+	clone := original.Clone()
+	require.NoError(t, clone.RegisterErrorType("Default:Clone", reflect.TypeOf(genericError{})))
+	require.NoError(t, original.RegisterErrorType("Default:AfterClone", reflect.TypeOf(genericError{})))
+
+	assert.Equal(t, map[string]reflect.Type{
+		"Default:Original":   reflect.TypeOf(genericError{}),
+		"Default:AfterClone": reflect.TypeOf(genericError{}),
+	}, original.registry)
+	assert.Equal(t, map[string]reflect.Type{
+		"Default:Original": reflect.TypeOf(genericError{}),
+		"Default:Clone":    reflect.TypeOf(genericError{}),
+	}, clone.registry)
+
+	t.Run("global registry", func(t *testing.T) {
+		clone := CloneGlobalRegistry()
+		require.NoError(t, clone.RegisterErrorType("Default:GlobalClone", reflect.TypeOf(genericError{})))
+		_, registered := globalRegistry.registry["Default:GlobalClone"]
+		assert.False(t, registered)
+	})
+}
+
+// wellFormedTestError is an error type which round-trips a conjure error, like a generated error type.
+type wellFormedTestError struct {
+	ErrorInstanceID uuid.UUID `json:"errorInstanceId"`
+}
+
+func (e wellFormedTestError) Error() string                        { return e.Name() }
+func (e wellFormedTestError) Code() ErrorCode                      { return NotFound }
+func (e wellFormedTestError) Name() string                         { return "Test:WellFormed" }
+func (e wellFormedTestError) InstanceID() uuid.UUID                { return e.ErrorInstanceID }
+func (e wellFormedTestError) SafeParams() map[string]interface{}   { return nil }
+func (e wellFormedTestError) UnsafeParams() map[string]interface{} { return nil }
+
+func (e wellFormedTestError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(SerializableError{ErrorCode: e.Code(), ErrorName: e.Name(), ErrorInstanceID: e.ErrorInstanceID})
+}
+
+// misspelledTestError is an error type whose instance ID has a misspelled json tag.
+type misspelledTestError struct {
+	wellFormedTestError
+	ErrorInstanceID uuid.UUID `json:"instanceId"`
+}
+
+func (e misspelledTestError) InstanceID() uuid.UUID { return e.ErrorInstanceID }
+
+func (e misspelledTestError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(SerializableError{ErrorCode: e.Code(), ErrorName: e.Name(), ErrorInstanceID: e.ErrorInstanceID})
+}
+
+func TestReflectTypeConjureErrorDecoder_RegisterErrorTypeStrict(t *testing.T) {
+	for _, test := range []struct {
+		Name      string
+		ErrorName string
+		Type      reflect.Type
+		Err       string
+	}{
+		{
+			Name:      "well-formed type",
+			ErrorName: "Test:WellFormed",
+			Type:      reflect.TypeOf(wellFormedTestError{}),
+		},
+		{
+			Name:      "generic error",
+			ErrorName: "Test:Generic",
+			Type:      reflect.TypeOf(genericError{}),
+		},
+		{
+			Name:      "mismatched name",
+			ErrorName: "Test:Other",
+			Type:      reflect.TypeOf(wellFormedTestError{}),
+			Err:       "Error type errors.wellFormedTestError unmarshaled conjure error",
+		},
+		{
+			Name:      "misspelled json tag",
+			ErrorName: "Test:WellFormed",
+			Type:      reflect.TypeOf(misspelledTestError{}),
+			Err:       "errorInstanceId 00000000-0000-0000-0000-000000000000",
+		},
+		{
+			Name:      "invalid name",
+			ErrorName: "invalid",
+			Type:      reflect.TypeOf(genericError{}),
+			Err:       "Error type errors.genericError failed to unmarshal conjure error",
+		},
+		{
+			Name:      "non-error type",
+			ErrorName: "Test:String",
+			Type:      reflect.TypeOf("string"),
+			Err:       "Error type *string does not implement errors.Error interface",
+		},
+	} {
+		t.Run(test.Name, func(t *testing.T) {
+			decoder := NewReflectTypeConjureErrorDecoder()
+			err := decoder.RegisterErrorTypeStrict(test.ErrorName, test.Type)
+			if test.Err == "" {
+				require.NoError(t, err)
+				assert.Equal(t, map[string]reflect.Type{test.ErrorName: test.Type}, decoder.registry)
+				return
+			}
+			assert.ErrorContains(t, err, test.Err)
+			assert.Empty(t, decoder.registry)
+		})
+	}
+}
@@ -19,6 +19,7 @@
 package errors
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 
@@ -47,11 +48,25 @@ type genericError struct {
 	params          wparams.ParamStorer
 	cause           error
 	stack           werror.StackTrace
+	// rawBody is the serialized conjure error from which the error was decoded, if any.
+	rawBody []byte
+}
+
+// GenericError is implemented by the Error returned by UnmarshalError for error names which are not registered,
+// and by the errors created by NewError and the other constructors of this package.
+type GenericError interface {
+	Error
+	// ErrorName returns the error name, which is the same as Name.
+	ErrorName() string
+	// RawBody returns a copy of the serialized conjure error from which the error was decoded,
+	// or nil if it was not decoded.
+	RawBody() []byte
 }
 
 var (
 	_ fmt.Stringer     = genericError{}
 	_ Error            = genericError{}
+	_ GenericError     = genericError{}
 	_ json.Marshaler   = genericError{}
 	_ json.Unmarshaler = &genericError{}
 )
@@ -97,6 +112,14 @@ func (e genericError) InstanceID() uuid.UUID {
 	return e.errorInstanceID
 }
 
+func (e genericError) ErrorName() string {
+	return e.errorType.name
+}
+
+func (e genericError) RawBody() []byte {
+	return bytes.Clone(e.rawBody)
+}
+
 func (e genericError) safeParams() map[string]interface{} {
 	// Copy safe params map (so we don't mutate the underlying one) and add errorInstanceId
 	safeParams := make(map[string]interface{}, len(e.params.SafeParams())+1)
@@ -90,3 +90,24 @@ func TestIsErrorOfType(t *testing.T) {
 	// non-conjure error
 	assert.False(t, isErrorOfType(fmt.Errorf("error"), DefaultNotFound))
 }
+
+func TestGenericError_RawBody(t *testing.T) {
+	body := []byte(`{"errorCode":"CUSTOM_CLIENT","errorName":"MyNamespace:MyOtherError","errorInstanceId":"00010203-0405-0607-0809-0a0b0c0d0e0f","parameters":{"id":"1"}}`)
+	cerr, err := UnmarshalError(body)
+	require.NoError(t, err)
+	generic, ok := cerr.(GenericError)
+	require.True(t, ok, "expected a GenericError but got %T", cerr)
+	assert.Equal(t, "MyNamespace:MyOtherError", generic.ErrorName())
+	assert.Equal(t, body, generic.RawBody())
+
+	rawBody := generic.RawBody()
+	rawBody[0] = '['
+	body[1] = '['
+	assert.Equal(t, byte('{'), generic.RawBody()[0], "mutating the returned body should not modify the error")
+	assert.Equal(t, byte('"'), generic.RawBody()[1], "mutating the decoded body should not modify the error")
+
+	generic, ok = NewError(DefaultNotFound).(GenericError)
+	require.True(t, ok)
+	assert.Equal(t, DefaultNotFound.Name(), generic.ErrorName())
+	assert.Nil(t, generic.RawBody())
+}
@@ -15,6 +15,8 @@
 package errors
 
 import (
+	"fmt"
+
 	"github.com/palantir/conjure-go-runtime/v2/conjure-go-contract/codecs"
 	werror "github.com/palantir/witchcraft-go-error"
 )
@@ -27,6 +29,13 @@ func UnmarshalError(body []byte) (Error, error) {
 	return UnmarshalErrorWithDecoder(globalRegistry, body)
 }
 
+// UnmarshalErrorStrict behaves like UnmarshalError, but returns an error wrapping an *UnknownErrorNameError, which
+// includes the ErrorName and the body, instead of a genericError if the ErrorName is not recognized.
+// Default error names, such as that of DefaultNotFound, are recognized without being registered.
+func UnmarshalErrorStrict(body []byte) (Error, error) {
+	return UnmarshalErrorWithDecoder(NewStrictConjureErrorDecoder(globalRegistry), body)
+}
+
 // UnmarshalErrorWithDecoder attempts to deserialize the message to a known implementation of Error
 // using the provided ConjureErrorDecoder.
 func UnmarshalErrorWithDecoder(ced ConjureErrorDecoder, body []byte) (Error, error) {
@@ -42,3 +51,71 @@ func UnmarshalErrorWithDecoder(ced ConjureErrorDecoder, body []byte) (Error, err
 	}
 	return cErr, nil
 }
+
+// UnmarshalErrorWithCodec behaves like UnmarshalError for a conjure error serialized with codec rather than JSON,
+// e.g. by a service which serializes its error responses with the same codec as its responses. The body is decoded
+// with codec and converted to JSON, which is then unmarshaled by UnmarshalError, so registered error types only need
+// to support JSON. The raw body of a GenericError returned for an unregistered error name is the converted JSON.
+// If codec is nil, the body is unmarshaled as JSON directly.
+func UnmarshalErrorWithCodec(body []byte, codec codecs.Decoder) (Error, error) {
+	if codec == nil {
+		return UnmarshalError(body)
+	}
+	var decoded interface{}
+	if err := codec.Unmarshal(body, &decoded); err != nil {
+		return nil, werror.Wrap(err, "failed to unmarshal body as conjure error", werror.SafeParam("accept", codec.Accept()))
+	}
+	jsonValue, err := toJSONValue(decoded)
+	if err != nil {
+		return nil, werror.Wrap(err, "failed to unmarshal body as conjure error", werror.SafeParam("accept", codec.Accept()))
+	}
+	jsonBody, err := codecs.JSON.Marshal(jsonValue)
+	if err != nil {
+		return nil, werror.Wrap(err, "failed to convert conjure error to JSON", werror.SafeParam("accept", codec.Accept()))
+	}
+	return UnmarshalError(jsonBody)
+}
+
+// toJSONValue converts maps with interface{} keys, as decoded by codecs such as CBOR, to maps with string keys so
+// that v can be marshaled as JSON.
+func toJSONValue(v interface{}) (interface{}, error) {
+	switch val := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(val))
+		for k, elem := range val {
+			key, ok := k.(string)
+			if !ok {
+				return nil, werror.Error("conjure error contains a map key which is not a string",
+					werror.SafeParam("keyType", fmt.Sprintf("%T", k)))
+			}
+			converted, err := toJSONValue(elem)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = converted
+		}
+		return m, nil
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(val))
+		for k, elem := range val {
+			converted, err := toJSONValue(elem)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = converted
+		}
+		return m, nil
+	case []interface{}:
+		s := make([]interface{}, len(val))
+		for i, elem := range val {
+			converted, err := toJSONValue(elem)
+			if err != nil {
+				return nil, err
+			}
+			s[i] = converted
+		}
+		return s, nil
+	default:
+		return v, nil
+	}
+}
@@ -16,10 +16,13 @@ package errors_test
 
 import (
 	"encoding/json"
+	stderrors "errors"
 	"fmt"
+	"io"
 	"reflect"
 	"testing"
 
+	"github.com/palantir/conjure-go-runtime/v2/conjure-go-contract/codecs"
 	"github.com/palantir/conjure-go-runtime/v2/conjure-go-contract/errors"
 	"github.com/palantir/pkg/uuid"
 	"github.com/stretchr/testify/assert"
@@ -165,6 +168,143 @@ func TestUnmarshalError(t *testing.T) {
 	}
 }
 
+func TestUnmarshalErrorStrict(t *testing.T) {
+	decoder := errors.NewReflectTypeConjureErrorDecoder()
+	require.NoError(t, decoder.RegisterErrorType(testErrorName, reflect.TypeOf(testErrorType{})))
+	strictDecoder := errors.NewStrictConjureErrorDecoder(decoder)
+
+	for _, test := range []struct {
+		name    string
+		in      errors.SerializableError
+		unknown bool
+	}{
+		{
+			name: "registered error type",
+			in:   errors.SerializableError{ErrorCode: errors.CustomClient, ErrorName: testErrorName, ErrorInstanceID: uuid.NewUUID()},
+		},
+		{
+			name: "default error type",
+			in:   errors.SerializableError{ErrorCode: errors.NotFound, ErrorName: errors.DefaultNotFound.Name(), ErrorInstanceID: uuid.NewUUID()},
+		},
+		{
+			name:    "unknown error type",
+			in:      errors.SerializableError{ErrorCode: errors.CustomClient, ErrorName: "MyNamespace:MyOtherError", ErrorInstanceID: uuid.NewUUID()},
+			unknown: true,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			marshaledError, err := json.Marshal(test.in)
+			require.NoError(t, err)
+
+			actual, err := errors.UnmarshalErrorWithDecoder(strictDecoder, marshaledError)
+			if !test.unknown {
+				require.NoError(t, err)
+				assert.Equal(t, test.in.ErrorName, actual.Name())
+				assert.Equal(t, test.in.ErrorInstanceID, actual.InstanceID())
+				return
+			}
+			assert.Nil(t, actual)
+			assert.EqualError(t, err, `unknown conjure error name "MyNamespace:MyOtherError"`)
+			var unknownErr *errors.UnknownErrorNameError
+			require.True(t, stderrors.As(err, &unknownErr))
+			assert.Equal(t, test.in.ErrorName, unknownErr.ErrorName)
+			assert.Equal(t, marshaledError, unknownErr.Body)
+		})
+	}
+
+	t.Run("global registry", func(t *testing.T) {
+		marshaledError, err := json.Marshal(errors.SerializableError{
+			ErrorCode:       errors.CustomClient,
+			ErrorName:       "MyNamespace:MyOtherError",
+			ErrorInstanceID: uuid.NewUUID(),
+		})
+		require.NoError(t, err)
+		_, err = errors.UnmarshalErrorStrict(marshaledError)
+		var unknownErr *errors.UnknownErrorNameError
+		assert.True(t, stderrors.As(err, &unknownErr))
+
+		_, err = errors.UnmarshalError(marshaledError)
+		assert.NoError(t, err)
+	})
+}
+
+// mapKeyDecoder decodes a conjure error with interface{} map keys, like a CBOR decoder.
+type mapKeyDecoder struct{}
+
+func (mapKeyDecoder) Accept() string { return "application/test" }
+
+func (mapKeyDecoder) Decode(r io.Reader, v interface{}) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return mapKeyDecoder{}.Unmarshal(data, v)
+}
+
+func (mapKeyDecoder) Unmarshal(data []byte, v interface{}) error {
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+	converted := make(map[interface{}]interface{}, len(m))
+	for k, elem := range m {
+		converted[k] = elem
+	}
+	if params, ok := m["parameters"].(map[string]interface{}); ok {
+		convertedParams := make(map[interface{}]interface{}, len(params))
+		for k, elem := range params {
+			convertedParams[k] = elem
+		}
+		converted["parameters"] = convertedParams
+	}
+	*(v.(*interface{})) = converted
+	return nil
+}
+
+func TestUnmarshalErrorWithCodec(t *testing.T) {
+	errors.RegisterErrorType("TestNamespace:CodecTestError", reflect.TypeOf(codecTestErrorType{}))
+	for _, test := range []struct {
+		name  string
+		codec codecs.Codec
+	}{
+		{name: "json", codec: codecs.JSON},
+		{name: "gzip", codec: codecs.GZIP(codecs.JSON)},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			in := errors.SerializableError{
+				ErrorCode:       errors.CustomClient,
+				ErrorName:       "TestNamespace:CodecTestError",
+				ErrorInstanceID: uuid.NewUUID(),
+				Parameters:      json.RawMessage(`{"intArg":3,"stringArg":"foo"}`),
+			}
+			body, err := test.codec.Marshal(in)
+			require.NoError(t, err)
+			actual, err := errors.UnmarshalErrorWithCodec(body, test.codec)
+			require.NoError(t, err)
+			require.IsType(t, &codecTestErrorType{}, actual)
+			assert.Equal(t, in.ErrorInstanceID, actual.InstanceID())
+			assert.Equal(t, testErrorTypeParams{IntArg: 3, StringArg: "foo"}, actual.(*codecTestErrorType).Parameters)
+		})
+	}
+	t.Run("interface map keys", func(t *testing.T) {
+		body := []byte(`{"errorCode":"NOT_FOUND","errorName":"MyNamespace:Unregistered","errorInstanceId":"00010203-0405-0607-0809-0a0b0c0d0e0f","parameters":{"id":"1"}}`)
+		actual, err := errors.UnmarshalErrorWithCodec(body, mapKeyDecoder{})
+		require.NoError(t, err)
+		assert.Equal(t, "MyNamespace:Unregistered", actual.Name())
+		assert.Equal(t, errors.NotFound, actual.Code())
+		assert.Equal(t, map[string]interface{}{"id": "1"}, actual.UnsafeParams())
+	})
+	t.Run("invalid body", func(t *testing.T) {
+		_, err := errors.UnmarshalErrorWithCodec([]byte("{"), mapKeyDecoder{})
+		assert.ErrorContains(t, err, "failed to unmarshal body as conjure error")
+	})
+}
+
+// codecTestErrorType is testErrorType registered under a different name.
+type codecTestErrorType struct {
+	testErrorType
+}
+
 const testErrorName = "TestNamespace:TestError"
 
 type testErrorType struct {